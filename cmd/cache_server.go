@@ -0,0 +1,126 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/juicedata/juicefs/pkg/chunk"
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/juicedata/juicefs/pkg/object"
+	"github.com/juicedata/juicefs/pkg/vfs"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdCacheServer() *cli.Command {
+	return &cli.Command{
+		Name:      "cache-server",
+		Action:    cacheServer,
+		Category:  "SERVICE",
+		Usage:     "Start a cache server serving blocks to other clients",
+		ArgsUsage: "META-URL [ADDRESS]",
+		Description: `
+The cache server keeps the blocks of a volume in its local cache, and serves them to the clients
+mounted with "--remote-cache". Every block is read from one of the cache servers chosen by consistent
+hashing, which downloads it from the object storage when missed, so the capacity of all the cache
+servers is added up. The clients read from the object storage directly when a cache server is down.
+The blocks could be loaded into the cache servers in advance by "juicefs warmup --remote".
+
+The requests are signed with the secret from "--group-secret", which is derived from the UUID and the
+secret key of object storage by default. ADDRESS is 127.0.0.1:9568 by default, serve the blocks over
+TLS with "--group-tls-cert" and "--group-tls-key" when listening on a routable address.
+
+Examples:
+$ juicefs cache-server redis://localhost --cache-dir /data/jfscache --cache-size 1T
+
+# Serve the clients on other nodes over TLS
+$ juicefs cache-server redis://localhost 192.168.1.10:9568 --cache-dir /data/jfscache --cache-size 1T \
+    --group-tls-cert /etc/jfs/cache.crt --group-tls-key /etc/jfs/cache.key
+
+# Mount the volume with two cache servers
+$ juicefs mount redis://localhost /mnt/jfs --remote-cache 192.168.1.10:9568,192.168.1.11:9568`,
+		Flags: expandFlags(metaFlags(), storageFlags(), dataCacheFlags(), shareInfoFlags()),
+	}
+}
+
+func cacheServer(c *cli.Context) error {
+	setup0(c, 1, 2)
+	metaUrl := c.Args().Get(0)
+	listenAddr := "127.0.0.1:9568"
+	if c.NArg() > 1 {
+		listenAddr = c.Args().Get(1)
+	}
+	host, _, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		logger.Fatalf("invalid address %q: %s", listenAddr, err)
+	}
+	removePassword(metaUrl)
+	metaConf := getMetaConf(c, "cache-server", true)
+	metaConf.NoBGJob = true
+	metaCli := meta.NewClient(metaUrl, metaConf)
+	format, err := metaCli.Load(true)
+	if err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	registerer, registry := wrapRegister(c, "cache-server", format.Name)
+
+	blob, err := NewReloadableStorage(format, metaCli, updateFormat(c))
+	if err != nil {
+		logger.Fatalf("object storage: %s", err)
+	}
+	logger.Infof("Data use %s", blob)
+
+	chunkConf := getChunkConf(c, format)
+	if !chunkConf.CacheEnabled() {
+		logger.Fatalf("cache server requires a cache directory with cache-size > 0")
+	}
+	if chunkConf.CacheGroup != "" || len(chunkConf.RemoteCache) > 0 {
+		logger.Warnf("cache-group and remote-cache are ignored by cache server")
+		chunkConf.CacheGroup, chunkConf.RemoteCache = "", nil
+	}
+	if chunkConf.GroupSecret == "" {
+		logger.Fatalf("no secret to authenticate the clients: --group-secret is not specified, and there is no secret key of object storage to derive it from")
+	}
+	if ip := net.ParseIP(host); (ip == nil || !ip.IsLoopback()) && host != "localhost" && chunkConf.GroupTLSCert == "" {
+		logger.Warnf("The blocks are served in plaintext on %s, please use --group-tls-cert and --group-tls-key in an untrusted network", listenAddr)
+	}
+	chunkConf.CacheServer = listenAddr
+	store := chunk.NewCachedStore(blob, *chunkConf, registerer)
+	store.SetDedupIndex(vfs.NewDedupIndex(metaCli))
+
+	// a read-only session to reload the setting
+	if err = metaCli.NewSession(false); err != nil {
+		logger.Fatalf("new session: %s", err)
+	}
+	metaCli.OnReload(func(fmt *meta.Format) {
+		updateFormat(c)(fmt)
+		store.UpdateLimit(fmt.UploadLimit, fmt.DownloadLimit)
+		store.UpdateCompress(fmt.Compression, fmt.UntaggedCompress)
+		updateDedup(store, fmt)
+	})
+	exposeMetrics(c, registerer, registry)
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	sig := <-signalChan
+	logger.Infof("Received signal %s, exiting...", sig.String())
+	object.Shutdown(blob)
+	return nil
+}
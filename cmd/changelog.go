@@ -17,9 +17,12 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/juicedata/juicefs/pkg/utils"
 	"github.com/urfave/cli/v2"
 )
 
@@ -38,12 +41,75 @@ Examples:
 $ juicefs changelog redis://localhost
 
 # Start tailing from a specific version
-$ juicefs changelog redis://localhost --from 100`,
+$ juicefs changelog redis://localhost --from 100
+
+# Consume as a consumer group, resume from the last acknowledged version
+$ juicefs changelog redis://localhost --group indexer --json
+
+# List or remove consumer groups
+$ juicefs changelog redis://localhost --list-groups
+$ juicefs changelog redis://localhost --remove-group indexer
+
+# Push the entries of creating and removing files under /data to a webhook
+$ juicefs changelog redis://localhost --group hook --sink http://localhost:8080/events --filter-op CREATE,UNLINK --filter-path /data`,
 		Flags: []cli.Flag{
 			&cli.Int64Flag{
 				Name:  "from",
 				Usage: "show changelog from this version (0 means from the latest)",
 			},
+			&cli.StringFlag{
+				Name:  "group",
+				Usage: "consume as the consumer group, entries are kept until acknowledged by all the groups",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "print the parsed entries in JSON",
+			},
+			&cli.BoolFlag{
+				Name:  "list-groups",
+				Usage: "list the consumer groups and their acknowledged versions",
+			},
+			&cli.StringFlag{
+				Name:  "remove-group",
+				Usage: "remove the consumer group",
+			},
+			&cli.StringSliceFlag{
+				Name:  "filter-op",
+				Usage: "only show the entries of these operations, such as CREATE,UNLINK",
+			},
+			&cli.StringSliceFlag{
+				Name:  "filter-path",
+				Usage: "only show the entries under these paths",
+			},
+			&cli.StringFlag{
+				Name:  "sink",
+				Usage: "push the entries in JSON to http(s)://HOST/PATH, file:///PATH or unix:///PATH instead of stdout (requires --group)",
+			},
+			&cli.IntFlag{
+				Name:  "batch-size",
+				Value: 100,
+				Usage: "maximum number of entries pushed to the sink at once",
+			},
+			&cli.DurationFlag{
+				Name:  "batch-interval",
+				Value: time.Second,
+				Usage: "maximum time to wait for a batch to be full",
+			},
+			&cli.IntFlag{
+				Name:  "sink-retries",
+				Value: 10,
+				Usage: "number of retries for the webhook or Unix socket sink",
+			},
+			&cli.StringFlag{
+				Name:  "rotate-size",
+				Value: "100M",
+				Usage: "rotate the file sink when it's larger than this (0 means never)",
+			},
+			&cli.IntFlag{
+				Name:  "rotate-keep",
+				Value: 5,
+				Usage: "number of rotated files to keep for the file sink",
+			},
 		},
 	}
 }
@@ -60,9 +126,114 @@ func changelog(ctx *cli.Context) error {
 		return fmt.Errorf("changelog is not enabled, use `juicefs config %s --changelog` to enable it", metaUri)
 	}
 
-	last := ctx.Int64("from")
-	return m.ScanChangelog(meta.Background(), last, func(ver int64, entry string) error {
-		fmt.Printf("%d: %s\n", ver, entry)
+	if ctx.Bool("list-groups") {
+		cs, st := m.ListChangelogConsumers(meta.Background())
+		if st != 0 {
+			return fmt.Errorf("list consumer groups: %s", st)
+		}
+		results := [][]string{{"Group", "Version", "Updated"}}
+		for _, c := range cs {
+			results = append(results, []string{c.Group, fmt.Sprintf("%d", c.Version), time.Unix(c.Updated, 0).Format("2006-01-02 15:04:05")})
+		}
+		printResult(results, 1, false)
 		return nil
+	}
+	if group := ctx.String("remove-group"); group != "" {
+		if st := m.RemoveChangelogConsumer(meta.Background(), group); st != 0 {
+			return fmt.Errorf("remove consumer group %s: %s", group, st)
+		}
+		return nil
+	}
+
+	mctx := meta.Background()
+	filter := newChangelogFilter(ctx.StringSlice("filter-op"), ctx.StringSlice("filter-path"))
+	resolver := newPathResolver(m)
+	sinkURI := ctx.String("sink")
+	// selectEntry returns nil if the entry is filtered out
+	selectEntry := func(e *meta.ChangelogEntry) *sinkEntry {
+		se := &sinkEntry{ChangelogEntry: e}
+		if filter.needPath() || sinkURI != "" {
+			se = resolver.resolve(mctx, e)
+		}
+		if !filter.match(se) {
+			return nil
+		}
+		return se
+	}
+
+	group := ctx.String("group")
+	if group != "" && ctx.IsSet("from") {
+		if st := m.AckChangelog(mctx, group, ctx.Int64("from")); st != 0 {
+			return fmt.Errorf("reset consumer group %s: %s", group, st)
+		}
+	}
+	if sinkURI != "" {
+		if group == "" {
+			return fmt.Errorf("--sink requires --group to track the delivered entries")
+		}
+		sink, err := newChangelogSink(sinkURI, sinkConfig{
+			retries:    ctx.Int("sink-retries"),
+			rotateSize: int64(utils.ParseBytes(ctx, "rotate-size", 'M')),
+			rotateKeep: ctx.Int("rotate-keep"),
+		})
+		if err != nil {
+			return err
+		}
+		defer sink.Close()
+		return meta.ConsumeChangelogBatch(mctx, m, group, ctx.Int("batch-size"), ctx.Duration("batch-interval"), func(es []*meta.ChangelogEntry) error {
+			var selected []*sinkEntry
+			for _, e := range es {
+				if se := selectEntry(e); se != nil {
+					selected = append(selected, se)
+				}
+			}
+			if len(selected) == 0 {
+				return nil
+			}
+			return sink.Write(mctx, selected)
+		})
+	}
+	if group != "" {
+		return meta.ConsumeChangelog(mctx, m, group, func(e *meta.ChangelogEntry) error {
+			if se := selectEntry(e); se != nil {
+				return printChangelog(se, ctx.Bool("json"))
+			}
+			return nil
+		})
+	}
+	filtering := filter.ops != nil || filter.needPath()
+	last := ctx.Int64("from")
+	return m.ScanChangelog(mctx, last, func(ver int64, entry string) error {
+		if !ctx.Bool("json") && !filtering {
+			fmt.Printf("%d: %s\n", ver, entry)
+			return nil
+		}
+		e, err := meta.ParseChangelogEntry(ver, entry)
+		if err != nil {
+			logger.Warnf("parse changelog %d: %s", ver, err)
+			return nil
+		}
+		se := selectEntry(e)
+		if se == nil {
+			return nil
+		}
+		if !ctx.Bool("json") {
+			fmt.Printf("%d: %s\n", ver, entry)
+			return nil
+		}
+		return printChangelog(se, true)
 	})
 }
+
+func printChangelog(e *sinkEntry, asJSON bool) error {
+	if !asJSON {
+		fmt.Println(e.ChangelogEntry)
+		return nil
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -0,0 +1,379 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/meta"
+)
+
+// pathResolver finds the current paths of the inodes in changelog entries.
+type pathResolver struct {
+	m    meta.Meta
+	dirs map[meta.Ino]string // paths of directories, empty if it's gone or internal
+}
+
+func newPathResolver(m meta.Meta) *pathResolver {
+	return &pathResolver{m: m, dirs: make(map[meta.Ino]string)}
+}
+
+// reset drops the cached paths, which should be called after a directory is moved.
+func (r *pathResolver) reset() {
+	r.dirs = make(map[meta.Ino]string)
+}
+
+func (r *pathResolver) forget(ino meta.Ino) {
+	delete(r.dirs, ino)
+}
+
+func (r *pathResolver) dir(ctx meta.Context, ino meta.Ino) string {
+	if ino == meta.RootInode {
+		return "/"
+	}
+	if p, ok := r.dirs[ino]; ok {
+		return p
+	}
+	var p string
+	if ps := r.inode(ctx, ino); len(ps) > 0 {
+		p = ps[0]
+	}
+	if len(r.dirs) > 100000 {
+		r.reset()
+	}
+	r.dirs[ino] = p
+	return p
+}
+
+// entry returns the path of name under parent, empty if the parent is gone or internal.
+func (r *pathResolver) entry(ctx meta.Context, parent meta.Ino, name string) string {
+	if dir := r.dir(ctx, parent); dir != "" && name != "" {
+		return path.Join(dir, name)
+	}
+	return ""
+}
+
+// inode returns all the paths of ino which are not internal.
+func (r *pathResolver) inode(ctx meta.Context, ino meta.Ino) []string {
+	var ps []string
+	for _, p := range r.m.GetPaths(ctx, ino) {
+		if strings.HasPrefix(p, "/") && !isInternalPath(p) {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// sinkEntry is a changelog entry with the paths resolved, which is sent to sinks in JSON.
+type sinkEntry struct {
+	*meta.ChangelogEntry
+	Path    string `json:",omitempty"`
+	NewPath string `json:",omitempty"`
+}
+
+func (r *pathResolver) resolve(ctx meta.Context, e *meta.ChangelogEntry) *sinkEntry {
+	se := &sinkEntry{ChangelogEntry: e}
+	switch {
+	case e.Parent != 0 && e.Name != "":
+		se.Path = r.entry(ctx, e.Parent, e.Name)
+	case e.Inode != 0:
+		if ps := r.inode(ctx, e.Inode); len(ps) > 0 {
+			se.Path = ps[0]
+		}
+	}
+	if e.Op == "MOVE" {
+		se.NewPath = r.entry(ctx, e.NewParent, e.NewName)
+		r.reset()
+	} else if e.Op == "RMDIR" {
+		r.forget(e.Inode)
+	}
+	return se
+}
+
+// changelogFilter selects entries by operation and path prefix, empty ones match everything.
+type changelogFilter struct {
+	ops      map[string]bool
+	prefixes []string
+}
+
+func newChangelogFilter(ops, prefixes []string) *changelogFilter {
+	f := &changelogFilter{}
+	for _, op := range ops {
+		for _, o := range strings.Split(op, ",") {
+			if o = strings.TrimSpace(o); o != "" {
+				if f.ops == nil {
+					f.ops = make(map[string]bool)
+				}
+				f.ops[strings.ToUpper(o)] = true
+			}
+		}
+	}
+	for _, p := range prefixes {
+		f.prefixes = append(f.prefixes, path.Clean("/"+p))
+	}
+	return f
+}
+
+func (f *changelogFilter) needPath() bool {
+	return len(f.prefixes) > 0
+}
+
+func hasPathPrefix(p, prefix string) bool {
+	return prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+func (f *changelogFilter) match(e *sinkEntry) bool {
+	if f.ops != nil && !f.ops[e.Op] {
+		return false
+	}
+	if len(f.prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range f.prefixes {
+		if e.Path != "" && hasPathPrefix(e.Path, prefix) || e.NewPath != "" && hasPathPrefix(e.NewPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// changelogSink delivers batches of entries to an external consumer. A batch is acknowledged
+// after Write returns without error, so Write should retry on temporary failures.
+type changelogSink interface {
+	Write(ctx context.Context, es []*sinkEntry) error
+	Close() error
+}
+
+type sinkConfig struct {
+	retries    int
+	rotateSize int64
+	rotateKeep int
+}
+
+// newChangelogSink creates a sink from a URL:
+// http(s)://host/path for webhook, file:///path for JSON lines file and unix:///path for Unix socket.
+func newChangelogSink(uri string, conf sinkConfig) (changelogSink, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("parse sink %s: %s", uri, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &httpSink{url: uri, retries: conf.retries, client: &http.Client{Timeout: time.Minute}}, nil
+	case "file":
+		return newFileSink(u.Path, conf.rotateSize, conf.rotateKeep)
+	case "unix":
+		return &unixSink{addr: u.Path, retries: conf.retries}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sink: %s", uri)
+	}
+}
+
+func encodeEntries(es []*sinkEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range es {
+		if err := enc.Encode(e); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// retry calls f until it succeeds, returns a permanent error or the retries are used up.
+func retry(ctx context.Context, retries int, what string, f func() (permanent bool, err error)) error {
+	backoff := 100 * time.Millisecond
+	for i := 0; ; i++ {
+		permanent, err := f()
+		if err == nil || permanent || i >= retries {
+			return err
+		}
+		logger.Warnf("%s (retry %d/%d): %s", what, i+1, retries, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > 30*time.Second {
+			backoff = 30 * time.Second
+		}
+	}
+}
+
+// httpSink posts each batch as newline-delimited JSON.
+type httpSink struct {
+	url     string
+	retries int
+	client  *http.Client
+}
+
+func (s *httpSink) Write(ctx context.Context, es []*sinkEntry) error {
+	body, err := encodeEntries(es)
+	if err != nil {
+		return err
+	}
+	return retry(ctx, s.retries, "post changelog to "+s.url, func() (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+		if err != nil {
+			return true, err
+		}
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		resp, err := s.client.Do(req)
+		if err != nil {
+			return false, err
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode/100 == 2 {
+			return false, nil
+		}
+		err = fmt.Errorf("status %s", resp.Status)
+		// retry on server errors and throttling only
+		return resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests, err
+	})
+}
+
+func (s *httpSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// fileSink appends entries to a file as JSON lines, and rotates it to name.1, name.2 ...
+// when it's larger than rotateSize.
+type fileSink struct {
+	name       string
+	rotateSize int64
+	rotateKeep int
+	f          *os.File
+	size       int64
+}
+
+func newFileSink(name string, rotateSize int64, rotateKeep int) (*fileSink, error) {
+	s := &fileSink{name: name, rotateSize: rotateSize, rotateKeep: rotateKeep}
+	return s, s.open()
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.f, s.size = f, fi.Size()
+	return nil
+}
+
+func (s *fileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	for i := s.rotateKeep - 1; i > 0; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", s.name, i), fmt.Sprintf("%s.%d", s.name, i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	var err error
+	if s.rotateKeep > 0 {
+		err = os.Rename(s.name, s.name+".1")
+	} else {
+		err = os.Remove(s.name)
+	}
+	if err != nil {
+		return err
+	}
+	return s.open()
+}
+
+func (s *fileSink) Write(ctx context.Context, es []*sinkEntry) error {
+	data, err := encodeEntries(es)
+	if err != nil {
+		return err
+	}
+	if s.rotateSize > 0 && s.size > 0 && s.size+int64(len(data)) > s.rotateSize {
+		if err = s.rotate(); err != nil {
+			return fmt.Errorf("rotate %s: %s", s.name, err)
+		}
+	}
+	n, err := s.f.Write(data)
+	s.size += int64(n)
+	if err == nil {
+		err = s.f.Sync()
+	}
+	return err
+}
+
+func (s *fileSink) Close() error {
+	return s.f.Close()
+}
+
+// unixSink writes entries as JSON lines to a Unix socket, and reconnects on failures.
+type unixSink struct {
+	addr    string
+	retries int
+	conn    net.Conn
+}
+
+func (s *unixSink) Write(ctx context.Context, es []*sinkEntry) error {
+	data, err := encodeEntries(es)
+	if err != nil {
+		return err
+	}
+	var sent int // the entries before it are delivered
+	return retry(ctx, s.retries, "write changelog to "+s.addr, func() (bool, error) {
+		if s.conn == nil {
+			var d net.Dialer
+			if s.conn, err = d.DialContext(ctx, "unix", s.addr); err != nil {
+				s.conn = nil
+				return false, err
+			}
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(time.Minute))
+		n, err := s.conn.Write(data[sent:])
+		if err != nil {
+			// the lines written completely are not sent again, but the partial one is
+			if i := bytes.LastIndexByte(data[sent:sent+n], '\n'); i >= 0 {
+				sent += i + 1
+			}
+			_ = s.conn.Close()
+			s.conn = nil
+			return false, err
+		}
+		return false, nil
+	})
+}
+
+func (s *unixSink) Close() error {
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSinkEntries(n int) []*sinkEntry {
+	es := make([]*sinkEntry, n)
+	for i := range es {
+		es[i] = &sinkEntry{ChangelogEntry: &meta.ChangelogEntry{Version: int64(i + 1), Op: "CREATE"}, Path: "/d/f"}
+	}
+	return es
+}
+
+func TestChangelogFilter(t *testing.T) {
+	f := newChangelogFilter([]string{"create,unlink", "MOVE"}, []string{"data/"})
+	entry := func(op, p, np string) *sinkEntry {
+		return &sinkEntry{ChangelogEntry: &meta.ChangelogEntry{Op: op}, Path: p, NewPath: np}
+	}
+	assert.True(t, f.needPath())
+	assert.True(t, f.match(entry("CREATE", "/data/a", "")))
+	assert.True(t, f.match(entry("UNLINK", "/data", "")))
+	assert.True(t, f.match(entry("MOVE", "/tmp/a", "/data/a")))
+	assert.False(t, f.match(entry("CREATE", "/database/a", "")))
+	assert.False(t, f.match(entry("SETATTR", "/data/a", "")))
+	assert.False(t, f.match(entry("CREATE", "", "")))
+
+	f = newChangelogFilter(nil, nil)
+	assert.False(t, f.needPath())
+	assert.True(t, f.match(entry("WRITE", "", "")))
+}
+
+func TestHTTPSink(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	var received []meta.ChangelogEntry
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var e meta.ChangelogEntry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			received = append(received, e)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := newChangelogSink(srv.URL, sinkConfig{retries: 3})
+	require.Nil(t, err)
+	defer s.Close()
+	require.Nil(t, s.Write(context.Background(), testSinkEntries(3)))
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 3, len(received))
+	assert.Equal(t, int64(3), received[2].Version)
+
+	// client errors are not retried
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	bad, err := newChangelogSink(notFound.URL, sinkConfig{retries: 3})
+	require.Nil(t, err)
+	assert.NotNil(t, bad.Write(context.Background(), testSinkEntries(1)))
+}
+
+func TestFileSink(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "changelog.json")
+	s, err := newChangelogSink("file://"+name, sinkConfig{rotateSize: 200, rotateKeep: 2})
+	require.Nil(t, err)
+	for i := 0; i < 10; i++ {
+		require.Nil(t, s.Write(context.Background(), testSinkEntries(1)))
+	}
+	require.Nil(t, s.Close())
+	for _, n := range []string{name, name + ".1", name + ".2"} {
+		fi, err := os.Stat(n)
+		require.Nil(t, err)
+		assert.LessOrEqual(t, fi.Size(), int64(200))
+	}
+	_, err = os.Stat(name + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = newChangelogSink("ftp://host/path", sinkConfig{})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,160 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/juicedata/juicefs/pkg/compress"
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdCompression() *cli.Command {
+	return &cli.Command{
+		Name:            "compression",
+		Category:        "ADMIN",
+		Usage:           "manage compression algorithm of directories",
+		ArgsUsage:       "META-URL",
+		HideHelpCommand: true,
+		Description: `
+The data written into a directory is compressed by the algorithm of the nearest directory having one,
+or the default one of the volume. Existing data is not changed, and blocks compressed by any
+algorithm can be read. Clients older than 1.5.0 can't read the volume after this is used.
+
+Examples:
+$ juicefs compression set redis://localhost --path /logs --algo zstd:9
+$ juicefs compression set redis://localhost --path /images --algo none
+$ juicefs compression list redis://localhost
+$ juicefs compression delete redis://localhost --path /logs`,
+		Subcommands: []*cli.Command{
+			{
+				Name:      "set",
+				Usage:     "set compression algorithm of a directory",
+				ArgsUsage: "META-URL",
+				Action:    setDirCompression,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "path",
+						Usage:    "full path of the directory within the volume",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "algo",
+						Usage:    "compression algorithm (lz4, zstd, zstd:LEVEL, s2, none)",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:      "list",
+				Usage:     "list compression algorithm of directories",
+				ArgsUsage: "META-URL",
+				Action:    listDirCompressions,
+			},
+			{
+				Name:      "delete",
+				Usage:     "delete compression algorithm of a directory",
+				ArgsUsage: "META-URL",
+				Action:    deleteDirCompression,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "path",
+						Usage:    "full path of the directory within the volume",
+						Required: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+func setDirCompression(ctx *cli.Context) error {
+	setup(ctx, 1)
+	removePassword(ctx.Args().Get(0))
+	m := meta.NewClient(ctx.Args().Get(0), nil)
+	format, err := m.Load(true)
+	if err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	algr := ctx.String("algo")
+	if compress.NewCompressor(algr) == nil {
+		logger.Fatalf("Unsupported compress algorithm: %q", algr)
+	}
+	// blocks of other algorithms are tagged, which can't be read by old clients
+	const required = "1.5.0-A"
+	changed := format.EnableCompressionTag()
+	if compareVersion(format.MinClientVersion, required) < 0 {
+		format.MinClientVersion = required
+		changed = true
+	}
+	if changed {
+		if err = m.Init(format, false); err != nil {
+			logger.Fatalf("save setting: %s", err)
+		}
+		logger.Infof("Compression tag is enabled, min-client-version is %s", format.MinClientVersion)
+	}
+	path := ctx.String("path")
+	if eno := m.SetDirCompression(meta.Background(), resolveDir(m, path), algr); eno != 0 {
+		return fmt.Errorf("set compression of %s: %s", path, eno)
+	}
+	logger.Infof("set compression of %s to %s", path, algr)
+	return nil
+}
+
+func listDirCompressions(ctx *cli.Context) error {
+	setup(ctx, 1)
+	removePassword(ctx.Args().Get(0))
+	m := meta.NewClient(ctx.Args().Get(0), nil)
+	if _, err := m.Load(true); err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	cs, eno := m.ListDirCompressions(meta.Background())
+	if eno != 0 {
+		return fmt.Errorf("list compression of directories: %s", eno)
+	}
+	inodes := make([]meta.Ino, 0, len(cs))
+	for inode := range cs {
+		inodes = append(inodes, inode)
+	}
+	sort.Slice(inodes, func(i, j int) bool { return inodes[i] < inodes[j] })
+	results := [][]string{{"inode", "path", "algorithm"}}
+	for _, inode := range inodes {
+		path := "(unknown)"
+		if ps := m.GetPaths(meta.Background(), inode); len(ps) > 0 {
+			path = ps[0]
+		}
+		results = append(results, []string{inode.String(), path, cs[inode]})
+	}
+	printResult(results, 1, false)
+	return nil
+}
+
+func deleteDirCompression(ctx *cli.Context) error {
+	setup(ctx, 1)
+	removePassword(ctx.Args().Get(0))
+	m := meta.NewClient(ctx.Args().Get(0), nil)
+	if _, err := m.Load(true); err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	path := ctx.String("path")
+	if eno := m.DeleteDirCompression(meta.Background(), resolveDir(m, path)); eno != 0 {
+		return fmt.Errorf("delete compression of %s: %s", path, eno)
+	}
+	return nil
+}
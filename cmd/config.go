@@ -26,6 +26,8 @@ import (
 	"time"
 
 	"github.com/dustin/go-humanize"
+	"github.com/juicedata/juicefs/pkg/chunk"
+	"github.com/juicedata/juicefs/pkg/compress"
 	"github.com/juicedata/juicefs/pkg/meta"
 	"github.com/juicedata/juicefs/pkg/object"
 	"github.com/juicedata/juicefs/pkg/utils"
@@ -54,8 +56,22 @@ $ juicefs config redis://localhost --inodes 10000000 --capacity 1048576
 # Change maximum days before files in trash are deleted
 $ juicefs config redis://localhost --trash-days 7
 
+# Compress new data with zstd at level 9, existing data is not changed
+$ juicefs config redis://localhost --compress zstd:9
+
+# Never cache the data under /logs, and keep the data under /models in cache
+$ juicefs config redis://localhost --cache-policy /logs:cache=none
+$ juicefs config redis://localhost --cache-policy /models:cache=full,large-write=true,pin=true
+
 # Limit client version that is allowed to connect
-$ juicefs config redis://localhost --min-client-version 1.0.0 --max-client-version 1.1.0`,
+$ juicefs config redis://localhost --min-client-version 1.0.0 --max-client-version 1.1.0
+
+# Rotate the encryption key: the first run adds the new key for all the clients to load it, run it again
+# after one minute to switch to it, then the data keys of existing objects are wrapped by the new key
+$ juicefs config redis://localhost --rotate-encrypt-key new-key.pem
+
+# Encrypt existing objects again with new data keys, which can be resumed after interrupted
+$ juicefs config redis://localhost --reencrypt`,
 		Flags: expandFlags(
 			formatStorageFlags(),
 			addCategories("DATA STORAGE", []cli.Flag{
@@ -67,9 +83,28 @@ $ juicefs config redis://localhost --min-client-version 1.0.0 --max-client-versi
 					Name:  "download-limit",
 					Usage: "default bandwidth limit of a client for download in Mbps",
 				},
+				&cli.BoolFlag{
+					Name:  "replica-async",
+					Usage: "replicate the objects to the replicas in background (see `juicefs replica`)",
+				},
+				&cli.DurationFlag{
+					Name:  "replica-timeout",
+					Usage: "latency budget of the primary storage before reading from the replicas",
+				},
+			}),
+			addCategories("DATA FORMAT", []cli.Flag{
+				&cli.StringFlag{
+					Name:  "compress",
+					Usage: "compression algorithm for new data (lz4, zstd, zstd:LEVEL, s2, none)",
+				},
+				&cli.BoolFlag{
+					Name:  "dedup",
+					Usage: "store new blocks with the same content only once (this flag is irreversible once enabled)",
+				},
 			}),
 			formatManagementFlags(),
 			configManagementFlags(),
+			configEncryptionFlags(),
 			configFlags()),
 	}
 }
@@ -110,6 +145,14 @@ func configManagementFlags() []cli.Flag {
 			Name:  "changelog-max-lines",
 			Usage: "max number of changelog entries to keep; 0 means unlimited",
 		},
+		&cli.DurationFlag{
+			Name:  "changelog-force-age",
+			Usage: "remove changelog entries older than this even if they are not consumed by all consumer groups; 0 means never",
+		},
+		&cli.StringFlag{
+			Name:  "cache-policy",
+			Usage: "cache policy of a directory as PATH:POLICY (e.g. /logs:cache=none), an empty POLICY deletes it",
+		},
 		&cli.IntFlag{
 			Name:  "tier",
 			Usage: "tier (0-3; 0 is default tier when unset)",
@@ -126,6 +169,32 @@ func configManagementFlags() []cli.Flag {
 	})
 }
 
+func configEncryptionFlags() []cli.Flag {
+	return addCategories("ENCRYPTION", []cli.Flag{
+		&cli.StringFlag{
+			Name:  "rotate-encrypt-key",
+			Usage: "a path to the new RSA private key (PEM) replacing the current one; it's added at the first run, and switched to with existing objects rewritten when run again after clients have loaded it",
+		},
+		&cli.StringFlag{
+			Name:  "rotate-encrypt-algo",
+			Usage: "encrypt algorithm used with the new key (aes256gcm-rsa, chacha20-rsa, sm4gcm), the current one is kept by default",
+		},
+		&cli.BoolFlag{
+			Name:  "reencrypt",
+			Usage: "encrypt existing objects again with new data keys instead of only re-wrapping the data keys",
+		},
+		&cli.IntFlag{
+			Name:  "rotate-threads",
+			Value: 10,
+			Usage: "number of threads to rewrite the objects for key rotation",
+		},
+		&cli.BoolFlag{
+			Name:  "drop-retired-keys",
+			Usage: "remove the keys replaced by key rotation, objects still encrypted by them can't be read anymore",
+		},
+	})
+}
+
 func configFlags() []cli.Flag {
 	return []cli.Flag{
 		&cli.BoolFlag{
@@ -183,6 +252,8 @@ func config(ctx *cli.Context) error {
 	var findTier bool
 	var newTier object.Tier
 
+	var rotate, dropKeys bool
+	var policyPath, policy string
 	var requiredMinClientVersion string
 	requireMinClientVersion := func(required string) {
 		requiredMinClientVersion = maxVersion(requiredMinClientVersion, required)
@@ -295,6 +366,30 @@ func config(ctx *cli.Context) error {
 				msg.WriteString(fmt.Sprintf("%10s: %s -> %s\n", flag, utils.Mbps(format.DownloadLimit), utils.Mbps(new)))
 				format.DownloadLimit = new
 			}
+		case "replica-async":
+			if new := ctx.Bool(flag); new != format.ReplicaAsync {
+				msg.WriteString(fmt.Sprintf("%10s: %t -> %t\n", flag, format.ReplicaAsync, new))
+				format.ReplicaAsync = new
+			}
+		case "replica-timeout":
+			if new := int(ctx.Duration(flag).Milliseconds()); new != format.ReplicaTimeout {
+				if new < 0 {
+					return fmt.Errorf("Invalid replica timeout: %s", ctx.Duration(flag))
+				}
+				msg.WriteString(fmt.Sprintf("%10s: %dms -> %dms\n", flag, format.ReplicaTimeout, new))
+				format.ReplicaTimeout = new
+			}
+		case "compress":
+			if new := ctx.String(flag); new != format.Compression {
+				if compress.NewCompressor(new) == nil {
+					return fmt.Errorf("Unsupported compress algorithm: %q", new)
+				}
+				msg.WriteString(fmt.Sprintf("%10s: %s -> %s\n", flag, format.Compression, new))
+				// blocks written before are kept, new blocks are tagged with their codec
+				format.EnableCompressionTag()
+				format.Compression = new
+				requireMinClientVersion("1.5.0-A")
+			}
 		case "trash-days":
 			if new := ctx.Int(flag); new != format.TrashDays {
 				if new < 0 {
@@ -328,6 +423,15 @@ func config(ctx *cli.Context) error {
 				msg.WriteString(fmt.Sprintf("%10s: %s -> %s\n", flag, time.Duration(format.ChangeLogMaxAge)*time.Second, d))
 				format.ChangeLogMaxAge = newAge
 			}
+		case "changelog-force-age":
+			d := ctx.Duration(flag)
+			if d < 0 {
+				return fmt.Errorf("negative duration for %s: %s", flag, d)
+			}
+			if newAge := int64(d.Seconds()); newAge != format.ChangeLogForceAge {
+				msg.WriteString(fmt.Sprintf("%10s: %s -> %s\n", flag, time.Duration(format.ChangeLogForceAge)*time.Second, d))
+				format.ChangeLogForceAge = newAge
+			}
 		case "changelog-max-lines":
 			if new := ctx.Int64(flag); new != format.ChangeLogMaxLines {
 				if new < 0 {
@@ -360,6 +464,105 @@ func config(ctx *cli.Context) error {
 				format.MaxClientVersion = new
 				clientVer = true
 			}
+		case "dedup":
+			if dedup := ctx.Bool(flag); dedup != format.Dedup {
+				if dedup {
+					msg.WriteString(fmt.Sprintf("%s: %v -> %v\n", flag, format.Dedup, true))
+					format.Dedup = true
+					requireMinClientVersion("1.5.0-A")
+				} else {
+					return errors.New("cannot disable dedup")
+				}
+			}
+			if format.Dedup && (format.DedupSecret == "" || format.DedupSecret == "removed") {
+				// the chunks written before are still read by their hash in the index
+				if err := format.Decrypt(); err != nil && strings.Contains(err.Error(), "secret was removed") {
+					logger.Warnf("decrypt secrets: %s", err)
+				}
+				format.NewDedupSecret()
+				msg.WriteString(fmt.Sprintf("%s secret: updated\n", flag))
+			}
+		case "cache-policy":
+			var ok bool
+			if policyPath, policy, ok = strings.Cut(ctx.String(flag), ":"); !ok || policyPath == "" {
+				logger.Fatalf("invalid cache policy %q, it should be like PATH:POLICY", ctx.String(flag))
+			}
+			if _, err := chunk.ParseCachePolicy(policy); err != nil {
+				logger.Fatalf("invalid cache policy %q: %s", policy, err)
+			}
+		case "rotate-encrypt-key":
+			if format.EncryptKey == "" {
+				return errors.New("volume is not encrypted")
+			}
+			if err := format.Decrypt(); err != nil {
+				return fmt.Errorf("decrypt secrets: %s", err)
+			}
+			key := loadEncrypt(ctx.String(flag))
+			algo := format.EncryptAlgo
+			if ctx.IsSet("rotate-encrypt-algo") {
+				algo = ctx.String("rotate-encrypt-algo")
+			}
+			if key == format.EncryptKey {
+				if algo != format.EncryptAlgo {
+					return errors.New("cannot change the encrypt algorithm without a new key")
+				}
+				rotate = true // resume the rotation
+				continue
+			}
+			if pk := format.PendingKey; pk != nil && pk.Key == key {
+				// the second phase: switch to the new key after the clients have loaded it
+				if ctx.IsSet("rotate-encrypt-algo") && algo != pk.Algo {
+					return fmt.Errorf("the new key was added with encrypt algorithm %s", pk.Algo)
+				}
+				if wait := rotateKeyDelay(m) - time.Since(time.Unix(pk.Added, 0)); wait > 0 {
+					return fmt.Errorf("the new key was added just now, please run it again after %s when all the clients have loaded it", wait.Round(time.Second))
+				}
+				msg.WriteString(fmt.Sprintf("%s: switched to the new key\n", flag))
+				if pk.Algo != format.EncryptAlgo {
+					msg.WriteString(fmt.Sprintf("%s: %s -> %s\n", "encrypt-algo", format.EncryptAlgo, pk.Algo))
+				}
+				format.RetiredKeys = append([]meta.RetiredKey{{Key: format.EncryptKey, Algo: format.EncryptAlgo}}, format.RetiredKeys...)
+				format.EncryptKey = pk.Key
+				format.EncryptAlgo = pk.Algo
+				format.PendingKey = nil
+				rotate = true
+				continue
+			}
+			// the first phase: add the new key, which is only used to decrypt until all the clients have it,
+			// otherwise they can't read the objects rewritten with it
+			privKey, err := parsePrivateKey(key)
+			if err != nil {
+				return err
+			}
+			if _, err = object.NewDataEncryptor(object.NewKeyEncryptor(privKey), algo); err != nil {
+				return err
+			}
+			msg.WriteString(fmt.Sprintf("%s: added, run it again after %s to switch to it\n", flag, rotateKeyDelay(m).Round(time.Second)))
+			format.PendingKey = &meta.PendingKey{Key: key, Algo: algo, Added: time.Now().Unix()}
+			requireMinClientVersion("1.5.0-A")
+		case "rotate-encrypt-algo":
+			if !ctx.IsSet("rotate-encrypt-key") {
+				return errors.New("--rotate-encrypt-algo should be used with --rotate-encrypt-key")
+			}
+		case "reencrypt":
+			if ctx.Bool(flag) {
+				if format.EncryptKey == "" {
+					return errors.New("volume is not encrypted")
+				}
+				if err := format.Decrypt(); err != nil {
+					return fmt.Errorf("decrypt secrets: %s", err)
+				}
+				rotate = true
+			}
+		case "drop-retired-keys":
+			if ctx.Bool(flag) && len(format.RetiredKeys) > 0 {
+				if rotate || ctx.Bool("reencrypt") || ctx.IsSet("rotate-encrypt-key") {
+					return errors.New("--drop-retired-keys should be used after the rotation is finished")
+				}
+				msg.WriteString(fmt.Sprintf("%s: %d keys are removed\n", flag, len(format.RetiredKeys)))
+				format.RetiredKeys = nil
+				dropKeys = true
+			}
 		case "enable-acl":
 			if enableACL := ctx.Bool(flag); enableACL != format.EnableACL {
 				if enableACL {
@@ -440,8 +643,21 @@ func config(ctx *cli.Context) error {
 		clientVer = true
 	}
 
+	if policyPath != "" {
+		if err = setCachePolicy(m, policyPath, policy); err != nil {
+			return err
+		}
+		// it's enabled by setting the policy, keep it when saving the format
+		format.CachePolicy = format.CachePolicy || policy != ""
+	}
+
 	if msg.Len() == 0 {
-		fmt.Println("Nothing changed.")
+		if rotate {
+			return rotateEncryptKey(format, ctx.Bool("reencrypt"), ctx.Int("rotate-threads"))
+		}
+		if policyPath == "" {
+			fmt.Println("Nothing changed.")
+		}
 		return nil
 	}
 
@@ -513,6 +729,12 @@ func config(ctx *cli.Context) error {
 				return fmt.Errorf("Aborted.")
 			}
 		}
+		if dropKeys {
+			warn("Objects still encrypted by the retired keys can't be read anymore, please make sure the rotation is finished.")
+			if !yes && !userConfirmed() {
+				return fmt.Errorf("Aborted.")
+			}
+		}
 		if tier {
 			if findTier && (currentTier.Sc != newTier.Sc || currentTier.Tag != newTier.Tag) {
 				fmt.Printf("existing tier will be overwritten: \n")
@@ -530,6 +752,7 @@ func config(ctx *cli.Context) error {
 		}
 	}
 
+	plain := *format // the secrets are needed by key rotation
 	if encrypted || ctx.Bool("encrypt-secret") {
 		if err = format.Encrypt(); err != nil {
 			logger.Fatalf("Format encrypt: %s", err)
@@ -537,6 +760,9 @@ func config(ctx *cli.Context) error {
 	}
 	if err = m.Init(format, false); err == nil {
 		fmt.Println(msg.String()[:msg.Len()-1])
+		if rotate {
+			err = rotateEncryptKey(&plain, ctx.Bool("reencrypt"), ctx.Int("rotate-threads"))
+		}
 	}
 
 	if !originUGQuota && format.UserGroupQuota {
@@ -547,3 +773,20 @@ func config(ctx *cli.Context) error {
 
 	return err
 }
+
+// setCachePolicy sets the cache policy of directory path, or deletes it if policy is empty.
+func setCachePolicy(m meta.Meta, path, policy string) error {
+	inode := resolveDir(m, path)
+	if policy == "" {
+		if eno := m.RemoveXattr(meta.Background(), inode, meta.CachePolicyXattr); eno != 0 && eno != meta.ENOATTR {
+			return fmt.Errorf("delete cache policy of %s: %s", path, eno)
+		}
+		fmt.Printf("cache-policy of %s: deleted\n", path)
+		return nil
+	}
+	if eno := m.SetXattr(meta.Background(), inode, meta.CachePolicyXattr, []byte(policy), 0); eno != 0 {
+		return fmt.Errorf("set cache policy of %s: %s", path, eno)
+	}
+	fmt.Printf("cache-policy of %s: %s\n", path, policy)
+	return nil
+}
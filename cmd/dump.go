@@ -150,9 +150,13 @@ func dump(ctx *cli.Context) error {
 	metaConf := meta.DefaultConf()
 	metaConf.Subdir = ctx.String("subdir")
 	m := meta.NewClient(metaUri, metaConf)
-	if _, err := m.Load(true); err != nil {
+	format, err := m.Load(true)
+	if err != nil {
 		return err
 	}
+	if format.Dedup && metaConf.Subdir != "" {
+		logger.Warnf("The index of deduplicated blocks is not included in the dump of a subdirectory, the data of it can't be read after loaded")
+	}
 	if st := m.Chroot(meta.Background(), metaConf.Subdir); st != 0 {
 		return st
 	}
@@ -163,7 +167,7 @@ func dump(ctx *cli.Context) error {
 		threads = 1
 	}
 
-	err := dumpMeta(m, dst, threads, ctx.Bool("keep-secret-key"), ctx.Bool("fast"), ctx.Bool("skip-trash"), ctx.Bool("binary"))
+	err = dumpMeta(m, dst, threads, ctx.Bool("keep-secret-key"), ctx.Bool("fast"), ctx.Bool("skip-trash"), ctx.Bool("binary"))
 	if err == nil {
 		if dst == "" {
 			dst = "STDOUT"
@@ -208,6 +208,15 @@ func dataCacheFlags() []cli.Flag {
 			Value: 1,
 			Usage: "prefetch N blocks in parallel",
 		},
+		&cli.IntFlag{
+			Name:  "prefetch-files",
+			Usage: "prefetch the first block of N files following the opened one, when the files are read in the order of directory listing",
+		},
+		&cli.IntFlag{
+			Name:  "prefetch-files-limit",
+			Value: 64,
+			Usage: "max number of files being prefetched at the same time",
+		},
 		&cli.BoolFlag{
 			Name:  "writeback",
 			Usage: "upload blocks in background",
@@ -226,6 +235,10 @@ func dataCacheFlags() []cli.Flag {
 			Name:  "upload-hours",
 			Usage: "(start-end) hour of a day between which the delayed blocks can be uploaded",
 		},
+		&cli.StringFlag{
+			Name:  "writeback-replica",
+			Usage: "mirror the staging blocks into this directory or to another member of cache group (\"peer\") before acknowledging the writes",
+		},
 		&cli.StringFlag{
 			Name:  "cache-dir",
 			Value: defaultCacheDir,
@@ -241,6 +254,11 @@ func dataCacheFlags() []cli.Flag {
 			Value: "100G",
 			Usage: "size of cached object for read in MiB",
 		},
+		&cli.StringFlag{
+			Name:  "cache-pin-size",
+			Value: "0",
+			Usage: "size of pinned blocks in each cache directory in MiB, which are not counted in cache-size",
+		},
 		&cli.Int64Flag{
 			Name:  "cache-items",
 			Value: 0,
@@ -267,18 +285,56 @@ func dataCacheFlags() []cli.Flag {
 		&cli.StringFlag{
 			Name:  "cache-eviction",
 			Value: chunk.Eviction2Random,
-			Usage: fmt.Sprintf("cache eviction policy [%s, %s, %s]", chunk.EvictionNone, chunk.Eviction2Random, chunk.EvictionLRU),
+			Usage: fmt.Sprintf("cache eviction policy [%s, %s, %s, %s]", chunk.EvictionNone, chunk.Eviction2Random, chunk.EvictionLRU, chunk.Eviction2Q),
 		},
 		&cli.StringFlag{
 			Name:  "cache-scan-interval",
 			Value: "1h",
 			Usage: "interval to scan cache-dir to rebuild in-memory index",
 		},
+		&cli.BoolFlag{
+			Name:  "cache-index",
+			Usage: "persist the index of cached blocks in cache-dir, so it's loaded without scanning on startup",
+		},
+		&cli.StringFlag{
+			Name:  "cache-tiers",
+			Usage: "ordered cache tiers from the fastest, like \"memory=4G;/nvme/jfscache=500G;/hdd1/jfscache:/hdd2/jfscache=4T\" (default unit: MiB), cache-dir and cache-size are ignored",
+		},
+		&cli.IntFlag{
+			Name:  "cache-promote-hits",
+			Value: 2,
+			Usage: "number of reads from a slower cache tier before the block is promoted to a faster one (0 means never)",
+		},
 		&cli.StringFlag{
 			Name:  "cache-expire",
 			Value: "0s",
 			Usage: "cached blocks not accessed for longer than this option will be automatically evicted (0 means never)",
 		},
+		&cli.StringFlag{
+			Name:  "cache-group",
+			Usage: "share the cached blocks with the clients in the same cache group",
+		},
+		&cli.StringFlag{
+			Name:  "group-listen",
+			Value: ":0",
+			Usage: "address to serve the other members of cache group (the first local IP and a random port by default)",
+		},
+		&cli.StringFlag{
+			Name:  "group-secret",
+			Usage: "secret to authenticate the members of cache group and the cache servers (derived from the secret key of object storage by default)",
+		},
+		&cli.StringFlag{
+			Name:  "group-tls-cert",
+			Usage: "certificate to serve and verify the members of cache group and the cache servers over TLS",
+		},
+		&cli.StringFlag{
+			Name:  "group-tls-key",
+			Usage: "key of the certificate to serve the members of cache group or the clients of cache server",
+		},
+		&cli.StringFlag{
+			Name:  "remote-cache",
+			Usage: "comma-separated addresses of the cache servers to read blocks from (started by 'juicefs cache-server')",
+		},
 	})
 }
 
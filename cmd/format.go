@@ -159,7 +159,7 @@ func formatFlags() []cli.Flag {
 		&cli.StringFlag{
 			Name:  "compress",
 			Value: "none",
-			Usage: "compression algorithm (lz4, zstd, none)",
+			Usage: "compression algorithm (lz4, zstd, zstd:LEVEL, s2, none)",
 		},
 		&cli.StringFlag{
 			Name:  "encrypt-rsa-key",
@@ -174,10 +174,18 @@ func formatFlags() []cli.Flag {
 			Name:  "hash-prefix",
 			Usage: "add a hash prefix to name of objects",
 		},
+		&cli.BoolFlag{
+			Name:  "dedup",
+			Usage: "store blocks with the same content only once (this flag is irreversible once enabled)",
+		},
 		&cli.IntFlag{
 			Name:  "shards",
 			Usage: "store the blocks into N buckets by hash of key",
 		},
+		&cli.StringFlag{
+			Name:  "erasure",
+			Usage: "split the blocks into K data and M parity shards stored in K+M buckets (K+M)",
+		},
 	})
 }
 
@@ -276,7 +284,12 @@ func createStorage(format meta.Format) (object.ObjectStorage, error) {
 		}
 	}
 
-	if format.Shards > 1 {
+	if format.Erasure != "" {
+		var k, m int
+		if k, m, err = object.ParseErasure(format.Erasure); err == nil {
+			blob, err = object.NewErasure(strings.ToLower(format.Storage), format.Bucket, format.AccessKey, format.SecretKey, format.SessionToken, k, m)
+		}
+	} else if format.Shards > 1 {
 		blob, err = object.NewSharded(strings.ToLower(format.Storage), format.Bucket, format.AccessKey, format.SecretKey, format.SessionToken, format.Shards)
 	} else {
 		blob, err = object.CreateStorage(strings.ToLower(format.Storage), format.Bucket, format.AccessKey, format.SecretKey, format.SessionToken)
@@ -284,17 +297,15 @@ func createStorage(format meta.Format) (object.ObjectStorage, error) {
 	if err != nil {
 		return nil, err
 	}
+	if len(format.Replicas) > 0 {
+		if blob, err = createReplicated(&format, blob); err != nil {
+			return nil, err
+		}
+	}
 	blob = object.WithPrefix(blob, format.Name+"/")
 	initStorageTiers(blob, format.Tiers)
 	if format.EncryptKey != "" {
-		privKey, err := object.ParsePrivateKeyFromPem([]byte(format.EncryptKey), []byte(os.Getenv("JFS_RSA_PASSPHRASE")))
-		if err != nil {
-			if errors.Is(err, object.ErrKeyNeedPasswd) {
-				return nil, fmt.Errorf("%w: please set the 'JFS_RSA_PASSPHRASE' environment variable", err)
-			}
-			return nil, fmt.Errorf("parse private key: %s", err)
-		}
-		encryptor, err := object.NewDataEncryptor(object.NewKeyEncryptor(privKey), format.EncryptAlgo)
+		encryptor, err := newEncryptor(&format)
 		if err != nil {
 			return nil, err
 		}
@@ -303,6 +314,70 @@ func createStorage(format meta.Format) (object.ObjectStorage, error) {
 	return blob, nil
 }
 
+func parsePrivateKey(key string) (any, error) {
+	privKey, err := object.ParsePrivateKeyFromPem([]byte(key), []byte(os.Getenv("JFS_RSA_PASSPHRASE")))
+	if err != nil {
+		if errors.Is(err, object.ErrKeyNeedPasswd) {
+			return nil, fmt.Errorf("%w: please set the 'JFS_RSA_PASSPHRASE' environment variable", err)
+		}
+		return nil, fmt.Errorf("parse private key: %s", err)
+	}
+	return privKey, nil
+}
+
+// newEncryptor returns the encryptor of the volume, which can also decrypt the objects encrypted
+// by the keys retired by key rotation, or the pending one to be switched to.
+func newEncryptor(format *meta.Format) (object.KeyRotator, error) {
+	privKey, err := parsePrivateKey(format.EncryptKey)
+	if err != nil {
+		return nil, err
+	}
+	var retired []any
+	var algos []string
+	for i, r := range format.RetiredKeys {
+		k, err := parsePrivateKey(r.Key)
+		if err != nil {
+			return nil, fmt.Errorf("retired key %d: %w", i+1, err)
+		}
+		retired = append(retired, k)
+		algos = append(algos, r.Algo)
+	}
+	if pk := format.PendingKey; pk != nil {
+		k, err := parsePrivateKey(pk.Key)
+		if err != nil {
+			return nil, fmt.Errorf("pending key: %w", err)
+		}
+		retired = append(retired, k)
+		algos = append(algos, pk.Algo)
+	}
+	return object.NewRotatedEncryptor(privKey, format.EncryptAlgo, retired, algos)
+}
+
+// createReplicated returns a storage that keeps a copy of the data in all the replicas, the pending
+// replications are persisted under the default cache directory in async mode.
+func createReplicated(format *meta.Format, primary object.ObjectStorage) (object.ObjectStorage, error) {
+	replicas := make([]object.ObjectStorage, 0, len(format.Replicas))
+	for _, r := range format.Replicas {
+		s, err := object.CreateStorage(strings.ToLower(r.Storage), r.Bucket, r.AccessKey, r.SecretKey, r.SessionToken)
+		if err != nil {
+			return nil, fmt.Errorf("replica %s: %s", r.Bucket, err)
+		}
+		replicas = append(replicas, s)
+	}
+	conf := object.ReplicaConfig{
+		Async:       format.ReplicaAsync,
+		ReadTimeout: time.Second * 5,
+		Workers:     10,
+	}
+	if format.ReplicaTimeout > 0 {
+		conf.ReadTimeout = time.Millisecond * time.Duration(format.ReplicaTimeout)
+	}
+	if conf.Async {
+		conf.QueueDir = filepath.Join(getDefaultCacheDir(), format.UUID, "replica")
+	}
+	return object.NewReplicated(primary, replicas, conf)
+}
+
 func initStorageTiers(storage object.ObjectStorage, tiers object.Tiers) {
 	if tierStorage, ok := storage.(object.SupportTier); ok {
 		if err := tierStorage.InitTiers(tiers); err != nil && hasConfiguredTiers(tiers) {
@@ -451,6 +526,14 @@ func format(c *cli.Context) error {
 	if v := c.Int("shards"); v > 256 {
 		logger.Fatalf("too many shards: %d", v)
 	}
+	if v := c.String("erasure"); v != "" {
+		if _, _, err := object.ParseErasure(v); err != nil {
+			logger.Fatalf("%s", err)
+		}
+		if c.Int("shards") > 1 {
+			logger.Fatalf("erasure code cannot be used together with shards")
+		}
+	}
 
 	var create, encrypted bool
 	format, err := m.Load(false)
@@ -489,12 +572,16 @@ func format(c *cli.Context) error {
 				format.Compression = c.String(flag)
 			case "shards":
 				format.Shards = c.Int(flag)
+			case "erasure":
+				format.Erasure = c.String(flag)
 			case "hash-prefix":
 				format.HashPrefix = c.Bool(flag)
 			case "storage":
 				format.Storage = c.String(flag)
 			case "encrypt-rsa-key", "encrypt-algo":
 				logger.Warnf("Flag %q is ignored since it cannot be updated", flag)
+			case "dedup":
+				logger.Warnf("Flag %q is ignored, please enable it with `juicefs config`", flag)
 			case "ranger-rest-url":
 				format.RangerRestUrl = c.String(flag)
 			case "ranger-service":
@@ -518,7 +605,9 @@ func format(c *cli.Context) error {
 			EncryptKey:       loadEncrypt(c.String("encrypt-rsa-key")),
 			EncryptAlgo:      c.String("encrypt-algo"),
 			Shards:           c.Int("shards"),
+			Erasure:          c.String("erasure"),
 			HashPrefix:       c.Bool("hash-prefix"),
+			Dedup:            c.Bool("dedup"),
 			Capacity:         utils.ParseBytes(c, "capacity", 'G'),
 			Inodes:           c.Uint64("inodes"),
 			BlockSize:        int(fixObjectSize(utils.ParseBytes(c, "block-size", 'K')) >> 10),
@@ -549,6 +638,12 @@ func format(c *cli.Context) error {
 		if format.KerbConf != "" {
 			format.MinClientVersion = maxVersion(format.MinClientVersion, "1.4.0-A")
 		}
+		if format.Dedup || format.Erasure != "" {
+			format.MinClientVersion = maxVersion(format.MinClientVersion, "1.5.0-A")
+		}
+		if format.Dedup {
+			format.NewDedupSecret()
+		}
 
 		if format.AccessKey == "" && os.Getenv("ACCESS_KEY") != "" {
 			format.AccessKey = os.Getenv("ACCESS_KEY")
@@ -566,15 +661,21 @@ func format(c *cli.Context) error {
 		logger.Fatalf("Load metadata: %s", err)
 	}
 	if format.Storage == "file" || format.Storage == "sqlite3" {
-		p, err := filepath.Abs(format.Bucket)
-		if err == nil {
-			format.Bucket = p
-		} else {
-			logger.Fatalf("Failed to get absolute path of %q: %s", format.Bucket, err)
+		buckets := []string{format.Bucket}
+		if format.Erasure != "" {
+			buckets = strings.Split(format.Bucket, ",") // the buckets of shards
 		}
-		if format.Storage == "file" {
-			format.Bucket += "/"
+		for i, b := range buckets {
+			p, err := filepath.Abs(b)
+			if err != nil {
+				logger.Fatalf("Failed to get absolute path of %q: %s", b, err)
+			}
+			buckets[i] = p
+			if format.Storage == "file" {
+				buckets[i] += "/"
+			}
 		}
+		format.Bucket = strings.Join(buckets, ",")
 	}
 
 	blob, err := createStorage(*format)
@@ -24,6 +24,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/juicedata/juicefs/pkg/chunk"
 	"github.com/juicedata/juicefs/pkg/meta"
 	"github.com/juicedata/juicefs/pkg/object"
 	"github.com/juicedata/juicefs/pkg/utils"
@@ -49,7 +50,10 @@ $ juicefs fsck redis://localhost
 $ juicefs fsck redis://localhost --path /d1/d2 --repair
 
 # recursively check
-$ juicefs fsck redis://localhost --path /d1/d2 --recursive`,
+$ juicefs fsck redis://localhost --path /d1/d2 --recursive
+
+# Rebuild lost shards of blocks for volume with erasure code
+$ juicefs fsck redis://localhost --repair`,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "path",
@@ -57,7 +61,7 @@ $ juicefs fsck redis://localhost --path /d1/d2 --recursive`,
 			},
 			&cli.BoolFlag{
 				Name:  "repair",
-				Usage: "repair specified path if it's broken",
+				Usage: "repair specified path if it's broken, or rebuild lost shards of blocks for volume with erasure code",
 			},
 			&cli.BoolFlag{
 				Name:    "recursive",
@@ -79,15 +83,15 @@ $ juicefs fsck redis://localhost --path /d1/d2 --recursive`,
 
 func fsck(ctx *cli.Context) error {
 	setup(ctx, 1)
-	if ctx.Bool("repair") && ctx.String("path") == "" {
-		logger.Fatalf("Please provide the path to repair with `--path` option")
-	}
 	removePassword(ctx.Args().Get(0))
 	m := meta.NewClient(ctx.Args().Get(0), nil)
 	format, err := m.Load(true)
 	if err != nil {
 		logger.Fatalf("load setting: %s", err)
 	}
+	if ctx.Bool("repair") && ctx.String("path") == "" && format.Erasure == "" {
+		logger.Fatalf("Please provide the path to repair with `--path` option")
+	}
 	var c = meta.NewContext(0, 0, []uint32{0})
 	progress := utils.NewProgress(false)
 	// prepare slices
@@ -132,6 +136,7 @@ func fsck(ctx *cli.Context) error {
 		logger.Fatalf("object storage: %s", err)
 	}
 	logger.Infof("Data use %s", blob)
+	rawBlob := blob
 	blob = object.WithPrefix(blob, "chunks/")
 
 	// Find all blocks in object storage
@@ -175,7 +180,60 @@ func fsck(ctx *cli.Context) error {
 	sliceCBar := progress.AddCountBar("Scanned slices", sliceCSpin.Current())
 	sliceBSpin := progress.AddByteSpinner("Scanned slices")
 	lostDSpin := progress.AddDoubleSpinner("Lost blocks")
+	var degradedDSpin, repairedDSpin *utils.DoubleSpinner
+	if format.Erasure != "" {
+		degradedDSpin = progress.AddDoubleSpinner("Degraded blocks")
+		repairedDSpin = progress.AddDoubleSpinner("Repaired blocks")
+	}
 	brokens := make(map[meta.Ino]string)
+	brokenPath := func(inode meta.Ino) string {
+		if p, ok := brokens[inode]; ok {
+			return p
+		}
+		if ps := m.GetPaths(meta.Background(), inode); len(ps) > 0 {
+			return ps[0]
+		}
+		return fmt.Sprintf("inode:%d", inode)
+	}
+	type blockObj struct {
+		key  string
+		size int
+	}
+	checkObject := func(inode meta.Ino, store object.ObjectStorage, objKey string, size int, found bool) {
+		if !found {
+			obj, err := store.Head(ctx.Context, objKey)
+			if err != nil {
+				filePath := brokenPath(inode)
+				if !errors.Is(err, os.ErrNotExist) {
+					logger.Warnf("check block %s for file %s in object storage: %s", objKey, filePath, err)
+					return
+				}
+				brokens[inode] = filePath
+				logger.Errorf("can't find block %s for file %s: %s", objKey, filePath, err)
+				lostDSpin.IncrInt64(int64(size))
+				return
+			}
+			blockDSpin.IncrInt64(obj.Size())
+		}
+		if format.Erasure != "" {
+			// check the shards of block, and rebuild the lost ones
+			repairer := store.(object.SupportRepair)
+			n, err := repairer.Repair(ctx.Context, objKey, !ctx.Bool("repair"))
+			if err != nil {
+				filePath := brokenPath(inode)
+				brokens[inode] = filePath
+				logger.Errorf("can't read block %s for file %s: %s", objKey, filePath, err)
+				lostDSpin.IncrInt64(int64(size))
+			} else if n > 0 {
+				if ctx.Bool("repair") {
+					repairedDSpin.IncrInt64(int64(size))
+				} else {
+					logger.Warnf("%d shards of block %s are lost or damaged", n, objKey)
+					degradedDSpin.IncrInt64(int64(size))
+				}
+			}
+		}
+	}
 	for inode, ss := range slices {
 		if delfiles[inode] {
 			delfilesSpin.Increment()
@@ -190,33 +248,29 @@ func fsck(ctx *cli.Context) error {
 					sz = int(s.Size) - int(i)*chunkConf.BlockSize
 				}
 				key := fmt.Sprintf("%d_%d_%d", s.Id, i, sz)
-				if _, ok := blocks[key]; !ok {
-					var objKey string
-					if format.HashPrefix {
-						objKey = fmt.Sprintf("%02X/%v/%s", s.Id%256, s.Id/1000/1000, key)
-					} else {
-						objKey = fmt.Sprintf("%v/%v/%s", s.Id/1000/1000, s.Id/1000, key)
-					}
-					obj, err := blob.Head(ctx.Context, objKey)
-					if err != nil {
-						filePath, ok := brokens[inode]
-						if !ok {
-							if ps := m.GetPaths(meta.Background(), inode); len(ps) > 0 {
-								filePath = ps[0]
-							} else {
-								filePath = fmt.Sprintf("inode:%d", inode)
-							}
-						}
-						if !errors.Is(err, os.ErrNotExist) {
-							logger.Warnf("check block %s for file %s in object storage: %s", objKey, filePath, err)
-							continue
+				var objKey string
+				if format.HashPrefix {
+					objKey = fmt.Sprintf("%02X/%v/%s", s.Id%256, s.Id/1000/1000, key)
+				} else {
+					objKey = fmt.Sprintf("%v/%v/%s", s.Id/1000/1000, s.Id/1000, key)
+				}
+				_, found := blocks[key]
+				store := blob
+				objs := []blockObj{{objKey, sz}}
+				if format.Dedup && !found {
+					// the block may be split into chunks stored in shared objects
+					chunks, st := m.GetDedupBlock(meta.Background(), s.Id, i)
+					if st != 0 {
+						logger.Warnf("lookup dedup index of block %s: %s", key, st)
+					} else if chunks != nil {
+						store, objs = rawBlob, objs[:0]
+						for _, c := range chunks {
+							objs = append(objs, blockObj{chunk.DedupKey(c.Hash, int(c.Size)), int(c.Size)})
 						}
-						brokens[inode] = filePath
-						logger.Errorf("can't find block %s for file %s: %s", objKey, filePath, err)
-						lostDSpin.IncrInt64(int64(sz))
-						continue
 					}
-					blockDSpin.IncrInt64(obj.Size())
+				}
+				for _, obj := range objs {
+					checkObject(inode, store, obj.key, obj.size, found)
 				}
 			}
 			sliceCBar.Increment()
@@ -229,6 +283,14 @@ func fsck(ctx *cli.Context) error {
 		logger.Infof("Found %d blocks (%d bytes)", c, b)
 		logger.Infof("Used by %d slices (%d bytes)", sliceCBar.Current(), sliceBSpin.Current())
 	}
+	if degradedDSpin != nil {
+		if dc, db := degradedDSpin.Current(); dc > 0 {
+			logger.Warnf("%d blocks (%d bytes) have lost or damaged shards, run with `--repair` to rebuild them", dc, db)
+		}
+		if rc, rb := repairedDSpin.Current(); rc > 0 {
+			logger.Infof("Rebuilt the shards of %d blocks (%d bytes)", rc, rb)
+		}
+	}
 	if lc, lb := lostDSpin.Current(); lc > 0 {
 		msg := fmt.Sprintf("%d objects are lost (%d bytes), %d broken files:\n", lc, lb, len(brokens))
 		msg += fmt.Sprintf("%13s: PATH\n", "INODE")
@@ -22,6 +22,7 @@ package cmd
 import (
 	"context"
 	"errors"
+	"net"
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
@@ -112,6 +113,15 @@ func cmdGateway() *cli.Command {
 			Value: "s3gateway",
 			Usage: "the mount point for current volume (to follow symlink)",
 		},
+		&cli.StringSliceFlag{
+			Name:  "event-target",
+			Usage: "target of bucket notifications in the format of ID=URL, the URL can be http(s)://host/path, nats://[user:pass@]host:port/subject or file:///dir (can be specified multiple times)",
+		},
+		&cli.StringFlag{
+			Name:  "event-queue-dir",
+			Value: path.Join(getDefaultCacheDir(), "gateway-events"),
+			Usage: "directory to keep the events of bucket notifications until they are delivered",
+		},
 	}
 
 	return &cli.Command{
@@ -192,6 +202,10 @@ func gateway(c *cli.Context) error {
 			HeadDir:     c.Bool("head-dir"),
 			HideDir:     c.Bool("hide-dir-object"),
 			ReadOnly:    readonly,
+			RootUser:    ak,
+
+			EventTargets:  c.StringSlice("event-target"),
+			EventQueueDir: c.String("event-queue-dir"),
 		},
 	)
 	if err != nil {
@@ -207,6 +221,8 @@ func gateway(c *cli.Context) error {
 		}
 	}
 
+	go jfsgateway.SyncUsers(context.Background(), jfsGateway, localEndpoint(listenAddr), ak, sk, false, readonly, utils.Duration(c.String("refresh-iam-interval")))
+
 	args := []string{"server", "--address", listenAddr, "--anonymous"}
 	if c.Bool("no-banner") {
 		args = append(args, "--quiet")
@@ -238,6 +254,18 @@ func gateway(c *cli.Context) error {
 
 var jfsGateway minio.ObjectLayer
 
+// localEndpoint returns the address to access the gateway listening on addr from local.
+func localEndpoint(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, port)
+}
+
 func gateway2(ctx *mcli.Context) error {
 	minio.ServerMainForJFS(ctx, jfsGateway)
 	return nil
@@ -271,6 +299,8 @@ func initForSvc(c *cli.Context, mp string, svcType, metaUrl, listenAddr string)
 	chunkConf := getChunkConf(c, format)
 	store := chunk.NewCachedStore(blob, *chunkConf, registerer)
 	registerMetaMsg(metaCli, store, chunkConf)
+	groupCtx, leaveGroup := context.WithCancel(context.Background())
+	joinCacheGroup(groupCtx, metaCli, metaConf, store, chunkConf)
 
 	err = metaCli.NewSession(true)
 	if err != nil {
@@ -279,6 +309,8 @@ func initForSvc(c *cli.Context, mp string, svcType, metaUrl, listenAddr string)
 	metaCli.OnReload(func(fmt *meta.Format) {
 		updateFormat(c)(fmt)
 		store.UpdateLimit(fmt.UploadLimit, fmt.DownloadLimit)
+		store.UpdateCompress(fmt.Compression, fmt.UntaggedCompress)
+		updateDedup(store, fmt)
 	})
 
 	// Go will catch all the signals
@@ -288,6 +320,7 @@ func initForSvc(c *cli.Context, mp string, svcType, metaUrl, listenAddr string)
 	go func() {
 		sig := <-signalChan
 		logger.Infof("Received signal %s, exiting...", sig.String())
+		leaveGroup()
 		if err := metaCli.CloseSession(); err != nil {
 			logger.Fatalf("close session failed: %s", err)
 		}
@@ -108,6 +108,7 @@ func gc(ctx *cli.Context) error {
 	}
 	logger.Infof("Data use %s", blob)
 	store := chunk.NewCachedStore(blob, chunkConf, nil)
+	store.SetDedupIndex(vfs.NewDedupIndex(m))
 
 	// Scan all chunks first and do compaction if necessary
 	progress := utils.NewProgress(false)
@@ -219,6 +220,9 @@ func gc(ctx *cli.Context) error {
 	} else {
 		gcErr = gcInMemory(c, m, &chunkConf, blob, stats, threads, delFlag, maxMtime)
 	}
+	if gcErr == nil && format.Dedup {
+		gcErr = gcDedup(c, m, blob, stats, threads, delFlag, maxMtime)
+	}
 	if gcErr == nil {
 		stats.finish(delFlag, compact)
 	}
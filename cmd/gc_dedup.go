@@ -0,0 +1,90 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/chunk"
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/juicedata/juicefs/pkg/object"
+	"github.com/pkg/errors"
+)
+
+// gcDedup deletes the deduplicated objects which are not referenced for a while, and finds
+// the leaked ones which are not in the index.
+func gcDedup(c meta.Context, m meta.Meta, blob object.ObjectStorage, stats *gcStats, threads int, delFlag bool, maxMtime time.Time) error {
+	if delFlag {
+		freed := stats.progress.AddDoubleSpinnerTwo("Cleaned dedup objects", "Cleaned dedup data")
+		_, st := m.CleanupDedupRefs(c, maxMtime, func(ref *meta.DedupRef) error {
+			key := chunk.DedupKey(ref.Hash, int(ref.Size))
+			if err := blob.Delete(c, key); err != nil {
+				return err
+			}
+			freed.IncrInt64(int64(ref.Size))
+			return nil
+		})
+		freed.Done()
+		if st != 0 {
+			return errors.Errorf("cleanup dedup objects: %s", st)
+		}
+	}
+
+	refs := make(map[string]int64)
+	if st := m.ScanDedupRefs(c, func(ref *meta.DedupRef) bool {
+		refs[ref.Hash] = ref.Refs
+		return true
+	}); st != 0 {
+		return errors.Errorf("scan dedup index: %s", st)
+	}
+
+	dblob := object.WithPrefix(blob, "dedup/")
+	leakedObj, waitLeakedObj := startGcObjectDeleters(c, dblob, max(threads, 1), delFlag)
+	defer waitLeakedObj()
+	objs, err := object.ListAll(c, dblob, "", "", true, false)
+	if err != nil {
+		return errors.Errorf("list dedup objects: %s", err)
+	}
+	for obj := range objs {
+		if obj == nil {
+			return errors.Errorf("list dedup objects from %s failed", dblob)
+		}
+		var ok bool
+		if obj, ok = filterGcObject(context.Background(), dblob, obj, maxMtime, stats.scanned, stats.skipped); !ok {
+			continue
+		}
+		hash, _, ok := chunk.ParseDedupKey(obj.Key())
+		if !ok {
+			continue
+		}
+		stats.scanned.IncrTotal(1)
+		stats.scanned.Increment()
+		if n, found := refs[hash]; !found {
+			logger.Debugf("find leaked dedup object: %s, size: %d", obj.Key(), obj.Size())
+			stats.addLeaked(obj.Size())
+			if leakedObj != nil {
+				leakedObj <- obj.Key()
+			}
+		} else if n > 0 {
+			stats.addObject(gcStateUsed, obj.Size())
+		} else {
+			stats.addObject(gcStatePending, obj.Size())
+		}
+	}
+	return nil
+}
@@ -171,6 +171,9 @@ func info(ctx *cli.Context) error {
 		if resp.RestoreStatus != "" {
 			fmt.Printf("   restore status: %s\n", resp.RestoreStatus)
 		}
+		if resp.Pinned > 0 {
+			fmt.Printf(" pinned: %s\n", utils.FormatBytes(resp.Pinned))
+		}
 		if len(resp.Chunks) > 0 {
 			fmt.Println(" chunks:")
 			results := make([][]string, 0, 1+len(resp.Chunks))
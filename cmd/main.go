@@ -75,7 +75,9 @@ func Main(args []string) error {
 			cmdMount(),
 			cmdUmount(),
 			cmdGateway(),
+			cmdGatewayUser(),
 			cmdWebDav(),
+			cmdCacheServer(),
 			cmdBench(),
 			cmdObjbench(),
 			cmdMdtest(),
@@ -86,7 +88,12 @@ func Main(args []string) error {
 			cmdClone(),
 			cmdSummary(),
 			cmdCompact(),
+			cmdStage(),
 			cmdTier(),
+			cmdSnapshot(),
+			cmdReplicate(),
+			cmdCompression(),
+			cmdReplica(),
 		},
 	}
 
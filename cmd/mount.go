@@ -298,7 +298,9 @@ func getVfsConf(c *cli.Context, metaConf *meta.Config, format *meta.Format, chun
 		PPid:            os.Getppid(),
 		UMask:           0xFFFF,
 		HideInternal:    c.Bool("hide-internal"),
+		PrefetchFiles:   c.Int("prefetch-files"),
 	}
+	cfg.PrefetchFilesLimit = c.Int("prefetch-files-limit")
 
 	if c.IsSet("umask") {
 		umask, err := strconv.ParseUint(c.String("umask"), 8, 16)
@@ -315,7 +317,27 @@ func getVfsConf(c *cli.Context, metaConf *meta.Config, format *meta.Format, chun
 	return cfg
 }
 
+// dedupSecret returns the key of the HMAC of deduplicated chunks, which can't be hashed without it.
+func dedupSecret(format *meta.Format) string {
+	secret, err := format.PlainDedupSecret()
+	if err != nil {
+		logger.Fatalf("decrypt dedup secret: %s", err)
+	}
+	return secret
+}
+
+// updateDedup updates the deduplication of store with the reloaded format.
+func updateDedup(store chunk.ChunkStore, format *meta.Format) {
+	secret, err := format.PlainDedupSecret()
+	if err != nil {
+		logger.Warnf("decrypt dedup secret: %s", err)
+		return
+	}
+	store.UpdateDedup(format.Dedup, secret)
+}
+
 func registerMetaMsg(m meta.Meta, store chunk.ChunkStore, chunkConf *chunk.Config) {
+	store.SetDedupIndex(vfs.NewDedupIndex(m))
 	m.OnMsg(meta.DeleteSlice, func(args ...interface{}) error {
 		return store.Remove(args[0].(uint64), int(args[1].(uint32)))
 	})
@@ -375,9 +397,13 @@ func getChunkConf(c *cli.Context, format *meta.Format) *chunk.Config {
 		cm = 0600
 	}
 	chunkConf := &chunk.Config{
-		BlockSize:  format.BlockSize * 1024,
-		Compress:   format.Compression,
-		HashPrefix: format.HashPrefix,
+		BlockSize:        format.BlockSize * 1024,
+		Compress:         format.Compression,
+		UntaggedCompress: format.UntaggedCompress,
+		Dedup:            format.Dedup,
+		DedupSecret:      dedupSecret(format),
+		HashPrefix:       format.HashPrefix,
+		Volume:           format.UUID,
 
 		GetTimeout:             utils.Duration(c.String("get-timeout")),
 		PutTimeout:             utils.Duration(c.String("put-timeout")),
@@ -393,9 +419,11 @@ func getChunkConf(c *cli.Context, format *meta.Format) *chunk.Config {
 		DownloadLimit:          utils.ParseMbps(c, "download-limit") * 1e6 / 8,
 		UploadDelay:            utils.Duration(c.String("upload-delay")),
 		UploadHours:            c.String("upload-hours"),
+		StageReplica:           c.String("writeback-replica"),
 
 		CacheDir:          c.String("cache-dir"),
 		CacheSize:         utils.ParseBytes(c, "cache-size", 'M'),
+		CachePinSize:      utils.ParseBytes(c, "cache-pin-size", 'M'),
 		CacheItems:        c.Int64("cache-items"),
 		FreeSpace:         float32(c.Float64("free-space-ratio")),
 		CacheMode:         os.FileMode(cm),
@@ -404,16 +432,59 @@ func getChunkConf(c *cli.Context, format *meta.Format) *chunk.Config {
 		CacheChecksum:     c.String("verify-cache-checksum"),
 		CacheEviction:     c.String("cache-eviction"),
 		CacheScanInterval: utils.Duration(c.String("cache-scan-interval")),
+		CacheIndex:        c.Bool("cache-index"),
 		CacheExpire:       utils.Duration(c.String("cache-expire")),
 		OSCache:           os.Getenv("JFS_DROP_OSCACHE") == "",
 		AutoCreate:        true,
 	}
+	if tiers := c.String("cache-tiers"); tiers != "" {
+		if chunkConf.CacheTiers, err = chunk.ParseCacheTiers(tiers); err != nil {
+			logger.Fatalf("invalid cache-tiers: %s", err)
+		}
+		var dirs []string
+		chunkConf.CacheSize = 0
+		for _, t := range chunkConf.CacheTiers {
+			chunkConf.CacheSize += t.Size
+			if t.Dir != "memory" {
+				dirs = append(dirs, t.Dir)
+			}
+		}
+		chunkConf.CacheDir = "memory"
+		if len(dirs) > 0 {
+			chunkConf.CacheDir = strings.Join(dirs, string(os.PathListSeparator))
+		}
+		chunkConf.CachePromoteHits = c.Int("cache-promote-hits")
+	}
 	if c.IsSet("max-readahead") {
 		chunkConf.Readahead = int(utils.ParseBytes(c, "max-readahead", 'M'))
 	} else {
 		chunkConf.Readahead = 8 * chunkConf.BlockSize
 	}
 
+	if group := c.String("cache-group"); group != "" {
+		chunkConf.CacheGroup = group
+		chunkConf.GroupListen = groupListenAddr(c)
+	}
+	if servers := c.String("remote-cache"); servers != "" {
+		if chunkConf.CacheGroup != "" {
+			logger.Fatalf("--remote-cache can't be used together with --cache-group")
+		}
+		for _, s := range strings.Split(servers, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				chunkConf.RemoteCache = append(chunkConf.RemoteCache, s)
+			}
+		}
+	}
+	if chunkConf.StageReplica == chunk.ReplicaPeer && chunkConf.CacheGroup != "" {
+		// the replicas are kept by the address of members, which should not change after restarted
+		if _, port, _ := net.SplitHostPort(chunkConf.GroupListen); port == "0" {
+			logger.Fatalf("--writeback-replica=peer requires a fixed port in --group-listen")
+		}
+	}
+	chunkConf.GroupSecret = groupSecret(c, format)
+	chunkConf.GroupTLSCert = c.String("group-tls-cert")
+	chunkConf.GroupTLSKey = c.String("group-tls-key")
+
 	if chunkConf.UploadLimit == 0 {
 		chunkConf.UploadLimit = format.UploadLimit * 1e6 / 8
 	}
@@ -424,6 +495,51 @@ func getChunkConf(c *cli.Context, format *meta.Format) *chunk.Config {
 	return chunkConf
 }
 
+// groupListenAddr returns the address to serve the other members of cache group, which should be
+// reachable from them, so the first local IP is used if no host is specified.
+func groupListenAddr(c *cli.Context) string {
+	host, port, err := net.SplitHostPort(c.String("group-listen"))
+	if err != nil {
+		logger.Fatalf("invalid group-listen %q: %s", c.String("group-listen"), err)
+	}
+	if host == "" {
+		var ifaces []string
+		if s := c.String("network-interfaces"); s != "" {
+			for _, i := range strings.Split(s, ",") {
+				ifaces = append(ifaces, strings.TrimSpace(i))
+			}
+		}
+		ips, err := utils.FindLocalIPs(ifaces...)
+		if err != nil || len(ips) == 0 {
+			logger.Fatalf("no local IP for cache group (%v), please specify it with --group-listen", err)
+		}
+		host = ips[0].String()
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// groupSecret returns the secret to authenticate the members of cache group and the cache servers,
+// which is derived from the UUID and the secret key of the volume if not specified.
+func groupSecret(c *cli.Context, format *meta.Format) string {
+	if s := c.String("group-secret"); s != "" {
+		return s
+	}
+	if format.SecretKey == "" {
+		return ""
+	}
+	return format.UUID + "/" + format.SecretKey
+}
+
+// joinCacheGroup makes the store share its cached blocks with the other clients in the same group
+// until ctx is done, the address serving them is recorded in the session.
+func joinCacheGroup(ctx context.Context, m meta.Meta, metaConf *meta.Config, store chunk.ChunkStore, chunkConf *chunk.Config) {
+	if chunkConf.CacheGroup == "" {
+		return
+	}
+	metaConf.CacheGroup = chunkConf.CacheGroup
+	metaConf.GroupAddr = store.JoinCacheGroup(ctx, vfs.NewCacheGroupMembers(m, chunkConf.CacheGroup))
+}
+
 func initBackgroundTasks(c *cli.Context, vfsConf *vfs.Config, metaConf *meta.Config, m meta.Meta, blob object.ObjectStorage, registerer prometheus.Registerer, registry *prometheus.Registry) {
 	metricsAddr := exposeMetrics(c, registerer, registry)
 	m.InitMetrics(registerer)
@@ -476,7 +592,9 @@ func NewReloadableStorage(format *meta.Format, cli meta.Meta, patch func(*meta.F
 			patch(new)
 		}
 		old := &holder.fmt
-		if new.Storage != old.Storage || new.Bucket != old.Bucket || new.AccessKey != old.AccessKey || new.SecretKey != old.SecretKey || new.SessionToken != old.SessionToken || new.Tiers[0].Sc != old.Tiers[0].Sc || !reflect.DeepEqual(new.Tiers, old.Tiers) {
+		if new.Storage != old.Storage || new.Bucket != old.Bucket || new.AccessKey != old.AccessKey || new.SecretKey != old.SecretKey || new.SessionToken != old.SessionToken || new.Tiers[0].Sc != old.Tiers[0].Sc || !reflect.DeepEqual(new.Tiers, old.Tiers) ||
+			!reflect.DeepEqual(new.Replicas, old.Replicas) || new.ReplicaAsync != old.ReplicaAsync || new.ReplicaTimeout != old.ReplicaTimeout ||
+			new.EncryptKey != old.EncryptKey || new.EncryptAlgo != old.EncryptAlgo || !reflect.DeepEqual(new.RetiredKeys, old.RetiredKeys) || !reflect.DeepEqual(new.PendingKey, old.PendingKey) {
 			logger.Infof("found new configuration: storage=%q bucket=%q ak=%q storageClass=%q tiers=%v", new.Storage, new.Bucket, new.AccessKey, new.Tiers[0].Sc, new.Tiers)
 			newBlob, err := createStorage(*new)
 			if err != nil {
@@ -674,6 +792,9 @@ func mount(c *cli.Context) error {
 
 	store := chunk.NewCachedStore(blob, *chunkConf, registerer)
 	registerMetaMsg(metaCli, store, chunkConf)
+	groupCtx, leaveGroup := context.WithCancel(context.Background())
+	defer leaveGroup()
+	joinCacheGroup(groupCtx, metaCli, metaConf, store, chunkConf)
 
 	err = metaCli.NewSession(true)
 	if err != nil {
@@ -683,12 +804,15 @@ func mount(c *cli.Context) error {
 	metaCli.OnReload(func(fmt *meta.Format) {
 		updateFormat(c)(fmt)
 		store.UpdateLimit(fmt.UploadLimit, fmt.DownloadLimit)
+		store.UpdateCompress(fmt.Compression, fmt.UntaggedCompress)
+		updateDedup(store, fmt)
 	})
 	v := vfs.NewVFS(vfsConf, metaCli, store, registerer, registry)
 	installHandler(metaCli, mp, v, blob)
 	v.UpdateFormat = updateFormat(c)
 	initBackgroundTasks(c, vfsConf, metaConf, metaCli, blob, registerer, registry)
 	mountMain(v, c)
+	leaveGroup()
 	if err := v.FlushAll(""); err != nil {
 		logger.Errorf("flush all delayed data: %s", err)
 	}
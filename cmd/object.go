@@ -481,15 +481,18 @@ func (j *juiceFS) CompleteUpload(rCtx context.Context, key string, uploadID stri
 
 func getDefaultChunkConf(format *meta.Format) *chunk.Config {
 	chunkConf := &chunk.Config{
-		BlockSize:   format.BlockSize * 1024,
-		Compress:    format.Compression,
-		HashPrefix:  format.HashPrefix,
-		GetTimeout:  time.Minute,
-		PutTimeout:  time.Minute,
-		MaxUpload:   50,
-		MaxDownload: 200,
-		MaxRetries:  10,
-		BufferSize:  300 << 20,
+		BlockSize:        format.BlockSize * 1024,
+		Compress:         format.Compression,
+		UntaggedCompress: format.UntaggedCompress,
+		Dedup:            format.Dedup,
+		DedupSecret:      dedupSecret(format),
+		HashPrefix:       format.HashPrefix,
+		GetTimeout:       time.Minute,
+		PutTimeout:       time.Minute,
+		MaxUpload:        50,
+		MaxDownload:      200,
+		MaxRetries:       10,
+		BufferSize:       300 << 20,
 	}
 	chunkConf.SelfCheck(format.UUID)
 	return chunkConf
@@ -500,15 +503,24 @@ func (j *juiceFS) Shutdown() {
 }
 
 func newJFS(endpoint, accessKey, secretKey, token string) (object.ObjectStorage, error) {
+	metaUrl := os.Getenv(endpoint)
+	if metaUrl == "" {
+		metaUrl = endpoint
+	}
+	j, err := openJFS(metaUrl, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// openJFS opens the volume as an object storage, entries and attributes are cached for cacheTimeout.
+func openJFS(metaUrl string, cacheTimeout time.Duration) (*juiceFS, error) {
 	pid, uid, gid = uint32(os.Getpid()), uint32(utils.GetCurrentUID()), uint32(utils.GetCurrentGID())
 	if runtime.GOOS == "windows" && utils.IsWinAdminOrElevatedPrivilege() {
 		uid = 0
 		gid = 0
 	}
-	metaUrl := os.Getenv(endpoint)
-	if metaUrl == "" {
-		metaUrl = endpoint
-	}
 	metaConf := meta.DefaultConf()
 	metaConf.MaxDeletes = 10
 	metaConf.NoBGJob = true
@@ -530,6 +542,8 @@ func newJFS(endpoint, accessKey, secretKey, token string) (object.ObjectStorage,
 	}
 	metaCli.OnReload(func(fmt *meta.Format) {
 		store.UpdateLimit(fmt.UploadLimit, fmt.DownloadLimit)
+		store.UpdateCompress(fmt.Compression, fmt.UntaggedCompress)
+		updateDedup(store, fmt)
 	})
 
 	vfsConf := &vfs.Config{
@@ -537,8 +551,8 @@ func newJFS(endpoint, accessKey, secretKey, token string) (object.ObjectStorage,
 		Format:          *format,
 		Version:         version.Version(),
 		Chunk:           chunkConf,
-		AttrTimeout:     time.Second,
-		DirEntryTimeout: time.Second,
+		AttrTimeout:     cacheTimeout,
+		DirEntryTimeout: cacheTimeout,
 		Mountpoint:      cliCtx.String("mountpoint"),
 	}
 
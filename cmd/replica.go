@@ -0,0 +1,193 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/juicedata/juicefs/pkg/object"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdReplica() *cli.Command {
+	return &cli.Command{
+		Name:            "replica",
+		Category:        "ADMIN",
+		Usage:           "manage replicas of the object storage",
+		ArgsUsage:       "META-URL",
+		HideHelpCommand: true,
+		Description: `
+Objects are written into the primary object storage and all the replicas, and read from the replicas
+when the primary fails or does not respond in time. By default the writes are replicated synchronously,
+run "juicefs config --replica-async" to replicate them in background, the pending replications are
+persisted under the default cache directory. Existing objects are not copied into a new replica,
+please copy them with "juicefs sync". Clients older than 1.5.0 can't connect after a replica is added.
+
+Examples:
+$ juicefs replica add redis://localhost --storage s3 --bucket https://mybucket.s3.us-east-2.amazonaws.com --access-key ABC --secret-key XYZ
+$ juicefs replica list redis://localhost
+$ juicefs replica remove redis://localhost --bucket https://mybucket.s3.us-east-2.amazonaws.com`,
+		Subcommands: []*cli.Command{
+			{
+				Name:      "add",
+				Usage:     "add a replica of the object storage",
+				ArgsUsage: "META-URL",
+				Action:    addReplica,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "storage",
+						Value: "file",
+						Usage: "object storage type (e.g. s3, gs, oss, cos)",
+					},
+					&cli.StringFlag{
+						Name:     "bucket",
+						Usage:    "the bucket URL of the replica",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "access-key",
+						Usage: "access key for the replica",
+					},
+					&cli.StringFlag{
+						Name:  "secret-key",
+						Usage: "secret key for the replica",
+					},
+					&cli.StringFlag{
+						Name:  "session-token",
+						Usage: "session token for the replica",
+					},
+				},
+			},
+			{
+				Name:      "list",
+				Usage:     "list replicas of the object storage",
+				ArgsUsage: "META-URL",
+				Action:    listReplicas,
+			},
+			{
+				Name:      "remove",
+				Usage:     "remove a replica of the object storage",
+				ArgsUsage: "META-URL",
+				Action:    removeReplica,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "bucket",
+						Usage:    "the bucket URL of the replica",
+						Required: true,
+					},
+				},
+			},
+		},
+	}
+}
+
+// loadReplicaFormat loads the setting of volume with secrets decrypted, and returns whether they
+// should be encrypted when saved.
+func loadReplicaFormat(ctx *cli.Context) (meta.Meta, *meta.Format, bool) {
+	setup(ctx, 1)
+	removePassword(ctx.Args().Get(0))
+	m := meta.NewClient(ctx.Args().Get(0), nil)
+	format, err := m.Load(true)
+	if err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	encrypted := format.KeyEncrypted
+	if err = format.Decrypt(); err != nil {
+		logger.Warnf("decrypt secrets: %s", err)
+	}
+	return m, format, encrypted
+}
+
+func saveReplicaFormat(m meta.Meta, format *meta.Format, encrypted bool) {
+	if encrypted {
+		if err := format.Encrypt(); err != nil {
+			logger.Fatalf("Format encrypt: %s", err)
+		}
+	}
+	if err := m.Init(format, false); err != nil {
+		logger.Fatalf("save setting: %s", err)
+	}
+}
+
+func addReplica(ctx *cli.Context) error {
+	m, format, encrypted := loadReplicaFormat(ctx)
+	replica := meta.Replica{
+		Storage:      ctx.String("storage"),
+		Bucket:       ctx.String("bucket"),
+		AccessKey:    ctx.String("access-key"),
+		SecretKey:    ctx.String("secret-key"),
+		SessionToken: ctx.String("session-token"),
+	}
+	if replica.Bucket == format.Bucket {
+		return fmt.Errorf("replica %s is the primary storage", replica.Bucket)
+	}
+	for _, r := range format.Replicas {
+		if r.Bucket == replica.Bucket {
+			return fmt.Errorf("replica %s exists already", replica.Bucket)
+		}
+	}
+	store, err := object.CreateStorage(strings.ToLower(replica.Storage), replica.Bucket, replica.AccessKey, replica.SecretKey, replica.SessionToken)
+	if err != nil {
+		return fmt.Errorf("create replica %s: %s", replica.Bucket, err)
+	}
+	if err = test(context.Background(), object.WithPrefix(store, format.Name+"/")); err != nil {
+		return fmt.Errorf("storage %s is not configured correctly: %s", store, err)
+	}
+	format.Replicas = append(format.Replicas, replica)
+	// old clients write into the primary only
+	format.MinClientVersion = maxVersion(format.MinClientVersion, "1.5.0-A")
+	saveReplicaFormat(m, format, encrypted)
+	logger.Infof("Replica %s is added, existing objects should be copied into it with `juicefs sync`", store)
+	return nil
+}
+
+func listReplicas(ctx *cli.Context) error {
+	_, format, _ := loadReplicaFormat(ctx)
+	results := [][]string{{"#", "storage", "bucket", "access key"}}
+	for i, r := range format.Replicas {
+		results = append(results, []string{strconv.Itoa(i + 1), r.Storage, r.Bucket, r.AccessKey})
+	}
+	printResult(results, 1, false)
+	mode := "sync"
+	if format.ReplicaAsync {
+		mode = "async"
+	}
+	fmt.Printf("mode: %s, primary timeout: %dms (0 means default)\n", mode, format.ReplicaTimeout)
+	return nil
+}
+
+func removeReplica(ctx *cli.Context) error {
+	m, format, encrypted := loadReplicaFormat(ctx)
+	bucket := ctx.String("bucket")
+	replicas := format.Replicas[:0:0]
+	for _, r := range format.Replicas {
+		if r.Bucket != bucket {
+			replicas = append(replicas, r)
+		}
+	}
+	if len(replicas) == len(format.Replicas) {
+		return fmt.Errorf("replica %s is not found", bucket)
+	}
+	format.Replicas = replicas
+	saveReplicaFormat(m, format, encrypted)
+	logger.Infof("Replica %s is removed", bucket)
+	return nil
+}
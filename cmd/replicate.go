@@ -0,0 +1,456 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/meta"
+	osync "github.com/juicedata/juicefs/pkg/sync"
+	"github.com/juicedata/juicefs/pkg/utils"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdReplicate() *cli.Command {
+	return &cli.Command{
+		Name:      "replicate",
+		Action:    replicate,
+		Category:  "TOOL",
+		Usage:     "Replicate the changes of a volume to another one",
+		ArgsUsage: "SRC-META-URL DST-META-URL",
+		Description: `
+Tail the changelog of the source volume and apply the same changes to the target volume, the data
+of changed files is copied in the same way as "juicefs sync". The changelog must be enabled on the
+source volume via "juicefs config SRC-META-URL --changelog".
+
+The last applied version is kept in a changelog consumer group of the source volume, so replication
+resumes from where it stopped, and the entries are not cleaned up before they are applied.
+The target volume should be a copy of the source one before replication starts, for example, loaded
+from a metadata backup (use the version recorded in the backup as --from), or synced with "juicefs sync".
+
+Examples:
+# Start from the version recorded in the metadata backup
+$ juicefs replicate redis://localhost/1 redis://dr-host/1 --from 1024
+
+# Resume replication
+$ juicefs replicate redis://localhost/1 redis://dr-host/1`,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "group",
+				Usage: "name of the changelog consumer group (default: replicate-<name of the target volume>)",
+			},
+			&cli.Int64Flag{
+				Name:  "from",
+				Usage: "apply the changes after this version, instead of the last applied one",
+			},
+		},
+	}
+}
+
+func replicate(c *cli.Context) error {
+	setup(c, 2)
+	cliCtx = c
+	srcURL, dstURL := c.Args().Get(0), c.Args().Get(1)
+	removePassword(srcURL, dstURL)
+	src, err := openJFS(srcURL, 0) // the source is changed by other clients, don't cache anything
+	if err != nil {
+		return fmt.Errorf("open %s: %s", utils.RemovePassword(srcURL), err)
+	}
+	defer src.Shutdown()
+	dst, err := openJFS(dstURL, time.Second)
+	if err != nil {
+		return fmt.Errorf("open %s: %s", utils.RemovePassword(dstURL), err)
+	}
+	defer dst.Shutdown()
+
+	srcFormat, dstFormat := src.jfs.Meta().GetFormat(), dst.jfs.Meta().GetFormat()
+	if !srcFormat.ChangeLog {
+		return fmt.Errorf("changelog is not enabled, use `juicefs config %s --changelog` to enable it", srcURL)
+	}
+	if srcFormat.UUID == dstFormat.UUID {
+		return fmt.Errorf("can't replicate volume %s to itself", srcFormat.Name)
+	}
+	group := c.String("group")
+	if group == "" {
+		group = "replicate-" + dstFormat.Name
+	}
+	ctx := meta.WrapWithCancel(context.Background(), 0, 0, []uint32{0})
+	if c.IsSet("from") {
+		if st := src.jfs.Meta().AckChangelog(ctx, group, c.Int64("from")); st != 0 {
+			return fmt.Errorf("reset consumer group %s: %s", group, st)
+		}
+	}
+
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		sig := <-signalChan
+		logger.Infof("Received signal %s, stop replicating", sig)
+		ctx.Cancel()
+	}()
+
+	r := newReplicator(src, dst)
+	progress := time.NewTicker(time.Minute)
+	defer progress.Stop()
+	logger.Infof("Replicate %s to %s as consumer group %s", srcFormat.Name, dstFormat.Name, group)
+	err = meta.ConsumeChangelog(ctx, src.jfs.Meta(), group, func(e *meta.ChangelogEntry) error {
+		if err := r.apply(ctx, e); err != nil {
+			return fmt.Errorf("apply %s: %s", e, err)
+		}
+		select {
+		case <-progress.C:
+			logger.Infof("Applied %d entries, copied %d files; last version: %d", atomic.LoadUint64(&r.applied), atomic.LoadUint64(&r.copied), e.Version)
+		default:
+		}
+		return nil
+	})
+	if ctx.Canceled() {
+		err = nil
+	}
+	logger.Infof("Applied %d entries, copied %d files", atomic.LoadUint64(&r.applied), atomic.LoadUint64(&r.copied))
+	return err
+}
+
+// replicator applies the changelog of a volume to another one. Instead of replaying the
+// operations literally, it makes the paths touched by an entry on the target the same as
+// the ones on the source, which is idempotent, so entries can be applied more than once.
+type replicator struct {
+	src, dst *juiceFS
+	dirs     map[meta.Ino]string // paths of source directories
+	applied  uint64
+	copied   uint64
+}
+
+func newReplicator(src, dst *juiceFS) *replicator {
+	osync.InitForCopyData()
+	return &replicator{src: src, dst: dst, dirs: make(map[meta.Ino]string)}
+}
+
+func isInternalPath(p string) bool {
+	for _, name := range []string{meta.TrashName, meta.SnapshotName} {
+		if p == "/"+name || strings.HasPrefix(p, "/"+name+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// dirPath returns the current path of a source directory, empty if it's gone or internal.
+func (r *replicator) dirPath(ctx meta.Context, ino meta.Ino) string {
+	if ino == meta.RootInode {
+		return "/"
+	}
+	if p, ok := r.dirs[ino]; ok {
+		return p
+	}
+	var p string
+	if ps := r.src.jfs.Meta().GetPaths(ctx, ino); len(ps) > 0 && strings.HasPrefix(ps[0], "/") && !isInternalPath(ps[0]) {
+		p = ps[0]
+	}
+	if len(r.dirs) > 100000 {
+		r.dirs = make(map[meta.Ino]string)
+	}
+	r.dirs[ino] = p
+	return p
+}
+
+func (r *replicator) entryPath(ctx meta.Context, parent meta.Ino, name string) string {
+	if dir := r.dirPath(ctx, parent); dir != "" && name != "" {
+		return path.Join(dir, name)
+	}
+	return ""
+}
+
+func (r *replicator) apply(ctx meta.Context, e *meta.ChangelogEntry) error {
+	var err error
+	switch e.Op {
+	case "CREATE", "CLONE", "ATTACH":
+		err = r.sync(ctx, r.entryPath(ctx, e.Parent, e.Name), e.Op == "CLONE")
+	case "LINK":
+		err = r.link(ctx, e.Inode, r.entryPath(ctx, e.Parent, e.Name))
+	case "UNLINK", "RMDIR":
+		if e.Op == "RMDIR" {
+			delete(r.dirs, e.Inode)
+		}
+		err = r.sync(ctx, r.entryPath(ctx, e.Parent, e.Name), false)
+	case "UNLINKBATCH": // parent,names...,trash,updateParent
+		for i := 1; i < len(e.Args)-2 && err == nil; i++ {
+			err = r.sync(ctx, r.entryPath(ctx, e.Parent, e.Args[i]), false)
+		}
+	case "MOVE":
+		r.dirs = make(map[meta.Ino]string) // paths of all the directories under it are changed
+		err = r.move(ctx, r.entryPath(ctx, e.Parent, e.Name), r.entryPath(ctx, e.NewParent, e.NewName))
+	case "SETATTR", "SETXATTR", "REMOVEXATTR", "WRITE", "TRUNCATE", "FALLOCATE", "COPYFILERANGE":
+		for _, p := range r.src.jfs.Meta().GetPaths(ctx, e.Inode) {
+			if strings.HasPrefix(p, "/") && !isInternalPath(p) {
+				if err = r.sync(ctx, p, false); err != nil {
+					break
+				}
+			}
+		}
+	default:
+		return nil
+	}
+	atomic.AddUint64(&r.applied, 1)
+	return err
+}
+
+// ignorable errors are caused by the changes after the entry, which will be fixed by the later entries
+func ignorable(err error) bool {
+	return err == syscall.ENOENT || err == syscall.ENOTDIR || err == syscall.EEXIST || err == syscall.ENOTEMPTY
+}
+
+func (r *replicator) link(ctx meta.Context, inode meta.Ino, p string) error {
+	if p == "" {
+		return nil
+	}
+	for _, other := range r.src.jfs.Meta().GetPaths(ctx, inode) {
+		if other == p || !strings.HasPrefix(other, "/") || isInternalPath(other) {
+			continue
+		}
+		if fi, st := r.dst.jfs.Lstat(ctx, other); st == 0 && !fi.IsDir() {
+			if _, st = r.dst.jfs.Lstat(ctx, p); st == 0 {
+				if st = r.dst.jfs.Delete(ctx, p); st != 0 {
+					return st
+				}
+			}
+			if st = r.dst.jfs.Link(ctx, other, p); st == 0 {
+				return r.sync(ctx, p, false)
+			}
+		}
+	}
+	return r.sync(ctx, p, false)
+}
+
+func (r *replicator) move(ctx meta.Context, oldPath, newPath string) error {
+	if oldPath == "" || newPath == "" {
+		for _, p := range []string{oldPath, newPath} {
+			if err := r.sync(ctx, p, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	_, srcSt := r.src.jfs.Lstat(ctx, oldPath)
+	_, dstSt := r.dst.jfs.Lstat(ctx, oldPath)
+	if srcSt == syscall.ENOENT && dstSt == 0 {
+		if _, st := r.dst.jfs.Lstat(ctx, newPath); st == 0 {
+			if st = r.dst.jfs.Rmr(ctx, newPath, false, meta.RmrDefaultThreads); st != 0 && st != syscall.ENOENT {
+				return st
+			}
+		}
+		if st := r.dst.jfs.Rename(ctx, oldPath, newPath, 0); st != 0 && !ignorable(st) {
+			return st
+		}
+	}
+	if err := r.sync(ctx, oldPath, false); err != nil {
+		return err
+	}
+	return r.sync(ctx, newPath, false)
+}
+
+// sync makes the entry at p on the target the same as the one on the source,
+// it syncs the whole tree if recursive is set or the directory is missing in the target.
+func (r *replicator) sync(ctx meta.Context, p string, recursive bool) error {
+	if p == "" || p == "/" || isInternalPath(p) {
+		return nil
+	}
+	err := r.syncEntry(ctx, p, recursive)
+	if err != nil && ignorable(err) {
+		logger.Debugf("Sync %s: %s", p, err)
+		err = nil
+	}
+	return err
+}
+
+func (r *replicator) syncEntry(ctx meta.Context, p string, recursive bool) error {
+	sfi, st := r.src.jfs.Lstat(ctx, p)
+	dfi, dSt := r.dst.jfs.Lstat(ctx, p)
+	if st == syscall.ENOENT {
+		if dSt == 0 {
+			logger.Debugf("Remove %s", p)
+			if dSt = r.dst.jfs.Rmr(ctx, p, false, meta.RmrDefaultThreads); dSt != 0 {
+				return dSt
+			}
+		}
+		return nil
+	} else if st != 0 {
+		return st
+	}
+	if dSt != 0 && dSt != syscall.ENOENT {
+		return dSt
+	}
+	sattr := sfi.Attr()
+	if dSt == 0 && dfi.Attr().Typ != sattr.Typ {
+		if dSt = r.dst.jfs.Rmr(ctx, p, false, meta.RmrDefaultThreads); dSt != 0 {
+			return dSt
+		}
+		dSt = syscall.ENOENT
+	}
+	if dSt == syscall.ENOENT {
+		if _, pst := r.dst.jfs.Lstat(ctx, path.Dir(p)); pst == syscall.ENOENT {
+			if err := r.syncEntry(ctx, path.Dir(p), false); err != nil {
+				return err
+			}
+		}
+	}
+
+	key := p[1:]
+	switch sattr.Typ {
+	case meta.TypeDirectory:
+		if dSt == syscall.ENOENT {
+			if st = r.dst.jfs.Mkdir(ctx, p, sattr.Mode, 0); st != 0 {
+				return st
+			}
+			recursive = true
+		}
+	case meta.TypeSymlink:
+		target, st := r.src.jfs.Readlink(ctx, p)
+		if st != 0 {
+			return st
+		}
+		if dSt == 0 {
+			if old, st := r.dst.jfs.Readlink(ctx, p); st == 0 && bytes.Equal(old, target) {
+				break
+			}
+			if st = r.dst.jfs.Delete(ctx, p); st != 0 {
+				return st
+			}
+		}
+		if st = r.dst.jfs.Symlink(ctx, string(target), p); st != 0 {
+			return st
+		}
+	case meta.TypeFile:
+		if dSt == 0 && dfi.Size() == sfi.Size() && dfi.Attr().Mtime == sattr.Mtime && dfi.Attr().Mtimensec == sattr.Mtimensec {
+			break
+		}
+		logger.Debugf("Copy %s (%d bytes)", p, sfi.Size())
+		if _, err := osync.CopyData(r.src, r.dst, key, sfi.Size(), false); err != nil {
+			return err
+		}
+		atomic.AddUint64(&r.copied, 1)
+	default:
+		logger.Warnf("Skip %s: unsupported file type %d", p, sattr.Typ)
+		return nil
+	}
+	if err := r.syncAttr(ctx, p, sattr); err != nil {
+		return err
+	}
+	if recursive && sattr.Typ == meta.TypeDirectory {
+		f, st := r.src.jfs.Open(ctx, p, 0)
+		if st != 0 {
+			return st
+		}
+		entries, st := f.Readdir(ctx, 0)
+		_ = f.Close(ctx)
+		if st != 0 {
+			return st
+		}
+		for _, e := range entries {
+			if err := r.sync(ctx, path.Join(p, e.Name()), true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *replicator) syncAttr(ctx meta.Context, p string, sattr *meta.Attr) error {
+	fi, st := r.dst.jfs.Lstat(ctx, p)
+	if st != 0 {
+		return st
+	}
+	dattr := fi.Attr()
+	f, st := r.dst.jfs.Lopen(ctx, p, 0)
+	if st != 0 {
+		return st
+	}
+	defer f.Close(ctx)
+	if sattr.Typ != meta.TypeSymlink && dattr.Mode != sattr.Mode {
+		if st = f.Chmod(ctx, sattr.Mode); st != 0 {
+			return st
+		}
+	}
+	if dattr.Uid != sattr.Uid || dattr.Gid != sattr.Gid {
+		if st = f.Chown(ctx, sattr.Uid, sattr.Gid); st != 0 {
+			return st
+		}
+	}
+	if err := r.syncXattrs(ctx, p); err != nil {
+		return err
+	}
+	if dattr.Mtime != sattr.Mtime || dattr.Mtimensec != sattr.Mtimensec || dattr.Atime != sattr.Atime || dattr.Atimensec != sattr.Atimensec {
+		return toError(f.Utime2(ctx, sattr.Atime, int64(sattr.Atimensec), sattr.Mtime, int64(sattr.Mtimensec)))
+	}
+	return nil
+}
+
+func listXattrs(ctx meta.Context, j *juiceFS, p string) (map[string][]byte, error) {
+	names, st := j.jfs.ListXattr(ctx, p)
+	if st == syscall.ENOTSUP || st == syscall.ENODATA {
+		return nil, nil
+	} else if st != 0 {
+		return nil, st
+	}
+	xattrs := make(map[string][]byte)
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+		v, st := j.jfs.GetXattr(ctx, p, string(name))
+		if st != 0 && st != syscall.ENODATA {
+			return nil, st
+		}
+		xattrs[string(name)] = v
+	}
+	return xattrs, nil
+}
+
+func (r *replicator) syncXattrs(ctx meta.Context, p string) error {
+	sx, err := listXattrs(ctx, r.src, p)
+	if err != nil {
+		return err
+	}
+	dx, err := listXattrs(ctx, r.dst, p)
+	if err != nil {
+		return err
+	}
+	for name, v := range sx {
+		if old, ok := dx[name]; !ok || !bytes.Equal(old, v) {
+			if st := r.dst.jfs.SetXattr(ctx, p, name, v, 0); st != 0 {
+				return st
+			}
+		}
+	}
+	for name := range dx {
+		if _, ok := sx[name]; !ok {
+			if st := r.dst.jfs.RemoveXattr(ctx, p, name); st != 0 && st != syscall.ENODATA {
+				return st
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/chunk"
+	"github.com/juicedata/juicefs/pkg/fs"
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/juicedata/juicefs/pkg/object"
+	"github.com/juicedata/juicefs/pkg/utils"
+	"github.com/juicedata/juicefs/pkg/vfs"
+)
+
+func newTestJuiceFS(t *testing.T, metaUrl string) *juiceFS {
+	m := meta.NewClient(metaUrl, nil)
+	format := &meta.Format{Name: "test", BlockSize: 4096, Capacity: 1 << 30}
+	if err := m.Init(format, true); err != nil {
+		t.Fatalf("init %s: %s", metaUrl, err)
+	}
+	var conf = vfs.Config{
+		Meta: meta.DefaultConf(),
+		Chunk: &chunk.Config{
+			BlockSize:   format.BlockSize << 10,
+			MaxUpload:   1,
+			MaxDownload: 200,
+			BufferSize:  100 << 20,
+		},
+	}
+	objStore, _ := object.CreateStorage("mem", "", "", "", "")
+	store := chunk.NewCachedStore(objStore, *conf.Chunk, nil)
+	jfs, err := fs.NewFileSystem(&conf, m, store, nil)
+	if err != nil {
+		t.Fatalf("initialize failed: %s", err)
+	}
+	return &juiceFS{object.DefaultObjectStorage{}, "test", uint16(utils.GetUmask()), jfs}
+}
+
+func readJFS(t *testing.T, j *juiceFS, key string) string {
+	r, err := j.Get(context.Background(), key, 0, -1)
+	if err != nil {
+		t.Fatalf("get %s: %s", key, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read %s: %s", key, err)
+	}
+	return string(data)
+}
+
+func TestReplicator(t *testing.T) {
+	src := newTestJuiceFS(t, "memkv://")
+	dst := newTestJuiceFS(t, "sqlite3://"+filepath.Join(t.TempDir(), "dst.db"))
+	ctx := meta.Background()
+	if err := src.Put(context.Background(), "d/f", strings.NewReader("hello")); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	if st := src.jfs.Symlink(ctx, "f", "/d/l"); st != 0 {
+		t.Fatalf("symlink: %s", st)
+	}
+	if st := src.jfs.SetXattr(ctx, "/d/f", "user.k", []byte("v"), 0); st != 0 {
+		t.Fatalf("setxattr: %s", st)
+	}
+	if err := src.Chmod("d/f", 0600); err != nil {
+		t.Fatalf("chmod: %s", err)
+	}
+	d, _ := src.jfs.Stat(ctx, "/d")
+	f, _ := src.jfs.Stat(ctx, "/d/f")
+
+	r := newReplicator(src, dst)
+	// a directory missing in the target is copied as a whole
+	if err := r.apply(ctx, &meta.ChangelogEntry{Op: "CREATE", Parent: meta.RootInode, Name: "d"}); err != nil {
+		t.Fatalf("apply create: %s", err)
+	}
+	if s := readJFS(t, dst, "d/f"); s != "hello" {
+		t.Fatalf("content of d/f: %q", s)
+	}
+	if fi, st := dst.jfs.Stat(ctx, "/d/f"); st != 0 || fi.Mode().Perm() != 0600 || fi.Mtime() != f.Mtime() {
+		t.Fatalf("stat d/f: %+v %s", fi, st)
+	}
+	if target, st := dst.jfs.Readlink(ctx, "/d/l"); st != 0 || string(target) != "f" {
+		t.Fatalf("readlink d/l: %s %s", target, st)
+	}
+	if v, st := dst.jfs.GetXattr(ctx, "/d/f", "user.k"); st != 0 || string(v) != "v" {
+		t.Fatalf("getxattr d/f: %s %s", v, st)
+	}
+
+	time.Sleep(10 * time.Millisecond) // make sure mtime is changed
+	if err := src.Put(context.Background(), "d/f", strings.NewReader("hello world")); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	f, _ = src.jfs.Stat(ctx, "/d/f")
+	if err := r.apply(ctx, &meta.ChangelogEntry{Op: "WRITE", Inode: f.Inode()}); err != nil {
+		t.Fatalf("apply write: %s", err)
+	}
+	if s := readJFS(t, dst, "d/f"); s != "hello world" {
+		t.Fatalf("content of d/f: %q", s)
+	}
+
+	if st := src.jfs.Rename(ctx, "/d/f", "/d/g", 0); st != 0 {
+		t.Fatalf("rename: %s", st)
+	}
+	move := &meta.ChangelogEntry{Op: "MOVE", Parent: d.Inode(), Name: "f", NewParent: d.Inode(), NewName: "g", Inode: f.Inode()}
+	if err := r.apply(ctx, move); err != nil {
+		t.Fatalf("apply move: %s", err)
+	}
+	if _, st := dst.jfs.Stat(ctx, "/d/f"); st != syscall.ENOENT {
+		t.Fatalf("d/f should be moved: %s", st)
+	}
+	if s := readJFS(t, dst, "d/g"); s != "hello world" {
+		t.Fatalf("content of d/g: %q", s)
+	}
+
+	if st := src.jfs.Rmr(ctx, "/d", true, meta.RmrDefaultThreads); st != 0 {
+		t.Fatalf("rmr: %s", st)
+	}
+	rmdir := &meta.ChangelogEntry{Op: "RMDIR", Parent: meta.RootInode, Name: "d", Inode: d.Inode()}
+	for i := 0; i < 2; i++ { // applying an entry twice is harmless
+		if err := r.apply(ctx, rmdir); err != nil {
+			t.Fatalf("apply rmdir: %s", err)
+		}
+	}
+	if _, st := dst.jfs.Stat(ctx, "/d"); st != syscall.ENOENT {
+		t.Fatalf("d should be removed: %s", st)
+	}
+	if err := r.apply(ctx, move); err != nil {
+		t.Fatalf("apply move again: %s", err)
+	}
+}
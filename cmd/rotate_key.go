@@ -0,0 +1,212 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/juicedata/juicefs/pkg/object"
+	"github.com/juicedata/juicefs/pkg/utils"
+)
+
+const (
+	rotateBatch = 1000
+	// the largest heartbeat of the clients, see meta.Config.SelfCheck
+	rotateMaxHeartbeat = time.Minute * 10
+)
+
+// rotateKeyDelay returns how long to wait before switching to the pending key. The clients reload
+// the setting in every heartbeat, so it's twice the largest heartbeat among the active sessions
+// (at least one minute), then all of them should have loaded the new key.
+func rotateKeyDelay(m meta.Meta) time.Duration {
+	delay := time.Minute
+	sessions, err := m.ListSessions()
+	if err != nil {
+		logger.Warnf("list sessions: %s, assume the largest heartbeat", err)
+		return rotateMaxHeartbeat * 2
+	}
+	now := time.Now()
+	for _, s := range sessions {
+		// a live session expires within 5 heartbeats after the last refresh
+		heartbeat := min(s.Expire.Sub(now)/4, rotateMaxHeartbeat)
+		delay = max(delay, heartbeat*2)
+	}
+	return delay
+}
+
+// rotateCheckpoint is the progress of key rotation, all the objects up to Marker are done.
+type rotateCheckpoint struct {
+	Key       string // fingerprint of the current key
+	Reencrypt bool
+	Marker    string
+}
+
+func rotateCheckpointPath(format *meta.Format) string {
+	return filepath.Join(getDefaultCacheDir(), format.UUID, "rotate-key.checkpoint")
+}
+
+func loadRotateCheckpoint(path string, cp *rotateCheckpoint) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("read checkpoint %s: %s", path, err)
+		}
+		return
+	}
+	var saved rotateCheckpoint
+	if err = json.Unmarshal(data, &saved); err != nil {
+		logger.Warnf("invalid checkpoint %s: %s", path, err)
+	} else if saved.Key == cp.Key && saved.Reencrypt == cp.Reencrypt {
+		cp.Marker = saved.Marker
+	}
+}
+
+func saveRotateCheckpoint(path string, cp *rotateCheckpoint) {
+	data, _ := json.Marshal(cp)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		logger.Warnf("create directory for checkpoint: %s", err)
+		return
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		logger.Warnf("write checkpoint %s: %s", tmp, err)
+	} else if err = os.Rename(tmp, path); err != nil {
+		logger.Warnf("rename checkpoint %s: %s", tmp, err)
+	}
+}
+
+// rotateEncryptKey rewrites all the objects of the volume which are not encrypted by the current key.
+// The objects are processed in order, and the last finished one is saved as a checkpoint under the
+// default cache directory, so it can be resumed after interrupted.
+func rotateEncryptKey(format *meta.Format, reencrypt bool, threads int) error {
+	encryptor, err := newEncryptor(format)
+	if err != nil {
+		return err
+	}
+	raw := *format
+	raw.EncryptKey = ""
+	blob, err := createStorage(raw)
+	if err != nil {
+		return err
+	}
+	defer object.Shutdown(blob)
+
+	sum := sha256.Sum256([]byte(format.EncryptKey))
+	cp := rotateCheckpoint{Key: hex.EncodeToString(sum[:8]), Reencrypt: reencrypt}
+	cpPath := rotateCheckpointPath(format)
+	loadRotateCheckpoint(cpPath, &cp)
+	if cp.Marker != "" {
+		logger.Infof("Resume the key rotation after %s", cp.Marker)
+	}
+
+	ctx := context.Background()
+	objs, err := object.ListAll(ctx, blob, "", cp.Marker, true, true)
+	if err != nil {
+		return fmt.Errorf("list objects: %s", err)
+	}
+	progress := utils.NewProgress(false)
+	scanned := progress.AddCountSpinner("Scanned objects")
+	rotated := progress.AddCountSpinner("Rotated objects")
+	var failed atomic.Int64
+	rotate := func(key string) {
+		in, err := blob.Get(ctx, key, 0, -1)
+		if err == nil {
+			var data []byte
+			data, err = io.ReadAll(in)
+			_ = in.Close()
+			if err == nil {
+				if data, err = encryptor.Rotate(data, reencrypt); err == nil && data != nil {
+					if err = blob.Put(ctx, key, bytes.NewReader(data)); err == nil {
+						rotated.Increment()
+					}
+				}
+			}
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			logger.Warnf("Rotate key of object %s: %s", key, err)
+			failed.Add(1)
+		}
+	}
+
+	keys := make(chan string, threads)
+	var batch []string
+	flush := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < threads; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for key := range keys {
+					rotate(key)
+				}
+			}()
+		}
+		for _, key := range batch {
+			keys <- key
+		}
+		close(keys)
+		wg.Wait()
+		keys = make(chan string, threads)
+		// objects after a failed one should be checked again
+		if failed.Load() == 0 && len(batch) > 0 {
+			cp.Marker = batch[len(batch)-1]
+			saveRotateCheckpoint(cpPath, &cp)
+		}
+		batch = batch[:0]
+	}
+	for obj := range objs {
+		if obj == nil {
+			err = fmt.Errorf("list objects from %s failed", blob)
+			break
+		}
+		scanned.Increment()
+		if obj.IsDir() || obj.Size() == 0 {
+			continue
+		}
+		if batch = append(batch, obj.Key()); len(batch) >= rotateBatch {
+			flush()
+		}
+	}
+	flush()
+	progress.Done()
+
+	if err != nil {
+		return err
+	}
+	if n := failed.Load(); n > 0 {
+		return fmt.Errorf("failed to rotate %d objects, please run it again", n)
+	}
+	_ = os.Remove(cpPath)
+	logger.Infof("All the %d objects are encrypted by the current key (%d rotated)", scanned.Current(), rotated.Current())
+	if len(format.RetiredKeys) > 0 {
+		logger.Infof("The retired keys can be removed by `juicefs config --drop-retired-keys` when all the clients are using the current key")
+	}
+	return nil
+}
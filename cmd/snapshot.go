@@ -0,0 +1,215 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/juicedata/juicefs/pkg/utils"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdSnapshot() *cli.Command {
+	return &cli.Command{
+		Name:            "snapshot",
+		Category:        "ADMIN",
+		Usage:           "manage read-only snapshots of directories",
+		ArgsUsage:       "META-URL",
+		HideHelpCommand: true,
+		Description: `
+A snapshot is a read-only copy of a directory which shares all the data with the original one,
+so it's cheap to create and the data is kept until the snapshot is deleted.
+Snapshots can be accessed in the '.snapshots' directory under the root of the volume.
+
+Examples:
+$ juicefs snapshot create redis://localhost /dir1 snap1
+$ juicefs snapshot list redis://localhost
+$ juicefs snapshot restore redis://localhost snap1 /dir1-restored
+$ juicefs snapshot delete redis://localhost snap1`,
+		Subcommands: []*cli.Command{
+			{
+				Name:      "create",
+				Usage:     "create a snapshot of a directory",
+				ArgsUsage: "META-URL PATH NAME",
+				Action:    createSnapshot,
+				Flags: []cli.Flag{
+					&cli.UintFlag{
+						Name:  "threads",
+						Value: 4,
+						Usage: "number of threads to clone the directory",
+					},
+				},
+			},
+			{
+				Name:      "list",
+				Usage:     "list all the snapshots",
+				ArgsUsage: "META-URL",
+				Action:    listSnapshots,
+			},
+			{
+				Name:      "delete",
+				Usage:     "delete a snapshot",
+				ArgsUsage: "META-URL NAME",
+				Action:    deleteSnapshot,
+			},
+			{
+				Name:      "restore",
+				Usage:     "restore a snapshot into a new writable directory",
+				ArgsUsage: "META-URL NAME PATH",
+				Action:    restoreSnapshot,
+				Flags: []cli.Flag{
+					&cli.UintFlag{
+						Name:  "threads",
+						Value: 4,
+						Usage: "number of threads to clone the snapshot",
+					},
+				},
+			},
+		},
+	}
+}
+
+func openSnapshotMeta(ctx *cli.Context) meta.Meta {
+	removePassword(ctx.Args().Get(0))
+	m := meta.NewClient(ctx.Args().Get(0), nil)
+	if _, err := m.Load(true); err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	if err := m.NewSession(false); err != nil {
+		logger.Fatalf("new session: %s", err)
+	}
+	return m
+}
+
+func trackProgress(name string, count, total *uint64) func() {
+	progress := utils.NewProgress(false)
+	bar := progress.AddCountBar(name, 0)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			if total != nil {
+				bar.SetTotal(int64(atomic.LoadUint64(total)))
+			}
+			bar.SetCurrent(int64(atomic.LoadUint64(count)))
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+		bar.Done()
+		progress.Done()
+	}
+}
+
+func createSnapshot(ctx *cli.Context) error {
+	setup(ctx, 3)
+	m := openSnapshotMeta(ctx)
+	defer func() { _ = m.CloseSession() }()
+	p, name := ctx.Args().Get(1), ctx.Args().Get(2)
+	var ino meta.Ino
+	var attr meta.Attr
+	if eno := m.Resolve(meta.Background(), meta.RootInode, p, &ino, &attr, true); eno != 0 {
+		return fmt.Errorf("resolve %s: %s", p, eno)
+	}
+	var count, total uint64
+	done := trackProgress("Cloning entries", &count, &total)
+	eno := m.CreateSnapshot(meta.Background(), ino, name, uint8(ctx.Uint("threads")), &count, &total)
+	done()
+	if eno != 0 {
+		return fmt.Errorf("create snapshot %s of %s: %s", name, p, eno)
+	}
+	logger.Infof("Snapshot %s of %s is created with %d entries", name, p, count)
+	return nil
+}
+
+func listSnapshots(ctx *cli.Context) error {
+	setup(ctx, 1)
+	removePassword(ctx.Args().Get(0))
+	m := meta.NewClient(ctx.Args().Get(0), nil)
+	if _, err := m.Load(true); err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	snaps, eno := m.ListSnapshots(meta.Background())
+	if eno != 0 {
+		return fmt.Errorf("list snapshots: %s", eno)
+	}
+	results := make([][]string, 0, 1+len(snaps))
+	results = append(results, []string{"Name", "Path", "Inode", "Created"})
+	for _, s := range snaps {
+		created := time.Unix(s.Created, 0).Format("2006-01-02 15:04:05")
+		results = append(results, []string{s.Name, s.Path, fmt.Sprintf("%d", s.Inode), created})
+	}
+	printResult(results, 2, false)
+	return nil
+}
+
+func deleteSnapshot(ctx *cli.Context) error {
+	setup(ctx, 2)
+	m := openSnapshotMeta(ctx)
+	defer func() { _ = m.CloseSession() }()
+	name := ctx.Args().Get(1)
+	var count uint64
+	done := trackProgress("Removing entries", &count, nil)
+	eno := m.DeleteSnapshot(meta.Background(), name, &count)
+	done()
+	if eno != 0 {
+		return fmt.Errorf("delete snapshot %s: %s", name, eno)
+	}
+	logger.Infof("Snapshot %s is deleted", name)
+	return nil
+}
+
+func restoreSnapshot(ctx *cli.Context) error {
+	setup(ctx, 3)
+	m := openSnapshotMeta(ctx)
+	defer func() { _ = m.CloseSession() }()
+	name, dst := ctx.Args().Get(1), path.Clean("/"+ctx.Args().Get(2))
+	if dst == "/" {
+		return fmt.Errorf("can't restore snapshot into the root directory")
+	}
+	var parent, ino meta.Ino
+	var attr meta.Attr
+	if eno := m.Resolve(meta.Background(), meta.RootInode, path.Dir(dst), &parent, &attr, true); eno != 0 {
+		return fmt.Errorf("resolve %s: %s", path.Dir(dst), eno)
+	}
+	if eno := m.Lookup(meta.Background(), parent, path.Base(dst), &ino, &attr, false); eno == 0 {
+		return fmt.Errorf("%s: %s", dst, syscall.EEXIST)
+	}
+	var count, total uint64
+	done := trackProgress("Cloning entries", &count, &total)
+	eno := m.RestoreSnapshot(meta.Background(), name, parent, path.Base(dst), uint8(ctx.Uint("threads")), &count, &total)
+	done()
+	if eno != 0 {
+		return fmt.Errorf("restore snapshot %s to %s: %s", name, dst, eno)
+	}
+	logger.Infof("Snapshot %s is restored to %s with %d entries", name, dst, count)
+	return nil
+}
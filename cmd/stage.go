@@ -0,0 +1,241 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/juicedata/juicefs/pkg/chunk"
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/juicedata/juicefs/pkg/utils"
+	"github.com/juicedata/juicefs/pkg/vfs"
+	"github.com/urfave/cli/v2"
+)
+
+func cmdStage() *cli.Command {
+	return &cli.Command{
+		Name:            "stage",
+		Category:        "TOOL",
+		Usage:           "Manage the staging blocks of writeback",
+		ArgsUsage:       "PATH",
+		HideHelpCommand: true,
+		Description: `
+With --writeback, the blocks are staged in the cache dir and uploaded in background. PATH is a mount
+point, or a cache dir (including the UUID of volume) of a client not running.
+
+Examples:
+# List the staging blocks of a mount point
+$ juicefs stage list /mnt/jfs
+
+# Upload them now, ignoring --upload-delay and --upload-hours
+$ juicefs stage upload /mnt/jfs
+
+# Copy the staging blocks of a crashed client into another dir, and upload them by a client using it as cache dir
+$ juicefs stage export /var/jfsCache/e3bc7f2a-... /backup/jfsCache/e3bc7f2a-...`,
+		Subcommands: []*cli.Command{
+			{
+				Name:      "list",
+				Usage:     "list the staging blocks",
+				ArgsUsage: "PATH",
+				Action:    listStaging,
+			},
+			{
+				Name:      "upload",
+				Usage:     "upload the staging blocks of a mount point now",
+				ArgsUsage: "MOUNTPOINT",
+				Action:    uploadStaging,
+			},
+			{
+				Name:      "export",
+				Usage:     "copy the staging blocks into another dir in the layout of cache dir",
+				ArgsUsage: "PATH DEST",
+				Action:    exportStaging,
+			},
+		},
+	}
+}
+
+// stagingDirs returns the dirs of staging blocks in a mount point or a cache dir.
+func stagingDirs(path string) []string {
+	raw, err := readConfig(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Fatalf("read config of %s: %s", path, err)
+		}
+		if _, err := os.Stat(filepath.Join(path, "rawstaging")); err != nil {
+			logger.Fatalf("%s is neither a mount point nor a cache dir with staging blocks", path)
+		}
+		return []string{filepath.Join(path, "rawstaging")}
+	}
+	var conf vfs.Config
+	if err = json.Unmarshal(raw, &conf); err != nil {
+		logger.Fatalf("parse config of %s: %s", path, err)
+	}
+	if !conf.Chunk.Writeback {
+		logger.Warnf("writeback is not enabled in %s", path)
+	}
+	var dirs []string
+	for _, d := range utils.SplitDir(conf.Chunk.CacheDir) {
+		if d != "memory" {
+			dirs = append(dirs, filepath.Join(d, "rawstaging"))
+		}
+	}
+	if r := conf.Chunk.StageReplica; r != "" && r != chunk.ReplicaPeer {
+		dirs = append(dirs, filepath.Join(r, "rawstaging"))
+	}
+	return dirs
+}
+
+type stagingBlock struct {
+	key   string
+	path  string
+	size  int
+	mtime time.Time
+}
+
+func walkStaging(dir string, fn func(b *stagingBlock)) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		key := filepath.ToSlash(path[len(dir)+1:])
+		size, _ := strconv.Atoi(key[strings.LastIndexByte(key, '_')+1:])
+		fn(&stagingBlock{key, path, size, fi.ModTime()})
+		return nil
+	})
+}
+
+func listStaging(ctx *cli.Context) error {
+	setup(ctx, 1)
+	var count, total uint64
+	for _, dir := range stagingDirs(ctx.Args().Get(0)) {
+		walkStaging(dir, func(b *stagingBlock) {
+			fmt.Printf("%s\t%s\t%s\t%s\n", b.key, humanize.IBytes(uint64(b.size)), time.Since(b.mtime).Truncate(time.Second), dir)
+			count++
+			total += uint64(b.size)
+		})
+	}
+	fmt.Printf("%d staging blocks (%s)\n", count, humanize.IBytes(total))
+	return nil
+}
+
+func uploadStaging(ctx *cli.Context) error {
+	setup(ctx, 1)
+	mp := ctx.Args().Get(0)
+	f, err := openController(mp)
+	if err != nil {
+		return fmt.Errorf("open control file of %s: %w", mp, err)
+	}
+	defer f.Close()
+
+	wb := utils.NewBuffer(8)
+	wb.Put32(meta.UploadStaging)
+	wb.Put32(0)
+	if _, err = f.Write(wb.Bytes()); err != nil {
+		logger.Fatalf("write message: %s", err)
+	}
+	progress := utils.NewProgress(false)
+	bar := progress.AddCountBar("Uploaded blocks", 0)
+	_, errno := readProgress(f, func(total, uploaded uint64) {
+		bar.SetTotal(int64(total))
+		bar.SetCurrent(int64(uploaded))
+	})
+	bar.Done()
+	progress.Done()
+
+	if errno == syscall.EINVAL {
+		logger.Fatalf("upload staging blocks is not supported, please upgrade and mount again")
+	}
+	if errno != 0 {
+		return fmt.Errorf("upload staging blocks of %s: %s, check the log of the client for details", mp, errno)
+	}
+	logger.Infof("Uploaded %d staging blocks of %s", bar.Current(), mp)
+	return nil
+}
+
+func copyStaging(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err == nil {
+		err = out.Sync()
+	}
+	if e := out.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+func exportStaging(ctx *cli.Context) error {
+	setup(ctx, 2)
+	dest := filepath.Join(ctx.Args().Get(1), "rawstaging")
+	var count, total uint64
+	var failed int
+	for _, dir := range stagingDirs(ctx.Args().Get(0)) {
+		walkStaging(dir, func(b *stagingBlock) {
+			target := filepath.Join(dest, b.key)
+			if _, err := os.Stat(target); err == nil {
+				return
+			}
+			if err := copyStaging(b.path, target); err != nil {
+				logger.Errorf("copy %s to %s: %s", b.path, target, err)
+				failed++
+				return
+			}
+			count++
+			total += uint64(b.size)
+		})
+	}
+	logger.Infof("Exported %d staging blocks (%s) into %s", count, humanize.IBytes(total), dest)
+	if failed > 0 {
+		return fmt.Errorf("%d staging blocks failed to export", failed)
+	}
+	if count > 0 {
+		logger.Infof("Mount with --writeback and --cache-dir %s to upload them", filepath.Dir(ctx.Args().Get(1)))
+	}
+	return nil
+}
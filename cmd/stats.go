@@ -170,6 +170,7 @@ func (w *statsWatcher) buildSchema(schema string, verbosity uint) {
 			s.name = "blockcache"
 			s.items = append(s.items, &item{"read", "juicefs_blockcache_hit_bytes", metricByte | metricCounter})
 			s.items = append(s.items, &item{"write", "juicefs_blockcache_write_bytes", metricByte | metricCounter})
+			s.items = append(s.items, &item{"pin", "juicefs_blockcache_pinned_bytes", metricGauge})
 		case 'o':
 			s.name = "object"
 			s.items = append(s.items, &item{"get", "juicefs_object_request_data_bytes_GET", metricByte | metricCounter})
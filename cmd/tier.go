@@ -20,8 +20,11 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/juicedata/juicefs/pkg/chunk"
 	"github.com/juicedata/juicefs/pkg/meta"
 	"github.com/juicedata/juicefs/pkg/object"
 	"github.com/juicedata/juicefs/pkg/vfs"
@@ -45,7 +48,11 @@ $ juicefs tier set redis://localhost --tier 3 /file1
 $ juicefs tier set redis://localhost --tier 0 /file1
 $ juicefs tier restore redis://localhost /file1
 $ juicefs tier restore redis://localhost /file1 --days 7
-$ juicefs tier restore redis://localhost /dir1`,
+$ juicefs tier restore redis://localhost /dir1
+$ juicefs tier policy set redis://localhost /dir1 --transition 1:30 --transition 2:90 --expire 365
+$ juicefs tier policy list redis://localhost
+$ juicefs tier policy delete redis://localhost /dir1
+$ juicefs tier policy apply redis://localhost`,
 		Subcommands: []*cli.Command{
 			{
 				Name:      "list",
@@ -65,6 +72,52 @@ $ juicefs tier restore redis://localhost /dir1`,
 				ArgsUsage: "META-URL PATH",
 				Action:    objRestore,
 			},
+			{
+				Name:            "policy",
+				Usage:           "manage lifecycle policies of directories",
+				HideHelpCommand: true,
+				Description: `
+A policy moves the files under a directory to colder tiers, or removes them, once they are not
+accessed or modified for some days. The policies are evaluated hourly by a background job in the
+clients, or immediately with "apply". A subdirectory with its own policy is not affected by the
+policy of its parents.`,
+				Subcommands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "set lifecycle policy to a directory",
+						ArgsUsage: "META-URL PATH",
+						Action:    setTierPolicy,
+						Flags: []cli.Flag{
+							&cli.StringSliceFlag{
+								Name:  "transition",
+								Usage: "move files to TIER after DAYS without access or modification, in format of TIER:DAYS",
+							},
+							&cli.IntFlag{
+								Name:  "expire",
+								Usage: "remove files after DAYS without access or modification (0 means never)",
+							},
+						},
+					},
+					{
+						Name:      "list",
+						Usage:     "list lifecycle policies",
+						ArgsUsage: "META-URL",
+						Action:    listTierPolicies,
+					},
+					{
+						Name:      "delete",
+						Usage:     "delete lifecycle policy of a directory",
+						ArgsUsage: "META-URL PATH",
+						Action:    deleteTierPolicy,
+					},
+					{
+						Name:      "apply",
+						Usage:     "evaluate all the lifecycle policies now",
+						ArgsUsage: "META-URL",
+						Action:    applyTierPolicies,
+					},
+				},
+			},
 		},
 		Flags: []cli.Flag{
 			&cli.IntFlag{
@@ -214,6 +267,140 @@ func setTier(ctx *cli.Context) error {
 	return err
 }
 
+func parseTierRules(vals []string, tiers object.Tiers) ([]meta.TierRule, error) {
+	var rules []meta.TierRule
+	for _, v := range vals {
+		parts := strings.Split(v, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid transition %q, should be TIER:DAYS", v)
+		}
+		tier, err := strconv.ParseUint(parts[0], 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tier in %q: %s", v, err)
+		}
+		if _, ok := tiers[uint8(tier)]; !ok {
+			return nil, fmt.Errorf("unknown tier %d", tier)
+		}
+		days, err := strconv.Atoi(parts[1])
+		if err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid days in %q", v)
+		}
+		rules = append(rules, meta.TierRule{Tier: uint8(tier), Days: days})
+	}
+	return rules, nil
+}
+
+func resolveDir(m meta.Meta, path string) meta.Ino {
+	var ino meta.Ino
+	var attr meta.Attr
+	if eno := m.Resolve(meta.Background(), meta.RootInode, path, &ino, &attr, true); eno != 0 {
+		logger.Fatalf("resolve %s: %s", path, eno)
+	}
+	if attr.Typ != meta.TypeDirectory {
+		logger.Fatalf("%s is not a directory", path)
+	}
+	return ino
+}
+
+func setTierPolicy(ctx *cli.Context) error {
+	setup(ctx, 2)
+	removePassword(ctx.Args().Get(0))
+	m := meta.NewClient(ctx.Args().Get(0), nil)
+	format, err := m.Load(true)
+	if err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	rules, err := parseTierRules(ctx.StringSlice("transition"), format.Tiers)
+	if err != nil {
+		logger.Fatalf("%s", err)
+	}
+	expire := ctx.Int("expire")
+	if len(rules) == 0 && expire <= 0 {
+		logger.Fatalf("at least one of --transition and --expire is required")
+	}
+	for _, r := range rules {
+		if expire > 0 && r.Days >= expire {
+			logger.Fatalf("files expire after %d days, before moving to tier %d after %d days", expire, r.Tier, r.Days)
+		}
+	}
+	path := ctx.Args().Get(1)
+	policy := &meta.TierPolicy{Rules: rules, ExpireDays: expire}
+	if eno := m.SetTierPolicy(meta.Background(), resolveDir(m, path), policy); eno != 0 {
+		return fmt.Errorf("set tier policy of %s: %s", path, eno)
+	}
+	logger.Infof("set tier policy of %s: %s", path, policy)
+	return nil
+}
+
+func listTierPolicies(ctx *cli.Context) error {
+	setup(ctx, 1)
+	removePassword(ctx.Args().Get(0))
+	m := meta.NewClient(ctx.Args().Get(0), nil)
+	if _, err := m.Load(true); err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	ps, eno := m.ListTierPolicies(meta.Background())
+	if eno != 0 {
+		return fmt.Errorf("list tier policies: %s", eno)
+	}
+	results := [][]string{{"inode", "path", "policy", "updated"}}
+	for _, p := range ps {
+		path := p.Path
+		if cur := m.GetPaths(meta.Background(), p.Inode); len(cur) > 0 {
+			path = cur[0]
+		}
+		results = append(results, []string{p.Inode.String(), path, p.String(), time.Unix(p.Updated, 0).Format(time.RFC3339)})
+	}
+	printResult(results, 1, false)
+	return nil
+}
+
+func deleteTierPolicy(ctx *cli.Context) error {
+	setup(ctx, 2)
+	removePassword(ctx.Args().Get(0))
+	m := meta.NewClient(ctx.Args().Get(0), nil)
+	if _, err := m.Load(true); err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	path := ctx.Args().Get(1)
+	if eno := m.DeleteTierPolicy(meta.Background(), resolveDir(m, path)); eno != 0 {
+		return fmt.Errorf("delete tier policy of %s: %s", path, eno)
+	}
+	return nil
+}
+
+func applyTierPolicies(ctx *cli.Context) error {
+	setup(ctx, 1)
+	removePassword(ctx.Args().Get(0))
+	metaConf := meta.DefaultConf()
+	metaConf.NoBGJob = true
+	m := meta.NewClient(ctx.Args().Get(0), metaConf)
+	format, err := m.Load(true)
+	if err != nil {
+		logger.Fatalf("load setting: %s", err)
+	}
+	if err = m.NewSession(false); err != nil {
+		logger.Fatalf("create session: %s", err)
+	}
+	defer m.CloseSession() //nolint:errcheck
+
+	chunkConf := *getDefaultChunkConf(format)
+	chunkConf.CacheDir = "memory"
+	blob, err := createStorage(*format)
+	if err != nil {
+		logger.Fatalf("object storage: %s", err)
+	}
+	store := chunk.NewCachedStore(blob, chunkConf, nil)
+	registerMetaMsg(m, store, &chunkConf)
+
+	var stats meta.TierPolicyStats
+	if eno := m.ApplyTierPolicies(meta.Background(), &stats); eno != 0 {
+		return fmt.Errorf("apply tier policies: %s", eno)
+	}
+	logger.Infof("scanned %d files, moved %d files and removed %d files", stats.Scanned, stats.Transitioned, stats.Expired)
+	return nil
+}
+
 func objRestore(ctx *cli.Context) error {
 	setup(ctx, 2)
 	removePassword(ctx.Args().Get(0))
@@ -58,7 +58,14 @@ $ cat /tmp/filelist
 /mnt/jfs/datadir/f1
 /mnt/jfs/datadir/f2
 /mnt/jfs/datadir/f3
-$ juicefs warmup -f /tmp/filelist`,
+$ juicefs warmup -f /tmp/filelist
+
+# Warm the cache servers (or other members of the cache group) instead of local cache
+$ juicefs warmup --remote /mnt/jfs/datadir
+
+# Warm and pin the files, the blocks are not evicted until unpinned (requires --cache-pin-size in mount)
+$ juicefs warmup --pin /mnt/jfs/models
+$ juicefs warmup --unpin /mnt/jfs/models`,
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:    "file",
@@ -84,6 +91,18 @@ $ juicefs warmup -f /tmp/filelist`,
 				Name:  "check",
 				Usage: "check whether the data blocks are cached or not",
 			},
+			&cli.BoolFlag{
+				Name:  "remote",
+				Usage: "warm up the cache servers or other members of the cache group, the blocks are not cached locally",
+			},
+			&cli.BoolFlag{
+				Name:  "pin",
+				Usage: "warm up and pin the blocks in local cache, so they are not evicted",
+			},
+			&cli.BoolFlag{
+				Name:  "unpin",
+				Usage: "unpin the blocks, they are kept in cache but could be evicted",
+			},
 		},
 	}
 }
@@ -200,10 +219,17 @@ func sendCommand(cf *os.File, action vfs.CacheAction, batch []string, threads ui
 func warmup(ctx *cli.Context) error {
 	setup0(ctx, 0, 0)
 
-	evict, check := ctx.Bool("evict"), ctx.Bool("check")
+	evict, check, remote := ctx.Bool("evict"), ctx.Bool("check"), ctx.Bool("remote")
 	if evict && check {
 		logger.Fatalf("--check and --evict can't be used together")
 	}
+	if remote && (evict || check) {
+		logger.Fatalf("--remote can't be used together with --check or --evict")
+	}
+	pin, unpin := ctx.Bool("pin"), ctx.Bool("unpin")
+	if (pin || unpin) && (pin == unpin || evict || check || remote) {
+		logger.Fatalf("--pin or --unpin can't be used together with --check, --evict, --remote or each other")
+	}
 
 	var paths []string
 	for _, p := range ctx.Args().Slice() {
@@ -268,6 +294,12 @@ func warmup(ctx *cli.Context) error {
 		action = vfs.EvictCache
 	} else if check {
 		action = vfs.CheckCache
+	} else if remote {
+		action = vfs.WarmupRemoteCache
+	} else if pin {
+		action = vfs.PinCache
+	} else if unpin {
+		action = vfs.UnpinCache
 	}
 
 	background := ctx.Bool("background")
@@ -305,7 +337,7 @@ func warmup(ctx *cli.Context) error {
 	if !background {
 		count, bytes := dspin.Current()
 		switch action {
-		case vfs.WarmupCache:
+		case vfs.WarmupCache, vfs.WarmupRemoteCache, vfs.PinCache, vfs.UnpinCache:
 			logger.Infof("%s: %d files (%s bytes)", action, count, humanize.IBytes(uint64(bytes)))
 		case vfs.EvictCache:
 			logger.Infof("%s: %d files (%s bytes)", action, count, humanize.IBytes(uint64(bytes)))
@@ -333,6 +365,9 @@ func warmup(ctx *cli.Context) error {
 				humanize.IBytes(uint64(bytes)-total.MissBytes),
 				humanize.IBytes(uint64(bytes)),
 				pct)
+			if total.Pinned > 0 {
+				logger.Infof("%s: %s pinned", action, humanize.IBytes(total.Pinned))
+			}
 		}
 	}
 	return nil
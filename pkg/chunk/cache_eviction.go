@@ -27,6 +27,7 @@ const (
 	EvictionNone    = "none"
 	Eviction2Random = "2-random"
 	EvictionLRU     = "lru"
+	Eviction2Q      = "2q"
 )
 
 const notInLru = math.MinInt // to trigger panic when misused
@@ -65,6 +66,11 @@ func NewKeyIndex(config *Config) (KeyIndex, error) {
 			keys:    make(map[cacheKey]*lruItem),
 			lruHeap: atimeHeap{},
 		}, nil
+	case Eviction2Q:
+		return &twoQEviction{
+			keys:   make(map[cacheKey]*twoQItem),
+			ghosts: make(map[cacheKey]uint64),
+		}, nil
 	default:
 		return nil, fmt.Errorf("unknown cache eviction policy: %q", config.CacheEviction)
 	}
@@ -375,3 +381,207 @@ func (p *lruEviction) verifyHeap() bool {
 	}
 	return true
 }
+
+// statefulIndex is a KeyIndex which remembers some keys across restarts.
+type statefulIndex interface {
+	// state returns the keys to remember
+	state() []cacheKey
+	// restore recovers the remembered keys, it should be called before any key is added
+	restore(keys []cacheKey)
+}
+
+const (
+	twoQRecentRatio = 4 // evict the recent blocks first if they are more than 1/4 of all blocks
+	twoQGhostRatio  = 2 // remember up to 1/2 of the number of blocks evicted from the recent queue
+	twoQMinGhosts   = 1024
+)
+
+type twoQItem struct {
+	lruItem
+	frequent bool
+}
+
+type ghostKey struct {
+	key cacheKey
+	seq uint64
+}
+
+// twoQEviction is a scan-resistant policy based on 2Q. New blocks are put into the recent queue,
+// which is evicted first, so the blocks read once (e.g. by a sequential scan) can't flush the
+// working set. The keys evicted from the recent queue are remembered as ghosts, a block is put
+// into the frequent queue if it's cached again while remembered. Both queues are ordered by atime.
+type twoQEviction struct {
+	keys      map[cacheKey]*twoQItem
+	recent    atimeHeap
+	frequent  atimeHeap
+	ghosts    map[cacheKey]uint64 // key -> sequence in ghostQ
+	ghostQ    []ghostKey          // FIFO of ghosts, may contain stale entries
+	ghostHead int
+	seq       uint64
+}
+
+func (p *twoQEviction) name() string {
+	return Eviction2Q
+}
+
+func (p *twoQEviction) queue(item *twoQItem) *atimeHeap {
+	if item.frequent {
+		return &p.frequent
+	}
+	return &p.recent
+}
+
+func (p *twoQEviction) add(key cacheKey, item cacheItem) {
+	iter, ok := p.keys[key]
+	if !ok {
+		iter = &twoQItem{lruItem: lruItem{cacheItem: item, pos: notInLru}}
+		if _, ok = p.ghosts[key]; ok {
+			iter.frequent = true
+			delete(p.ghosts, key)
+		}
+		p.keys[key] = iter
+	} else {
+		iter.cacheItem = item
+	}
+	if iter.pos != notInLru {
+		heap.Fix(p.queue(iter), iter.pos)
+	} else if iter.size > 0 { // staging blocks should not be evicted
+		heap.Push(p.queue(iter), heapItem{&iter.lruItem, &key})
+	}
+}
+
+func (p *twoQEviction) remove(key cacheKey, staging bool) *cacheItem {
+	item, ok := p.keys[key]
+	if !ok {
+		return nil
+	}
+	if item.size < 0 && !staging {
+		return nil
+	}
+	delete(p.keys, key)
+	if item.pos != notInLru {
+		heap.Remove(p.queue(item), item.pos)
+	}
+	return &item.cacheItem
+}
+
+func (p *twoQEviction) get(key cacheKey) *cacheItem {
+	if iter, ok := p.keys[key]; ok {
+		iter.atime = uint32(time.Now().Unix())
+		if iter.pos != notInLru {
+			heap.Fix(p.queue(iter), iter.pos)
+		}
+		return &iter.cacheItem
+	}
+	return nil
+}
+
+func (p *twoQEviction) peekAtime(key cacheKey) uint32 {
+	if item, ok := p.keys[key]; ok {
+		return item.atime
+	}
+	return 0
+}
+
+func (p *twoQEviction) len() int {
+	return len(p.keys)
+}
+
+// reset keeps the ghosts and remembers the frequent blocks as ghosts, so they are put into the
+// frequent queue again when added back. The ghosts beyond the limit are dropped, the frequent
+// blocks are remembered last, so they are kept.
+func (p *twoQEviction) reset() KeyIndex {
+	snap := &twoQEviction{
+		keys:     p.keys,
+		recent:   p.recent,
+		frequent: p.frequent,
+	}
+	for _, item := range p.frequent {
+		p.remember(*item.key)
+	}
+	p.forget(p.ghostLimit() + len(p.frequent))
+	p.keys = make(map[cacheKey]*twoQItem, len(snap.keys))
+	p.recent = make(atimeHeap, 0, len(snap.recent))
+	p.frequent = make(atimeHeap, 0, len(snap.frequent))
+	return snap
+}
+
+func (p *twoQEviction) randomIter() func(yield func(key cacheKey, item cacheItem) bool) {
+	return func(yield func(key cacheKey, item cacheItem) bool) {
+		for k, v := range p.keys {
+			if !yield(k, v.cacheItem) {
+				return
+			}
+		}
+	}
+}
+
+func (p *twoQEviction) evictionIter() func(yield func(key cacheKey, item cacheItem) bool) {
+	return func(yield func(key cacheKey, item cacheItem) bool) {
+		for p.recent.Len()+p.frequent.Len() > 0 {
+			var item heapItem
+			if p.frequent.Len() == 0 || p.recent.Len() > (p.recent.Len()+p.frequent.Len())/twoQRecentRatio {
+				item = heap.Pop(&p.recent).(heapItem)
+				p.remember(*item.key)
+				p.forget(p.ghostLimit())
+			} else {
+				item = heap.Pop(&p.frequent).(heapItem)
+			}
+			delete(p.keys, *item.key)
+			if !yield(*item.key, item.lruItem.cacheItem) {
+				return
+			}
+		}
+	}
+}
+
+func (p *twoQEviction) remember(key cacheKey) {
+	p.seq++
+	p.ghosts[key] = p.seq
+	p.ghostQ = append(p.ghostQ, ghostKey{key, p.seq})
+}
+
+func (p *twoQEviction) ghostLimit() int {
+	return max(len(p.keys)/twoQGhostRatio, twoQMinGhosts)
+}
+
+// forget drops the oldest ghosts beyond limit, and the stale entries in ghostQ.
+func (p *twoQEviction) forget(limit int) {
+	for len(p.ghosts) > limit && p.ghostHead < len(p.ghostQ) {
+		if g := p.ghostQ[p.ghostHead]; p.ghosts[g.key] == g.seq {
+			delete(p.ghosts, g.key)
+		}
+		p.ghostHead++
+	}
+	if len(p.ghostQ)-p.ghostHead > 2*len(p.ghosts)+twoQMinGhosts {
+		q := make([]ghostKey, 0, len(p.ghosts))
+		for _, g := range p.ghostQ[p.ghostHead:] {
+			if p.ghosts[g.key] == g.seq {
+				q = append(q, g)
+			}
+		}
+		p.ghostQ, p.ghostHead = q, 0
+	} else if p.ghostHead > len(p.ghostQ)/2 {
+		p.ghostQ = append(p.ghostQ[:0], p.ghostQ[p.ghostHead:]...)
+		p.ghostHead = 0
+	}
+}
+
+func (p *twoQEviction) state() []cacheKey {
+	keys := make([]cacheKey, 0, len(p.frequent)+len(p.ghosts))
+	for _, item := range p.frequent {
+		keys = append(keys, *item.key)
+	}
+	for _, g := range p.ghostQ[p.ghostHead:] {
+		if p.ghosts[g.key] == g.seq {
+			keys = append(keys, g.key)
+		}
+	}
+	return keys
+}
+
+func (p *twoQEviction) restore(keys []cacheKey) {
+	for _, k := range keys {
+		p.remember(k)
+	}
+}
@@ -0,0 +1,701 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chunk
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/davies/groupcache/consistenthash"
+	"github.com/juicedata/juicefs/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/twmb/murmur3"
+)
+
+const (
+	groupHeader       = "X-JuiceFS-Cache-Group"
+	originHeader      = "X-JuiceFS-Origin"
+	dateHeader        = "X-JuiceFS-Date"
+	digestHeader      = "X-JuiceFS-Content-Sha256"
+	signatureHeader   = "X-JuiceFS-Signature"
+	maxClockSkew      = time.Minute * 5
+	groupRefreshEvery = time.Second * 30
+	peerDownFor       = time.Second * 30
+)
+
+// peerRequest marks the requests from other members, which should not be sent to peers again.
+type peerRequest struct{}
+
+func fromPeer(ctx context.Context) bool {
+	return ctx.Value(peerRequest{}) != nil
+}
+
+// cacheGroup shares the cached blocks among the clients in the same group. Every block is owned
+// by one member chosen by consistent hashing, other members read it from the owner, which
+// downloads it from the object storage and keeps it in its cache.
+type cacheGroup struct {
+	store    *cachedStore
+	name     string
+	addr     string // address serving the other members
+	remote   bool   // the members are dedicated cache servers
+	key      []byte // to sign the requests among the members
+	scheme   string
+	tlsConf  *tls.Config
+	listener net.Listener
+	client   *http.Client
+	done     chan struct{}
+
+	sync.RWMutex
+	members func() ([]string, error)
+	peers   []string
+	ring    *consistenthash.Map
+	down    map[string]time.Time
+
+	adopting atomic.Bool // uploading the replicas of the members left
+
+	peerHits     prometheus.Counter
+	peerHitBytes prometheus.Counter
+	peerErrors   prometheus.Counter
+}
+
+// groupKey derives the key to sign the requests of a cache group from the shared secret.
+func groupKey(secret, name string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(name))
+	return mac.Sum(nil)
+}
+
+// groupTLS loads the certificate to serve the members, which is also trusted as the CA of them.
+func groupTLS(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in %s", certFile)
+	}
+	conf := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	if keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+func newGroupClient(store *cachedStore, name string, remote bool) (*cacheGroup, error) {
+	if store.conf.GroupSecret == "" {
+		return nil, errors.New("a secret is required to authenticate the members")
+	}
+	tlsConf, err := groupTLS(store.conf.GroupTLSCert, store.conf.GroupTLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate: %s", err)
+	}
+	metric, target := "peer", "other members in the cache group"
+	if remote {
+		metric, target = "remote", "cache servers"
+	}
+	scheme := "http"
+	if tlsConf != nil {
+		scheme = "https"
+	}
+	return &cacheGroup{
+		store:   store,
+		name:    name,
+		remote:  remote,
+		key:     groupKey(store.conf.GroupSecret, name),
+		scheme:  scheme,
+		tlsConf: tlsConf,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:               nil,
+				TLSClientConfig:     tlsConf,
+				MaxIdleConnsPerHost: 100,
+				IdleConnTimeout:     time.Minute,
+				DialContext:         (&net.Dialer{Timeout: time.Second * 3}).DialContext,
+			},
+			Timeout: store.conf.GetTimeout,
+		},
+		done: make(chan struct{}),
+		down: make(map[string]time.Time),
+		peerHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blockcache_" + metric + "_hits",
+			Help: "read from the cache of " + target,
+		}),
+		peerHitBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blockcache_" + metric + "_hit_bytes",
+			Help: "read bytes from the cache of " + target,
+		}),
+		peerErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blockcache_" + metric + "_errors",
+			Help: "failed reads from " + target,
+		}),
+	}, nil
+}
+
+func newCacheGroup(store *cachedStore, name, listen string) (*cacheGroup, error) {
+	g, err := newGroupClient(store, name, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.serve(listen); err != nil {
+		return nil, err
+	}
+	logger.Infof("Serve cache group %s on %s", name, g.addr)
+	return g, nil
+}
+
+// newRemoteCache reads the blocks from the dedicated cache servers, each block is read from the
+// server chosen by consistent hashing, and served from the object storage when it's down.
+func newRemoteCache(store *cachedStore, servers []string) (*cacheGroup, error) {
+	g, err := newGroupClient(store, store.conf.Volume, true)
+	if err != nil {
+		return nil, err
+	}
+	g.peers = slices.Clone(servers)
+	sort.Strings(g.peers)
+	g.ring = consistenthash.New(100, murmur3.Sum32)
+	g.ring.Add(g.peers...)
+	logger.Infof("Read blocks from %d cache servers: %v", len(g.peers), g.peers)
+	return g, nil
+}
+
+// newCacheServer serves the cached blocks to the clients with remote cache, it never reads
+// blocks from others.
+func newCacheServer(store *cachedStore, listen string) (*cacheGroup, error) {
+	g, err := newGroupClient(store, store.conf.Volume, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.serve(listen); err != nil {
+		return nil, err
+	}
+	logger.Infof("Serve cached blocks of volume %s on %s", store.conf.Volume, g.addr)
+	return g, nil
+}
+
+func (g *cacheGroup) serve(listen string) error {
+	l, err := net.Listen("tcp", listen)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %s", listen, err)
+	}
+	if g.tlsConf != nil {
+		if len(g.tlsConf.Certificates) == 0 {
+			_ = l.Close()
+			return errors.New("the key of TLS certificate is required to serve the members")
+		}
+		l = tls.NewListener(l, g.tlsConf)
+	}
+	g.listener = l
+	g.addr = l.Addr().String()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/block", g.serveBlock)
+	mux.HandleFunc("/prefetch", g.servePrefetch)
+	mux.HandleFunc("/warmup", g.serveWarmup)
+	mux.HandleFunc("/replica", g.serveReplica)
+	go func() {
+		if err := http.Serve(l, mux); err != nil && !errors.Is(err, net.ErrClosed) {
+			logger.Warnf("serve cached blocks on %s: %s", g.addr, err)
+		}
+	}()
+	return nil
+}
+
+// signature returns the HMAC of a request, which binds the method, the URI, the sender, the time
+// and the digest of body.
+func (g *cacheGroup) signature(method, uri, origin, date, digest string) string {
+	mac := hmac.New(sha256.New, g.key)
+	_, _ = mac.Write([]byte(strings.Join([]string{method, uri, g.name, origin, date, digest}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// newRequest creates a request to peer signed with the key of the group.
+func (g *cacheGroup) newRequest(ctx context.Context, method, peer, uri string, body []byte) (*http.Request, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s://%s%s", g.scheme, peer, uri), r)
+	if err != nil {
+		return nil, err
+	}
+	var origin, digest string
+	if !g.remote {
+		origin = g.addr
+		req.Header.Set(originHeader, origin)
+	}
+	if body != nil {
+		sum := sha256.Sum256(body)
+		digest = hex.EncodeToString(sum[:])
+		req.Header.Set(digestHeader, digest)
+	}
+	date := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(groupHeader, g.name)
+	req.Header.Set(dateHeader, date)
+	req.Header.Set(signatureHeader, g.signature(method, req.URL.RequestURI(), origin, date, digest))
+	return req, nil
+}
+
+// authorize verifies the signature of the request from another member, the digest of body should be
+// checked by the handler.
+func (g *cacheGroup) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get(groupHeader) != g.name {
+		http.Error(w, "not in the same cache group", http.StatusForbidden)
+		return false
+	}
+	date := r.Header.Get(dateHeader)
+	ts, err := strconv.ParseInt(date, 10, 64)
+	if err != nil || time.Since(time.Unix(ts, 0)).Abs() > maxClockSkew {
+		http.Error(w, "request expired", http.StatusForbidden)
+		return false
+	}
+	expected := g.signature(r.Method, r.URL.RequestURI(), r.Header.Get(originHeader), date, r.Header.Get(digestHeader))
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get(signatureHeader))) {
+		logger.Warnf("invalid signature of request from %s", r.RemoteAddr)
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// close stops discovering the members and serving them.
+func (g *cacheGroup) close() {
+	select {
+	case <-g.done:
+		return
+	default:
+		close(g.done)
+	}
+	if g.listener != nil {
+		_ = g.listener.Close()
+	}
+	g.client.CloseIdleConnections()
+}
+
+func (g *cacheGroup) regMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(g.peerHits)
+	reg.MustRegister(g.peerHitBytes)
+	reg.MustRegister(g.peerErrors)
+}
+
+// join starts to discover the members in background until ctx is done, members returns the
+// addresses of all the members including this one.
+func (g *cacheGroup) join(ctx context.Context, members func() ([]string, error)) {
+	g.Lock()
+	g.members = members
+	g.Unlock()
+	g.refresh()
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				g.close()
+				return
+			case <-g.done:
+				return
+			case <-time.After(utils.JitterIt(groupRefreshEvery)):
+				g.refresh()
+			}
+		}
+	}()
+}
+
+func (g *cacheGroup) refresh() {
+	g.RLock()
+	members := g.members
+	g.RUnlock()
+	peers, err := members()
+	if err != nil {
+		logger.Warnf("list members of cache group %s: %s", g.name, err)
+		return
+	}
+	if !slices.Contains(peers, g.addr) {
+		peers = append(peers, g.addr)
+	}
+	sort.Strings(peers)
+	g.Lock()
+	defer g.Unlock()
+	if slices.Equal(peers, g.peers) {
+		return
+	}
+	ring := consistenthash.New(100, murmur3.Sum32)
+	ring.Add(peers...)
+	g.ring = ring
+	g.peers = peers
+	logger.Infof("Cache group %s has %d members: %v", g.name, len(peers), peers)
+	if g.adopting.CompareAndSwap(false, true) {
+		go func() {
+			defer g.adopting.Store(false)
+			g.store.adoptReplicas(peers)
+		}()
+	}
+}
+
+// owner returns the address of the member owning key, or empty if it's this one or unavailable.
+func (g *cacheGroup) owner(key string) string {
+	g.RLock()
+	defer g.RUnlock()
+	if g.ring == nil {
+		return ""
+	}
+	peer := g.ring.Get(key)
+	if peer == g.addr || time.Now().Before(g.down[peer]) {
+		return ""
+	}
+	return peer
+}
+
+// isMember returns whether addr is another member of the group now.
+func (g *cacheGroup) isMember(addr string) bool {
+	g.RLock()
+	defer g.RUnlock()
+	return addr != g.addr && slices.Contains(g.peers, addr)
+}
+
+func (g *cacheGroup) markDown(peer string) {
+	g.Lock()
+	g.down[peer] = time.Now().Add(peerDownFor)
+	g.Unlock()
+}
+
+func blockQuery(key string) string {
+	id, indx, _ := parseBlockKey(key)
+	return fmt.Sprintf("id=%d&indx=%d&size=%d", id, indx, parseObjOrigSize(key))
+}
+
+// read reads the part of block at off from its owner.
+func (g *cacheGroup) read(ctx context.Context, key string, p []byte, off int) (int, error) {
+	peer := g.owner(key)
+	if peer == "" {
+		return 0, errNotCached
+	}
+	uri := fmt.Sprintf("/block?%s&off=%d&limit=%d", blockQuery(key), off, len(p))
+	req, err := g.newRequest(ctx, http.MethodGet, peer, uri, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := g.client.Do(req)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Warnf("read %s from %s: %s, skip it for %s", key, peer, err, peerDownFor)
+			g.markDown(peer)
+			g.peerErrors.Inc()
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		g.peerErrors.Inc()
+		return 0, fmt.Errorf("read %s from %s: %s %s", key, peer, resp.Status, msg)
+	}
+	n, err := io.ReadFull(resp.Body, p)
+	if err != nil {
+		g.peerErrors.Inc()
+		return n, fmt.Errorf("read %s from %s: %s", key, peer, err)
+	}
+	logger.Debugf("read %s (%d,%d) from %s: %s", key, off, n, peer, time.Since(start))
+	g.peerHits.Inc()
+	g.peerHitBytes.Add(float64(n))
+	return n, nil
+}
+
+// warmup asks the owner of key to download it into its cache and waits for it.
+func (g *cacheGroup) warmup(key string) error {
+	peer := g.owner(key)
+	if peer == "" {
+		return errNotCached
+	}
+	req, err := g.newRequest(context.Background(), http.MethodPost, peer, "/warmup?"+blockQuery(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("warmup %s in %s: %s", key, peer, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("warmup %s in %s: %s %s", key, peer, resp.Status, msg)
+	}
+	return nil
+}
+
+// prefetch asks the owner of key to download it into its cache.
+func (g *cacheGroup) prefetch(key string) {
+	peer := g.owner(key)
+	if peer == "" {
+		return
+	}
+	req, err := g.newRequest(context.Background(), http.MethodPost, peer, "/prefetch?"+blockQuery(key), nil)
+	if err != nil {
+		return
+	}
+	if resp, err := g.client.Do(req); err != nil {
+		logger.Debugf("prefetch %s from %s: %s", key, peer, err)
+	} else {
+		_ = resp.Body.Close()
+	}
+}
+
+// replicaPeer returns the member to keep the replica of a staging block, other than this one.
+func (g *cacheGroup) replicaPeer(key string) string {
+	g.RLock()
+	defer g.RUnlock()
+	n := len(g.peers)
+	if n < 2 {
+		return ""
+	}
+	start := int(murmur3.Sum32([]byte(key)) % uint32(n))
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		peer := g.peers[(start+i)%n]
+		if peer != g.addr && !now.Before(g.down[peer]) {
+			return peer
+		}
+	}
+	return ""
+}
+
+// putReplica sends the staging block to another member, and returns the address of it.
+func (g *cacheGroup) putReplica(key string, data []byte, tierID uint8) (string, error) {
+	peer := g.replicaPeer(key)
+	if peer == "" {
+		return "", errors.New("no other member in the cache group")
+	}
+	uri := fmt.Sprintf("/replica?%s&tier=%d", blockQuery(key), tierID)
+	req, err := g.newRequest(context.Background(), http.MethodPut, peer, uri, data)
+	if err != nil {
+		return "", err
+	}
+	resp, err := g.client.Do(req)
+	if err != nil {
+		logger.Warnf("put replica of %s to %s: %s, skip it for %s", key, peer, err, peerDownFor)
+		g.markDown(peer)
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("put replica of %s to %s: %s %s", key, peer, resp.Status, msg)
+	}
+	return peer, nil
+}
+
+// removeReplica removes the replica of a staging block after uploaded.
+func (g *cacheGroup) removeReplica(peer, key string) {
+	req, err := g.newRequest(context.Background(), http.MethodDelete, peer, "/replica?"+blockQuery(key), nil)
+	if err != nil {
+		return
+	}
+	if resp, err := g.client.Do(req); err != nil {
+		logger.Debugf("remove replica of %s from %s: %s", key, peer, err)
+	} else {
+		_ = resp.Body.Close()
+	}
+}
+
+// parseBlock returns the slice and the index of the requested block.
+func (g *cacheGroup) parseBlock(w http.ResponseWriter, r *http.Request) (*rSlice, int, bool) {
+	if !g.authorize(w, r) {
+		return nil, 0, false
+	}
+	q := r.URL.Query()
+	id, err1 := strconv.ParseUint(q.Get("id"), 10, 64)
+	indx, err2 := strconv.Atoi(q.Get("indx"))
+	size, err3 := strconv.Atoi(q.Get("size"))
+	if err1 != nil || err2 != nil || err3 != nil || indx < 0 || size <= 0 || size > g.store.conf.BlockSize {
+		http.Error(w, "invalid block", http.StatusBadRequest)
+		return nil, 0, false
+	}
+	return sliceForRead(id, indx*g.store.conf.BlockSize+size, g.store), indx, true
+}
+
+func (g *cacheGroup) serveBlock(w http.ResponseWriter, r *http.Request) {
+	s, indx, ok := g.parseBlock(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	off, err1 := strconv.Atoi(q.Get("off"))
+	limit, err2 := strconv.Atoi(q.Get("limit"))
+	size := s.blockSize(indx)
+	if err1 != nil || err2 != nil || off < 0 || limit <= 0 || off+limit > size {
+		http.Error(w, "invalid range", http.StatusBadRequest)
+		return
+	}
+	page := NewOffPage(limit)
+	defer page.Release()
+	ctx := context.WithValue(r.Context(), peerRequest{}, true)
+	n, err := s.ReadAt(ctx, page, indx*g.store.conf.BlockSize+off)
+	if err != nil && n < limit {
+		logger.Warnf("read %s for %s: %s", s.key(indx), r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(limit))
+	_, _ = w.Write(page.Data)
+}
+
+func (g *cacheGroup) servePrefetch(w http.ResponseWriter, r *http.Request) {
+	if s, indx, ok := g.parseBlock(w, r); ok {
+		key := s.key(indx)
+		// it may be owned by another member after the members changed
+		if _, cached := g.store.bcache.exist(key); !cached && g.owner(key) == "" {
+			g.store.fetcher.fetch(key)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (g *cacheGroup) serveWarmup(w http.ResponseWriter, r *http.Request) {
+	s, indx, ok := g.parseBlock(w, r)
+	if !ok {
+		return
+	}
+	key := s.key(indx)
+	if _, cached := g.store.bcache.exist(key); !cached {
+		page := NewOffPage(s.blockSize(indx))
+		defer page.Release()
+		ctx := context.WithValue(r.Context(), peerRequest{}, true)
+		if err := g.store.load(ctx, key, page, true, true); err != nil {
+			logger.Warnf("warmup %s for %s: %s", key, r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveReplica keeps the replicas of the staging blocks for other members, they are uploaded after
+// the member left the group without removing them.
+func (g *cacheGroup) serveReplica(w http.ResponseWriter, r *http.Request) {
+	s, indx, ok := g.parseBlock(w, r)
+	if !ok {
+		return
+	}
+	origin := r.Header.Get(originHeader)
+	if !validOrigin(origin) || !g.isMember(origin) {
+		http.Error(w, "unknown origin", http.StatusForbidden)
+		return
+	}
+	root := g.store.peerReplicaRoot()
+	if root == "" {
+		http.Error(w, errNoReplicaDir.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	key := s.key(indx)
+	path := filepath.Join(root, replicaOrigin(origin), key)
+	switch r.Method {
+	case http.MethodPut:
+		tier, err := strconv.Atoi(r.URL.Query().Get("tier"))
+		if err != nil || tier < 0 || tier > maxTierID {
+			http.Error(w, "invalid tier", http.StatusBadRequest)
+			return
+		}
+		data := make([]byte, s.blockSize(indx))
+		if _, err = io.ReadFull(r.Body, data); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if sum := sha256.Sum256(data); hex.EncodeToString(sum[:]) != r.Header.Get(digestHeader) {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+		if err = writeStaging(path, data, uint8(tier), g.store.conf.CacheChecksum, g.store.conf.CacheMode); err != nil {
+			logger.Warnf("write replica of %s for %s: %s", key, origin, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case http.MethodDelete:
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JoinCacheGroup starts to share the cached blocks with the members returned by members until ctx
+// is done, it returns the address serving the others, or empty if no cache group is configured.
+func (store *cachedStore) JoinCacheGroup(ctx context.Context, members func() ([]string, error)) string {
+	if store.peers == nil || store.peers.remote {
+		return ""
+	}
+	store.peers.join(ctx, members)
+	return store.peers.addr
+}
+
+// FillRemoteCache loads the blocks into the cache of their owners in the cache group or the cache
+// servers, they are not cached locally except the ones owned by this client.
+func (store *cachedStore) FillRemoteCache(id uint64, length uint32) error {
+	if store.peers == nil {
+		return errors.New("neither cache group nor remote cache is configured")
+	}
+	r := sliceForRead(id, int(length), store)
+	var err error
+	for _, k := range r.keys() {
+		size := parseObjOrigSize(k)
+		if size == 0 || size > store.conf.BlockSize {
+			logger.Warnf("Invalid size: %s %d", k, size)
+			continue
+		}
+		e := store.peers.warmup(k)
+		if e == errNotCached && !store.peers.remote { // owned by this one
+			if _, cached := store.bcache.exist(k); !cached {
+				p := NewOffPage(size)
+				e = store.load(context.TODO(), k, p, true, true)
+				p.Release()
+			}
+		}
+		if e != nil {
+			logger.Warnf("Failed to warmup key: %s %s", k, e)
+			err = e
+		}
+	}
+	return err
+}
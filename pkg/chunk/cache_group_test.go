@@ -0,0 +1,263 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chunk
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/object"
+	"github.com/stretchr/testify/require"
+)
+
+type countedGets struct {
+	object.ObjectStorage
+	gets atomic.Int64
+}
+
+func (c *countedGets) Get(ctx context.Context, key string, off, limit int64, getters ...object.AttrGetter) (io.ReadCloser, error) {
+	c.gets.Add(1)
+	return c.ObjectStorage.Get(ctx, key, off, limit, getters...)
+}
+
+func TestCacheGroup(t *testing.T) {
+	mem, _ := object.CreateStorage("mem", "", "", "", "")
+	blob := &countedGets{ObjectStorage: mem}
+	newStore := func() *cachedStore {
+		conf := defaultConf
+		conf.CacheDir = t.TempDir()
+		conf.CacheGroup = "test"
+		conf.GroupListen = "127.0.0.1:0"
+		conf.GroupSecret = "secret"
+		return NewCachedStore(blob, conf, nil).(*cachedStore)
+	}
+	s1, s2 := newStore(), newStore()
+	require.NotNil(t, s1.peers)
+	require.NotNil(t, s2.peers)
+	members := func() ([]string, error) { return []string{s1.peers.addr, s2.peers.addr}, nil }
+	ctx, leave := context.WithCancel(context.Background())
+	defer leave()
+	require.Equal(t, s1.peers.addr, s1.JoinCacheGroup(ctx, members))
+	require.Equal(t, s2.peers.addr, s2.JoinCacheGroup(context.Background(), members))
+
+	const blocks = 8
+	size := blocks * defaultConf.BlockSize
+	data := bytes.Repeat([]byte("juicefs!"), size/8)
+	w := s1.NewWriter(1, 0)
+	_, err := w.WriteAt(data, 0)
+	require.NoError(t, err)
+	require.NoError(t, w.Finish(size))
+
+	var owned int
+	r := sliceForRead(1, size, s1)
+	for i := 0; i < blocks; i++ {
+		if s1.peers.owner(r.key(i)) != "" {
+			owned++
+		}
+	}
+	require.True(t, owned > 0 && owned < blocks, "blocks should be owned by both members: %d", owned)
+
+	read := func(s *cachedStore) {
+		reader := s.NewReader(1, size)
+		for i := 0; i < blocks; i++ {
+			p := NewPage(make([]byte, defaultConf.BlockSize))
+			n, err := reader.ReadAt(context.Background(), p, i*defaultConf.BlockSize)
+			require.NoError(t, err)
+			require.Equal(t, data[i*defaultConf.BlockSize:(i+1)*defaultConf.BlockSize], p.Data[:n])
+		}
+		// a partial read
+		p := NewPage(make([]byte, 100))
+		_, err := reader.ReadAt(context.Background(), p, defaultConf.BlockSize+10)
+		require.NoError(t, err)
+		require.Equal(t, data[defaultConf.BlockSize+10:defaultConf.BlockSize+110], p.Data)
+	}
+	read(s2)
+	read(s1)
+	require.Equal(t, int64(blocks), blob.gets.Load(), "every block should be downloaded once")
+
+	// the requests without valid signature are rejected
+	uri := "/block?" + blockQuery(r.key(0)) + "&off=0&limit=10"
+	req, _ := http.NewRequest(http.MethodGet, "http://"+s1.peers.addr+uri, nil)
+	req.Header.Set(groupHeader, "test")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	conf := defaultConf
+	conf.GroupSecret = "other"
+	forged, err := newGroupClient(&cachedStore{conf: conf}, "test", false)
+	require.NoError(t, err)
+	req, _ = forged.newRequest(context.Background(), http.MethodGet, s1.peers.addr, uri, nil)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// read from the object storage if the owner left
+	leave()
+	for i := 0; i < 100; i++ {
+		if _, err := net.Dial("tcp", s1.peers.addr); err != nil {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	s2.peers.client.CloseIdleConnections()
+	expected := blob.gets.Load() + int64(blocks-owned)
+	read(s2)
+	require.Equal(t, expected, blob.gets.Load())
+}
+
+func TestRemoteCache(t *testing.T) {
+	mem, _ := object.CreateStorage("mem", "", "", "", "")
+	blob := &countedGets{ObjectStorage: mem}
+	conf := defaultConf
+	conf.CacheDir = t.TempDir()
+	conf.Volume = "vol"
+	conf.GroupSecret = "secret"
+	server := NewCachedStore(blob, conf, nil).(*cachedStore)
+	g, err := newCacheServer(server, "127.0.0.1:0")
+	require.NoError(t, err)
+
+	conf.CacheDir = t.TempDir()
+	writer := NewCachedStore(blob, conf, nil)
+	const blocks = 4
+	size := blocks * defaultConf.BlockSize
+	data := bytes.Repeat([]byte("juicefs!"), size/8)
+	w := writer.NewWriter(1, 0)
+	_, err = w.WriteAt(data, 0)
+	require.NoError(t, err)
+	require.NoError(t, w.Finish(size))
+
+	conf.CacheDir = t.TempDir()
+	conf.CacheFullBlock = true
+	conf.RemoteCache = []string{g.addr}
+	client := NewCachedStore(blob, conf, nil).(*cachedStore)
+	require.NotNil(t, client.peers)
+	r := sliceForRead(1, size, client)
+
+	// warm up the cache server
+	require.NoError(t, client.FillRemoteCache(1, uint32(size)))
+	require.Equal(t, int64(blocks), blob.gets.Load())
+	for i := 0; i < blocks; i++ {
+		_, cached := server.bcache.exist(r.key(i))
+		require.True(t, cached, "block %d should be cached in server", i)
+		_, cached = client.bcache.exist(r.key(i))
+		require.False(t, cached, "block %d should not be cached locally", i)
+	}
+
+	read := func() {
+		reader := client.NewReader(1, size)
+		for i := 0; i < blocks; i++ {
+			p := NewPage(make([]byte, defaultConf.BlockSize))
+			n, err := reader.ReadAt(context.Background(), p, i*defaultConf.BlockSize)
+			require.NoError(t, err)
+			require.Equal(t, data[i*defaultConf.BlockSize:(i+1)*defaultConf.BlockSize], p.Data[:n])
+		}
+	}
+	read()
+	require.Equal(t, int64(blocks), blob.gets.Load(), "blocks should be read from the cache server")
+
+	// the cache server rejects the requests of other volumes, or with another secret
+	conf.Volume = "other"
+	conf.CacheDir = t.TempDir()
+	other := NewCachedStore(blob, conf, nil).(*cachedStore)
+	_, err = other.peers.read(context.Background(), r.key(0), make([]byte, 10), 0)
+	require.Error(t, err)
+	conf.Volume = "vol"
+	conf.GroupSecret = "guessed"
+	conf.CacheDir = t.TempDir()
+	other = NewCachedStore(blob, conf, nil).(*cachedStore)
+	_, err = other.peers.read(context.Background(), r.key(0), make([]byte, 10), 0)
+	require.ErrorContains(t, err, "403")
+
+	// the blocks from the cache server are cached locally
+	for i := 0; i < blocks; i++ {
+		for j := 0; j < 100; j++ {
+			if _, cached := client.bcache.exist(r.key(i)); cached {
+				break
+			}
+			time.Sleep(time.Millisecond * 50)
+		}
+	}
+	_ = g.listener.Close()
+	client.peers.client.CloseIdleConnections()
+	read()
+	require.Equal(t, int64(blocks), blob.gets.Load(), "blocks should be read from local cache")
+}
+
+func TestPeerReplica(t *testing.T) {
+	mem, _ := object.CreateStorage("mem", "", "", "", "")
+	newStore := func() *cachedStore {
+		conf := defaultConf
+		conf.CacheDir = t.TempDir()
+		conf.CacheGroup = "test"
+		conf.GroupListen = "127.0.0.1:0"
+		conf.GroupSecret = "secret"
+		conf.Writeback = true
+		conf.UploadDelay = time.Hour
+		conf.StageReplica = ReplicaPeer
+		return NewCachedStore(mem, conf, nil).(*cachedStore)
+	}
+	s1, s2 := newStore(), newStore()
+	require.NotNil(t, s1.replica)
+	members := func() ([]string, error) { return []string{s1.peers.addr, s2.peers.addr}, nil }
+	s1.JoinCacheGroup(context.Background(), members)
+	s2.JoinCacheGroup(context.Background(), members)
+
+	key := "chunks/0/0/12_0_1024"
+	peer, err := s1.peers.putReplica(key, make([]byte, 1024), 0)
+	require.NoError(t, err)
+	require.Equal(t, s2.peers.addr, peer)
+	root := s2.peerReplicaRoot()
+	_, err = os.Stat(filepath.Join(root, replicaOrigin(s1.peers.addr), key))
+	require.NoError(t, err)
+
+	// the replicas are only accepted from the members, and kept in the dir of replicas
+	put := func(origin string) int {
+		req, err := s1.peers.newRequest(context.Background(), http.MethodPut, s2.peers.addr, "/replica?"+blockQuery(key)+"&tier=0", make([]byte, 1024))
+		require.NoError(t, err)
+		req.Header.Set(originHeader, origin)
+		sig := s1.peers.signature(http.MethodPut, req.URL.RequestURI(), origin, req.Header.Get(dateHeader), req.Header.Get(digestHeader))
+		req.Header.Set(signatureHeader, sig)
+		resp, err := s1.peers.client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusForbidden, put("../../..:1"))
+	require.Equal(t, http.StatusForbidden, put("127.0.0.1:1"))
+	_, err = os.Stat(filepath.Join(root, replicaOrigin("127.0.0.1:1")))
+	require.True(t, os.IsNotExist(err))
+
+	// the replicas of the members never seen are not adopted
+	unknown := filepath.Join(root, replicaOrigin("10.0.0.1:9000"), key)
+	require.NoError(t, writeStaging(unknown, make([]byte, 1024), 0, CsNone, 0600))
+	s2.adoptReplicas([]string{s1.peers.addr, s2.peers.addr})
+	s2.adoptReplicas([]string{s2.peers.addr}) // s1 left
+	_, err = os.Stat(unknown)
+	require.NoError(t, err)
+	_, err = mem.Head(context.Background(), key)
+	require.NoError(t, err, "the replica of a member left should be uploaded")
+}
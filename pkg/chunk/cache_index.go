@@ -0,0 +1,356 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chunk
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+const (
+	indexMagic      = "JFSCIDX1"
+	indexRecordSize = 29 // op(1) + key(16) + size(4) + atime(4) + crc32(4)
+	indexAdd        = 1
+	indexRemove     = 2
+	indexCompactMin = 100000
+	indexBufferMax  = 16 << 20
+)
+
+var (
+	indexFlushInterval = time.Second
+	errIndexCorrupted  = errors.New("corrupted index")
+)
+
+// journaledIndex is a KeyIndex recording the changes into an append-only log in the cache dir,
+// so the cached blocks are known without scanning the cache dir after restarted.
+// All the methods are called with the lock of diskCache held.
+type journaledIndex struct {
+	KeyIndex
+	buf     []byte // records not flushed yet
+	records int    // records in the log, including the buffered ones
+	rewrite bool   // the log should be rewritten from the index
+}
+
+func unwrapIndex(index KeyIndex) KeyIndex {
+	if j, ok := index.(*journaledIndex); ok {
+		return j.KeyIndex
+	}
+	return index
+}
+
+func appendRecord(buf []byte, op byte, k cacheKey, item cacheItem) []byte {
+	var rec [indexRecordSize]byte
+	rec[0] = op
+	binary.LittleEndian.PutUint64(rec[1:], k.id)
+	binary.LittleEndian.PutUint32(rec[9:], k.indx)
+	binary.LittleEndian.PutUint32(rec[13:], k.size)
+	binary.LittleEndian.PutUint32(rec[17:], uint32(item.size))
+	binary.LittleEndian.PutUint32(rec[21:], item.atime)
+	binary.LittleEndian.PutUint32(rec[25:], crc32.ChecksumIEEE(rec[:25]))
+	return append(buf, rec[:]...)
+}
+
+func (j *journaledIndex) record(op byte, k cacheKey, item cacheItem) {
+	if j.rewrite {
+		return // covered by the next rewrite
+	}
+	if len(j.buf) >= indexBufferMax {
+		// the log can't be written in time, rewrite it later
+		j.buf = nil
+		j.rewrite = true
+		return
+	}
+	j.buf = appendRecord(j.buf, op, k, item)
+	j.records++
+}
+
+func (j *journaledIndex) add(key cacheKey, item cacheItem) {
+	j.KeyIndex.add(key, item)
+	j.record(indexAdd, key, item)
+}
+
+func (j *journaledIndex) remove(key cacheKey, staging bool) *cacheItem {
+	it := j.KeyIndex.remove(key, staging)
+	if it != nil {
+		j.record(indexRemove, key, *it)
+	}
+	return it
+}
+
+func (j *journaledIndex) evictionIter() func(yield func(key cacheKey, item cacheItem) bool) {
+	iter := j.KeyIndex.evictionIter()
+	return func(yield func(key cacheKey, item cacheItem) bool) {
+		for k, item := range iter {
+			j.record(indexRemove, k, item)
+			if !yield(k, item) {
+				return
+			}
+		}
+	}
+}
+
+// snapshot returns the content of a new log with all the keys in index.
+func (j *journaledIndex) snapshot() []byte {
+	buf := make([]byte, 0, len(indexMagic)+j.len()*indexRecordSize)
+	buf = append(buf, indexMagic...)
+	for k, item := range j.KeyIndex.randomIter() {
+		buf = appendRecord(buf, indexAdd, k, item)
+	}
+	j.buf = nil
+	j.records = j.len()
+	j.rewrite = false
+	return buf
+}
+
+// replayIndex returns the keys in the log and the length of valid records in it. A broken record at
+// the end is written partially before crashed, while the ones in the middle means the log is corrupted.
+func replayIndex(data []byte) (map[cacheKey]cacheItem, int, error) {
+	if len(data) < len(indexMagic) || string(data[:len(indexMagic)]) != indexMagic {
+		return nil, 0, errIndexCorrupted
+	}
+	items := make(map[cacheKey]cacheItem)
+	off := len(indexMagic)
+	for ; off+indexRecordSize <= len(data); off += indexRecordSize {
+		rec := data[off : off+indexRecordSize]
+		if crc32.ChecksumIEEE(rec[:25]) != binary.LittleEndian.Uint32(rec[25:]) {
+			if off+2*indexRecordSize <= len(data) {
+				return nil, 0, errIndexCorrupted
+			}
+			break
+		}
+		k := cacheKey{binary.LittleEndian.Uint64(rec[1:]), binary.LittleEndian.Uint32(rec[9:]), binary.LittleEndian.Uint32(rec[13:])}
+		switch rec[0] {
+		case indexAdd:
+			items[k] = cacheItem{int32(binary.LittleEndian.Uint32(rec[17:])), binary.LittleEndian.Uint32(rec[21:])}
+		case indexRemove:
+			delete(items, k)
+		default:
+			return nil, 0, errIndexCorrupted
+		}
+	}
+	return items, off, nil
+}
+
+func (cache *diskCache) indexPath() string {
+	return filepath.Join(cache.dir, ".index")
+}
+
+// loadIndex restores the cached blocks from the log, so the cache dir is not scanned on startup.
+// The blocks lost on disk are removed from index when they are read, or by reconcileIndex.
+func (cache *diskCache) loadIndex() {
+	j := cache.keys.(*journaledIndex)
+	path := cache.indexPath()
+	start := time.Now()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("read index of cached blocks from %s: %s", path, err)
+		}
+		return
+	}
+	items, valid, err := replayIndex(data)
+	if err != nil {
+		logger.Warnf("Ignore the index of cached blocks in %s: %s, scan the cache dir instead", path, err)
+		return
+	}
+	if valid < len(data) {
+		logger.Warnf("Drop the last %d bytes of %s written partially", len(data)-valid, path)
+		if err = os.Truncate(path, int64(valid)); err != nil {
+			logger.Warnf("truncate %s: %s", path, err)
+			return
+		}
+	}
+	cache.Lock()
+	for k, item := range items {
+		if _, ok := cache.pins[k]; ok {
+			continue
+		}
+		j.KeyIndex.add(k, item)
+		if item.size > 0 {
+			cache.used += int64(item.size) + 4096
+		}
+	}
+	j.records = (valid - len(indexMagic)) / indexRecordSize
+	j.rewrite = false
+	cache.scanned = true
+	pins := make([]cacheKey, 0, len(cache.pins))
+	for k := range cache.pins {
+		pins = append(pins, k)
+	}
+	cache.Unlock()
+	// the pinned blocks are not in index
+	for _, k := range pins {
+		if _, err := os.Stat(cache.cachePath(cache.getPathFromKey(k))); err == nil {
+			cache.Lock()
+			cache.setPinCached(k, true)
+			cache.Unlock()
+		}
+	}
+	logger.Infof("Loaded %d cached blocks (%s) from %s with %s", len(items), humanize.IBytes(uint64(cache.used)), path, time.Since(start))
+}
+
+// reconcileIndex checks the blocks loaded from the log against the cache dir once, in background.
+// The blocks lost or written partially before crashed are removed, and the ones cached but not
+// recorded in time are added, so used space is corrected without waiting for the periodic scan.
+func (cache *diskCache) reconcileIndex() {
+	start := time.Now()
+	cachePrefix := filepath.Join(cache.dir, cacheDir)
+	onDisk := make(map[cacheKey]bool)
+	var missed int
+	_ = filepath.WalkDir(cachePrefix, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		key := path[len(cachePrefix)+1:]
+		if runtime.GOOS == "windows" {
+			key = strings.ReplaceAll(key, "\\", "/")
+		}
+		size := parseObjOrigSize(key)
+		fi, err := d.Info()
+		if size == 0 || err != nil || fi.Size() < int64(size) {
+			return nil // removed below if it's in index
+		}
+		k := cache.getCacheKey(key)
+		onDisk[k] = true
+		cache.Lock()
+		_, pinned := cache.pins[k]
+		recorded := pinned || cache.keys.get(k) != nil
+		cache.Unlock()
+		if !recorded {
+			missed++
+			if getNlink(fi) > 1 {
+				cache.add(key, -int32(size), uint32(getAtime(fi).Unix()))
+			} else {
+				cache.add(key, int32(size), uint32(getAtime(fi).Unix()))
+			}
+		}
+		return nil
+	})
+
+	// the blocks accessed after started are cached again or still there
+	before := uint32(start.Unix())
+	var lost []cacheKey
+	cache.Lock()
+	for k, item := range cache.keys.randomIter() {
+		if !onDisk[k] && item.atime < before {
+			lost = append(lost, k)
+		}
+	}
+	for _, k := range lost {
+		if it := cache.keys.remove(k, false); it != nil && it.size > 0 {
+			cache.used -= int64(it.size + 4096)
+		}
+	}
+	used := cache.used
+	cache.Unlock()
+	for _, k := range lost {
+		_ = cache.removeFile(cache.cachePath(cache.getPathFromKey(k))) // written partially
+	}
+	if missed > 0 || len(lost) > 0 {
+		logger.Infof("Reconciled index of cached blocks in %s with %s: %d added, %d removed, used %s",
+			cache.dir, time.Since(start), missed, len(lost), humanize.IBytes(uint64(used)))
+	}
+}
+
+// flushIndex appends the changes of index into the log periodically, and rewrites the log when
+// most of the records in it are stale.
+func (cache *diskCache) flushIndex(interval time.Duration) {
+	j := cache.keys.(*journaledIndex)
+	path := cache.indexPath()
+	var f *os.File
+	for cache.available() {
+		time.Sleep(interval)
+		cache.Lock()
+		// wait for the scan to rewrite it with all the blocks
+		if cache.scanned && (j.rewrite || j.records > indexCompactMin && j.records > 2*j.len()) {
+			data := j.snapshot()
+			cache.Unlock()
+			if f != nil {
+				_ = f.Close()
+				f = nil
+			}
+			if err := cache.rewriteIndex(path, data); err != nil {
+				logger.Warnf("rewrite index of cached blocks into %s: %s", path, err)
+				cache.Lock()
+				j.rewrite = true
+				cache.Unlock()
+			}
+			continue
+		}
+		buf := j.buf
+		j.buf = nil
+		cache.Unlock()
+		if len(buf) == 0 {
+			continue
+		}
+		err := cache.checkErr(func() error {
+			var err error
+			if f == nil {
+				if f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, cache.mode); err != nil {
+					return err
+				}
+			}
+			if _, err = f.Write(buf); err == nil {
+				err = f.Sync()
+			}
+			return err
+		})
+		if err != nil {
+			logger.Warnf("write index of cached blocks into %s: %s", path, err)
+			if f != nil {
+				_ = f.Close()
+				f = nil
+			}
+			cache.Lock()
+			j.rewrite = true
+			cache.Unlock()
+		}
+	}
+	if f != nil {
+		_ = f.Close()
+	}
+}
+
+// rewriteIndex replaces the log with data atomically.
+func (cache *diskCache) rewriteIndex(path string, data []byte) error {
+	tmp := path + ".tmp"
+	return cache.checkErr(func() error {
+		f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, cache.mode)
+		if err != nil {
+			return err
+		}
+		if _, err = f.Write(data); err == nil {
+			err = f.Sync()
+		}
+		if e := f.Close(); err == nil {
+			err = e
+		}
+		if err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	})
+}
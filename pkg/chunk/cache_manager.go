@@ -101,10 +101,18 @@ type CacheManager interface {
 	usedMemory() int64
 	isEmpty() bool
 	getMetrics() *cacheManagerMetrics
+	// pin keeps the block from eviction, it's cached later if not cached yet.
+	pin(key string) error
+	unpin(key string)
+	isPinned(key string) bool
+	pinStats() (int64, int64)
 }
 
 func newCacheManager(config *Config, reg prometheus.Registerer, uploader func(key, path string, force bool) bool) CacheManager {
 	getEnvs()
+	if len(config.CacheTiers) > 0 {
+		return newTieredCache(config, reg, uploader)
+	}
 	metrics := newCacheManagerMetrics(reg)
 	if config.CacheDir == "memory" || !config.CacheEnabled() {
 		return newMemStore(config, metrics)
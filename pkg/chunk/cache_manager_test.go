@@ -195,3 +195,57 @@ func TestCacheManager(t *testing.T) {
 	time.Sleep(3 * time.Second)
 	require.True(t, m.isEmpty())
 }
+
+func TestTieredCache(t *testing.T) {
+	tiers, err := ParseCacheTiers("memory=2M; /nvme/jfs=100 ;/hdd1/jfs:/hdd2/jfs=1T")
+	require.NoError(t, err)
+	require.Equal(t, []CacheTier{{"memory", 2 << 20}, {"/nvme/jfs", 100 << 20}, {"/hdd1/jfs:/hdd2/jfs", 1 << 40}}, tiers)
+	for _, s := range []string{"/nvme/jfs", "/nvme/jfs=0", "/nvme/jfs=1G;memory=1G"} {
+		_, err = ParseCacheTiers(s)
+		require.Error(t, err, s)
+	}
+
+	conf := testConf()
+	defer os.RemoveAll(conf.CacheDir)
+	conf.CacheTiers = []CacheTier{{"memory", 2 << 20}, {conf.CacheDir, 1 << 30}}
+	conf.CachePromoteHits = 2
+	m := newCacheManager(&conf, nil, nil).(*tieredCache)
+	require.Equal(t, 1, m.stageTier)
+	key := func(i int) string { return fmt.Sprintf("chunks/0/0/%d_0_%d", i, 1<<20) }
+	for i := 1; i <= 3; i++ {
+		p := NewOffPage(1 << 20)
+		p.Data[0] = byte(i)
+		m.cache(key(i), p, true, false)
+		p.Release()
+	}
+	// one of them is evicted from memory and demoted to disk
+	var demoted string
+	for i := 1; i <= 3; i++ {
+		if _, ok := m.tiers[0].exist(key(i)); !ok {
+			demoted = key(i)
+		}
+	}
+	require.NotEmpty(t, demoted)
+	require.Eventually(t, func() bool {
+		_, ok := m.tiers[1].exist(demoted)
+		return ok
+	}, time.Second*3, time.Millisecond*50)
+	require.Equal(t, 1.0, toFloat64(m.demotions[1]))
+
+	// promoted after read twice
+	for i := 0; i < 2; i++ {
+		r, err := m.load(demoted)
+		require.NoError(t, err)
+		buf := make([]byte, 1)
+		_, err = r.ReadAt(buf, 0)
+		require.NoError(t, err)
+		require.Equal(t, demoted, key(int(buf[0])))
+		_ = r.Close()
+	}
+	require.Eventually(t, func() bool {
+		_, inMem := m.tiers[0].exist(demoted)
+		_, onDisk := m.tiers[1].exist(demoted)
+		return inMem && !onDisk
+	}, time.Second*3, time.Millisecond*50)
+	require.Equal(t, 1.0, toFloat64(m.promotions[0]))
+}
@@ -0,0 +1,252 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chunk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	errPinFull        = errors.New("quota of pinned blocks is exceeded")
+	errPinUnsupported = errors.New("pinning is not supported by memory cache")
+)
+
+var pinsSaveInterval = time.Second * 10
+
+func (cache *diskCache) pinsPath() string {
+	return filepath.Join(cache.dir, ".pinned")
+}
+
+// locked
+func (cache *diskCache) setPinCached(k cacheKey, cached bool) {
+	old, ok := cache.pins[k]
+	if !ok || old == cached {
+		return
+	}
+	cache.pins[k] = cached
+	if cached {
+		cache.pinBlocks++
+		cache.pinBytes += int64(k.size) + 4096
+	} else {
+		cache.pinBlocks--
+		cache.pinBytes -= int64(k.size) + 4096
+	}
+}
+
+// dropPin forgets the pin of k, the block (if cached) should be removed or added into keys by the caller.
+// locked
+func (cache *diskCache) dropPin(k cacheKey) {
+	if _, ok := cache.pins[k]; !ok {
+		return
+	}
+	cache.setPinCached(k, false)
+	delete(cache.pins, k)
+	cache.pinReserved -= int64(k.size) + 4096
+	cache.pinsDirty = true
+}
+
+// pin keeps the block out of eviction, the space is reserved in the quota of pinned blocks even
+// it's not cached yet.
+func (cache *diskCache) pin(key string) error {
+	k := cache.getCacheKey(key)
+	cache.Lock()
+	defer cache.Unlock()
+	if _, ok := cache.pins[k]; ok {
+		return nil
+	}
+	size := int64(k.size) + 4096
+	if cache.pinReserved+size > cache.pinCapacity {
+		return errPinFull
+	}
+	cache.pins[k] = false
+	cache.pinReserved += size
+	cache.pinsDirty = true
+	if it := cache.keys.get(k); it != nil && it.size > 0 {
+		cache.keys.remove(k, false)
+		cache.used -= int64(it.size + 4096)
+		cache.setPinCached(k, true)
+	}
+	return nil
+}
+
+// unpin makes the block evictable again.
+func (cache *diskCache) unpin(key string) {
+	k := cache.getCacheKey(key)
+	cache.Lock()
+	defer cache.Unlock()
+	cached, ok := cache.pins[k]
+	if !ok {
+		return
+	}
+	cache.dropPin(k)
+	if cached {
+		cache.keys.add(k, cacheItem{size: int32(k.size), atime: uint32(time.Now().Unix())})
+		cache.used += int64(k.size) + 4096
+		if cache.full() && cache.keys.name() != EvictionNone {
+			cache.cleanupFull()
+		}
+	}
+}
+
+func (cache *diskCache) isPinned(key string) bool {
+	cache.Lock()
+	defer cache.Unlock()
+	_, ok := cache.pins[cache.getCacheKey(key)]
+	return ok
+}
+
+func (cache *diskCache) pinStats() (int64, int64) {
+	cache.Lock()
+	defer cache.Unlock()
+	return cache.pinBlocks, cache.pinBytes
+}
+
+// loadPins restores the pinned keys before restarted, they are marked as cached when scanned.
+func (cache *diskCache) loadPins() {
+	path := cache.pinsPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("read pinned blocks from %s: %s", path, err)
+		}
+		return
+	}
+	keys := decodeKeys(data)
+	cache.Lock()
+	for _, k := range keys {
+		cache.pins[k] = false
+		cache.pinReserved += int64(k.size) + 4096
+	}
+	cache.Unlock()
+	if cache.pinReserved > cache.pinCapacity {
+		logger.Warnf("Pinned blocks (%d bytes) in %s exceed the quota (%d bytes)", cache.pinReserved, cache.dir, cache.pinCapacity)
+	}
+	logger.Infof("Restored %d pinned blocks for %s", len(keys), cache.dir)
+}
+
+// savePins saves the pinned keys after changed.
+func (cache *diskCache) savePins(interval time.Duration) {
+	path := cache.pinsPath()
+	for cache.available() {
+		time.Sleep(interval)
+		cache.Lock()
+		if !cache.pinsDirty {
+			cache.Unlock()
+			continue
+		}
+		keys := make([]cacheKey, 0, len(cache.pins))
+		for k := range cache.pins {
+			keys = append(keys, k)
+		}
+		cache.pinsDirty = false
+		cache.Unlock()
+		if err := cache.writeKeys(path, keys); err != nil {
+			logger.Warnf("save pinned blocks into %s: %s", path, err)
+			cache.Lock()
+			cache.pinsDirty = true
+			cache.Unlock()
+		}
+	}
+}
+
+func (m *cacheManager) pin(key string) error {
+	store := m.getStore(key)
+	if store == nil {
+		return errors.New("no available cache dir")
+	}
+	return store.pin(key)
+}
+
+func (m *cacheManager) unpin(key string) {
+	if store := m.getStore(key); store != nil {
+		store.unpin(key)
+	}
+}
+
+func (m *cacheManager) isPinned(key string) bool {
+	store := m.getStore(key)
+	return store != nil && store.isPinned(key)
+}
+
+func (m *cacheManager) pinStats() (int64, int64) {
+	var cnt, used int64
+	for _, s := range m.stores {
+		if s != nil {
+			c, u := s.pinStats()
+			cnt += c
+			used += u
+		}
+	}
+	return cnt, used
+}
+
+func (c *memcache) pin(key string) error     { return errPinUnsupported }
+func (c *memcache) unpin(key string)         {}
+func (c *memcache) isPinned(key string) bool { return false }
+func (c *memcache) pinStats() (int64, int64) { return 0, 0 }
+
+// PinCache loads the blocks of a slice into local cache, and keeps them from eviction.
+func (store *cachedStore) PinCache(id uint64, length uint32) error {
+	r := sliceForRead(id, int(length), store)
+	for _, k := range r.keys() {
+		if err := store.bcache.pin(k); err != nil {
+			return err
+		}
+		if _, existed := store.bcache.exist(k); existed {
+			continue
+		}
+		size := parseObjOrigSize(k)
+		if size == 0 || size > store.conf.BlockSize {
+			logger.Warnf("Invalid size: %s %d", k, size)
+			continue
+		}
+		p := NewOffPage(size)
+		// the pinned blocks are cached locally even with a cache group
+		ctx := context.WithValue(context.Background(), peerRequest{}, true)
+		err := store.load(ctx, k, p, true, true)
+		p.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnpinCache makes the blocks of a slice evictable again, they are kept in cache.
+func (store *cachedStore) UnpinCache(id uint64, length uint32) error {
+	r := sliceForRead(id, int(length), store)
+	for _, k := range r.keys() {
+		store.bcache.unpin(k)
+	}
+	return nil
+}
+
+// PinnedCache returns the size of pinned blocks of a slice.
+func (store *cachedStore) PinnedCache(id uint64, length uint32) uint64 {
+	r := sliceForRead(id, int(length), store)
+	var pinned uint64
+	for i, k := range r.keys() {
+		if store.bcache.isPinned(k) {
+			pinned += uint64(r.blockSize(i))
+		}
+	}
+	return pinned
+}
@@ -0,0 +1,229 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chunk
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/utils"
+)
+
+const (
+	CacheFull    = "full"    // cache the whole blocks
+	CachePartial = "partial" // cache the small blocks and the partial reads only
+	CacheNone    = "none"    // never cache the blocks
+)
+
+// CachePolicy overrides the cache options of the mount for the files under a directory,
+// the options not set follow the mount.
+type CachePolicy struct {
+	Cache      string         // full, partial or none
+	LargeWrite *bool          // cache the full blocks written
+	Readahead  *int           // max readahead of a file in bytes
+	Prefetch   *bool          // prefetch the whole block on random reads
+	Expire     *time.Duration // cached blocks not accessed for this long are removed
+	Pin        bool           // keep the cached blocks from eviction
+}
+
+// ParseCachePolicy parses a policy like "cache=none,readahead=256M,prefetch=false,expire=1h,pin=true".
+func ParseCachePolicy(s string) (*CachePolicy, error) {
+	var p CachePolicy
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid option %q", item)
+		}
+		var err error
+		switch k {
+		case "cache":
+			if v != CacheFull && v != CachePartial && v != CacheNone {
+				return nil, fmt.Errorf("invalid cache mode %q", v)
+			}
+			p.Cache = v
+		case "large-write":
+			var b bool
+			b, err = strconv.ParseBool(v)
+			p.LargeWrite = &b
+		case "readahead":
+			var n uint64
+			n, err = utils.TryParseBytes(v, 'B')
+			r := int(n)
+			p.Readahead = &r
+		case "prefetch":
+			var b bool
+			b, err = strconv.ParseBool(v)
+			p.Prefetch = &b
+		case "expire":
+			var d time.Duration
+			if d, err = time.ParseDuration(v); err == nil && d < 0 {
+				err = fmt.Errorf("negative duration")
+			}
+			p.Expire = &d
+		case "pin":
+			p.Pin, err = strconv.ParseBool(v)
+		default:
+			return nil, fmt.Errorf("unknown option %q", k)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of %s: %q: %s", k, v, err)
+		}
+	}
+	if p.Pin && p.Cache == CacheNone {
+		return nil, fmt.Errorf("blocks can't be pinned without cache")
+	}
+	return &p, nil
+}
+
+func formatSize(n int) string {
+	units := "KMGT"
+	var i int
+	for n > 0 && n%1024 == 0 && i < len(units) {
+		n >>= 10
+		i++
+	}
+	if i == 0 {
+		return strconv.Itoa(n)
+	}
+	return strconv.Itoa(n) + units[i-1:i]
+}
+
+func (p *CachePolicy) String() string {
+	var items []string
+	if p.Cache != "" {
+		items = append(items, "cache="+p.Cache)
+	}
+	if p.LargeWrite != nil {
+		items = append(items, "large-write="+strconv.FormatBool(*p.LargeWrite))
+	}
+	if p.Readahead != nil {
+		items = append(items, "readahead="+formatSize(*p.Readahead))
+	}
+	if p.Prefetch != nil {
+		items = append(items, "prefetch="+strconv.FormatBool(*p.Prefetch))
+	}
+	if p.Expire != nil {
+		items = append(items, "expire="+p.Expire.String())
+	}
+	if p.Pin {
+		items = append(items, "pin=true")
+	}
+	return strings.Join(items, ",")
+}
+
+type cachePolicyKey struct{}
+
+// WithCachePolicy returns a context carrying the cache policy for the reads with it.
+func WithCachePolicy(ctx context.Context, p *CachePolicy) context.Context {
+	if p == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, cachePolicyKey{}, p)
+}
+
+func cachePolicyOf(ctx context.Context) *CachePolicy {
+	p, _ := ctx.Value(cachePolicyKey{}).(*CachePolicy)
+	return p
+}
+
+func (p *CachePolicy) noCache() bool {
+	return p != nil && p.Cache == CacheNone
+}
+
+func (p *CachePolicy) noPrefetch() bool {
+	return p != nil && (p.Cache == CacheNone || p.Prefetch != nil && !*p.Prefetch)
+}
+
+func (p *CachePolicy) pinned() bool {
+	return p != nil && p.Pin
+}
+
+func (store *cachedStore) shouldCacheWrite(p *CachePolicy, size int) bool {
+	if p.noCache() {
+		return false
+	}
+	if p.pinned() {
+		return true
+	}
+	if p != nil && p.LargeWrite != nil {
+		return size < store.conf.BlockSize || *p.LargeWrite
+	}
+	return size < store.conf.BlockSize || store.conf.CacheLargeWrite
+}
+
+// policyExpiry removes the cached blocks of the directories having their own expire time,
+// it's kept in memory, so the blocks follow the expire time of mount after restarted.
+type policyExpiry struct {
+	sync.Mutex
+	once      sync.Once
+	deadlines map[string]time.Time
+}
+
+// touchPolicy pins a cached block if the policy asks for it, or records the access of it, which
+// is removed after the expire time of policy if not accessed again.
+func (store *cachedStore) touchPolicy(p *CachePolicy, key string) {
+	if p.pinned() {
+		if err := store.bcache.pin(key); err != nil {
+			logger.Debugf("pin cached block %s: %s", key, err)
+		}
+		return
+	}
+	if p == nil || p.Expire == nil || *p.Expire == 0 {
+		return
+	}
+	e := &store.expiry
+	e.once.Do(func() {
+		e.deadlines = make(map[string]time.Time)
+		go store.cleanupPolicyExpire(policyExpireInterval)
+	})
+	e.Lock()
+	e.deadlines[key] = time.Now().Add(*p.Expire)
+	e.Unlock()
+}
+
+func (store *cachedStore) cleanupPolicyExpire(interval time.Duration) {
+	e := &store.expiry
+	for {
+		time.Sleep(interval)
+		now := time.Now()
+		var expired []string
+		e.Lock()
+		for key, deadline := range e.deadlines {
+			if now.After(deadline) && !store.bcache.isPinned(key) {
+				expired = append(expired, key)
+				delete(e.deadlines, key)
+			}
+		}
+		e.Unlock()
+		for _, key := range expired {
+			store.bcache.remove(key, false)
+		}
+		if len(expired) > 0 {
+			logger.Debugf("removed %d cached blocks expired by cache policy", len(expired))
+		}
+	}
+}
+
+var policyExpireInterval = time.Second * 10
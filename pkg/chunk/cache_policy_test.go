@@ -0,0 +1,104 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chunk
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/object"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCachePolicy(t *testing.T) {
+	p, err := ParseCachePolicy("cache=partial, large-write=true,readahead=256M,prefetch=false,expire=1h")
+	require.Nil(t, err)
+	require.Equal(t, CachePartial, p.Cache)
+	require.True(t, *p.LargeWrite)
+	require.Equal(t, 256<<20, *p.Readahead)
+	require.False(t, *p.Prefetch)
+	require.Equal(t, time.Hour, *p.Expire)
+	require.Equal(t, "cache=partial,large-write=true,readahead=256M,prefetch=false,expire=1h0m0s", p.String())
+
+	p, err = ParseCachePolicy("cache=full,readahead=1.5G,pin=true")
+	require.Nil(t, err)
+	require.Equal(t, 3<<29, *p.Readahead)
+	require.True(t, p.Pin)
+	require.Equal(t, "cache=full,readahead=1536M,pin=true", p.String())
+
+	p, err = ParseCachePolicy("")
+	require.Nil(t, err)
+	require.Equal(t, "", p.String())
+
+	for _, s := range []string{"cache=all", "readahead=1X", "readahead=", "prefetch", "expire=-1s", "size=1G", "cache=none,pin=true"} {
+		_, err = ParseCachePolicy(s)
+		require.NotNil(t, err, s)
+	}
+}
+
+func TestStoreCachePolicy(t *testing.T) {
+	mem, _ := object.CreateStorage("mem", "", "", "", "")
+	conf := defaultConf
+	conf.CacheDir = t.TempDir()
+	conf.FreeSpace = 0.01
+	conf.CachePinSize = 4 << 20
+	store := NewCachedStore(mem, conf, nil).(*cachedStore)
+	none, _ := ParseCachePolicy("cache=none")
+	expire, _ := ParseCachePolicy("cache=full,expire=200ms")
+	pin, _ := ParseCachePolicy("pin=true")
+
+	w := store.NewWriter(10, 0)
+	w.SetCachePolicy(none)
+	_, err := w.WriteAt(bytes.Repeat([]byte{0x41}, 1024), 0)
+	require.Nil(t, err)
+	require.Nil(t, w.Finish(1024))
+	require.Nil(t, forgetSlice(store, 11, conf.BlockSize))
+	time.Sleep(time.Millisecond * 100) // waiting for flush
+	cnt, _ := store.bcache.stats()
+	require.Equal(t, int64(0), cnt)
+
+	read := func(p *CachePolicy, id uint64, length int) {
+		page := NewPage(make([]byte, length))
+		defer page.Release()
+		n, err := store.NewReader(id, length).ReadAt(WithCachePolicy(ctx, p), page, 0)
+		require.Nil(t, err)
+		require.Equal(t, length, n)
+	}
+	read(none, 10, 1024)
+	time.Sleep(time.Millisecond * 100)
+	cnt, _ = store.bcache.stats()
+	require.Equal(t, int64(0), cnt)
+
+	policyExpireInterval = time.Millisecond * 50
+	defer func() { policyExpireInterval = time.Second * 10 }()
+	read(expire, 11, conf.BlockSize)
+	time.Sleep(time.Millisecond * 100)
+	cnt, _ = store.bcache.stats()
+	require.Equal(t, int64(1), cnt)
+	time.Sleep(time.Millisecond * 300)
+	cnt, _ = store.bcache.stats()
+	require.Equal(t, int64(0), cnt)
+
+	// partial reads load the whole block to pin it
+	page := NewPage(make([]byte, 1024))
+	n, err := store.NewReader(11, conf.BlockSize).ReadAt(WithCachePolicy(ctx, pin), page, conf.BlockSize/2)
+	page.Release()
+	require.Nil(t, err)
+	require.Equal(t, 1024, n)
+	require.True(t, store.bcache.isPinned(sliceForRead(11, conf.BlockSize, store).key(0)))
+}
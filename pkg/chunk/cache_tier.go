@@ -0,0 +1,348 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chunk
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CacheTier is a layer of local cache, the tiers are ordered from the fastest to the slowest.
+type CacheTier struct {
+	Dir  string // "memory" or the cache dirs separated by colon
+	Size uint64
+}
+
+// ParseCacheTiers parses tiers like "memory=4G;/nvme/jfscache=500G;/hdd1/jfscache:/hdd2/jfscache=4T",
+// the size without unit is in MiB.
+func ParseCacheTiers(s string) ([]CacheTier, error) {
+	var tiers []CacheTier
+	for _, item := range strings.Split(s, ";") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid cache tier %q, should be DIR=SIZE", item)
+		}
+		dir, size := item[:i], item[i+1:]
+		if size != "" && size[len(size)-1] >= '0' && size[len(size)-1] <= '9' {
+			size += "M"
+		}
+		n, err := parseSize(size)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid size of cache tier %q", item)
+		}
+		if dir == "memory" && len(tiers) > 0 {
+			return nil, fmt.Errorf("memory should be the first cache tier")
+		}
+		tiers = append(tiers, CacheTier{dir, n})
+	}
+	return tiers, nil
+}
+
+// the blocks moved between tiers
+type tierMove struct {
+	key  string
+	from int
+	to   int
+	r    ReadCloser // for demotion, the evicted block
+}
+
+// tieredCache puts the new blocks into the fastest tier, the ones evicted from a tier are demoted
+// to the next one, and the ones read frequently from a slower tier are promoted to the previous one.
+type tieredCache struct {
+	sync.Mutex
+	tiers       []CacheManager
+	dirs        []string
+	stageTier   int // the first tier on disk, for staging and pinned blocks
+	promoteHits uint32
+	hits        map[string]uint32 // number of reads from slower tiers
+	moves       chan tierMove
+	promotions  []prometheus.Counter
+	demotions   []prometheus.Counter
+}
+
+const maxTrackedHits = 1 << 20
+
+func newTieredCache(config *Config, reg prometheus.Registerer, uploader func(key, path string, force bool) bool) CacheManager {
+	c := &tieredCache{
+		stageTier:   -1,
+		promoteHits: uint32(config.CachePromoteHits),
+		hits:        make(map[string]uint32),
+		moves:       make(chan tierMove, 100),
+	}
+	for i, t := range config.CacheTiers {
+		conf := *config
+		conf.CacheTiers = nil
+		conf.CacheDir, conf.CacheSize = t.Dir, t.Size
+		if t.Dir == "memory" && (conf.CacheEviction == EvictionLRU || conf.CacheEviction == Eviction2Q) {
+			conf.CacheEviction = Eviction2Random
+		}
+		tierReg := reg
+		if reg != nil {
+			tierReg = prometheus.WrapRegistererWith(prometheus.Labels{"tier": strconv.Itoa(i)}, reg)
+		}
+		tier := newCacheManager(&conf, tierReg, uploader)
+		if _, ok := tier.(*cacheManager); ok && c.stageTier < 0 {
+			c.stageTier = i
+		}
+		c.tiers = append(c.tiers, tier)
+		c.dirs = append(c.dirs, t.Dir)
+		c.promotions = append(c.promotions, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blockcache_promotions",
+			Help: "number of blocks promoted into the tier",
+		}))
+		c.demotions = append(c.demotions, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blockcache_demotions",
+			Help: "number of blocks demoted into the tier",
+		}))
+		if tierReg != nil {
+			tierReg.MustRegister(c.promotions[i], c.demotions[i])
+			tierReg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "blockcache_tier_blocks",
+				Help: "number of cached blocks in the tier",
+			}, func() float64 {
+				cnt, _ := tier.stats()
+				return float64(cnt)
+			}))
+			tierReg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "blockcache_tier_bytes",
+				Help: "number of cached bytes in the tier",
+			}, func() float64 {
+				_, used := tier.stats()
+				return float64(used)
+			}))
+		}
+	}
+	// the last tier drops the evicted blocks
+	for i, tier := range c.tiers[:len(c.tiers)-1] {
+		demote := c.demoteFrom(i)
+		switch t := tier.(type) {
+		case *cacheManager:
+			if runtime.GOOS == "windows" {
+				continue // the opened files can't be removed
+			}
+			for _, s := range t.stores {
+				s.demote = demote
+			}
+		case *memcache:
+			t.demote = demote
+		}
+	}
+	logger.Infof("Cache tiers: %s", strings.Join(c.dirs, " -> "))
+	go c.move()
+	return c
+}
+
+func (c *tieredCache) demoteFrom(i int) func(key string, r ReadCloser) bool {
+	return func(key string, r ReadCloser) bool {
+		select {
+		case c.moves <- tierMove{key, i, i + 1, r}:
+			return true
+		default:
+			return false // too busy, drop it
+		}
+	}
+}
+
+// hit counts the read from a slower tier, and promotes the block after read frequently.
+func (c *tieredCache) hit(i int, key string) {
+	if c.promoteHits == 0 || c.tiers[i].isPinned(key) {
+		return
+	}
+	c.Lock()
+	if len(c.hits) >= maxTrackedHits {
+		c.hits = make(map[string]uint32)
+	}
+	c.hits[key]++
+	promote := c.hits[key] >= c.promoteHits
+	if promote {
+		delete(c.hits, key)
+	}
+	c.Unlock()
+	if promote {
+		select {
+		case c.moves <- tierMove{key: key, from: i, to: i - 1}:
+		default:
+		}
+	}
+}
+
+func (c *tieredCache) move() {
+	for m := range c.moves {
+		r := m.r
+		if r == nil {
+			var err error
+			if r, err = c.tiers[m.from].load(m.key); err != nil {
+				continue
+			}
+		}
+		size := parseObjOrigSize(m.key)
+		p := NewOffPage(size)
+		n, err := r.ReadAt(p.Data, 0)
+		_ = r.Close()
+		if n == size {
+			c.tiers[m.to].cache(m.key, p, true, false)
+			if m.to < m.from {
+				c.tiers[m.from].remove(m.key, false)
+				c.promotions[m.to].Inc()
+				logger.Debugf("promote %s from %s to %s", m.key, c.dirs[m.from], c.dirs[m.to])
+			} else {
+				c.demotions[m.to].Inc()
+				logger.Debugf("demote %s from %s to %s", m.key, c.dirs[m.from], c.dirs[m.to])
+			}
+		} else {
+			logger.Warnf("read %s from %s: %d bytes, %v", m.key, c.dirs[m.from], n, err)
+		}
+		p.Release()
+	}
+}
+
+func (c *tieredCache) cache(key string, p *Page, force, dropCache bool) {
+	if c.stageTier > 0 && c.tiers[c.stageTier].isPinned(key) {
+		c.tiers[c.stageTier].cache(key, p, force, dropCache)
+		return
+	}
+	c.tiers[0].cache(key, p, force, dropCache)
+}
+
+func (c *tieredCache) remove(key string, staging bool) {
+	for _, t := range c.tiers {
+		t.remove(key, staging)
+	}
+}
+
+func (c *tieredCache) load(key string) (ReadCloser, error) {
+	var err error
+	for i, t := range c.tiers {
+		var r ReadCloser
+		if r, err = t.load(key); err == nil {
+			if i > 0 {
+				c.hit(i, key)
+			}
+			return r, nil
+		}
+	}
+	return nil, err
+}
+
+func (c *tieredCache) exist(key string) (string, bool) {
+	for _, t := range c.tiers {
+		if loc, ok := t.exist(key); ok {
+			return loc, ok
+		}
+	}
+	return "", false
+}
+
+func (c *tieredCache) uploaded(key string, size int) {
+	if c.stageTier >= 0 {
+		c.tiers[c.stageTier].uploaded(key, size)
+	}
+}
+
+func (c *tieredCache) stage(key string, data []byte, tierID uint8) (string, error) {
+	if c.stageTier < 0 {
+		return "", errors.New("no available cache dir")
+	}
+	return c.tiers[c.stageTier].stage(key, data, tierID)
+}
+
+func (c *tieredCache) removeStage(key string) error {
+	if c.stageTier < 0 {
+		return nil
+	}
+	return c.tiers[c.stageTier].removeStage(key)
+}
+
+func (c *tieredCache) stats() (int64, int64) {
+	var cnt, used int64
+	for _, t := range c.tiers {
+		n, u := t.stats()
+		cnt += n
+		used += u
+	}
+	return cnt, used
+}
+
+func (c *tieredCache) usedMemory() int64 {
+	var used int64
+	for _, t := range c.tiers {
+		used += t.usedMemory()
+	}
+	return used
+}
+
+func (c *tieredCache) isEmpty() bool {
+	for _, t := range c.tiers {
+		if !t.isEmpty() {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *tieredCache) getMetrics() *cacheManagerMetrics {
+	return c.tiers[0].getMetrics()
+}
+
+func (c *tieredCache) pin(key string) error {
+	if c.stageTier < 0 {
+		return errPinUnsupported
+	}
+	if err := c.tiers[c.stageTier].pin(key); err != nil {
+		return err
+	}
+	// move it into the tier of pinned blocks
+	for i, t := range c.tiers {
+		if i != c.stageTier {
+			if _, ok := t.exist(key); ok {
+				select {
+				case c.moves <- tierMove{key: key, from: i, to: c.stageTier}:
+				default:
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (c *tieredCache) unpin(key string) {
+	if c.stageTier >= 0 {
+		c.tiers[c.stageTier].unpin(key)
+	}
+}
+
+func (c *tieredCache) isPinned(key string) bool {
+	return c.stageTier >= 0 && c.tiers[c.stageTier].isPinned(key)
+}
+
+func (c *tieredCache) pinStats() (int64, int64) {
+	if c.stageTier < 0 {
+		return 0, 0
+	}
+	return c.tiers[c.stageTier].pinStats()
+}
@@ -30,6 +30,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/juicedata/juicefs/pkg/compress"
 	"github.com/juicedata/juicefs/pkg/object"
 	"github.com/juicedata/juicefs/pkg/utils"
@@ -128,6 +129,7 @@ func (s *rSlice) ReadAt(ctx context.Context, page *Page, off int) (n int, err er
 	}
 
 	key := s.key(indx)
+	policy := cachePolicyOf(ctx)
 	if s.store.conf.CacheEnabled() {
 		start := time.Now()
 		r, err := s.store.bcache.load(key)
@@ -141,6 +143,7 @@ func (s *rSlice) ReadAt(ctx context.Context, page *Page, off int) (n int, err er
 				s.store.cacheHits.Add(1)
 				s.store.cacheHitBytes.Add(float64(n))
 				s.store.cacheReadHist.Observe(time.Since(start).Seconds())
+				s.store.touchPolicy(policy, key)
 				return n, nil
 			}
 			logger.Warnf("remove partial cached block %s: %d %s", key, n, err)
@@ -151,8 +154,8 @@ func (s *rSlice) ReadAt(ctx context.Context, page *Page, off int) (n int, err er
 	s.store.cacheMiss.Add(1)
 	s.store.cacheMissBytes.Add(float64(len(p)))
 
-	if s.store.seekable &&
-		(!s.store.conf.CacheEnabled() || (boff > 0 && len(p) <= blockSize/4)) {
+	if s.store.codec.Load().seekable &&
+		(!s.store.conf.CacheEnabled() || policy.noCache() || (!policy.pinned() && boff > 0 && len(p) <= blockSize/4)) {
 		n, err = s.store.loadRange(ctx, key, page, boff)
 		if err == nil || !errors.Is(err, errTryFullRead) {
 			return n, err
@@ -166,7 +169,10 @@ func (s *rSlice) ReadAt(ctx context.Context, page *Page, off int) (n int, err er
 		} else {
 			tmp.Acquire()
 		}
-		err = s.store.load(ctx, key, tmp, s.store.shouldCache(blockSize), false)
+		cache := s.store.shouldCache(policy, blockSize)
+		if err = s.store.load(ctx, key, tmp, cache, false); err == nil && cache {
+			s.store.touchPolicy(policy, key)
+		}
 		return tmp, err
 	})
 	defer block.Release()
@@ -181,7 +187,7 @@ func (s *rSlice) ReadAt(ctx context.Context, page *Page, off int) (n int, err er
 
 func (s *rSlice) delete(indx int) error {
 	key := s.key(indx)
-	return s.store.delete(key)
+	return s.store.removeBlock(key)
 }
 
 func (s *rSlice) Remove() error {
@@ -195,6 +201,9 @@ func (s *rSlice) Remove() error {
 		// there could be multiple clients try to remove the same chunk in the same time,
 		// any of them should succeed if any blocks is removed
 		key := s.key(i)
+		if s.store.replica != nil && s.store.isPendingValid(key) {
+			s.store.replica.remove(key)
+		}
 		s.store.removePending(key)
 		s.store.bcache.remove(key, true)
 	}
@@ -244,6 +253,8 @@ type wSlice struct {
 	pendings    int
 	writeback   bool
 	tierID      uint8
+	compress    string
+	policy      *CachePolicy
 }
 
 func sliceForWrite(id uint64, store *cachedStore, tierID uint8) *wSlice {
@@ -264,6 +275,14 @@ func (s *wSlice) SetWriteback(enabled bool) {
 	s.writeback = enabled
 }
 
+func (s *wSlice) SetCompress(algr string) {
+	s.compress = algr
+}
+
+func (s *wSlice) SetCachePolicy(p *CachePolicy) {
+	s.policy = p
+}
+
 func (s *wSlice) WriteAt(p []byte, off int64) (n int, err error) {
 	if int(off)+len(p) > chunkSize {
 		return 0, fmt.Errorf("write out of chunk boundary: %d > %d", int(off)+len(p), chunkSize)
@@ -356,7 +375,31 @@ func (store *cachedStore) delete(key string) error {
 func (store *cachedStore) upload(ctx context.Context, key string, block *Page, s *wSlice) error {
 	sync := s != nil
 	blen := len(block.Data)
-	bufSize := store.compressor.CompressBound(blen)
+	if sync && store.shouldCacheWrite(s.policy, blen) {
+		// block will be freed after written into disk
+		store.bcache.cache(key, block, false, false)
+		store.touchPolicy(s.policy, key)
+	}
+	if store.dedupEnabled() {
+		if uploads, ok := store.addDedupBlock(ctx, key, block.Data); ok {
+			defer block.Release()
+			for _, u := range uploads {
+				if err := store.uploadObject(ctx, key, u.key, block.Slice(u.off, u.size), s); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return store.uploadObject(ctx, key, key, block, s)
+}
+
+// uploadObject compresses the data of block key and uploads it as objKey, the page is released after compressed.
+func (store *cachedStore) uploadObject(ctx context.Context, key, objKey string, block *Page, s *wSlice) error {
+	sync := s != nil
+	blen := len(block.Data)
+	compressor := store.compressorFor(s)
+	bufSize := compressor.CompressBound(blen)
 	var buf *Page
 	if bufSize > blen {
 		buf = NewOffPage(bufSize)
@@ -365,11 +408,7 @@ func (store *cachedStore) upload(ctx context.Context, key string, block *Page, s
 		buf.Acquire()
 	}
 	defer buf.Release()
-	if sync && (blen < store.conf.BlockSize || store.conf.CacheLargeWrite) {
-		// block will be freed after written into disk
-		store.bcache.cache(key, block, false, false)
-	}
-	n, err := store.compressor.Compress(buf.Data, block.Data)
+	n, err := compressor.Compress(buf.Data, block.Data)
 	block.Release()
 	if err != nil {
 		return fmt.Errorf("Compress block key %s: %s", key, err)
@@ -386,10 +425,10 @@ func (store *cachedStore) upload(ctx context.Context, key string, block *Page, s
 			err = fmt.Errorf("(cancelled) upload block %s: %s (after %d tries)", key, err, try)
 			break
 		}
-		if err = store.put(ctx, key, buf); err == nil {
+		if err = store.put(ctx, objKey, buf); err == nil {
 			break
 		}
-		logger.Debugf("Upload %s: %s (try %d)", key, err, try+1)
+		logger.Debugf("Upload %s: %s (try %d)", objKey, err, try+1)
 	}
 	if err != nil && try >= max {
 		err = fmt.Errorf("(max tries) upload block %s: %s (after %d tries)", key, err, try)
@@ -428,8 +467,15 @@ func (s *wSlice) upload(indx int) {
 			err := utils.WithTimeout(context.TODO(), func(context.Context) (err error) { // In case it hangs for more than 5 minutes(see fileWriter.flush), fallback to uploading directly to avoid `EIO`
 				defer block.Release()
 				stagingPath, err = s.store.bcache.stage(key, block.Data, s.tierID)
+				if err == nil && s.store.replica != nil {
+					// mirror it before acknowledged, or upload it directly
+					if err = s.store.replica.put(key, block.Data, s.tierID); err != nil {
+						_ = s.store.bcache.removeStage(key)
+						err = fmt.Errorf("replicate: %w", err)
+					}
+				}
 				if err == nil && stageFailed { // upload thread already marked me as failed because of timeout
-					_ = s.store.bcache.removeStage(key)
+					_ = s.store.removeStaging(key, stagingPath)
 				}
 				return err
 			}, s.store.conf.PutTimeout)
@@ -447,7 +493,7 @@ func (s *wSlice) upload(indx int) {
 						defer func() { <-s.store.currentUpload }()
 						if err = s.store.upload(ctx, key, block, nil); err == nil {
 							s.store.bcache.uploaded(key, blen)
-							if err := s.store.bcache.removeStage(key); err != nil {
+							if err := s.store.removeStaging(key, stagingPath); err != nil {
 								logger.Warnf("failed to remove stage %s in upload", stagingPath)
 							}
 						} else { // add to delay list and wait for later scanning
@@ -529,15 +575,22 @@ type Config struct {
 	CacheDir               string
 	CacheMode              os.FileMode
 	CacheSize              uint64
+	CachePinSize           uint64 // for each cache dir
 	CacheItems             int64
 	CacheChecksum          string
 	CacheEviction          string
 	CacheScanInterval      time.Duration
+	CacheIndex             bool // persist the index of cached blocks
+	CacheTiers             []CacheTier
+	CachePromoteHits       int // reads from a slower tier before promoted
 	CacheExpire            time.Duration
 	OSCache                bool
 	FreeSpace              float32
 	AutoCreate             bool
 	Compress               string
+	UntaggedCompress       string // codec of the blocks without tag, empty if no block is tagged
+	Dedup                  bool
+	DedupSecret            string // key of the HMAC of deduplicated chunks
 	MaxUpload              int
 	MaxDownload            int
 	MaxStageWrite          int
@@ -548,6 +601,7 @@ type Config struct {
 	WritebackThresholdSize int
 	UploadDelay            time.Duration
 	UploadHours            string
+	StageReplica           string // a dir or "peer" to mirror the staging blocks
 	HashPrefix             bool
 	BlockSize              int
 	GetTimeout             time.Duration
@@ -557,6 +611,14 @@ type Config struct {
 	BufferSize             uint64
 	Readahead              int
 	Prefetch               int
+	CacheGroup             string   // share the cached blocks with the clients in the same group
+	GroupListen            string   // address to serve the other members of cache group
+	RemoteCache            []string // addresses of the cache servers to read blocks from
+	CacheServer            string   // address to serve the cached blocks to the clients with remote cache
+	GroupSecret            string   `json:"-"` // to authenticate the members of cache group and cache servers
+	GroupTLSCert           string   // certificate to serve and verify the members over TLS
+	GroupTLSKey            string   // key of the certificate to serve the members
+	Volume                 string   // UUID of the volume, the name of the group of cache servers
 }
 
 func (c *Config) SelfCheck(uuid string) {
@@ -592,6 +654,15 @@ func (c *Config) SelfCheck(uuid string) {
 			ds[i] = filepath.Join(ds[i], uuid)
 		}
 		c.CacheDir = strings.Join(ds, string(os.PathListSeparator))
+		for i, t := range c.CacheTiers {
+			if t.Dir != "memory" {
+				ds := utils.SplitDir(t.Dir)
+				for j := range ds {
+					ds[j] = filepath.Join(ds[j], uuid)
+				}
+				c.CacheTiers[i].Dir = strings.Join(ds, string(os.PathListSeparator))
+			}
+		}
 		if cs := []string{CsNone, CsFull, CsShrink, CsExtend}; !utils.StringContains(cs, c.CacheChecksum) {
 			logger.Warnf("verify-cache-checksum should be one of %v", cs)
 			c.CacheChecksum = CsExtend
@@ -600,6 +671,18 @@ func (c *Config) SelfCheck(uuid string) {
 		logger.Warnf("writeback is not supported in memory cache mode")
 		c.Writeback = false
 	}
+	if c.StageReplica != "" && c.StageReplica != ReplicaPeer {
+		if !c.Writeback {
+			logger.Warnf("replica of staging blocks is ineffective without writeback")
+			c.StageReplica = ""
+		} else {
+			c.StageReplica = filepath.Join(c.StageReplica, uuid)
+			if utils.StringContains(utils.SplitDir(c.CacheDir), c.StageReplica) {
+				logger.Warnf("replica of staging blocks should not be in a cache dir, disable it")
+				c.StageReplica = ""
+			}
+		}
+	}
 	if c.Writeback {
 		if !c.CacheFullBlock {
 			logger.Warnf("cache-partial-only is ineffective for stage blocks with writeback enabled")
@@ -620,12 +703,12 @@ func (c *Config) SelfCheck(uuid string) {
 	}
 	if c.CacheEviction == "" {
 		c.CacheEviction = Eviction2Random
-	} else if c.CacheEviction != Eviction2Random && c.CacheEviction != EvictionNone && c.CacheEviction != EvictionLRU {
-		logger.Warnf("cache-eviction should be one of [%s, %s, %s]", EvictionNone, Eviction2Random, EvictionLRU)
+	} else if c.CacheEviction != Eviction2Random && c.CacheEviction != EvictionNone && c.CacheEviction != EvictionLRU && c.CacheEviction != Eviction2Q {
+		logger.Warnf("cache-eviction should be one of [%s, %s, %s, %s]", EvictionNone, Eviction2Random, EvictionLRU, Eviction2Q)
 		c.CacheEviction = Eviction2Random
 	}
-	if c.CacheDir == "memory" && c.CacheEviction == EvictionLRU {
-		logger.Warnf("LRU eviction is not supported in memory cache mode yet, setting it to 2-random")
+	if c.CacheDir == "memory" && (c.CacheEviction == EvictionLRU || c.CacheEviction == Eviction2Q) {
+		logger.Warnf("%s eviction is not supported in memory cache mode yet, setting it to 2-random", c.CacheEviction)
 		c.CacheEviction = Eviction2Random
 	}
 	if c.CacheExpire > 0 && c.CacheExpire < time.Second {
@@ -676,10 +759,16 @@ type cachedStore struct {
 	pendingMutex    sync.Mutex
 	startHour       int
 	endHour         int
-	compressor      compress.Compressor
-	seekable        bool
+	codec           atomic.Pointer[blockCodec]
 	upLimit         *ratelimit.Bucket
 	downLimit       *ratelimit.Bucket
+	dedup           atomic.Bool
+	dedupSecret     atomic.Pointer[[]byte]
+	dedupIndex      DedupIndex
+	dedupCache      *lru.Cache[string, []DedupChunk]
+	peers           *cacheGroup
+	expiry          policyExpiry
+	replica         *stageReplica
 
 	cacheHits           prometheus.Counter
 	cacheMiss           prometheus.Counter
@@ -691,6 +780,8 @@ type cachedStore struct {
 	objectDataBytes     *prometheus.CounterVec
 	stageBlockDelay     prometheus.Counter
 	stageBlockErrors    prometheus.Counter
+	dedupBlocks         prometheus.Counter
+	dedupBytes          prometheus.Counter
 }
 
 func logRequest(typeStr, key, param, reqID string, err error, used time.Duration) {
@@ -714,6 +805,76 @@ func (store *cachedStore) loadRange(ctx context.Context, key string, page *Page,
 		}
 	}
 
+	if store.peers != nil && !fromPeer(ctx) {
+		if n, err = store.peers.read(ctx, key, p, off); err == nil {
+			if store.peers.remote && !cachePolicyOf(ctx).noPrefetch() {
+				store.fetcher.fetch(key) // keep the whole block in local cache
+			}
+			return n, nil
+		}
+	}
+
+	chunks, err := store.dedupChunks(key)
+	if err != nil {
+		return 0, err
+	}
+	if chunks == nil {
+		n, err = store.loadRawRange(ctx, key, page, off)
+	} else {
+		// read the part of the chunks overlapping with the range
+		var coff, got int
+		end := off + len(p)
+		for _, c := range chunks {
+			cend := coff + int(c.Size)
+			if start, stop := max(off, coff), min(end, cend); start < stop {
+				sp := page.Slice(start-off, stop-start)
+				got, err = store.loadRawRange(ctx, DedupKey(c.Hash, int(c.Size)), sp, start-coff)
+				sp.Release()
+				n += got
+				if err != nil {
+					break
+				}
+			}
+			if coff = cend; coff >= end {
+				break
+			}
+		}
+	}
+	if errors.Is(err, context.Canceled) {
+		return 0, err
+	}
+	if err == nil {
+		if !cachePolicyOf(ctx).noPrefetch() {
+			store.fetcher.fetch(key)
+		}
+		return n, nil
+	}
+	// fall back to full read
+	return 0, errTryFullRead
+}
+
+// loadRawRange reads part of the data in object objKey starting from off into page, the tag of
+// the object is checked first if blocks could be tagged, errTryFullRead is returned if it's compressed.
+func (store *cachedStore) loadRawRange(ctx context.Context, objKey string, page *Page, off int) (int, error) {
+	if untagged := store.codec.Load().untagged; untagged != "" {
+		head := NewOffPage(compress.TagSize)
+		defer head.Release()
+		n, err := store.loadObjectRange(ctx, objKey, head, 0)
+		if err != nil {
+			return 0, err
+		}
+		skip := compress.RawOffset(head.Data[:n], untagged)
+		if skip < 0 {
+			return 0, errTryFullRead
+		}
+		off += skip
+	}
+	return store.loadObjectRange(ctx, objKey, page, off)
+}
+
+// loadObjectRange reads part of object objKey starting from off into page.
+func (store *cachedStore) loadObjectRange(ctx context.Context, objKey string, page *Page, off int) (n int, err error) {
+	p := page.Data
 	store.currentDownload <- struct{}{}
 	defer func() { <-store.currentDownload }()
 	if store.downLimit != nil {
@@ -728,7 +889,7 @@ func (store *cachedStore) loadRange(ctx context.Context, key string, page *Page,
 	page.Acquire()
 	err = utils.WithTimeout(ctx, func(cCtx context.Context) error {
 		defer page.Release()
-		in, err := store.storage.Get(cCtx, key, int64(off), int64(len(p)), object.WithRequestID(&reqID), object.WithStorageClass(&sc))
+		in, err := store.storage.Get(cCtx, objKey, int64(off), int64(len(p)), object.WithRequestID(&reqID), object.WithStorageClass(&sc))
 		if err == nil {
 			n, err = io.ReadFull(in, p)
 			_ = in.Close()
@@ -737,19 +898,16 @@ func (store *cachedStore) loadRange(ctx context.Context, key string, page *Page,
 	}, store.conf.GetTimeout)
 
 	used := time.Since(start)
-	logRequest("GET", key, fmt.Sprintf("RANGE(%d,%d) ", off, len(p)), reqID, err, used)
+	logRequest("GET", objKey, fmt.Sprintf("RANGE(%d,%d) ", off, len(p)), reqID, err, used)
 	if errors.Is(err, context.Canceled) {
 		return 0, err
 	}
 	store.objectDataBytes.WithLabelValues("GET", sc).Add(float64(n))
 	store.objectReqsHistogram.WithLabelValues("GET", sc).Observe(used.Seconds())
-	if err == nil {
-		store.fetcher.fetch(key)
-		return n, nil
+	if err != nil {
+		store.objectReqErrors.Add(1)
 	}
-	store.objectReqErrors.Add(1)
-	// fall back to full read
-	return 0, errTryFullRead
+	return n, err
 }
 
 func (store *cachedStore) load(ctx context.Context, key string, page *Page, cache bool, forceCache bool) (err error) {
@@ -759,9 +917,55 @@ func (store *cachedStore) load(ctx context.Context, key string, page *Page, cach
 			err = fmt.Errorf("recovered from %s", e)
 		}
 	}()
+	if store.peers != nil && !fromPeer(ctx) {
+		// the owner keeps it in cache, and the blocks from cache servers are cached locally
+		if _, err = store.peers.read(ctx, key, page.Data, 0); err == nil {
+			if cache && store.peers.remote {
+				store.bcache.cache(key, page, forceCache, !store.conf.OSCache)
+			}
+			return nil
+		}
+	}
+	chunks, err := store.dedupChunks(key)
+	if err != nil {
+		return err
+	}
+	if chunks == nil {
+		err = store.loadObject(ctx, key, key, page)
+	} else {
+		var off int
+		for _, c := range chunks {
+			size := int(c.Size)
+			if off+size > len(page.Data) {
+				return fmt.Errorf("chunks of %s exceed the size of block %d", key, len(page.Data))
+			}
+			cp := page.Slice(off, size)
+			err = store.loadObject(ctx, key, DedupKey(c.Hash, size), cp)
+			cp.Release()
+			if err != nil {
+				break
+			}
+			off += size
+		}
+		if err == nil && off < len(page.Data) {
+			err = fmt.Errorf("chunks of %s are shorter than the block: %d < %d", key, off, len(page.Data))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if cache {
+		store.bcache.cache(key, page, forceCache, !store.conf.OSCache)
+	}
+	return nil
+}
+
+// loadObject reads the whole object objKey of block key into page, and decompresses it.
+func (store *cachedStore) loadObject(ctx context.Context, key, objKey string, page *Page) (err error) {
 	store.currentDownload <- struct{}{}
 	defer func() { <-store.currentDownload }()
-	needed := store.compressor.CompressBound(len(page.Data))
+	compressor := store.codec.Load().compressor
+	needed := compressor.CompressBound(len(page.Data))
 	compressed := needed > len(page.Data)
 	// we don't know the actual size for compressed block
 	if store.downLimit != nil && !compressed {
@@ -786,7 +990,7 @@ func (store *cachedStore) load(ctx context.Context, key string, page *Page, cach
 	err = utils.WithTimeout(ctx, func(cCtx context.Context) error {
 		defer p.Release()
 		// it will be retried in the upper layer.
-		in, err = store.storage.Get(cCtx, key, 0, -1, object.WithRequestID(&reqID), object.WithStorageClass(&sc))
+		in, err = store.storage.Get(cCtx, objKey, 0, -1, object.WithRequestID(&reqID), object.WithStorageClass(&sc))
 		if err == nil {
 			n, err = io.ReadFull(in, p.Data)
 			_ = in.Close()
@@ -800,7 +1004,7 @@ func (store *cachedStore) load(ctx context.Context, key string, page *Page, cach
 		return err
 	}
 	used := time.Since(start)
-	logRequest("GET", key, "", reqID, err, used)
+	logRequest("GET", objKey, "", reqID, err, used)
 	if store.downLimit != nil && compressed {
 		store.downLimit.Wait(int64(n))
 	}
@@ -808,24 +1012,67 @@ func (store *cachedStore) load(ctx context.Context, key string, page *Page, cach
 	store.objectReqsHistogram.WithLabelValues("GET", sc).Observe(used.Seconds())
 	if err != nil {
 		store.objectReqErrors.Add(1)
-		return fmt.Errorf("get %s: %s", key, err)
+		return fmt.Errorf("get %s: %s", objKey, err)
 	}
 	if compressed {
-		n, err = store.compressor.Decompress(page.Data, p.Data[:n])
+		n, err = compressor.Decompress(page.Data, p.Data[:n])
 	}
 	if err != nil || n < len(page.Data) {
 		return fmt.Errorf("read %s fully: %v (%d < %d) after %s", key, err, n, len(page.Data), used)
 	}
-	if cache {
-		store.bcache.cache(key, page, forceCache, !store.conf.OSCache)
-	}
 	return nil
 }
 
+// blockCodec compresses the blocks, which could be changed after the format is reloaded.
+type blockCodec struct {
+	compress   string
+	untagged   string // codec of the blocks without tag, empty if no block is tagged
+	compressor compress.Compressor
+	seekable   bool // some blocks are stored without compression, the tag of them is checked before read partially
+}
+
+func newBlockCodec(algr, untagged string) *blockCodec {
+	var c compress.Compressor
+	if untagged == "" {
+		c = compress.NewCompressor(algr)
+	} else {
+		c = compress.NewTagged(algr, untagged)
+	}
+	if c == nil {
+		return nil
+	}
+	seekable := c.CompressBound(0) == 0
+	if untagged != "" {
+		// the tagged compressor reserves space for the tag, so check the codecs of blocks
+		seekable = isRawCodec(algr) || isRawCodec(untagged)
+	}
+	return &blockCodec{compress: algr, untagged: untagged, compressor: c, seekable: seekable}
+}
+
+func isRawCodec(algr string) bool {
+	c := compress.NewCompressor(algr)
+	return c != nil && c.CompressBound(0) == 0
+}
+
+// compressorFor returns the compressor for blocks of a slice, which may have its own codec.
+func (store *cachedStore) compressorFor(s *wSlice) compress.Compressor {
+	codec := store.codec.Load()
+	if s != nil && s.compress != "" && s.compress != codec.compress {
+		if codec.untagged == "" {
+			logger.Debugf("compression %s of slice %d is ignored since blocks are not tagged", s.compress, s.id)
+		} else if c := compress.NewTagged(s.compress, codec.untagged); c != nil {
+			return c
+		} else {
+			logger.Warnf("unknown compress algorithm of slice %d: %s", s.id, s.compress)
+		}
+	}
+	return codec.compressor
+}
+
 // NewCachedStore create a cached store.
 func NewCachedStore(storage object.ObjectStorage, config Config, reg prometheus.Registerer) ChunkStore {
-	compressor := compress.NewCompressor(config.Compress)
-	if compressor == nil {
+	codec := newBlockCodec(config.Compress, config.UntaggedCompress)
+	if codec == nil {
 		logger.Fatalf("unknown compress algorithm: %s", config.Compress)
 	}
 	if config.MaxRetries == 0 {
@@ -842,12 +1089,15 @@ func NewCachedStore(storage object.ObjectStorage, config Config, reg prometheus.
 		conf:            config,
 		currentUpload:   make(chan struct{}, config.MaxUpload),
 		currentDownload: make(chan struct{}, config.MaxDownload),
-		compressor:      compressor,
-		seekable:        compressor.CompressBound(0) == 0,
 		pendingCh:       make(chan *pendingItem, 100*config.MaxUpload),
 		pendingKeys:     make(map[string]*pendingItem),
 		group:           NewController(),
 	}
+	store.codec.Store(codec)
+	store.dedup.Store(config.Dedup)
+	dedupSecret := []byte(config.DedupSecret)
+	store.dedupSecret.Store(&dedupSecret)
+	store.dedupCache, _ = lru.New[string, []DedupChunk](dedupCacheSize)
 	if config.UploadLimit > 0 {
 		// there are overheads coming from HTTP/TCP/IP
 		store.upLimit = ratelimit.NewBucketWithRate(float64(config.UploadLimit)*0.85, config.UploadLimit/10)
@@ -856,11 +1106,27 @@ func NewCachedStore(storage object.ObjectStorage, config Config, reg prometheus.
 		store.downLimit = ratelimit.NewBucketWithRate(float64(config.DownloadLimit)*0.85, config.DownloadLimit/10)
 	}
 	store.initMetrics()
+	if config.CacheGroup != "" {
+		var err error
+		if store.peers, err = newCacheGroup(store, config.CacheGroup, config.GroupListen); err != nil {
+			logger.Warnf("Cache group %s is disabled: %s", config.CacheGroup, err)
+		}
+	} else if len(config.RemoteCache) > 0 {
+		var err error
+		if store.peers, err = newRemoteCache(store, config.RemoteCache); err != nil {
+			logger.Warnf("Remote cache is disabled: %s", err)
+		}
+	}
 	if store.conf.Writeback {
 		store.startHour, store.endHour, _ = config.parseHours()
 		if store.startHour != store.endHour {
 			logger.Infof("background upload at %d:00 ~ %d:00", store.startHour, store.endHour)
 		}
+		if config.StageReplica == ReplicaPeer && (store.peers == nil || store.peers.remote) {
+			logger.Warnf("Replica of staging blocks to peer requires a cache group, disable it")
+		} else if config.StageReplica != "" {
+			store.replica = newStageReplica(store, config.StageReplica)
+		}
 	}
 	store.bcache = newCacheManager(&config, reg, func(key, fpath string, force bool) bool {
 		if fi, err := os.Stat(fpath); err == nil {
@@ -891,6 +1157,10 @@ func NewCachedStore(storage object.ObjectStorage, config Config, reg prometheus.
 		if size == 0 || size > store.conf.BlockSize {
 			return
 		}
+		if store.peers != nil && !store.peers.remote && store.peers.owner(key) != "" {
+			store.peers.prefetch(key)
+			return
+		}
 		p := NewOffPage(size)
 		defer p.Release()
 		block, err := store.group.Execute(key, func() (*Page, error) { // dedup requests with full read
@@ -923,6 +1193,14 @@ func NewCachedStore(storage object.ObjectStorage, config Config, reg prometheus.
 				store.scanDelayedStaging()
 			}
 		}()
+		if store.replica != nil {
+			go store.replica.scan()
+		}
+	}
+	if config.CacheServer != "" {
+		if _, err := newCacheServer(store, config.CacheServer); err != nil {
+			logger.Fatalf("Serve cached blocks: %s", err)
+		}
 	}
 	store.regMetrics(reg)
 	return store
@@ -971,6 +1249,14 @@ func (store *cachedStore) initMetrics() {
 		Name: "staging_block_errors",
 		Help: "Total errors when staging blocks",
 	})
+	store.dedupBlocks = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "object_dedup_blocks",
+		Help: "Total chunks of blocks not uploaded since the same content exists",
+	})
+	store.dedupBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "object_dedup_bytes",
+		Help: "Total bytes not uploaded since the same content exists",
+	})
 }
 
 func (store *cachedStore) regMetrics(reg prometheus.Registerer) {
@@ -987,6 +1273,24 @@ func (store *cachedStore) regMetrics(reg prometheus.Registerer) {
 	reg.MustRegister(store.objectDataBytes)
 	reg.MustRegister(store.stageBlockDelay)
 	reg.MustRegister(store.stageBlockErrors)
+	reg.MustRegister(store.dedupBlocks)
+	reg.MustRegister(store.dedupBytes)
+	if store.peers != nil {
+		store.peers.regMetrics(reg)
+	}
+	reg.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name:        "blockcache_hit_ratio",
+			Help:        "ratio of reads served by cached blocks",
+			ConstLabels: prometheus.Labels{"policy": store.conf.CacheEviction},
+		},
+		func() float64 {
+			hits, miss := counterValue(store.cacheHits), counterValue(store.cacheMiss)
+			if hits+miss == 0 {
+				return 0
+			}
+			return hits / (hits + miss)
+		}))
 	reg.MustRegister(prometheus.NewGaugeFunc(
 		prometheus.GaugeOpts{
 			Name: "blockcache_blocks",
@@ -1005,6 +1309,24 @@ func (store *cachedStore) regMetrics(reg prometheus.Registerer) {
 			_, used := store.bcache.stats()
 			return float64(used)
 		}))
+	reg.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "blockcache_pinned_blocks",
+			Help: "number of pinned blocks",
+		},
+		func() float64 {
+			cnt, _ := store.bcache.pinStats()
+			return float64(cnt)
+		}))
+	reg.MustRegister(prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "blockcache_pinned_bytes",
+			Help: "number of pinned bytes",
+		},
+		func() float64 {
+			_, used := store.bcache.pinStats()
+			return float64(used)
+		}))
 	reg.MustRegister(prometheus.NewGaugeFunc(
 		prometheus.GaugeOpts{
 			Name: "object_request_uploading",
@@ -1015,7 +1337,13 @@ func (store *cachedStore) regMetrics(reg prometheus.Registerer) {
 		}))
 }
 
-func (store *cachedStore) shouldCache(size int) bool {
+func (store *cachedStore) shouldCache(p *CachePolicy, size int) bool {
+	if p.pinned() {
+		return true
+	}
+	if p != nil && p.Cache != "" {
+		return p.Cache == CacheFull || p.Cache == CachePartial && size < store.conf.BlockSize
+	}
 	return store.conf.CacheFullBlock || size < store.conf.BlockSize
 }
 
@@ -1025,7 +1353,8 @@ func parseObjOrigSize(key string) int {
 	return l
 }
 
-func (store *cachedStore) uploadStagingFile(key string, stagingPath string) {
+// uploadStagingFile uploads a staging block, force to ignore the upload hours.
+func (store *cachedStore) uploadStagingFile(key string, stagingPath string, force bool) bool {
 	store.currentUpload <- struct{}{}
 	defer func() {
 		<-store.currentUpload
@@ -1036,14 +1365,14 @@ func (store *cachedStore) uploadStagingFile(key string, stagingPath string) {
 	store.pendingMutex.Unlock()
 	if !ok {
 		logger.Debugf("Key %s is not needed, drop it", key)
-		return
+		return false
 	}
 	defer func() {
 		item.uploading.Store(false)
 	}()
 
-	if !store.canUpload() {
-		return
+	if !force && !store.canUpload() {
+		return false
 	}
 
 	blen := parseObjOrigSize(key)
@@ -1054,7 +1383,7 @@ func (store *cachedStore) uploadStagingFile(key string, stagingPath string) {
 		} else {
 			logger.Debugf("Key %s is not needed, drop it", key)
 		}
-		return
+		return false
 	}
 	block := NewOffPage(blen)
 	_, err = f.ReadAt(block.Data, 0)
@@ -1071,27 +1400,28 @@ func (store *cachedStore) uploadStagingFile(key string, stagingPath string) {
 	if err != nil {
 		block.Release()
 		logger.Errorf("Read staging file %s: %s", stagingPath, err)
-		return
+		return false
 	}
 	if !store.isPendingValid(key) {
 		block.Release()
 		logger.Debugf("Key %s is not needed, drop it", key)
-		return
+		return false
 	}
 	ctx := context.WithValue(context.Background(), object.TierKey{}, tierID)
 	store.stageBlockDelay.Add(time.Since(item.ts).Seconds())
 	if err = store.upload(ctx, key, block, nil); err == nil {
 		if !store.isPendingValid(key) { // Delete leaked objects if it's already deleted by other goroutines
-			err := store.delete(key)
+			err := store.removeBlock(key)
 			logger.Infof("Key %s is not needed, abandoned, err: %v", key, err)
 		} else {
 			store.bcache.uploaded(key, blen)
 			store.removePending(key)
-			if err := store.bcache.removeStage(key); err != nil {
+			if err := store.removeStaging(key, stagingPath); err != nil {
 				logger.Warnf("failed to remove stage %s, in upload staging file", stagingPath)
 			}
 		}
 	}
+	return err == nil
 }
 
 func (store *cachedStore) addDelayedStaging(key, stagingPath string, added time.Time, force bool) bool {
@@ -1148,7 +1478,7 @@ func (store *cachedStore) scanDelayedStaging() {
 
 func (store *cachedStore) uploader() {
 	for it := range store.pendingCh {
-		store.uploadStagingFile(it.key, it.fpath)
+		store.uploadStagingFile(it.key, it.fpath, false)
 	}
 }
 
@@ -1197,6 +1527,20 @@ func (store *cachedStore) FillCache(id uint64, length uint32) error {
 	return err
 }
 
+func (store *cachedStore) PrefetchCache(id uint64, length uint32, off, size uint32) {
+	if store.conf.Prefetch == 0 || size == 0 || off >= length {
+		return
+	}
+	r := sliceForRead(id, int(length), store)
+	keys := r.keys()
+	last := r.index(int(min(off+size, length) - 1))
+	for i := r.index(int(off)); i <= last; i++ {
+		if _, existed := store.bcache.exist(keys[i]); !existed {
+			store.fetcher.fetch(keys[i])
+		}
+	}
+}
+
 func (store *cachedStore) EvictCache(id uint64, length uint32) error {
 	r := sliceForRead(id, int(length), store)
 	keys := r.keys()
@@ -1224,6 +1568,20 @@ func (store *cachedStore) UsedMemory() int64 {
 	return store.bcache.usedMemory()
 }
 
+func (store *cachedStore) UpdateCompress(algr, untagged string) {
+	old := store.codec.Load()
+	if algr == old.compress && untagged == old.untagged {
+		return
+	}
+	codec := newBlockCodec(algr, untagged)
+	if codec == nil {
+		logger.Warnf("unknown compress algorithm: %s", algr)
+		return
+	}
+	logger.Infof("Compression changed from %s to %s (untagged: %s)", old.compress, algr, untagged)
+	store.codec.Store(codec)
+}
+
 func (store *cachedStore) UpdateLimit(upload, download int64) {
 	if upload = upload * 1e6 / 8; upload != store.conf.UploadLimit {
 		logger.Infof("Upload limit changed from %d to %d", store.conf.UploadLimit, upload)
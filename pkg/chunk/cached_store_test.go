@@ -23,8 +23,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -154,6 +156,67 @@ func TestStoreCompressed(t *testing.T) {
 	testStore(t, store)
 }
 
+type rangeGets struct {
+	object.ObjectStorage
+	sync.Mutex
+	limits []int64
+}
+
+func (r *rangeGets) Get(ctx context.Context, key string, off, limit int64, getters ...object.AttrGetter) (io.ReadCloser, error) {
+	r.Lock()
+	r.limits = append(r.limits, limit)
+	r.Unlock()
+	return r.ObjectStorage.Get(ctx, key, off, limit, getters...)
+}
+
+func (r *rangeGets) reset() []int64 {
+	r.Lock()
+	defer r.Unlock()
+	limits := r.limits
+	r.limits = nil
+	return limits
+}
+
+func TestStoreTagged(t *testing.T) {
+	for _, untagged := range []string{"none", "lz4"} {
+		mem, _ := object.CreateStorage("mem", "", "", "", "")
+		blob := &rangeGets{ObjectStorage: mem}
+		conf := defaultConf
+		conf.CacheSize = 0
+		conf.Compress = "none"
+		conf.UntaggedCompress = untagged
+		store := NewCachedStore(blob, conf, nil)
+		require.True(t, store.(*cachedStore).codec.Load().seekable)
+
+		data := make([]byte, conf.BlockSize)
+		rand.New(rand.NewSource(1)).Read(data)
+		write := func(id uint64) {
+			w := store.NewWriter(id, 0)
+			_, err := w.WriteAt(data, 0)
+			require.Nil(t, err)
+			require.Nil(t, w.Finish(len(data)))
+		}
+		read := func(id uint64) {
+			p := NewPage(make([]byte, 100))
+			n, err := store.NewReader(id, len(data)).ReadAt(ctx, p, 1000)
+			require.Nil(t, err)
+			require.Equal(t, 100, n)
+			require.Equal(t, data[1000:1100], p.Data)
+		}
+		write(1)
+		blob.reset()
+		read(1)
+		require.Equal(t, []int64{4, 100}, blob.reset(), "block without compression should be read partially")
+
+		store.UpdateCompress("zstd", untagged)
+		write(2)
+		blob.reset()
+		read(2)
+		require.NotEqual(t, []int64{4, 100}, blob.reset(), "compressed block should be read fully")
+		read(1)
+	}
+}
+
 func TestStoreLimited(t *testing.T) {
 	mem, _ := object.CreateStorage("mem", "", "", "", "")
 	conf := defaultConf
@@ -270,6 +333,49 @@ func TestStoreDelayed(t *testing.T) {
 	}
 }
 
+func TestStageReplica(t *testing.T) {
+	mem, _ := object.CreateStorage("mem", "", "", "", "")
+	conf := defaultConf
+	conf.CacheDir = t.TempDir()
+	conf.Writeback = true
+	conf.UploadDelay = time.Hour
+	conf.StageReplica = t.TempDir()
+	store := NewCachedStore(mem, conf, nil).(*cachedStore)
+	if store.replica == nil {
+		t.Fatalf("replica of staging blocks is not enabled")
+	}
+
+	w := store.NewWriter(11, 0)
+	if _, err := w.WriteAt(make([]byte, 1024), 0); err != nil {
+		t.Fatalf("write fail: %s", err)
+	}
+	if err := w.Finish(1024); err != nil {
+		t.Fatalf("write fail: %s", err)
+	}
+	key := "chunks/0/0/11_0_1024"
+	replica := store.replica.path(key)
+	if _, err := os.Stat(replica); err != nil {
+		t.Fatalf("replica of %s: %s", key, err)
+	}
+	if _, err := mem.Head(ctx, key); err == nil {
+		t.Fatalf("%s should not be uploaded before the delay", key)
+	}
+
+	var total, uploaded int
+	if err := store.UploadStaging(func() { total++ }, func() { uploaded++ }); err != nil {
+		t.Fatalf("upload staging: %s", err)
+	}
+	if total != 1 || uploaded != 1 {
+		t.Fatalf("expect 1 staging block uploaded, but got %d/%d", uploaded, total)
+	}
+	if _, err := mem.Head(ctx, key); err != nil {
+		t.Fatalf("head object %s: %s", key, err)
+	}
+	if _, err := os.Stat(replica); !os.IsNotExist(err) {
+		t.Fatalf("replica of %s should be removed after uploaded: %v", key, err)
+	}
+}
+
 func TestStoreMultiBuckets(t *testing.T) {
 	mem, _ := object.CreateStorage("mem", "", "", "", "")
 	conf := defaultConf
@@ -408,3 +514,201 @@ func TestStoreRetry(t *testing.T) {
 	cs.(*cachedStore).load(context.TODO(), "non", p, false, false) // wont retry
 	require.Equal(t, int32(1), s.cnt)
 }
+
+type memDedupIndex struct {
+	sync.Mutex
+	blocks map[string][]DedupChunk
+	refs   map[string]int
+}
+
+func (d *memDedupIndex) AddBlock(id uint64, indx uint32, chunks []DedupChunk) ([]bool, error) {
+	d.Lock()
+	defer d.Unlock()
+	exists := make([]bool, len(chunks))
+	k := fmt.Sprintf("%d_%d", id, indx)
+	if _, ok := d.blocks[k]; ok {
+		for i := range exists {
+			exists[i] = true
+		}
+		return exists, nil
+	}
+	d.blocks[k] = chunks
+	for i, c := range chunks {
+		exists[i] = d.refs[c.Hash] > 0
+	}
+	for _, c := range chunks {
+		d.refs[c.Hash]++
+	}
+	return exists, nil
+}
+
+func (d *memDedupIndex) GetBlock(id uint64, indx uint32) ([]DedupChunk, error) {
+	d.Lock()
+	defer d.Unlock()
+	return d.blocks[fmt.Sprintf("%d_%d", id, indx)], nil
+}
+
+func (d *memDedupIndex) RemoveBlock(id uint64, indx uint32) ([]DedupChunk, error) {
+	d.Lock()
+	defer d.Unlock()
+	k := fmt.Sprintf("%d_%d", id, indx)
+	chunks := d.blocks[k]
+	delete(d.blocks, k)
+	for _, c := range chunks {
+		d.refs[c.Hash]--
+	}
+	return chunks, nil
+}
+
+func TestStoreDedup(t *testing.T) {
+	mem, _ := object.CreateStorage("mem", "", "", "", "")
+	conf := defaultConf
+	conf.CacheDir = "memory"
+	conf.Compress = "lz4"
+	conf.Dedup = true
+	store := NewCachedStore(mem, conf, nil)
+	index := &memDedupIndex{blocks: make(map[string][]DedupChunk), refs: make(map[string]int)}
+	store.SetDedupIndex(index)
+
+	// written before dedup is enabled
+	store.UpdateDedup(false)
+	require.Nil(t, forgetSlice(store, 10, 100))
+	store.UpdateDedup(true)
+	size := conf.BlockSize + 100
+	for _, id := range []uint64{11, 12} {
+		require.Nil(t, forgetSlice(store, id, size))
+	}
+	countObjects := func(prefix string) int {
+		objs, err := object.ListAll(ctx, mem, prefix, "", true, false)
+		require.Nil(t, err)
+		var n int
+		for o := range objs {
+			if o != nil && !o.IsDir() {
+				n++
+			}
+		}
+		return n
+	}
+	require.Equal(t, 1, countObjects("chunks/"))
+	require.Equal(t, 2, countObjects("dedup/"))
+	require.Equal(t, 2, index.refs[dedupHash(nil, bytes.Repeat([]byte{0x41}, conf.BlockSize))])
+
+	reads := []struct {
+		id        uint64
+		size, off int
+	}{{10, 100, 0}, {11, size, conf.BlockSize - 50}, {12, size, conf.BlockSize - 50}}
+	for _, r := range reads {
+		p := NewPage(make([]byte, 100))
+		n, err := store.NewReader(r.id, r.size).ReadAt(ctx, p, r.off)
+		require.Nil(t, err)
+		require.Equal(t, 100, n)
+		require.Equal(t, bytes.Repeat([]byte{0x41}, 100), p.Data)
+	}
+
+	require.Nil(t, store.Remove(10, 100))
+	require.Nil(t, store.Remove(11, size))
+	require.Equal(t, 0, countObjects("chunks/"))
+	require.Equal(t, 1, index.refs[dedupHash(nil, bytes.Repeat([]byte{0x41}, conf.BlockSize))])
+	_, err := store.NewReader(12, size).ReadAt(ctx, NewPage(make([]byte, 10)), 0)
+	require.Nil(t, err)
+}
+
+func TestDedupChunks(t *testing.T) {
+	data := make([]byte, 8<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	split := func(data []byte) map[string]bool {
+		sizes := splitDedupChunks(data)
+		hashes := make(map[string]bool, len(sizes))
+		var off int
+		for i, size := range sizes {
+			if i < len(sizes)-1 {
+				require.GreaterOrEqual(t, size, dedupMinChunk)
+			}
+			hashes[dedupHash(nil, data[off:off+size])] = true
+			off += size
+		}
+		require.Equal(t, len(data), off)
+		return hashes
+	}
+	chunks := split(data)
+	require.Greater(t, len(chunks), 2)
+
+	// the chunks after an insertion are not changed
+	shifted := append(append(append([]byte{}, data[:1000]...), []byte("inserted")...), data[1000:]...)
+	var same int
+	for h := range split(shifted) {
+		if chunks[h] {
+			same++
+		}
+	}
+	require.GreaterOrEqual(t, same, len(chunks)-1)
+	require.Equal(t, []int{100}, splitDedupChunks(data[:100]))
+}
+
+func TestStoreDedupChunks(t *testing.T) {
+	mem, _ := object.CreateStorage("mem", "", "", "", "")
+	conf := defaultConf
+	conf.BlockSize = 4 << 20
+	conf.CacheSize = 0
+	conf.Dedup = true
+	index := &memDedupIndex{blocks: make(map[string][]DedupChunk), refs: make(map[string]int)}
+	store := NewCachedStore(mem, conf, nil)
+	store.SetDedupIndex(index)
+
+	data := make([]byte, conf.BlockSize+1000)
+	rand.New(rand.NewSource(2)).Read(data)
+	write := func(id uint64, data []byte) {
+		w := store.NewWriter(id, 0)
+		_, err := w.WriteAt(data, 0)
+		require.Nil(t, err)
+		require.Nil(t, w.Finish(conf.BlockSize))
+	}
+	write(1, data[1000:])
+	write(2, data[:conf.BlockSize])
+	objs, err := object.ListAll(ctx, mem, "dedup/", "", true, false)
+	require.Nil(t, err)
+	var n int
+	for o := range objs {
+		if o != nil && !o.IsDir() {
+			n++
+		}
+	}
+	blocks := 0
+	for _, chunks := range index.blocks {
+		blocks += len(chunks)
+	}
+	require.Less(t, n, blocks, "the chunks after the shifted content should be shared")
+
+	for _, r := range [][2]int{{0, conf.BlockSize}, {100, 1000}, {1 << 20, 3 << 20}} {
+		p := NewPage(make([]byte, r[1]))
+		got, err := store.NewReader(2, conf.BlockSize).ReadAt(ctx, p, r[0])
+		require.Nil(t, err)
+		require.Equal(t, r[1], got)
+		require.Equal(t, data[r[0]:r[0]+r[1]], p.Data)
+	}
+}
+
+func TestParseDedupKey(t *testing.T) {
+	hash := dedupHash(nil, []byte("hello"))
+	key := DedupKey(hash, 5)
+	require.Equal(t, "dedup/"+hash[:2]+"/"+hash[2:4]+"/"+hash+"_5", key)
+	h, size, ok := ParseDedupKey(key)
+	require.True(t, ok)
+	require.Equal(t, hash, h)
+	require.Equal(t, 5, size)
+	_, _, ok = ParseDedupKey("chunks/0/0/1_0_5")
+	require.False(t, ok)
+
+	// the hash keyed by the secret of volume is not the plain SHA-256
+	keyed := dedupHash([]byte("secret"), []byte("hello"))
+	require.NotEqual(t, hash, keyed)
+	require.NotEqual(t, keyed, dedupHash([]byte("other"), []byte("hello")))
+	h, _, ok = ParseDedupKey(DedupKey(keyed, 5))
+	require.True(t, ok)
+	require.Equal(t, keyed, h)
+
+	id, indx, ok := parseBlockKey("chunks/0A/0/10_3_1024")
+	require.True(t, ok)
+	require.Equal(t, uint64(10), id)
+	require.Equal(t, uint32(3), indx)
+}
@@ -32,6 +32,10 @@ type Writer interface {
 	ID() uint64
 	SetID(id uint64)
 	SetWriteback(enabled bool)
+	// SetCompress sets the codec for the blocks of this slice, instead of the default one.
+	SetCompress(algr string)
+	// SetCachePolicy sets the cache policy for the blocks of this slice, instead of the mount options.
+	SetCachePolicy(p *CachePolicy)
 	FlushTo(offset int) error
 	Finish(length int) error
 	Abort()
@@ -44,7 +48,27 @@ type ChunkStore interface {
 	FillCache(id uint64, length uint32) error
 	EvictCache(id uint64, length uint32) error
 	CheckCache(id uint64, length uint32, handler func(exists bool, loc string, size int)) error
+	// PinCache loads the blocks into local cache and keeps them from eviction.
+	PinCache(id uint64, length uint32) error
+	UnpinCache(id uint64, length uint32) error
+	// PinnedCache returns the size of the pinned blocks.
+	PinnedCache(id uint64, length uint32) uint64
+	// PrefetchCache schedules the blocks covering [off, off+size) of the slice to be downloaded into
+	// local cache in background, it's ignored if prefetch is disabled or too busy.
+	PrefetchCache(id uint64, length uint32, off, size uint32)
+	// FillRemoteCache loads the blocks into the cache of other members in cache group or the cache servers.
+	FillRemoteCache(id uint64, length uint32) error
+	// UploadStaging uploads the staging blocks now, pre is called for every block found and post after
+	// it's uploaded.
+	UploadStaging(pre, post func()) error
 	UsedMemory() int64
 	UpdateLimit(upload, download int64)
+	UpdateCompress(algr, untagged string)
+	// SetDedupIndex sets the index of deduplicated blocks, which is required to enable dedup.
+	SetDedupIndex(index DedupIndex)
+	UpdateDedup(enabled bool, secret string)
+	// JoinCacheGroup shares the cached blocks with the members of cache group until ctx is done, it
+	// returns the address serving the other members.
+	JoinCacheGroup(ctx context.Context, members func() ([]string, error)) string
 	BlobStorage() object.ObjectStorage
 }
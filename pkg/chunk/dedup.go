@@ -0,0 +1,248 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chunk
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DedupChunk is a content-defined chunk of block, which is stored in the object addressed by its content.
+type DedupChunk struct {
+	Hash string // hex encoded HMAC-SHA256 of the chunk, or SHA-256 if the volume has no dedup secret
+	Size uint32
+}
+
+// DedupIndex maps the blocks of slices to the chunks of them, and the objects of chunks are
+// reference counted so that the same content is stored only once.
+type DedupIndex interface {
+	// AddBlock references the objects of the chunks of block indx of slice id, it returns whether
+	// each object was referenced by other blocks before.
+	AddBlock(id uint64, indx uint32, chunks []DedupChunk) ([]bool, error)
+	// GetBlock returns the chunks of block indx of slice id, or nil if the block is not deduplicated.
+	GetBlock(id uint64, indx uint32) ([]DedupChunk, error)
+	// RemoveBlock drops the references of block indx of slice id, and returns the chunks of it,
+	// or nil if the block is not deduplicated.
+	RemoveBlock(id uint64, indx uint32) ([]DedupChunk, error)
+}
+
+const (
+	dedupMinChunk  = 256 << 10
+	dedupChunkMask = uint64(0xfffff) << 44 // cut when the top 20 bits are zero, about 1 MiB after dedupMinChunk
+	dedupWindow    = 64                    // the gear hash only depends on the last 64 bytes
+	dedupCacheSize = 1 << 16
+)
+
+// gearTable is the random table for the gear hash, which should NEVER be changed, otherwise the
+// same content will be split differently.
+var gearTable = func() (t [256]uint64) {
+	seed := uint64(0x6a75696365667321)
+	for i := range t {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		t[i] = z ^ (z >> 31)
+	}
+	return
+}()
+
+// splitDedupChunks splits a block into chunks by its content with a gear hash, so the chunks after
+// an insertion or deletion are still the same. It returns the sizes of the chunks.
+func splitDedupChunks(data []byte) []int {
+	var sizes []int
+	for len(data) > 0 {
+		n := len(data)
+		var h uint64
+		for i := dedupMinChunk - dedupWindow; i < len(data); i++ {
+			h = h<<1 + gearTable[data[i]]
+			if i+1 >= dedupMinChunk && h&dedupChunkMask == 0 {
+				n = i + 1
+				break
+			}
+		}
+		sizes = append(sizes, n)
+		data = data[n:]
+	}
+	return sizes
+}
+
+// DedupKey returns the key of the object for a chunk with content hash.
+func DedupKey(hash string, size int) string {
+	return fmt.Sprintf("dedup/%s/%s/%s_%d", hash[:2], hash[2:4], hash, size)
+}
+
+// ParseDedupKey returns the hash and size of a deduplicated object, the prefix "dedup/" is optional.
+func ParseDedupKey(key string) (string, int, bool) {
+	name := key[strings.LastIndexByte(key, '/')+1:]
+	p := strings.LastIndexByte(name, '_')
+	if p != 64 {
+		return "", 0, false
+	}
+	size, err := strconv.Atoi(name[p+1:])
+	if err != nil {
+		return "", 0, false
+	}
+	return name[:p], size, true
+}
+
+// dedupHash returns the hash of a chunk keyed by secret, so the content can't be confirmed by the
+// names of objects without it. The plain SHA-256 is used by the volumes created without a secret.
+func dedupHash(secret, data []byte) string {
+	if len(secret) == 0 {
+		sum := sha256.Sum256(data)
+		return hex.EncodeToString(sum[:])
+	}
+	h := hmac.New(sha256.New, secret)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// parseBlockKey returns the slice id and index of a block from its key.
+func parseBlockKey(key string) (id uint64, indx uint32, ok bool) {
+	parts := strings.Split(key[strings.LastIndexByte(key, '/')+1:], "_")
+	if len(parts) != 3 {
+		return
+	}
+	var err error
+	if id, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+		return
+	}
+	i, err := strconv.ParseUint(parts[1], 10, 32)
+	return id, uint32(i), err == nil
+}
+
+func (store *cachedStore) dedupEnabled() bool {
+	return store.dedup.Load() && store.dedupIndex != nil
+}
+
+// dedupChunks returns the chunks of a block, or nil if it's not deduplicated. They are cached since
+// the chunks of a block never change.
+func (store *cachedStore) dedupChunks(key string) ([]DedupChunk, error) {
+	if !store.dedupEnabled() {
+		return nil, nil
+	}
+	if chunks, ok := store.dedupCache.Get(key); ok {
+		return chunks, nil
+	}
+	id, indx, ok := parseBlockKey(key)
+	if !ok {
+		return nil, nil
+	}
+	chunks, err := store.dedupIndex.GetBlock(id, indx)
+	if err != nil {
+		return nil, fmt.Errorf("lookup dedup index of %s: %s", key, err)
+	}
+	store.dedupCache.Add(key, chunks)
+	return chunks, nil
+}
+
+// dedupUpload is a chunk of block to be uploaded.
+type dedupUpload struct {
+	key  string
+	off  int
+	size int
+}
+
+// addDedupBlock splits a block into chunks and references the objects with the same content, it
+// returns the chunks to be uploaded, or false if the block should be uploaded without dedup.
+func (store *cachedStore) addDedupBlock(ctx context.Context, key string, data []byte) ([]dedupUpload, bool) {
+	id, indx, ok := parseBlockKey(key)
+	if !ok {
+		return nil, false
+	}
+	sizes := splitDedupChunks(data)
+	chunks := make([]DedupChunk, len(sizes))
+	var secret []byte
+	if p := store.dedupSecret.Load(); p != nil {
+		secret = *p
+	}
+	var off int
+	for i, size := range sizes {
+		chunks[i] = DedupChunk{dedupHash(secret, data[off:off+size]), uint32(size)}
+		off += size
+	}
+	exists, err := store.dedupIndex.AddBlock(id, indx, chunks)
+	if err != nil {
+		// a block missing from the index is read with its own key, as well as the ones
+		// referencing objects being deleted
+		logger.Warnf("add %s into dedup index: %s, upload it without dedup", key, err)
+		return nil, false
+	}
+	store.dedupCache.Add(key, chunks)
+
+	var uploads []dedupUpload
+	seen := make(map[string]bool, len(chunks))
+	off = 0
+	for i, c := range chunks {
+		dkey := DedupKey(c.Hash, int(c.Size))
+		if exists[i] && !seen[dkey] {
+			// the object may be still uploading by another client, or failed
+			if _, err = store.storage.Head(ctx, dkey); err != nil {
+				logger.Debugf("Head %s: %s, upload it again", dkey, err)
+				exists[i] = false
+			}
+		}
+		if !exists[i] && !seen[dkey] {
+			uploads = append(uploads, dedupUpload{dkey, off, int(c.Size)})
+		} else {
+			store.dedupBlocks.Add(1)
+			store.dedupBytes.Add(float64(c.Size))
+		}
+		seen[dkey] = true
+		off += int(c.Size)
+	}
+	return uploads, true
+}
+
+// removeBlock deletes the object of a block, or drops the references of its chunks if it's deduplicated.
+// The objects not referenced any more are deleted by gc.
+func (store *cachedStore) removeBlock(key string) error {
+	if store.dedupEnabled() {
+		if id, indx, ok := parseBlockKey(key); ok {
+			store.dedupCache.Remove(key)
+			chunks, err := store.dedupIndex.RemoveBlock(id, indx)
+			if err != nil {
+				return fmt.Errorf("remove %s from dedup index: %s", key, err)
+			}
+			if chunks != nil {
+				return nil
+			}
+		}
+	}
+	return store.delete(key)
+}
+
+func (store *cachedStore) SetDedupIndex(index DedupIndex) {
+	store.dedupIndex = index
+}
+
+func (store *cachedStore) UpdateDedup(enabled bool, secret string) {
+	key := []byte(secret)
+	if old := store.dedupSecret.Swap(&key); old == nil || string(*old) != secret {
+		logger.Infof("Secret of deduplicated chunks is changed")
+	}
+	if store.dedup.Swap(enabled) != enabled {
+		logger.Infof("Deduplication of blocks is changed to %t", enabled)
+	}
+}
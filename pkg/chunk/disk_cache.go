@@ -17,6 +17,7 @@
 package chunk
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -43,6 +44,7 @@ var (
 	stagingDir          = "rawstaging"
 	cacheDir            = "raw"
 	maxIODur            = time.Second * 30
+	indexStateInterval  = time.Minute * 5
 	stagingBlocks       atomic.Int64
 	errStageFull        = errors.New("space not enough on device")
 	errStageConcurrency = errors.New("concurrent staging limit reached")
@@ -86,6 +88,15 @@ type diskCache struct {
 	rawFull   bool
 	checksum  string // checksum level
 	uploader  func(key, path string, force bool) bool
+	demote    func(key string, r ReadCloser) bool // receives the evicted blocks
+
+	// the pinned blocks are kept out of keys, so they are never evicted
+	pins        map[cacheKey]bool // pinned key -> cached
+	pinCapacity int64
+	pinReserved int64 // size of all pinned blocks, cached or not
+	pinBlocks   int64 // number of cached pinned blocks
+	pinBytes    int64 // size of cached pinned blocks
+	pinsDirty   bool
 
 	opTs map[time.Duration]func() error
 	opMu sync.Mutex
@@ -124,12 +135,17 @@ func newDiskCache(m *cacheManagerMetrics, dir string, cacheSize, maxItems int64,
 		scanInterval:        config.CacheScanInterval,
 		cacheExpire:         config.CacheExpire,
 		keys:                keyIndex,
+		pins:                make(map[cacheKey]bool),
+		pinCapacity:         int64(config.CachePinSize),
 		pending:             make(chan pendingFile, pendingPages),
 		pages:               make(map[string]*Page),
 		uploader:            uploader,
 		opTs:                make(map[time.Duration]func() error),
 		stagedBlockCooldown: config.CacheExpire / 2,
 	}
+	if config.CacheIndex {
+		c.keys = &journaledIndex{KeyIndex: keyIndex, rewrite: true}
+	}
 	c.stateLock = sync.Mutex{}
 	if config.Writeback {
 		c.state = newDCState(dcUnchanged, c)
@@ -148,7 +164,15 @@ func newDiskCache(m *cacheManagerMetrics, dir string, cacheSize, maxItems int64,
 	c.setLimitByFreeRatio(usage, c.freeRatio)
 
 	c.createLockFile()
+	c.loadIndexState()
+	c.loadPins()
+	if config.CacheIndex {
+		c.loadIndex()
+		go c.flushIndex(indexFlushInterval)
+	}
 	go c.checkLockFile()
+	go c.saveIndexState(indexStateInterval)
+	go c.savePins(pinsSaveInterval)
 	go c.flush()
 	go c.checkFreeSpace()
 	if c.cacheExpire > 0 {
@@ -229,6 +253,74 @@ func (cache *diskCache) checkLockFile() {
 	}
 }
 
+func (cache *diskCache) indexStatePath() string {
+	return filepath.Join(cache.dir, ".eviction."+cache.keys.name())
+}
+
+// loadIndexState restores the keys remembered by the eviction policy before restarted.
+func (cache *diskCache) loadIndexState() {
+	index, ok := unwrapIndex(cache.keys).(statefulIndex)
+	if !ok {
+		return
+	}
+	path := cache.indexStatePath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warnf("read state of %s eviction from %s: %s", cache.keys.name(), path, err)
+		}
+		return
+	}
+	keys := decodeKeys(data)
+	cache.Lock()
+	index.restore(keys)
+	cache.Unlock()
+	logger.Infof("Restored %d keys of %s eviction for %s", len(keys), cache.keys.name(), cache.dir)
+}
+
+// saveIndexState saves the keys remembered by the eviction policy periodically.
+func (cache *diskCache) saveIndexState(interval time.Duration) {
+	index, ok := unwrapIndex(cache.keys).(statefulIndex)
+	if !ok {
+		return
+	}
+	path := cache.indexStatePath()
+	for cache.available() {
+		time.Sleep(interval)
+		cache.Lock()
+		keys := index.state()
+		cache.Unlock()
+		if err := cache.writeKeys(path, keys); err != nil {
+			logger.Warnf("save state of %s eviction into %s: %s", cache.keys.name(), path, err)
+		}
+	}
+}
+
+func decodeKeys(data []byte) []cacheKey {
+	keys := make([]cacheKey, 0, len(data)/16)
+	for ; len(data) >= 16; data = data[16:] {
+		keys = append(keys, cacheKey{binary.LittleEndian.Uint64(data), binary.LittleEndian.Uint32(data[8:]), binary.LittleEndian.Uint32(data[12:])})
+	}
+	return keys
+}
+
+// writeKeys writes the keys into path atomically.
+func (cache *diskCache) writeKeys(path string, keys []cacheKey) error {
+	data := make([]byte, 16*len(keys))
+	for i, k := range keys {
+		binary.LittleEndian.PutUint64(data[i*16:], k.id)
+		binary.LittleEndian.PutUint32(data[i*16+8:], k.indx)
+		binary.LittleEndian.PutUint32(data[i*16+12:], k.size)
+	}
+	tmp := path + ".tmp"
+	return cache.checkErr(func() error {
+		if err := os.WriteFile(tmp, data, cache.mode); err != nil {
+			return err
+		}
+		return os.Rename(tmp, path)
+	})
+}
+
 func (c *diskCache) available() bool {
 	return c.state.state() != dcDown
 }
@@ -331,7 +423,7 @@ func (cache *diskCache) usedMemory() int64 {
 func (cache *diskCache) stats() (int64, int64) {
 	cache.Lock()
 	defer cache.Unlock()
-	return int64(len(cache.pages) + cache.keys.len()), cache.used + cache.usedMemory()
+	return int64(len(cache.pages)+cache.keys.len()) + cache.pinBlocks, cache.used + cache.pinBytes + cache.usedMemory()
 }
 
 func (cache *diskCache) isFull(usage DiskFreeRatio, stage bool) bool {
@@ -410,7 +502,14 @@ func (cache *diskCache) refreshCacheKeys() {
 	if cache.scanInterval < 0 {
 		return
 	}
-	cache.scanCached(true)
+	cache.Lock()
+	loaded := cache.scanned // from the persisted index
+	cache.Unlock()
+	if loaded {
+		cache.reconcileIndex()
+	} else {
+		cache.scanCached(true)
+	}
 	if cache.scanInterval > 0 {
 		for {
 			time.Sleep(cache.scanInterval)
@@ -447,7 +546,7 @@ func (cache *diskCache) cache(key string, p *Page, force, dropCache bool) {
 		return
 	}
 	k := cache.getCacheKey(key)
-	if cache.keys.get(k) != nil {
+	if cache.pins[k] || cache.keys.get(k) != nil {
 		return
 	}
 	p.Acquire()
@@ -635,11 +734,13 @@ func (cache *diskCache) remove(key string, staging bool) {
 	delete(cache.pages, key)
 	path := cache.cachePath(key)
 	k := cache.getCacheKey(key)
+	pinned := cache.pins[k]
+	cache.dropPin(k)
 	if it := cache.keys.remove(k, staging); it != nil {
 		if it.size > 0 {
 			cache.used -= int64(it.size + 4096)
 		}
-	} else if cache.scanned || !staging {
+	} else if !pinned && (cache.scanned || !staging) {
 		path = "" // not existed or staging block
 	}
 	cache.Unlock()
@@ -663,7 +764,7 @@ func (cache *diskCache) load(key string) (ReadCloser, error) {
 		return NewPageReader(p), nil
 	}
 	k := cache.getCacheKey(key)
-	if cache.scanned && cache.keys.get(k) == nil {
+	if cache.scanned && !cache.pins[k] && cache.keys.get(k) == nil {
 		return nil, errNotCached
 	}
 	cache.Unlock()
@@ -683,6 +784,7 @@ func (cache *diskCache) load(key string) (ReadCloser, error) {
 		if it := cache.keys.remove(k, false); it != nil {
 			cache.used -= int64(it.size + 4096)
 		}
+		cache.setPinCached(k, false)
 	}
 	return f, err
 }
@@ -694,7 +796,7 @@ func (cache *diskCache) exist(key string) (bool, error) {
 		return true, nil
 	}
 	k := cache.getCacheKey(key)
-	if cache.scanned && cache.keys.get(k) == nil {
+	if cache.scanned && !cache.pins[k] && cache.keys.get(k) == nil {
 		return false, errNotCached
 	}
 	cache.Unlock()
@@ -713,6 +815,7 @@ func (cache *diskCache) exist(key string) (bool, error) {
 	} else if it := cache.keys.remove(k, false); it != nil {
 		cache.used -= int64(it.size + 4096)
 	}
+	cache.setPinCached(k, false)
 	return false, err
 }
 
@@ -752,6 +855,13 @@ func (cache *diskCache) add(key string, size int32, atime uint32) {
 	k := cache.getCacheKey(key)
 	cache.Lock()
 	defer cache.Unlock()
+	if _, ok := cache.pins[k]; ok && size > 0 {
+		if it := cache.keys.remove(k, true); it != nil && it.size > 0 {
+			cache.used -= int64(it.size + 4096)
+		}
+		cache.setPinCached(k, true)
+		return
+	}
 	iter := cache.keys.get(k)
 	if iter == nil {
 		iter = &cacheItem{size: size, atime: atime}
@@ -874,11 +984,26 @@ func (cache *diskCache) cleanupFull() {
 		if !cache.available() {
 			break
 		}
-		_ = cache.removeFile(cache.cachePath(cache.getPathFromKey(k)))
+		key := cache.getPathFromKey(k)
+		if cache.demote != nil {
+			cache.demoteBlock(key)
+		}
+		_ = cache.removeFile(cache.cachePath(key))
 	}
 	cache.Lock()
 }
 
+// demoteBlock hands the evicted block to the next cache tier, which reads it after removed.
+func (cache *diskCache) demoteBlock(key string) {
+	f, err := openCacheFile(cache.cachePath(key), parseObjOrigSize(key), cache.checksum)
+	if err != nil {
+		return
+	}
+	if !cache.demote(key, f) {
+		_ = f.Close()
+	}
+}
+
 func (cache *diskCache) uploadStaging() {
 	if !cache.scanned || cache.uploader == nil {
 		return
@@ -940,6 +1065,9 @@ func (cache *diskCache) uploadStaging() {
 func (cache *diskCache) scanCached(fast bool) {
 	cache.Lock()
 	cache.used = 0
+	for k := range cache.pins {
+		cache.setPinCached(k, false) // will be found again
+	}
 	// atime in memory is more accurate than on disk, inherit it for the next round
 	lastSnap := cache.keys.reset()
 	cache.scanned = false
@@ -998,6 +1126,9 @@ func (cache *diskCache) scanCached(fast bool) {
 	})
 	cache.Lock()
 	cache.scanned = true
+	if j, ok := cache.keys.(*journaledIndex); ok {
+		j.rewrite = true // drop the blocks not found
+	}
 	logger.Debugf("Found %s cached blocks (%s) in %s with %s", humanize.Comma(int64(cache.keys.len())), humanize.IBytes(uint64(cache.used)), cache.dir, time.Since(start))
 	cache.Unlock()
 }
@@ -164,7 +164,7 @@ func TestCleanupFullDoesNotBlockLoad(t *testing.T) {
 }
 
 func TestAtimeNotLost(t *testing.T) {
-	for _, eviction := range []string{EvictionNone, Eviction2Random, EvictionLRU} {
+	for _, eviction := range []string{EvictionNone, Eviction2Random, EvictionLRU, Eviction2Q} {
 		cfg := defaultConf
 		cfg.CacheEviction = eviction
 		cfg.FreeSpace = 0.03
@@ -512,3 +512,267 @@ func TestSpaceToFreeNoAction(t *testing.T) {
 		require.Equal(t, 0, uploadCount, "should not upload when disk has enough free space and inodes")
 	})
 }
+
+func Test2QEviction(t *testing.T) {
+	idx, err := NewKeyIndex(&Config{CacheEviction: Eviction2Q})
+	require.NoError(t, err)
+	p := idx.(*twoQEviction)
+	key := func(i int) cacheKey { return cacheKey{uint64(i), 0, 1024} }
+	now := uint32(time.Now().Unix())
+	for i := 1; i <= 4; i++ {
+		p.add(key(i), cacheItem{1024, now})
+	}
+	for range p.evictionIter() {
+	}
+	require.Equal(t, 0, p.len())
+	// cached again after evicted from the recent queue
+	for i := 1; i <= 4; i++ {
+		p.add(key(i), cacheItem{1024, now})
+	}
+	require.Equal(t, 4, p.frequent.Len())
+	// a sequential scan
+	for i := 100; i < 116; i++ {
+		p.add(key(i), cacheItem{1024, now + 1})
+	}
+	var evicted int
+	for k := range p.evictionIter() {
+		require.GreaterOrEqual(t, k.id, uint64(100), "frequent block %s should not be evicted", k)
+		if evicted++; evicted == 8 {
+			break
+		}
+	}
+	for i := 1; i <= 4; i++ {
+		require.NotNil(t, p.get(key(i)))
+	}
+
+	// the frequent blocks are kept after rescanned
+	snap := p.reset()
+	require.Equal(t, 12, snap.len())
+	p.add(key(1), cacheItem{1024, now})
+	p.add(key(200), cacheItem{1024, now})
+	require.Equal(t, 1, p.frequent.Len())
+	require.Equal(t, 1, p.recent.Len())
+
+	// and restarted
+	dir := t.TempDir()
+	conf := defaultConf
+	conf.CacheEviction = Eviction2Q
+	conf.CacheScanInterval = -1
+	m := new(cacheManagerMetrics)
+	m.initMetrics()
+	old := indexStateInterval
+	indexStateInterval = time.Millisecond * 100
+	defer func() { indexStateInterval = old }()
+	s := newDiskCache(m, dir, int64(conf.CacheSize), 0, 1, &conf, nil)
+	s.add("1_0_1024", 1024, now)
+	s.Lock()
+	for range s.keys.evictionIter() {
+	}
+	s.Unlock()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(s.indexStatePath())
+		return err == nil
+	}, time.Second*3, time.Millisecond*100)
+	s2 := newDiskCache(m, dir, int64(conf.CacheSize), 0, 1, &conf, nil)
+	s2.add("1_0_1024", 1024, now)
+	s2.Lock()
+	require.Equal(t, 1, s2.keys.(*twoQEviction).frequent.Len())
+	s2.Unlock()
+}
+
+func Test2QGhostsReset(t *testing.T) {
+	idx, err := NewKeyIndex(&Config{CacheEviction: Eviction2Q})
+	require.NoError(t, err)
+	p := idx.(*twoQEviction)
+	now := uint32(time.Now().Unix())
+	const n = 3000
+	for i := 0; i < 20; i++ {
+		for j := 0; j < n; j++ {
+			key := cacheKey{uint64(i*n + j), 0, 1024}
+			p.remember(key)
+			p.add(key, cacheItem{1024, now})
+		}
+		require.Equal(t, n, p.frequent.Len())
+		p.reset()
+		// the ghosts evicted by the last rescan are kept
+		require.LessOrEqual(t, len(p.ghosts), n/twoQGhostRatio+n)
+		require.LessOrEqual(t, len(p.ghostQ)-p.ghostHead, 2*len(p.ghosts)+twoQMinGhosts)
+	}
+	// the frequent blocks of the last rescan are still remembered
+	p.add(cacheKey{uint64(19*n + 1), 0, 1024}, cacheItem{1024, now})
+	require.Equal(t, 1, p.frequent.Len())
+}
+
+func TestPinCache(t *testing.T) {
+	dir := t.TempDir()
+	conf := defaultConf
+	conf.CacheEviction = EvictionLRU
+	conf.FreeSpace = 0.00001
+	conf.CacheScanInterval = -1
+	conf.CacheItems = 10
+	conf.CachePinSize = 3 * (1024 + 4096)
+	m := new(cacheManagerMetrics)
+	m.initMetrics()
+	old := pinsSaveInterval
+	pinsSaveInterval = time.Millisecond * 100
+	defer func() { pinsSaveInterval = old }()
+	s := newDiskCache(m, dir, int64(conf.CacheSize), conf.CacheItems, 1, &conf, nil)
+	key := func(i int) string { return fmt.Sprintf("%d_%d_1024", i, i) }
+
+	require.NoError(t, s.pin(key(1)))
+	require.NoError(t, s.pin(key(2)))
+	now := time.Now()
+	for i := 1; i <= 20; i++ {
+		s.add(key(i), 1024, uint32(now.Add(time.Duration(i)*time.Second).Unix()))
+	}
+	require.LessOrEqual(t, s.keys.len(), 10)
+	require.Nil(t, s.keys.get(s.getCacheKey(key(1))), "pinned block should not be in the eviction index")
+	blocks, bytes := s.pinStats()
+	require.Equal(t, int64(2), blocks)
+	require.Equal(t, int64(2*(1024+4096)), bytes)
+
+	// the pinned blocks survive evicting all the others
+	s.Lock()
+	s.maxItems = 1
+	s.cleanupFull()
+	s.Unlock()
+	require.Equal(t, 0, s.keys.len())
+	require.True(t, s.isPinned(key(1)))
+	require.True(t, s.isPinned(key(2)))
+	blocks, _ = s.pinStats()
+	require.Equal(t, int64(2), blocks)
+
+	// the space is reserved for the blocks not cached yet
+	require.NoError(t, s.pin(key(3)))
+	require.ErrorIs(t, s.pin(key(4)), errPinFull)
+
+	s.Lock()
+	s.maxItems = 10
+	s.Unlock()
+	s.unpin(key(1))
+	require.False(t, s.isPinned(key(1)))
+	require.NotNil(t, s.keys.get(s.getCacheKey(key(1))), "unpinned block should be evictable again")
+	blocks, _ = s.pinStats()
+	require.Equal(t, int64(1), blocks)
+	require.NoError(t, s.pin(key(4)))
+
+	// restored after restarted
+	require.Eventually(t, func() bool {
+		s.Lock()
+		defer s.Unlock()
+		return !s.pinsDirty
+	}, time.Second*3, time.Millisecond*100)
+	s2 := newDiskCache(m, dir, int64(conf.CacheSize), conf.CacheItems, 1, &conf, nil)
+	for i, pinned := range []bool{false, true, true, true} {
+		require.Equal(t, pinned, s2.isPinned(key(i+1)), "pin of %s", key(i+1))
+	}
+	require.ErrorIs(t, s2.pin(key(5)), errPinFull)
+}
+
+func TestCacheIndex(t *testing.T) {
+	dir := t.TempDir()
+	conf := defaultConf
+	conf.CacheEviction = EvictionLRU
+	conf.CacheScanInterval = -1
+	conf.CacheIndex = true
+	m := new(cacheManagerMetrics)
+	m.initMetrics()
+	old := indexFlushInterval
+	indexFlushInterval = time.Millisecond * 50
+	defer func() { indexFlushInterval = old }()
+	key := func(i int) string { return fmt.Sprintf("%d_%d_1024", i, i) }
+	flushed := func(s *diskCache) func() bool {
+		return func() bool {
+			s.Lock()
+			defer s.Unlock()
+			j := s.keys.(*journaledIndex)
+			return !j.rewrite && len(j.buf) == 0
+		}
+	}
+
+	s := newDiskCache(m, dir, int64(conf.CacheSize), 0, 1, &conf, nil)
+	_, ok := unwrapIndex(s.keys).(*lruEviction)
+	require.True(t, ok)
+	s.scanCached(true) // an empty dir
+	require.Eventually(t, flushed(s), time.Second*3, time.Millisecond*50)
+	now := uint32(time.Now().Unix())
+	for i := 1; i <= 5; i++ {
+		s.add(key(i), 1024, now)
+	}
+	s.remove(key(2), false)
+	require.Eventually(t, flushed(s), time.Second*3, time.Millisecond*50)
+
+	s2 := newDiskCache(m, dir, int64(conf.CacheSize), 0, 1, &conf, nil)
+	s2.Lock()
+	require.True(t, s2.scanned, "cache dir should not be scanned")
+	require.Equal(t, 4, s2.keys.len())
+	require.Equal(t, int64(4*(1024+4096)), s2.used)
+	require.Nil(t, s2.keys.get(s2.getCacheKey(key(2))))
+	require.NotNil(t, s2.keys.get(s2.getCacheKey(key(5))))
+	s2.Unlock()
+
+	// a record written partially before crashed
+	path := s.indexPath()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, append(data, make([]byte, indexRecordSize)...), 0600))
+	items, valid, err := replayIndex(append(data, make([]byte, indexRecordSize)...))
+	require.NoError(t, err)
+	require.Equal(t, 4, len(items))
+	require.Equal(t, len(data), valid)
+	s3 := newDiskCache(m, dir, int64(conf.CacheSize), 0, 1, &conf, nil)
+	s3.Lock()
+	require.True(t, s3.scanned)
+	require.Equal(t, 4, s3.keys.len())
+	s3.Unlock()
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), fi.Size())
+
+	// corrupted in the middle
+	data[len(indexMagic)+3] ^= 0xFF
+	_, _, err = replayIndex(data)
+	require.ErrorIs(t, err, errIndexCorrupted)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+	s4 := newDiskCache(m, dir, int64(conf.CacheSize), 0, 1, &conf, nil)
+	s4.Lock()
+	require.False(t, s4.scanned, "cache dir should be scanned")
+	require.Equal(t, 0, s4.keys.len())
+	s4.Unlock()
+}
+
+func TestReconcileIndex(t *testing.T) {
+	dir := t.TempDir()
+	conf := defaultConf
+	conf.CacheScanInterval = -1
+	conf.CacheIndex = true
+	m := new(cacheManagerMetrics)
+	m.initMetrics()
+	key := func(i int) string { return fmt.Sprintf("%d_%d_1024", i, i) }
+	s := newDiskCache(m, dir, int64(conf.CacheSize), 0, 1, &conf, nil)
+	path := func(i int) string { return s.cachePath(s.getPathFromKey(s.getCacheKey(key(i)))) }
+	write := func(i, size int) {
+		path := path(i)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+	}
+	// 1 is cached, 2 is lost, 3 is written partially and 4 is not recorded
+	old := uint32(time.Now().Add(-time.Hour).Unix())
+	for i := 1; i <= 3; i++ {
+		s.add(key(i), 1024, old)
+	}
+	write(1, 1024)
+	write(3, 100)
+	write(4, 1024)
+	s.reconcileIndex()
+
+	s.Lock()
+	defer s.Unlock()
+	require.NotNil(t, s.keys.get(s.getCacheKey(key(1))))
+	require.Nil(t, s.keys.get(s.getCacheKey(key(2))))
+	require.Nil(t, s.keys.get(s.getCacheKey(key(3))))
+	require.NotNil(t, s.keys.get(s.getCacheKey(key(4))))
+	require.Equal(t, int64(2*(1024+4096)), s.used)
+	_, err := os.Stat(path(3))
+	require.True(t, os.IsNotExist(err))
+}
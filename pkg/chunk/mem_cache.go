@@ -40,6 +40,7 @@ type memcache struct {
 	cacheExpire time.Duration
 
 	metrics *cacheManagerMetrics
+	demote  func(key string, r ReadCloser) bool // receives the evicted blocks
 }
 
 func newMemStore(config *Config, metrics *cacheManagerMetrics) *memcache {
@@ -159,6 +160,11 @@ func (c *memcache) cleanup() {
 		if cnt > 1 {
 			logger.Debugf("remove %s from cache, age: %d", lastKey, now.Sub(lastValue.atime))
 			c.metrics.cacheEvicts.Add(1)
+			if c.demote != nil {
+				if r := NewPageReader(lastValue.page); !c.demote(lastKey, r) {
+					_ = r.Close()
+				}
+			}
 			c.delete(lastKey, lastValue.page)
 			cnt = 0
 			if !c.full() {
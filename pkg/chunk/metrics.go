@@ -18,8 +18,17 @@ package chunk
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		return 0
+	}
+	return m.GetCounter().GetValue()
+}
+
 // CacheManager Metrics
 type cacheManagerMetrics struct {
 	cacheDrops      prometheus.Counter
@@ -0,0 +1,362 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chunk
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/utils"
+)
+
+const (
+	ReplicaPeer = "peer"       // mirror the staging blocks to another member of cache group
+	replicaDir  = "rawreplica" // the replicas kept for other members
+	seenFile    = "members"    // the members ever seen, whose replicas could be adopted
+)
+
+// writeStaging writes a block in the same format as the staging files, and syncs it before returning.
+func writeStaging(path string, data []byte, tierID uint8, cs string, mode os.FileMode) (err error) {
+	if mode == 0 {
+		mode = 0600
+	}
+	if err = os.MkdirAll(filepath.Dir(path), mode|0111); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmp)
+		}
+	}()
+	buf := data
+	if cs != CsNone || tierID != 0 {
+		buf = append(make([]byte, 0, len(data)+len(data)/csBlock*4+64), data...)
+		if cs != CsNone {
+			buf = append(buf, checksum(data)...)
+		}
+		if tierID != 0 {
+			var footer []byte
+			if footer, err = (&stageFooter{Tier: tierID}).marshal(cs != CsNone); err != nil {
+				_ = f.Close()
+				return err
+			}
+			buf = append(buf, footer...)
+		}
+	}
+	if _, err = f.Write(buf); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	return err
+}
+
+// stageReplica mirrors the staging blocks to another dir or a member of cache group before the writes
+// are acknowledged, so they are not lost with the disk of cache. The replica is removed after the
+// block is uploaded or deleted.
+type stageReplica struct {
+	store *cachedStore
+	dir   string // the dir keeping the replicas, or empty for peer
+
+	sync.Mutex
+	owners map[string]string // key -> the member keeping its replica
+}
+
+func newStageReplica(store *cachedStore, target string) *stageReplica {
+	r := &stageReplica{store: store, owners: make(map[string]string)}
+	if target != ReplicaPeer {
+		r.dir = target
+		logger.Infof("Mirror the staging blocks into %s", target)
+	} else {
+		logger.Infof("Mirror the staging blocks to the members of cache group %s", store.conf.CacheGroup)
+	}
+	return r
+}
+
+// the same layout as the cache dir, so it can be used as the cache dir to upload them
+func (r *stageReplica) path(key string) string {
+	return filepath.Join(r.dir, stagingDir, key)
+}
+
+func (r *stageReplica) put(key string, data []byte, tierID uint8) error {
+	if r.dir != "" {
+		return writeStaging(r.path(key), data, tierID, r.store.conf.CacheChecksum, r.store.conf.CacheMode)
+	}
+	peer, err := r.store.peers.putReplica(key, data, tierID)
+	if err != nil {
+		return err
+	}
+	r.Lock()
+	r.owners[key] = peer
+	r.Unlock()
+	return nil
+}
+
+func (r *stageReplica) remove(key string) {
+	if r.dir != "" {
+		if err := os.Remove(r.path(key)); err != nil && !os.IsNotExist(err) {
+			logger.Warnf("remove replica of %s: %s", key, err)
+		}
+		return
+	}
+	r.Lock()
+	peer, ok := r.owners[key]
+	delete(r.owners, key)
+	r.Unlock()
+	if !ok {
+		peer = r.store.peers.replicaPeer(key) // replicated before restarted
+	}
+	if peer != "" {
+		go r.store.peers.removeReplica(peer, key)
+	}
+}
+
+// scan finds the replicas left in the dir, the ones not uploaded yet are uploaded as staging blocks.
+func (r *stageReplica) scan() {
+	if r.dir == "" {
+		return
+	}
+	prefix := filepath.Join(r.dir, stagingDir)
+	var count int
+	_ = filepath.WalkDir(prefix, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		key := path[len(prefix)+1:]
+		if runtime.GOOS == "windows" {
+			key = strings.ReplaceAll(key, "\\", "/")
+		}
+		if !pathReg.MatchString(key) {
+			return nil
+		}
+		if fi, err := d.Info(); err == nil {
+			r.store.addDelayedStaging(key, path, fi.ModTime(), false)
+			count++
+		}
+		return nil
+	})
+	if count > 0 {
+		logger.Infof("Found %d replicas of staging blocks in %s", count, r.dir)
+	}
+}
+
+func isPeerReplica(path string) bool {
+	return strings.Contains(filepath.ToSlash(path), "/"+replicaDir+"/")
+}
+
+// removeStaging removes the staging block and its replica after uploaded.
+func (store *cachedStore) removeStaging(key, stagingPath string) error {
+	if isPeerReplica(stagingPath) {
+		// kept for a member gone
+		if err := os.Remove(stagingPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if store.replica != nil {
+		store.replica.remove(key)
+	}
+	return store.bcache.removeStage(key)
+}
+
+// the dir to keep the replicas for other members, empty if there is no cache dir
+func (store *cachedStore) peerReplicaRoot() string {
+	if !store.conf.CacheEnabled() || store.conf.CacheDir == "memory" {
+		return ""
+	}
+	ds := utils.SplitDir(store.conf.CacheDir)
+	if len(ds) == 0 {
+		return ""
+	}
+	return filepath.Join(ds[0], replicaDir)
+}
+
+func replicaOrigin(addr string) string {
+	return strings.ReplaceAll(addr, ":", "_")
+}
+
+// validOrigin checks the address of the member sending a replica, which is used as the name of dir.
+func validOrigin(addr string) bool {
+	if addr == "" || strings.ContainsAny(addr, `/\`) || strings.Contains(addr, "..") {
+		return false
+	}
+	_, _, err := net.SplitHostPort(addr)
+	return err == nil
+}
+
+// seenMembers adds the members into the ones ever seen, which are persisted in the dir of replicas,
+// and returns the dirs of all of them.
+func seenMembers(root string, members []string) map[string]bool {
+	path := filepath.Join(root, seenFile)
+	seen := make(map[string]bool)
+	if data, err := os.ReadFile(path); err == nil {
+		for _, m := range strings.Split(string(data), "\n") {
+			if m != "" {
+				seen[m] = true
+			}
+		}
+	}
+	var added bool
+	for _, m := range members {
+		if o := replicaOrigin(m); !seen[o] {
+			seen[o] = true
+			added = true
+		}
+	}
+	if added {
+		names := make([]string, 0, len(seen))
+		for m := range seen {
+			names = append(names, m)
+		}
+		sort.Strings(names)
+		if err := os.MkdirAll(root, 0755); err == nil {
+			err = os.WriteFile(path+".tmp", []byte(strings.Join(names, "\n")+"\n"), 0644)
+			if err == nil {
+				err = os.Rename(path+".tmp", path)
+			}
+			if err != nil {
+				logger.Warnf("save the members of cache group: %s", err)
+			}
+		}
+	}
+	return seen
+}
+
+var errNoReplicaDir = errors.New("no cache dir to keep the replicas")
+
+// adoptReplicas uploads the replicas kept for the members not in the group any more, only the ones
+// ever seen by this member are adopted.
+func (store *cachedStore) adoptReplicas(members []string) {
+	root := store.peerReplicaRoot()
+	if root == "" {
+		return
+	}
+	seen := seenMembers(root, members)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	alive := make(map[string]bool, len(members))
+	for _, m := range members {
+		alive[replicaOrigin(m)] = true
+	}
+	for _, e := range entries {
+		if !e.IsDir() || alive[e.Name()] {
+			continue
+		}
+		if !seen[e.Name()] {
+			logger.Warnf("Skip the replicas in %s, which is not a member ever seen", filepath.Join(root, e.Name()))
+			continue
+		}
+		prefix := filepath.Join(root, e.Name())
+		var count int
+		var dirs []string
+		_ = filepath.WalkDir(prefix, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || strings.HasSuffix(path, ".tmp") {
+				return nil
+			}
+			if d.IsDir() {
+				dirs = append(dirs, path)
+				return nil
+			}
+			key := filepath.ToSlash(path[len(prefix)+1:])
+			fi, err := d.Info()
+			if err != nil || !pathReg.MatchString(key) {
+				return nil
+			}
+			store.pendingMutex.Lock()
+			if _, ok := store.pendingKeys[key]; !ok {
+				store.pendingKeys[key] = &pendingItem{key: key, fpath: path, ts: fi.ModTime()}
+			}
+			store.pendingMutex.Unlock()
+			if store.uploadStagingFile(key, path, true) {
+				count++
+			}
+			return nil
+		})
+		// remove the empty dirs, the blocks failed are retried later
+		for i := len(dirs) - 1; i >= 0; i-- {
+			_ = os.Remove(dirs[i])
+		}
+		if count > 0 {
+			logger.Infof("Uploaded %d staging blocks of %s, which left the cache group", count, e.Name())
+		}
+	}
+}
+
+// UploadStaging uploads all the staging blocks now, ignoring the upload delay and hours.
+func (store *cachedStore) UploadStaging(pre, post func()) error {
+	if !store.conf.Writeback {
+		return errors.New("writeback is not enabled")
+	}
+	store.pendingMutex.Lock()
+	items := make([]*pendingItem, 0, len(store.pendingKeys))
+	for _, it := range store.pendingKeys {
+		items = append(items, it)
+		pre()
+	}
+	store.pendingMutex.Unlock()
+
+	todo := make(chan *pendingItem)
+	var failed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < store.conf.MaxUpload; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range todo {
+				// wait for the one being uploaded in background
+				for !it.uploading.CompareAndSwap(false, true) && store.isPendingValid(it.key) {
+					time.Sleep(time.Millisecond * 100)
+				}
+				if store.isPendingValid(it.key) && !store.uploadStagingFile(it.key, it.fpath, true) {
+					failed.Add(1)
+				} else {
+					post()
+				}
+			}
+		}()
+	}
+	for _, it := range items {
+		todo <- it
+	}
+	close(todo)
+	wg.Wait()
+	if n := failed.Load(); n > 0 {
+		return fmt.Errorf("%d staging blocks failed to upload", n)
+	}
+	return nil
+}
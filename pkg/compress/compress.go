@@ -18,15 +18,20 @@ package compress
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/DataDog/zstd"
 	"github.com/hungys/go-lz4"
+	"github.com/klauspost/compress/s2"
 )
 
 // ZSTD_LEVEL compression level used by Zstd
 const ZSTD_LEVEL = 1 // fastest
 
+// ZSTD_MAX_LEVEL is the highest compression level of Zstd
+const ZSTD_MAX_LEVEL = 22
+
 // Compressor interface to be implemented by a compression algo
 type Compressor interface {
 	Name() string
@@ -35,13 +40,23 @@ type Compressor interface {
 	Decompress(dst, src []byte) (int, error)
 }
 
-// NewCompressor returns a struct implementing Compressor interface
+// NewCompressor returns a struct implementing Compressor interface, the level of zstd
+// can be specified as "zstd:LEVEL" (1-22).
 func NewCompressor(algr string) Compressor {
 	algr = strings.ToLower(algr)
+	if name, level, ok := strings.Cut(algr, ":"); ok && name == "zstd" {
+		l, err := strconv.Atoi(level)
+		if err != nil || l < 1 || l > ZSTD_MAX_LEVEL {
+			return nil
+		}
+		return ZStandard{l}
+	}
 	if algr == "zstd" {
 		return ZStandard{ZSTD_LEVEL}
 	} else if algr == "lz4" {
 		return LZ4{}
+	} else if algr == "s2" {
+		return S2{}
 	} else if algr == "none" || algr == "" {
 		return noOp{}
 	}
@@ -123,3 +138,36 @@ func (l LZ4) Decompress(dst, src []byte) (int, error) {
 	}
 	return lz4.DecompressSafe(src, dst)
 }
+
+// S2 implements Compressor using S2, an extension of Snappy
+type S2 struct{}
+
+// Name returns name of the algorithm S2
+func (n S2) Name() string { return "S2" }
+
+// CompressBound max size of compressed data
+func (n S2) CompressBound(l int) int { return s2.MaxEncodedLen(l) }
+
+// Compress using S2
+func (n S2) Compress(dst, src []byte) (int, error) {
+	if s2.MaxEncodedLen(len(src)) < 0 {
+		return 0, fmt.Errorf("block too large: %d", len(src))
+	}
+	d := s2.Encode(dst, src)
+	if len(d) > 0 && (len(dst) == 0 || &d[0] != &dst[0]) {
+		return 0, fmt.Errorf("buffer too short: %d < %d", len(dst), s2.MaxEncodedLen(len(src)))
+	}
+	return len(d), nil
+}
+
+// Decompress using S2
+func (n S2) Decompress(dst, src []byte) (int, error) {
+	d, err := s2.Decode(dst, src)
+	if err != nil {
+		return 0, err
+	}
+	if len(d) > 0 && (len(dst) == 0 || &d[0] != &dst[0]) {
+		return 0, fmt.Errorf("buffer too short: %d < %d", len(dst), len(d))
+	}
+	return len(d), nil
+}
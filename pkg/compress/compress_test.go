@@ -17,8 +17,10 @@
 package compress
 
 import (
+	"bytes"
 	"io"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -75,6 +77,103 @@ func TestLZ4(t *testing.T) {
 	testCompress(t, NewCompressor("lz4"))
 }
 
+func TestS2(t *testing.T) {
+	testCompress(t, NewCompressor("s2"))
+}
+
+func TestZstdLevel(t *testing.T) {
+	testCompress(t, NewCompressor("zstd:19"))
+	for _, algr := range []string{"zstd:0", "zstd:23", "zstd:x", "lz4:1", "snappy"} {
+		if NewCompressor(algr) != nil {
+			t.Fatalf("%s should be invalid", algr)
+		}
+	}
+}
+
+func TestTagged(t *testing.T) {
+	src := bytes.Repeat([]byte("juicefs "), 1000)
+	compress := func(c Compressor) []byte {
+		buf := make([]byte, c.CompressBound(len(src)))
+		n, err := c.Compress(buf, src)
+		if err != nil {
+			t.Fatalf("compress with %s: %s", c.Name(), err)
+		}
+		return buf[:n]
+	}
+	type block struct {
+		codec string // empty for tagged blocks
+		data  []byte
+	}
+	var blocks []block
+	for _, algr := range []string{"none", "lz4", "zstd", "zstd:9", "s2"} {
+		codec, _, _ := strings.Cut(algr, ":")
+		other := "none"
+		if codec == "none" {
+			other = "lz4"
+		}
+		blocks = append(blocks, block{codec, compress(NewCompressor(algr))})
+		blocks = append(blocks, block{"", compress(NewTagged(algr, other))})
+	}
+	// blocks written by the untagged codec have no tag
+	if !bytes.Equal(compress(NewTagged("zstd:9", "zstd")), compress(NewCompressor("zstd:9"))) {
+		t.Fatalf("block of untagged codec should not be tagged")
+	}
+	for _, untagged := range []string{"none", "lz4", "zstd", "s2"} {
+		c := NewTagged("zstd:3", untagged)
+		for i, b := range blocks {
+			if b.codec != "" && b.codec != untagged {
+				continue
+			}
+			dst := make([]byte, len(src))
+			n, err := c.Decompress(dst, b.data)
+			if err != nil || n != len(src) || !bytes.Equal(dst, src) {
+				t.Fatalf("decompress block %d with untagged %s: %d %v", i, untagged, n, err)
+			}
+		}
+	}
+	if NewTagged("zstd", "gzip") != nil || NewTagged("gzip", "zstd") != nil {
+		t.Fatalf("unknown codec should be rejected")
+	}
+}
+
+func TestRawOffset(t *testing.T) {
+	src := bytes.Repeat([]byte("juicefs "), 100)
+	cases := []struct {
+		algr, untagged string
+		offset         int
+	}{
+		{"none", "none", 0},
+		{"lz4", "none", -1},
+		{"zstd", "none", -1},
+		{"none", "lz4", TagSize},
+		{"none", "zstd", TagSize},
+		{"zstd", "zstd", -1},
+		{"s2", "zstd", -1},
+	}
+	for _, c := range cases {
+		comp := NewTagged(c.algr, c.untagged)
+		buf := make([]byte, comp.CompressBound(len(src)))
+		n, err := comp.Compress(buf, src)
+		if err != nil {
+			t.Fatalf("compress with %s: %s", c.algr, err)
+		}
+		off := RawOffset(buf[:TagSize], c.untagged)
+		if off != c.offset {
+			t.Fatalf("offset of block compressed by %s (untagged %s): %d != %d", c.algr, c.untagged, off, c.offset)
+		}
+		if off >= 0 && !bytes.Equal(buf[off:n], src) {
+			t.Fatalf("raw data of block compressed by %s (untagged %s) mismatch", c.algr, c.untagged)
+		}
+	}
+	// raw data looks like a tag
+	if off := RawOffset([]byte("JFC\x01"), "none"); off != 0 {
+		t.Fatalf("offset of raw data like a tag: %d", off)
+	}
+	if off := RawOffset([]byte("JF"), "zstd"); off != -1 {
+		t.Fatalf("offset of short block: %d", off)
+	}
+}
+
 func benchmarkDecompress(b *testing.B, comp Compressor) {
 	f, _ := os.Open(os.Getenv("PAYLOAD"))
 	var c = make([]byte, 5<<20)
@@ -0,0 +1,142 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compress
+
+import (
+	"fmt"
+)
+
+// A tagged block starts with "JFC" and the id of the codec. The blocks compressed by the
+// original codec of a volume have no tag, so they are compatible with old clients.
+const TagSize = 4
+
+var tagMagic = [3]byte{'J', 'F', 'C'}
+
+const (
+	codecNone byte = iota + 1
+	codecLZ4
+	codecZstd
+	codecS2
+)
+
+var codecs = map[byte]Compressor{
+	codecNone: noOp{},
+	codecLZ4:  LZ4{},
+	codecZstd: ZStandard{ZSTD_LEVEL},
+	codecS2:   S2{},
+}
+
+func codecOf(c Compressor) byte {
+	switch c.(type) {
+	case noOp:
+		return codecNone
+	case LZ4:
+		return codecLZ4
+	case ZStandard:
+		return codecZstd
+	case S2:
+		return codecS2
+	}
+	return 0
+}
+
+// Tagged compresses blocks with a codec, and tags the blocks when the codec is different
+// from the one of untagged blocks. It can decompress blocks written by any codec.
+type Tagged struct {
+	c        Compressor
+	id       byte
+	untagged Compressor
+}
+
+// NewTagged returns a Compressor which compresses blocks with algr, untagged is the codec
+// of the blocks without tag. It returns nil if any of them is unknown.
+func NewTagged(algr, untagged string) Compressor {
+	c, u := NewCompressor(algr), NewCompressor(untagged)
+	if c == nil || u == nil {
+		return nil
+	}
+	return &Tagged{c: c, id: codecOf(c), untagged: u}
+}
+
+// Name returns name of the codec used for new blocks
+func (t *Tagged) Name() string { return t.c.Name() }
+
+// CompressBound returns the max size of a block compressed by any codec, which is used for
+// both compressing and reading blocks.
+func (t *Tagged) CompressBound(l int) int {
+	bound := l
+	for _, c := range codecs {
+		bound = max(bound, c.CompressBound(l))
+	}
+	return bound + TagSize
+}
+
+func (t *Tagged) needTag() bool {
+	return t.id != codecOf(t.untagged)
+}
+
+// Compress a block, and tag it if needed
+func (t *Tagged) Compress(dst, src []byte) (int, error) {
+	if !t.needTag() {
+		return t.c.Compress(dst, src)
+	}
+	if len(dst) < TagSize {
+		return 0, fmt.Errorf("buffer too short: %d < %d", len(dst), TagSize)
+	}
+	n, err := t.c.Compress(dst[TagSize:], src)
+	if err != nil {
+		return 0, err
+	}
+	copy(dst, tagMagic[:])
+	dst[3] = t.id
+	return TagSize + n, nil
+}
+
+// Decompress a block with the codec in its tag. Untagged blocks, and those could not be
+// decompressed into a full block, are decompressed by the untagged codec.
+func (t *Tagged) Decompress(dst, src []byte) (int, error) {
+	if len(src) >= TagSize && [3]byte(src[:3]) == tagMagic {
+		if c := codecs[src[3]]; c != nil {
+			if n, err := c.Decompress(dst, src[TagSize:]); err == nil && n == len(dst) {
+				return n, nil
+			}
+		}
+	}
+	return t.untagged.Decompress(dst, src)
+}
+
+// RawOffset returns the offset of the data in a block stored without compression, head is the
+// first TagSize bytes of the block and untagged is the codec of the blocks without tag. It returns
+// -1 if the block is compressed, or it can't be told without decompressing the whole block.
+func RawOffset(head []byte, untagged string) int {
+	u := NewCompressor(untagged)
+	if u == nil {
+		return -1
+	}
+	tagged := len(head) >= TagSize && [3]byte(head[:3]) == tagMagic && codecs[head[3]] != nil
+	if codecOf(u) == codecNone {
+		// blocks without compression are never tagged, it could be a tagged one or data looks like a tag
+		if tagged && head[3] != codecNone {
+			return -1
+		}
+		return 0
+	}
+	if tagged && head[3] == codecNone {
+		return TagSize
+	}
+	return -1
+}
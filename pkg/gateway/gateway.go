@@ -69,12 +69,29 @@ type Config struct {
 	HeadDir     bool
 	HideDir     bool
 	ReadOnly    bool
+	RootUser    string // the access key of the root credential
+
+	EventTargets  []string // ID=URL of the targets of bucket notifications
+	EventQueueDir string   // the dir to keep the events not delivered
 }
 
 func NewJFSGateway(jfs *fs.FileSystem, conf *vfs.Config, gConf *Config) (minio.ObjectLayer, error) {
 	mctx = meta.NewContext(uint32(os.Getpid()), uint32(utils.GetCurrentUID()), []uint32{uint32(utils.GetCurrentGID())})
 	jfsObj := &jfsObjects{fs: jfs, conf: conf, listPool: minio.NewTreeWalkPool(time.Second * 10), gConf: gConf, nsMutex: minio.NewNSLock(false)}
+	jfsObj.users = newUserStore(jfs.Meta(), gConf.RootUser)
+	if len(gConf.EventTargets) > 0 {
+		events, err := newEventNotifier(jfsObj, gConf.EventTargets, gConf.EventQueueDir)
+		if err != nil {
+			return nil, err
+		}
+		jfsObj.events = events
+	}
 	go jfsObj.cleanup()
+	if !gConf.ReadOnly {
+		var ctx context.Context
+		ctx, jfsObj.stop = context.WithCancel(context.Background())
+		go jfsObj.lifecycle(ctx)
+	}
 	return jfsObj, nil
 }
 
@@ -82,8 +99,13 @@ type jfsObjects struct {
 	conf     *vfs.Config
 	fs       *fs.FileSystem
 	listPool *minio.TreeWalkPool
+	poolMu   sync.Mutex
+	pools    map[string]*minio.TreeWalkPool // the tree walks of the users, by access key
 	nsMutex  *minio.NsLockMap
 	gConf    *Config
+	events   *eventNotifier
+	users    *userStore
+	stop     context.CancelFunc // stops the background jobs
 }
 
 func (n *jfsObjects) PutObjectMetadata(ctx context.Context, s string, s2 string, options minio.ObjectOptions) (minio.ObjectInfo, error) {
@@ -108,6 +130,12 @@ func (n *jfsObjects) IsReady(_ context.Context) bool {
 }
 
 func (n *jfsObjects) Shutdown(ctx context.Context) error {
+	if n.stop != nil {
+		n.stop()
+	}
+	if n.events != nil {
+		n.events.close()
+	}
 	return n.fs.Close()
 }
 
@@ -163,6 +191,8 @@ func jfsToObjectErr(ctx context.Context, err error, params ...string) error {
 			return minio.PrefixAccessDenied{Bucket: bucket, Object: object}
 		}
 		return minio.BucketNotEmpty{Bucket: bucket}
+	case errors.Is(err, syscall.EACCES) || errors.Is(err, syscall.EPERM):
+		return minio.PrefixAccessDenied{Bucket: bucket, Object: object}
 	default:
 		logger.Errorf("other error: %s bucket: %s, object: %s, uploadID: %s", err, bucket, object, uploadID)
 		return err
@@ -213,12 +243,20 @@ func (n *jfsObjects) DeleteBucket(ctx context.Context, bucket string, forceDelet
 	if !n.gConf.MultiBucket {
 		return minio.BucketNotEmpty{Bucket: bucket}
 	}
+	if err := n.checkBucketUser(ctx, bucket); err != nil {
+		return err
+	}
+	if err := n.checkWrite(ctx, bucket, "", n.path(bucket), false); err != nil {
+		return err
+	}
+	if eno := n.fs.Delete(mctx, n.path(bucket)); eno != 0 {
+		return jfsToObjectErr(ctx, eno, bucket)
+	}
 	if eno := n.fs.Delete(mctx, n.path(minio.MinioMetaBucket, minio.BucketMetaPrefix, bucket, minio.BucketMetadataFile)); eno != 0 {
 		logger.Errorf("delete bucket metadata: %s", eno)
 	}
 	_ = n.fs.Delete(mctx, n.path(minio.MinioMetaBucket, minio.BucketMetaPrefix, bucket))
-	eno := n.fs.Delete(mctx, n.path(bucket))
-	return jfsToObjectErr(ctx, eno, bucket)
+	return nil
 }
 
 func (n *jfsObjects) MakeBucketWithLocation(ctx context.Context, bucket string, options minio.BucketOptions) error {
@@ -229,9 +267,16 @@ func (n *jfsObjects) MakeBucketWithLocation(ctx context.Context, bucket string,
 		if !n.gConf.MultiBucket {
 			return nil
 		}
+		if err := n.checkBucketUser(ctx, bucket); err != nil {
+			return err
+		}
+		if err := n.checkWrite(ctx, bucket, "", n.path(bucket), false); err != nil {
+			return err
+		}
 	}
 	eno := n.fs.Mkdir(mctx, n.path(bucket), 0777, n.gConf.Umask)
 	if eno == 0 {
+		n.chown(ctx, n.path(bucket))
 		metadata := minio.NewBucketMetadata(bucket)
 		if err := metadata.Save(ctx, n); err != nil {
 			return err
@@ -244,6 +289,9 @@ func (n *jfsObjects) GetBucketInfo(ctx context.Context, bucket string) (bi minio
 	if err := n.isValidBucketName(bucket); err != nil {
 		return bi, err
 	}
+	if err := n.checkBucketUser(ctx, bucket); err != nil {
+		return bi, err
+	}
 	fi, eno := n.fs.Stat(mctx, n.path(bucket))
 	if eno == 0 {
 		bi = minio.BucketInfo{
@@ -284,11 +332,15 @@ func (n *jfsObjects) ListBuckets(ctx context.Context) (buckets []minio.BucketInf
 		return nil, jfsToObjectErr(ctx, eno)
 	}
 
+	u := n.reqUser(ctx)
 	for _, entry := range entries {
 		// Ignore all reserved bucket names and invalid bucket names.
 		if isReservedOrInvalidBucket(entry.Name(), false) || n.isValidBucketName(entry.Name()) != nil {
 			continue
 		}
+		if u != nil && !u.canAccess(entry.Name()) {
+			continue
+		}
 		if entry.IsDir() {
 			buckets = append(buckets, minio.BucketInfo{
 				Name:    entry.Name(),
@@ -312,9 +364,21 @@ func (n *jfsObjects) isLeaf(bucket, leafPath string) bool {
 	return !strings.HasSuffix(leafPath, "/")
 }
 
-func (n *jfsObjects) listDirFactory() minio.ListDirFunc {
+// listDirFactory returns the function to list a directory, the ones can't be read by u are skipped
+// with the objects under them. No permission is checked if u is nil.
+func (n *jfsObjects) listDirFactory(u *User) minio.ListDirFunc {
+	var uctx meta.Context
+	if u != nil {
+		uctx = u.context()
+	}
 	return func(bucket, prefixDir, prefixEntry string) (emptyDir bool, entries []*minio.Entry, delayIsLeaf bool) {
-		f, eno := n.fs.Open(mctx, n.path(bucket, prefixDir), 0)
+		p := n.path(bucket, prefixDir)
+		if uctx != nil {
+			if eno := n.fs.Access(uctx, p, vfs.MODE_MASK_R|vfs.MODE_MASK_X); eno == syscall.EACCES || eno == syscall.EPERM {
+				return false, nil, false
+			}
+		}
+		f, eno := n.fs.Open(mctx, p, 0)
 		if eno != 0 {
 			return fs.IsNotExist(eno), nil, false
 		}
@@ -329,7 +393,7 @@ func (n *jfsObjects) listDirFactory() minio.ListDirFunc {
 		if eno != 0 {
 			return
 		}
-		root := n.path(bucket, prefixDir) == "/"
+		root := p == "/"
 		for _, fi := range fis {
 			if root && (fi.Name() == metaBucket || fi.Name() == minio.MinioMetaBucket) {
 				continue
@@ -357,10 +421,32 @@ func (n *jfsObjects) listDirFactory() minio.ListDirFunc {
 	}
 }
 
+// walkPool returns the pool to keep the tree walks of u, which are not shared with the other users
+// as they may see different objects.
+func (n *jfsObjects) walkPool(u *User) *minio.TreeWalkPool {
+	if u == nil {
+		return n.listPool
+	}
+	n.poolMu.Lock()
+	defer n.poolMu.Unlock()
+	if n.pools == nil {
+		n.pools = make(map[string]*minio.TreeWalkPool)
+	}
+	pool := n.pools[u.AccessKey]
+	if pool == nil {
+		pool = minio.NewTreeWalkPool(time.Second * 10)
+		n.pools[u.AccessKey] = pool
+	}
+	return pool
+}
+
 func (n *jfsObjects) checkBucket(ctx context.Context, bucket string) error {
 	if err := n.isValidBucketName(bucket); err != nil {
 		return err
 	}
+	if err := n.checkBucketUser(ctx, bucket); err != nil {
+		return err
+	}
 	bucketPath := n.path(bucket)
 	if bucketPath != "/" { // no need to stat "/" in every request
 		if _, eno := n.fs.Stat(mctx, bucketPath); eno != 0 {
@@ -375,6 +461,9 @@ func (n *jfsObjects) ListObjects(ctx context.Context, bucket, prefix, marker, de
 	if err := n.checkBucket(ctx, bucket); err != nil {
 		return loi, err
 	}
+	if err := n.checkList(ctx, bucket, prefix); err != nil {
+		return loi, err
+	}
 	getObjectInfo := func(ctx context.Context, bucket, object string, fi_ any) (obj minio.ObjectInfo, err error) {
 		var eno syscall.Errno
 		var info *minio.ObjectInfo
@@ -439,7 +528,8 @@ func (n *jfsObjects) ListObjects(ctx context.Context, bucket, prefix, marker, de
 	if maxKeys == 0 {
 		maxKeys = -1 // list as many objects as possible
 	}
-	return minio.ListObjects(ctx, n, bucket, prefix, marker, delimiter, maxKeys, n.listPool, n.listDirFactory(), n.isLeaf, n.isLeafDir, getObjectInfo, getObjectInfo)
+	u := n.reqUser(ctx)
+	return minio.ListObjects(ctx, n, bucket, prefix, marker, delimiter, maxKeys, n.walkPool(u), n.listDirFactory(u), n.isLeaf, n.isLeafDir, getObjectInfo, getObjectInfo)
 }
 
 // ListObjectsV2 lists all blobs in JFS bucket filtered by prefix
@@ -481,12 +571,33 @@ func (n *jfsObjects) DeleteObject(ctx context.Context, bucket, object string, op
 	if err = n.checkBucket(ctx, bucket); err != nil {
 		return
 	}
+	if err = n.checkWrite(ctx, bucket, object, n.path(bucket, object), false); err != nil {
+		return
+	}
+	if isVersioned(options) || options.VersionID != "" {
+		info, err = n.deleteVersioned(ctx, bucket, object, options)
+		if err == nil {
+			n.notify(ctx, deleteEvent(info, options.VersionID), info)
+		}
+		return info, jfsToObjectErr(ctx, err, bucket, object)
+	}
 	err = n.delObj(bucket, object)
 	info.Bucket = bucket
 	info.Name = object
+	if err == nil {
+		n.notify(ctx, EventObjectRemovedDelete, info)
+	}
 	return info, jfsToObjectErr(ctx, err, bucket, object)
 }
 
+// deleteEvent returns the event of a deletion, which creates a delete marker if no version is specified.
+func deleteEvent(info minio.ObjectInfo, versionID string) string {
+	if versionID == "" && info.DeleteMarker {
+		return EventObjectRemovedMarker
+	}
+	return EventObjectRemovedDelete
+}
+
 func (n *jfsObjects) delObj(bucket string, object string) error {
 	p := path.Clean(n.path(bucket, object))
 	root := n.path(bucket)
@@ -521,8 +632,38 @@ func (n *jfsObjects) DeleteObjects(ctx context.Context, bucket string, objects [
 		}
 		return
 	}
+	for idx, o := range objects {
+		errs[idx] = n.checkWrite(ctx, bucket, o.ObjectName, n.path(bucket, o.ObjectName), false)
+	}
+	if isVersioned(options) || hasVersionID(objects) {
+		for idx, o := range objects {
+			if errs[idx] != nil {
+				continue
+			}
+			opts := options
+			opts.VersionID = o.VersionID
+			info, err := n.deleteVersioned(ctx, bucket, o.ObjectName, opts)
+			if err != nil {
+				errs[idx] = jfsToObjectErr(ctx, err, bucket, o.ObjectName)
+				continue
+			}
+			n.notify(ctx, deleteEvent(info, o.VersionID), info)
+			objs[idx] = minio.DeletedObject{ObjectName: o.ObjectName}
+			if o.VersionID == "" && info.DeleteMarker {
+				objs[idx].DeleteMarker = true
+				objs[idx].DeleteMarkerVersionID = info.VersionID
+			} else {
+				objs[idx].VersionID = o.VersionID
+				objs[idx].DeleteMarker = info.DeleteMarker
+			}
+		}
+		return
+	}
 	delMap := make(map[string][]int)
 	for idx, o := range objects {
+		if errs[idx] != nil {
+			continue
+		}
 		p := path.Dir(path.Clean(n.path(bucket, o.ObjectName)))
 		delMap[p] = append(delMap[p], idx)
 	}
@@ -554,9 +695,25 @@ func (n *jfsObjects) DeleteObjects(ctx context.Context, bucket string, objects [
 		})
 	}
 	_ = g.Wait()
+	if n.events != nil {
+		for idx, o := range objects {
+			if errs[idx] == nil {
+				n.notify(ctx, EventObjectRemovedDelete, minio.ObjectInfo{Bucket: bucket, Name: o.ObjectName})
+			}
+		}
+	}
 	return
 }
 
+func hasVersionID(objects []minio.ObjectToDelete) bool {
+	for _, o := range objects {
+		if o.VersionID != "" {
+			return true
+		}
+	}
+	return false
+}
+
 type fReader struct {
 	*fs.File
 }
@@ -566,17 +723,20 @@ func (f *fReader) Read(b []byte) (int, error) {
 }
 
 func (n *jfsObjects) GetObjectNInfo(ctx context.Context, bucket, object string, rs *minio.HTTPRangeSpec, h http.Header, lockType minio.LockType, opts minio.ObjectOptions) (gr *minio.GetObjectReader, err error) {
-	objInfo, err := n.GetObjectInfo(ctx, bucket, object, opts)
+	objInfo, p, err := n.getObjectInfo(ctx, bucket, object, opts)
 	if err != nil {
 		return nil, err
 	}
+	if err = n.checkAccess(ctx, bucket, object, p, vfs.MODE_MASK_R); err != nil {
+		return nil, err
+	}
 
 	var startOffset, length int64
 	startOffset, length, err = rs.GetOffsetLength(objInfo.Size)
 	if err != nil {
 		return
 	}
-	f, eno := n.fs.Open(mctx, n.path(bucket, object), vfs.MODE_MASK_R)
+	f, eno := n.fs.Open(mctx, p, vfs.MODE_MASK_R)
 	if eno != 0 {
 		return nil, jfsToObjectErr(ctx, eno, bucket, object)
 	}
@@ -587,6 +747,11 @@ func (n *jfsObjects) GetObjectNInfo(ctx context.Context, bucket, object string,
 }
 
 func (n *jfsObjects) CopyObject(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject string, srcInfo minio.ObjectInfo, srcOpts, dstOpts minio.ObjectOptions) (info minio.ObjectInfo, err error) {
+	defer func() {
+		if err == nil {
+			n.notify(ctx, EventObjectCreatedCopy, info)
+		}
+	}()
 	if err = n.checkBucket(ctx, srcBucket); err != nil {
 		return
 	}
@@ -595,6 +760,18 @@ func (n *jfsObjects) CopyObject(ctx context.Context, srcBucket, srcObject, dstBu
 	}
 	dst := n.path(dstBucket, dstObject)
 	src := n.path(srcBucket, srcObject)
+	if srcOpts.VersionID != "" {
+		if src, _, _, err = n.resolveVersion(srcBucket, srcObject, srcOpts.VersionID); err != nil {
+			return
+		}
+	}
+
+	if err = n.checkAccess(ctx, srcBucket, srcObject, src, vfs.MODE_MASK_R); err != nil {
+		return
+	}
+	if err = n.checkWrite(ctx, dstBucket, dstObject, dst, true); err != nil {
+		return
+	}
 
 	if minio.IsStringEqual(src, dst) {
 		// if we copy the same object for set metadata
@@ -615,6 +792,7 @@ func (n *jfsObjects) CopyObject(ctx context.Context, srcBucket, srcObject, dstBu
 		logger.Errorf("create %s: %s", tmp, eno)
 		return
 	}
+	n.chown(ctx, tmp)
 	defer func() {
 		_ = f.Close(mctx)
 		if err != nil {
@@ -652,6 +830,12 @@ func (n *jfsObjects) CopyObject(ctx context.Context, srcBucket, srcObject, dstBu
 	if err != nil {
 		logger.Errorf("set object metadata error, path: %s error %s", dst, err)
 	}
+	versionID, unlock, err := n.newVersion(ctx, dstBucket, dstObject, tmp, dstOpts)
+	if err != nil {
+		err = jfsToObjectErr(ctx, err, dstBucket, dstObject)
+		return
+	}
+	defer unlock()
 
 	eno = n.fs.Rename(mctx, tmp, dst, 0)
 	if eno == syscall.ENOENT {
@@ -683,6 +867,7 @@ func (n *jfsObjects) CopyObject(ctx context.Context, srcBucket, srcObject, dstBu
 		AccTime:     fi.ModTime(),
 		UserTags:    tagStr,
 		UserDefined: minio.CleanMetadata(srcInfo.UserDefined),
+		VersionID:   versionID,
 		IsLatest:    true,
 	}, nil
 }
@@ -695,10 +880,27 @@ var buffPool = sync.Pool{
 }
 
 func (n *jfsObjects) GetObjectInfo(ctx context.Context, bucket, object string, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
+	objInfo, _, err = n.getObjectInfo(ctx, bucket, object, opts)
+	return
+}
+
+// getObjectInfo returns the info of the requested version of an object, and the path of it.
+func (n *jfsObjects) getObjectInfo(ctx context.Context, bucket, object string, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, p string, err error) {
 	if err = n.checkBucket(ctx, bucket); err != nil {
 		return
 	}
-	fi, eno := n.fs.Stat(mctx, n.path(bucket, object))
+	p = n.path(bucket, object)
+	var versionID string
+	latest := true
+	if opts.VersionID != "" || isVersioned(opts) {
+		if p, versionID, latest, err = n.resolveVersion(bucket, object, opts.VersionID); err != nil {
+			return
+		}
+	}
+	if err = n.checkAccess(ctx, bucket, object, p, 0); err != nil {
+		return
+	}
+	fi, eno := n.fs.Stat(mctx, p)
 	if eno != 0 {
 		err = jfsToObjectErr(ctx, eno, bucket, object)
 		return
@@ -717,7 +919,7 @@ func (n *jfsObjects) GetObjectInfo(ctx context.Context, bucket, object string, o
 	}
 	var etag []byte
 	if n.gConf.KeepEtag && !fi.IsDir() {
-		etag, _ = n.fs.GetXattr(mctx, n.path(bucket, object), s3Etag)
+		etag, _ = n.fs.GetXattr(mctx, p, s3Etag)
 	}
 	size := fi.Size()
 	if fi.IsDir() {
@@ -727,13 +929,13 @@ func (n *jfsObjects) GetObjectInfo(ctx context.Context, bucket, object string, o
 	var tagStr []byte
 	if n.gConf.ObjTag {
 		var errno syscall.Errno
-		if tagStr, errno = n.fs.GetXattr(mctx, n.path(bucket, object), s3Tags); errno != 0 && errno != meta.ENOATTR {
-			return minio.ObjectInfo{}, errno
+		if tagStr, errno = n.fs.GetXattr(mctx, p, s3Tags); errno != 0 && errno != meta.ENOATTR {
+			return minio.ObjectInfo{}, "", errno
 		}
 	}
-	objMeta, err := n.getObjMeta(n.path(bucket, object))
+	objMeta, err := n.getObjMeta(p)
 	if err != nil {
-		return minio.ObjectInfo{}, err
+		return minio.ObjectInfo{}, "", err
 	}
 	if opts.UserDefined == nil {
 		opts.UserDefined = make(map[string]string)
@@ -756,8 +958,9 @@ func (n *jfsObjects) GetObjectInfo(ctx context.Context, bucket, object string, o
 		ContentType: contentType,
 		UserTags:    string(tagStr),
 		UserDefined: minio.CleanMetadata(opts.UserDefined),
-		IsLatest:    true,
-	}, nil
+		VersionID:   objVersionID(versionID),
+		IsLatest:    latest,
+	}, p, nil
 }
 
 func (n *jfsObjects) mkdirAll(ctx context.Context, p string) error {
@@ -774,6 +977,9 @@ func (n *jfsObjects) mkdirAll(ctx context.Context, p string) error {
 		}
 		eno = n.fs.Mkdir(mctx, p, 0777, n.gConf.Umask)
 	}
+	if eno == 0 && !strings.HasPrefix(p, sep+metaBucket+sep) && !strings.HasPrefix(p, sep+minio.MinioMetaBucket+sep) {
+		n.chown(ctx, p)
+	}
 	if eno != 0 && fs.IsExist(eno) {
 		eno = 0
 	}
@@ -783,7 +989,7 @@ func (n *jfsObjects) mkdirAll(ctx context.Context, p string) error {
 	return eno
 }
 
-func (n *jfsObjects) putObject(ctx context.Context, bucket, object string, r *minio.PutObjReader, opts minio.ObjectOptions, applyObjTaggingFunc func(tmpName string)) (err error) {
+func (n *jfsObjects) putObject(ctx context.Context, bucket, object string, r *minio.PutObjReader, opts minio.ObjectOptions, applyObjTaggingFunc func(tmpName string) error) (err error) {
 	uuid := minio.MustGetUUID()
 	tmpname := n.tpath(bucket, "tmp", uuid[:subDirPrefix], uuid)
 	f, eno := n.fs.Create(mctx, tmpname, 0666, n.gConf.Umask)
@@ -796,6 +1002,7 @@ func (n *jfsObjects) putObject(ctx context.Context, bucket, object string, r *mi
 		err = eno
 		return
 	}
+	n.chown(ctx, tmpname)
 	defer func() {
 		if err != nil {
 			_ = n.fs.Delete(mctx, tmpname)
@@ -830,7 +1037,9 @@ func (n *jfsObjects) putObject(ctx context.Context, bucket, object string, r *mi
 		return
 	}
 
-	applyObjTaggingFunc(tmpname)
+	if err = applyObjTaggingFunc(tmpname); err != nil {
+		return
+	}
 
 	eno = n.fs.Rename(mctx, tmpname, object, 0)
 	if eno == syscall.ENOENT {
@@ -848,12 +1057,26 @@ func (n *jfsObjects) putObject(ctx context.Context, bucket, object string, r *mi
 }
 
 func (n *jfsObjects) PutObject(ctx context.Context, bucket string, object string, r *minio.PutObjReader, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
+	defer func() {
+		if err == nil {
+			n.notify(ctx, EventObjectCreatedPut, objInfo)
+		}
+	}()
 	if err = n.checkBucket(ctx, bucket); err != nil {
 		return
 	}
 	var tagStr string
-	var etag string
+	var etag, versionID string
+	var unlock func() // the versions of object are locked until it's replaced
+	defer func() {
+		if unlock != nil {
+			unlock()
+		}
+	}()
 	p := n.path(bucket, object)
+	if err = n.checkWrite(ctx, bucket, object, p, true); err != nil {
+		return
+	}
 	if strings.HasSuffix(object, sep) {
 		if err = n.mkdirAll(ctx, p); err != nil {
 			err = jfsToObjectErr(ctx, err, bucket, object)
@@ -870,7 +1093,7 @@ func (n *jfsObjects) PutObject(ctx context.Context, bucket string, object string
 		// if the put object is a directory, set its atime to 0
 		n.setFileAtime(p, 0)
 	} else {
-		if err = n.putObject(ctx, bucket, p, r, opts, func(tmpName string) {
+		if err = n.putObject(ctx, bucket, p, r, opts, func(tmpName string) (err error) {
 			etag = r.MD5CurrentHexString()
 			if n.gConf.KeepEtag && !strings.HasSuffix(object, sep) {
 				if eno := n.fs.SetXattr(mctx, tmpName, s3Etag, []byte(etag), 0); eno != 0 {
@@ -889,6 +1112,8 @@ func (n *jfsObjects) PutObject(ctx context.Context, bucket string, object string
 			if err != nil {
 				logger.Errorf("set object metadata error, path: %s error %s", p, err)
 			}
+			versionID, unlock, err = n.newVersion(ctx, bucket, object, tmpName, opts)
+			return jfsToObjectErr(ctx, err, bucket, object)
 		}); err != nil {
 			return
 		}
@@ -908,6 +1133,7 @@ func (n *jfsObjects) PutObject(ctx context.Context, bucket string, object string
 		AccTime:     fi.ModTime(),
 		UserTags:    tagStr,
 		UserDefined: minio.CleanMetadata(opts.UserDefined),
+		VersionID:   versionID,
 		IsLatest:    true,
 	}, nil
 }
@@ -916,6 +1142,9 @@ func (n *jfsObjects) NewMultipartUpload(ctx context.Context, bucket string, obje
 	if err = n.checkBucket(ctx, bucket); err != nil {
 		return
 	}
+	if err = n.checkWrite(ctx, bucket, object, n.path(bucket, object), true); err != nil {
+		return
+	}
 	uploadID = minio.MustGetUUID()
 	p := n.upath(bucket, uploadID)
 	err = n.mkdirAll(ctx, p)
@@ -1109,6 +1338,9 @@ func (n *jfsObjects) checkUploadIDExists(ctx context.Context, bucket, object, up
 	if err = n.checkBucket(ctx, bucket); err != nil {
 		return
 	}
+	if err = n.checkWrite(ctx, bucket, object, n.path(bucket, object), true); err != nil {
+		return
+	}
 	_, eno := n.fs.Stat(mctx, n.upath(bucket, uploadID))
 	return jfsToObjectErr(ctx, eno, bucket, object, uploadID)
 }
@@ -1158,7 +1390,10 @@ func (n *jfsObjects) ListObjectParts(ctx context.Context, bucket, object, upload
 
 func (n *jfsObjects) CopyObjectPart(ctx context.Context, srcBucket, srcObject, dstBucket, dstObject, uploadID string, partID int,
 	startOffset int64, length int64, srcInfo minio.ObjectInfo, srcOpts, dstOpts minio.ObjectOptions) (result minio.PartInfo, err error) {
-	if err = n.isValidBucketName(srcBucket); err != nil {
+	if err = n.checkBucket(ctx, srcBucket); err != nil {
+		return
+	}
+	if err = n.checkAccess(ctx, srcBucket, srcObject, n.path(srcBucket, srcObject), vfs.MODE_MASK_R); err != nil {
 		return
 	}
 	if err = n.checkUploadIDExists(ctx, dstBucket, dstObject, uploadID); err != nil {
@@ -1174,11 +1409,12 @@ func (n *jfsObjects) PutObjectPart(ctx context.Context, bucket, object, uploadID
 	}
 	p := n.ppath(bucket, uploadID, strconv.Itoa(partID))
 	var etag string
-	if err = n.putObject(ctx, bucket, p, r, opts, func(tmpName string) {
+	if err = n.putObject(ctx, bucket, p, r, opts, func(tmpName string) error {
 		etag = r.MD5CurrentHexString()
 		if n.fs.SetXattr(mctx, tmpName, s3Etag, []byte(etag), 0) != 0 {
 			logger.Warnf("set xattr error, path: %s,xattr: %s,value: %s,flags: %d", tmpName, s3Etag, etag, 0)
 		}
+		return nil
 	}); err != nil {
 		err = jfsToObjectErr(ctx, err, bucket, object)
 		return
@@ -1201,6 +1437,11 @@ func (n *jfsObjects) GetMultipartInfo(ctx context.Context, bucket, object, uploa
 }
 
 func (n *jfsObjects) CompleteMultipartUpload(ctx context.Context, bucket, object, uploadID string, parts []minio.CompletePart, opts minio.ObjectOptions) (objInfo minio.ObjectInfo, err error) {
+	defer func() {
+		if err == nil {
+			n.notify(ctx, EventObjectCreatedComplete, objInfo)
+		}
+	}()
 	if err = n.checkUploadIDExists(ctx, bucket, object, uploadID); err != nil {
 		return
 	}
@@ -1234,6 +1475,7 @@ func (n *jfsObjects) CompleteMultipartUpload(ctx context.Context, bucket, object
 		logger.Errorf("create complete: %s", err)
 		return
 	}
+	n.chown(ctx, tmp)
 	defer func() {
 		_ = f.Close(mctx)
 	}()
@@ -1285,6 +1527,12 @@ func (n *jfsObjects) CompleteMultipartUpload(ctx context.Context, bucket, object
 		}
 	}
 
+	versionID, unlock, err := n.newVersion(ctx, bucket, object, tmp, opts)
+	if err != nil {
+		err = jfsToObjectErr(ctx, err, bucket, object, uploadID)
+		return
+	}
+	defer unlock()
 	name := n.path(bucket, object)
 	eno = n.fs.Rename(mctx, tmp, name, 0)
 	if eno == syscall.ENOENT {
@@ -1322,6 +1570,7 @@ func (n *jfsObjects) CompleteMultipartUpload(ctx context.Context, bucket, object
 		AccTime:     fi.ModTime(),
 		UserTags:    string(tagStr),
 		UserDefined: minio.CleanMetadata(opts.UserDefined),
+		VersionID:   versionID,
 		IsLatest:    true,
 	}, nil
 }
@@ -1516,15 +1765,6 @@ func (n *jfsObjects) LocalStorageInfo(ctx context.Context) (minio.StorageInfo, [
 	return n.StorageInfo(ctx)
 }
 
-func (n *jfsObjects) ListObjectVersions(ctx context.Context, bucket, prefix, marker, versionMarker, delimiter string, maxKeys int) (loi minio.ListObjectVersionsInfo, err error) {
-	objs, err := n.ListObjects(ctx, bucket, prefix, marker, delimiter, maxKeys)
-	if err == nil {
-		loi.Objects = objs.Objects
-		loi.Prefixes = objs.Prefixes
-	}
-	return loi, err
-}
-
 func (n *jfsObjects) getObjectInfoNoFSLock(ctx context.Context, bucket, object string, info any) (oi minio.ObjectInfo, e error) {
 	if info != nil {
 		fi := info.(*fs.FileStat)
@@ -1546,7 +1786,7 @@ func (n *jfsObjects) getObjectInfoNoFSLock(ctx context.Context, bucket, object s
 }
 
 func (n *jfsObjects) Walk(ctx context.Context, bucket, prefix string, results chan<- minio.ObjectInfo, opts minio.ObjectOptions) error {
-	return minio.FsWalk(ctx, n, bucket, prefix, n.listDirFactory(), n.isLeaf, n.isLeafDir, results, n.getObjectInfoNoFSLock, n.getObjectInfoNoFSLock)
+	return minio.FsWalk(ctx, n, bucket, prefix, n.listDirFactory(nil), n.isLeaf, n.isLeafDir, results, n.getObjectInfoNoFSLock, n.getObjectInfoNoFSLock)
 }
 
 func (n *jfsObjects) SetBucketPolicy(ctx context.Context, bucket string, policy *policy.Policy) error {
@@ -1627,6 +1867,9 @@ func (n *jfsObjects) PutObjectTags(ctx context.Context, bucket, object string, t
 	if !n.gConf.ObjTag {
 		return minio.ObjectInfo{}, minio.NotImplemented{}
 	}
+	if err := n.checkAccess(ctx, bucket, object, n.path(bucket, object), vfs.MODE_MASK_W); err != nil {
+		return minio.ObjectInfo{}, err
+	}
 	if eno := n.fs.SetXattr(mctx, n.path(bucket, object), s3Tags, []byte(tags), 0); eno != 0 {
 		return minio.ObjectInfo{}, eno
 	}
@@ -1637,6 +1880,9 @@ func (n *jfsObjects) GetObjectTags(ctx context.Context, bucket, object string, o
 	if !n.gConf.ObjTag {
 		return nil, minio.NotImplemented{}
 	}
+	if err := n.checkAccess(ctx, bucket, object, n.path(bucket, object), vfs.MODE_MASK_R); err != nil {
+		return nil, err
+	}
 	oi, err := n.GetObjectInfo(ctx, bucket, object, minio.ObjectOptions{})
 	if err != nil {
 		return nil, err
@@ -1649,13 +1895,26 @@ func (n *jfsObjects) DeleteObjectTags(ctx context.Context, bucket, object string
 	if !n.gConf.ObjTag {
 		return minio.ObjectInfo{}, minio.NotImplemented{}
 	}
+	if err := n.checkAccess(ctx, bucket, object, n.path(bucket, object), vfs.MODE_MASK_W); err != nil {
+		return minio.ObjectInfo{}, err
+	}
 	if errno := n.fs.RemoveXattr(mctx, n.path(bucket, object), s3Tags); errno != 0 && errno != meta.ENOATTR {
 		return minio.ObjectInfo{}, errno
 	}
 	return n.GetObjectInfo(ctx, bucket, object, opts)
 }
 
+// IsNotificationSupported is called by MinIO before initializing the notification system and handling
+// the notification configuration, the event targets are registered into it here.
 func (n *jfsObjects) IsNotificationSupported() bool {
+	if n.events != nil && !n.events.registered.Load() {
+		if list := minioTargets(); list != nil {
+			if err := n.events.registerTargets(list); err != nil {
+				logger.Errorf("%s", err)
+			}
+			n.events.registered.Store(true)
+		}
+	}
 	return true
 }
 
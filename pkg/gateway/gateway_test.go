@@ -18,8 +18,11 @@ package gateway
 
 import (
 	"context"
+	"encoding/xml"
 	"errors"
 	"os"
+	"path"
+	"sync"
 	"testing"
 	"time"
 
@@ -29,6 +32,8 @@ import (
 	"github.com/juicedata/juicefs/pkg/object"
 	"github.com/juicedata/juicefs/pkg/vfs"
 	minio "github.com/minio/minio/cmd"
+	mlogger "github.com/minio/minio/cmd/logger"
+	iampolicy "github.com/minio/minio/pkg/iam/policy"
 )
 
 func TestGatewayLock(t *testing.T) {
@@ -220,3 +225,468 @@ func TestGetObjectInfo(t *testing.T) {
 		assertHeadObject(t, jfsObj, bucket, "dir1/key1/", false)
 	})
 }
+
+func putTestVersion(t *testing.T, jfsObj *jfsObjects, jfs *fs.FileSystem, object, data string, opts minio.ObjectOptions) string {
+	t.Helper()
+	tmp := "/" + minio.MustGetUUID()
+	f, eno := jfs.Create(mctx, tmp, 0666, 022)
+	if eno != 0 {
+		t.Fatalf("create %s: %s", tmp, eno)
+	}
+	if _, eno = f.Write(mctx, []byte(data)); eno != 0 {
+		t.Fatalf("write %s: %s", tmp, eno)
+	}
+	if eno = f.Close(mctx); eno != 0 {
+		t.Fatalf("close %s: %s", tmp, eno)
+	}
+	id, unlock, err := jfsObj.newVersion(context.Background(), jfsObj.gConf.Bucket, object, tmp, opts)
+	if err != nil {
+		t.Fatalf("new version of %s: %s", object, err)
+	}
+	defer unlock()
+	if eno = jfs.Rename(mctx, tmp, "/"+object, 0); eno != 0 {
+		t.Fatalf("rename %s: %s", tmp, eno)
+	}
+	return id
+}
+
+func TestVersioning(t *testing.T) {
+	ctx := context.Background()
+	jfsObj, jfs, bucket := newTestGateway(t, Config{})
+	versioned := minio.ObjectOptions{Versioned: true}
+	head := func(versionID string) (minio.ObjectInfo, error) {
+		return jfsObj.GetObjectInfo(ctx, bucket, "obj", minio.ObjectOptions{Versioned: true, VersionID: versionID})
+	}
+	list := func(marker, versionMarker string, maxKeys int) minio.ListObjectVersionsInfo {
+		loi, err := jfsObj.ListObjectVersions(ctx, bucket, "", marker, versionMarker, "", maxKeys)
+		if err != nil {
+			t.Fatalf("list versions: %s", err)
+		}
+		return loi
+	}
+
+	v1 := putTestVersion(t, jfsObj, jfs, "obj", "v1", versioned)
+	v2 := putTestVersion(t, jfsObj, jfs, "obj", "version2", versioned)
+	if v1 == "" || v2 == "" || v1 == v2 {
+		t.Fatalf("invalid version IDs: %q %q", v1, v2)
+	}
+	if oi, err := head(""); err != nil || oi.VersionID != v2 || oi.Size != 8 || !oi.IsLatest {
+		t.Fatalf("head the latest version: %+v %v", oi, err)
+	}
+	if oi, err := head(v1); err != nil || oi.VersionID != v1 || oi.Size != 2 || oi.IsLatest {
+		t.Fatalf("head version %s: %+v %v", v1, oi, err)
+	}
+	if _, err := head(minio.MustGetUUID()); !errors.As(err, &minio.VersionNotFound{}) {
+		t.Fatalf("head unknown version should return VersionNotFound: %v", err)
+	}
+	assertHeadObject(t, jfsObj, bucket, "obj", true)
+
+	// delete marker
+	info, err := jfsObj.DeleteObject(ctx, bucket, "obj", versioned)
+	if err != nil || !info.DeleteMarker || info.VersionID == "" {
+		t.Fatalf("delete obj: %+v %v", info, err)
+	}
+	marker := info.VersionID
+	assertHeadObject(t, jfsObj, bucket, "obj", false)
+	if _, err = head(marker); !errors.As(err, &minio.MethodNotAllowed{}) {
+		t.Fatalf("head delete marker should return MethodNotAllowed: %v", err)
+	}
+	loi := list("", "", 0)
+	if len(loi.Objects) != 3 || loi.IsTruncated {
+		t.Fatalf("expect 3 versions, got %+v", loi)
+	}
+	for i, id := range []string{marker, v2, v1} {
+		if oi := loi.Objects[i]; oi.Name != "obj" || oi.VersionID != id || oi.IsLatest != (i == 0) || oi.DeleteMarker != (i == 0) {
+			t.Fatalf("version %d: %+v", i, oi)
+		}
+	}
+
+	// paginated
+	loi = list("", "", 2)
+	if len(loi.Objects) != 2 || !loi.IsTruncated || loi.NextMarker != "obj" || loi.NextVersionIDMarker != v2 {
+		t.Fatalf("list the first page: %+v", loi)
+	}
+	loi = list(loi.NextMarker, loi.NextVersionIDMarker, 2)
+	if len(loi.Objects) != 1 || loi.IsTruncated || loi.Objects[0].VersionID != v1 {
+		t.Fatalf("list the second page: %+v", loi)
+	}
+
+	// removing the delete marker restores the object
+	if _, err = jfsObj.DeleteObject(ctx, bucket, "obj", minio.ObjectOptions{VersionID: marker}); err != nil {
+		t.Fatalf("delete the marker: %s", err)
+	}
+	if oi, err := head(""); err != nil || oi.VersionID != v2 {
+		t.Fatalf("head obj after the marker removed: %+v %v", oi, err)
+	}
+	// removing the latest version restores the prior one
+	if _, err = jfsObj.DeleteObject(ctx, bucket, "obj", minio.ObjectOptions{VersionID: v2}); err != nil {
+		t.Fatalf("delete version %s: %s", v2, err)
+	}
+	if oi, err := head(""); err != nil || oi.VersionID != v1 || oi.Size != 2 {
+		t.Fatalf("head obj after the latest removed: %+v %v", oi, err)
+	}
+
+	// the null version is replaced in suspended mode
+	suspended := minio.ObjectOptions{VersionSuspended: true}
+	if id := putTestVersion(t, jfsObj, jfs, "obj", "null1", suspended); id != "" {
+		t.Fatalf("expect the null version, got %s", id)
+	}
+	putTestVersion(t, jfsObj, jfs, "obj", "null2", suspended)
+	loi = list("", "", 0)
+	if len(loi.Objects) != 2 || loi.Objects[0].VersionID != "" || loi.Objects[0].Size != 5 || loi.Objects[1].VersionID != v1 {
+		t.Fatalf("expect the null version and %s, got %+v", v1, loi)
+	}
+
+	// concurrent puts archive each version once
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tmp := "/" + minio.MustGetUUID()
+			f, eno := jfs.Create(mctx, tmp, 0666, 022)
+			if eno != 0 {
+				errs <- eno
+				return
+			}
+			_ = f.Close(mctx)
+			_, unlock, err := jfsObj.newVersion(ctx, bucket, "obj", tmp, versioned)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer unlock()
+			if eno = jfs.Rename(mctx, tmp, "/obj", 0); eno != 0 {
+				errs <- eno
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent put: %s", err)
+	}
+	if loi = list("", "", 0); len(loi.Objects) != 10 {
+		t.Fatalf("expect 10 versions, got %d", len(loi.Objects))
+	}
+
+	// paginated over the objects
+	for _, name := range []string{"a/1", "a/2", "b"} {
+		if eno := jfs.MkdirAll(mctx, path.Dir("/"+name), 0755, 022); eno != 0 {
+			t.Fatalf("mkdir: %s", eno)
+		}
+		putTestVersion(t, jfsObj, jfs, name, "1", versioned)
+		putTestVersion(t, jfsObj, jfs, name, "2", versioned)
+	}
+	all := list("", "", 0)
+	var paged []minio.ObjectInfo
+	for marker, versionMarker := "", ""; ; {
+		loi = list(marker, versionMarker, 3)
+		paged = append(paged, loi.Objects...)
+		if !loi.IsTruncated {
+			break
+		}
+		marker, versionMarker = loi.NextMarker, loi.NextVersionIDMarker
+	}
+	if len(all.Objects) != 16 || len(paged) != len(all.Objects) {
+		t.Fatalf("expect 16 versions, got %d and %d paged", len(all.Objects), len(paged))
+	}
+	for i := range paged {
+		if paged[i].Name != all.Objects[i].Name || paged[i].VersionID != all.Objects[i].VersionID {
+			t.Fatalf("version %d: %+v, expect %+v", i, paged[i], all.Objects[i])
+		}
+	}
+}
+
+func TestGatewayUsers(t *testing.T) {
+	jfsObj, jfs, bucket := newTestGateway(t, Config{})
+	mctx = meta.NewContext(uint32(os.Getpid()), 0, []uint32{0})
+	m := jfs.Meta()
+	alice := &User{Uid: 1000, Gid: 1000}
+	if err := AddUser(m, alice, ""); err == nil {
+		t.Fatalf("root credential is required")
+	}
+	if err := AddUser(m, alice, "rootsecret"); err != nil || len(alice.AccessKey) != 20 || len(alice.SecretKey) != 40 {
+		t.Fatalf("add user: %s %+v", err, alice)
+	}
+	if err := AddUser(m, &User{AccessKey: alice.AccessKey}, "rootsecret"); err == nil {
+		t.Fatalf("access key should be unique")
+	}
+	bob := &User{AccessKey: "bob", SecretKey: "bobsecret", Uid: 1001, Gid: 1001, Buckets: []string{"other"}}
+	if err := AddUser(m, bob, "rootsecret"); err != nil {
+		t.Fatalf("add user: %s", err)
+	}
+	if _, err := m.StoreToken(meta.Background(), []byte(`{"User":"hdfs","Expire":1}`)); err != 0 {
+		t.Fatalf("store token: %s", err)
+	}
+	users, err := ListUsers(m)
+	if err != nil || len(users) != 2 {
+		t.Fatalf("list users: %s %d", err, len(users))
+	}
+	for _, u := range users {
+		if u.SecretKey != "" || u.Sealed == "" {
+			t.Fatalf("secret key of %s should be encrypted: %+v", u.AccessKey, u)
+		}
+		if secret, err := u.Secret("rootsecret"); err != nil || u.AccessKey == "bob" && secret != "bobsecret" {
+			t.Fatalf("decrypt secret key of %s: %s %s", u.AccessKey, secret, err)
+		}
+		if _, err := u.Secret("wrongsecret"); err == nil {
+			t.Fatalf("secret key of %s should not be decrypted with a wrong root credential", u.AccessKey)
+		}
+	}
+	jfsObj.users = newUserStore(m, "admin")
+	as := func(u *User) context.Context {
+		return mlogger.SetReqInfo(context.Background(), &mlogger.ReqInfo{AccessKey: u.AccessKey})
+	}
+	isDenied := func(err error) bool {
+		return errors.As(err, &minio.PrefixAccessDenied{})
+	}
+
+	if err := jfsObj.checkBucket(as(bob), bucket); !isDenied(err) {
+		t.Fatalf("bob should not access bucket %s: %v", bucket, err)
+	}
+	if err := jfsObj.checkBucket(as(alice), bucket); err != nil {
+		t.Fatalf("check bucket: %s", err)
+	}
+	if err := jfsObj.checkBucket(context.Background(), bucket); err != nil {
+		t.Fatalf("check bucket as root: %s", err)
+	}
+	if _, err := jfsObj.GetBucketInfo(as(bob), bucket); !isDenied(err) {
+		t.Fatalf("bob should not get bucket %s: %v", bucket, err)
+	}
+	if u := jfsObj.users.get("admin"); u != nil {
+		t.Fatalf("root credential should access as the gateway: %+v", u)
+	}
+	if u := jfsObj.users.get("svc"); u != deniedUser {
+		t.Fatalf("unknown access key should be denied: %+v", u)
+	}
+	jfsObj.users.setOthers(map[string]bool{"svc": true})
+	if err := jfsObj.checkBucket(as(&User{AccessKey: "svc"}), bucket); err != nil {
+		t.Fatalf("users of MinIO should access as the gateway: %s", err)
+	}
+	if err := jfsObj.checkBucket(as(&User{AccessKey: "mallory"}), bucket); !isDenied(err) {
+		t.Fatalf("unknown access key should be denied: %v", err)
+	}
+
+	// the config of buckets in the meta bucket of MinIO
+	for b, denied := range map[string]bool{bucket: true, "other": false} {
+		object := path.Join(minio.BucketMetaPrefix, b, minio.BucketMetadataFile)
+		if err := jfsObj.checkWrite(as(bob), minio.MinioMetaBucket, object, jfsObj.path(minio.MinioMetaBucket, object), true); isDenied(err) != denied {
+			t.Fatalf("bob should write the config of bucket %s: %v, got %v", b, !denied, err)
+		}
+	}
+	if err := jfsObj.checkAccess(as(bob), minio.MinioMetaBucket, "config/iam/format.json", "/", vfs.MODE_MASK_R); !isDenied(err) {
+		t.Fatalf("bob should not read the config of MinIO: %v", err)
+	}
+	for b, allowed := range map[string]bool{bucket: false, "other": true} {
+		p, err := bob.policy()
+		if err != nil {
+			t.Fatalf("policy of bob: %s", err)
+		}
+		args := iampolicy.Args{AccountName: "bob", Action: iampolicy.PutObjectAction, BucketName: b, ObjectName: "obj"}
+		if p.IsAllowed(args) != allowed {
+			t.Fatalf("bob should put objects into %s: %v", b, allowed)
+		}
+		args.Action, args.ObjectName = iampolicy.PutBucketLifecycleAction, ""
+		if p.IsAllowed(args) {
+			t.Fatalf("bob should not change the lifecycle of %s", b)
+		}
+	}
+
+	if eno := jfs.Mkdir(mctx, "/private", 0700, 0); eno != 0 {
+		t.Fatalf("mkdir: %s", eno)
+	}
+	if err := jfsObj.checkWrite(as(alice), bucket, "private/a/b", "/private/a/b", true); !isDenied(err) {
+		t.Fatalf("alice should not write into /private: %v", err)
+	}
+	if err := jfsObj.checkList(as(alice), bucket, "private/a"); !isDenied(err) {
+		t.Fatalf("alice should not list /private: %v", err)
+	}
+	if err := jfsObj.checkWrite(context.Background(), bucket, "private/a", "/private/a", true); err != nil {
+		t.Fatalf("check write as root: %s", err)
+	}
+	if err := jfsObj.checkWrite(as(alice), bucket, "shared/a/b", "/shared/a/b", true); err != nil {
+		t.Fatalf("check write: %s", err)
+	}
+	if err := jfsObj.mkdirAll(as(alice), "/shared/a"); err != nil {
+		t.Fatalf("mkdir: %s", err)
+	}
+	for _, p := range []string{"/shared", "/shared/a"} {
+		if fi, eno := jfs.Stat(mctx, p); eno != 0 || fi.Uid() != 1000 || fi.Gid() != 1000 {
+			t.Fatalf("owner of %s: %s %+v", p, eno, fi)
+		}
+	}
+
+	createTestFile(t, jfs, "/secret")
+	if err := jfsObj.checkAccess(as(alice), bucket, "secret", "/secret", vfs.MODE_MASK_R); err != nil {
+		t.Fatalf("alice should read /secret: %s", err)
+	}
+	if err := jfsObj.checkWrite(as(alice), bucket, "secret", "/secret", true); !isDenied(err) {
+		t.Fatalf("alice should not overwrite /secret: %v", err)
+	}
+	createTestFile(t, jfs, "/private/leak")
+	listed := func(ctx context.Context) bool {
+		loi, err := jfsObj.ListObjects(ctx, bucket, "", "", "", 1000)
+		if err != nil {
+			t.Fatalf("list objects: %s", err)
+		}
+		for _, o := range loi.Objects {
+			if o.Name == "private/leak" {
+				return true
+			}
+		}
+		return false
+	}
+	if listed(as(alice)) {
+		t.Fatalf("alice should not list the objects under /private")
+	}
+	if !listed(context.Background()) {
+		t.Fatalf("root should list the objects under /private")
+	}
+
+	if err := RemoveUser(m, alice.AccessKey); err != nil {
+		t.Fatalf("remove user: %s", err)
+	}
+	if users, err := ListUsers(m); err != nil || len(users) != 1 || users[0].AccessKey != "bob" {
+		t.Fatalf("list users: %s %+v", err, users)
+	}
+	jfsObj.users.loaded = time.Time{}
+	if err := jfsObj.checkBucket(as(alice), bucket); !isDenied(err) {
+		t.Fatalf("removed user should be denied: %v", err)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	ctx := context.Background()
+	jfsObj, jfs, bucket := newTestGateway(t, Config{ObjTag: true})
+	var conf lifecycleConfig
+	err := xml.Unmarshal([]byte(`<LifecycleConfiguration>
+  <Rule><ID>logs</ID><Status>Enabled</Status><Filter><Prefix>logs/</Prefix></Filter><Expiration><Days>1</Days></Expiration></Rule>
+  <Rule><ID>temp</ID><Status>Enabled</Status><Filter><Tag><Key>temp</Key><Value>true</Value></Tag></Filter><Expiration><Days>1</Days></Expiration></Rule>
+  <Rule><ID>uploads</ID><Status>Enabled</Status><Filter><Prefix>tmp/</Prefix></Filter><AbortIncompleteMultipartUpload><DaysAfterInitiation>1</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule>
+</LifecycleConfiguration>`), &conf)
+	if err != nil || len(conf.Rules) != 3 || conf.Rules[1].tags()[0].Key != "temp" {
+		t.Fatalf("parse lifecycle: %s %+v", err, conf)
+	}
+
+	now := time.Now()
+	old := now.Add(-time.Hour * 72).UnixMilli()
+	if eno := jfs.MkdirAll(mctx, "/logs/2026", 0755, 022); eno != 0 {
+		t.Fatalf("mkdir: %s", eno)
+	}
+	for name, tags := range map[string]string{"logs/2026/old": "", "logs/new": "", "a": "temp=true", "b": "temp=false&x=y"} {
+		createTestFile(t, jfs, "/"+name)
+		f, eno := jfs.Open(mctx, "/"+name, 0)
+		if eno != 0 {
+			t.Fatalf("open %s: %s", name, eno)
+		}
+		if name != "logs/new" {
+			_ = f.Utime(mctx, -1, old)
+		}
+		_ = f.Close(mctx)
+		if tags != "" {
+			if eno = jfs.SetXattr(mctx, "/"+name, s3Tags, []byte(tags), 0); eno != 0 {
+				t.Fatalf("set tags of %s: %s", name, eno)
+			}
+		}
+	}
+	uploadID, err := jfsObj.NewMultipartUpload(ctx, bucket, "tmp/big", minio.ObjectOptions{})
+	if err != nil {
+		t.Fatalf("new multipart upload: %s", err)
+	}
+
+	jfsObj.applyLifecycle(ctx, bucket, conf.Rules, minio.ObjectOptions{}, now)
+	for name, exists := range map[string]bool{"logs/2026/old": false, "logs/2026": false, "logs/new": true, "a": false, "b": true} {
+		if _, eno := jfs.Stat(mctx, "/"+name); (eno == 0) != exists {
+			t.Fatalf("%s should exist: %v, got %s", name, exists, eno)
+		}
+	}
+	if err = jfsObj.checkUploadIDExists(ctx, bucket, "tmp/big", uploadID); err != nil {
+		t.Fatalf("upload should not be aborted: %s", err)
+	}
+
+	jfsObj.applyLifecycle(ctx, bucket, conf.Rules, minio.ObjectOptions{}, now.Add(time.Hour*48))
+	if err = jfsObj.checkUploadIDExists(ctx, bucket, "tmp/big", uploadID); err == nil {
+		t.Fatalf("upload should be aborted")
+	}
+	if _, eno := jfs.Stat(mctx, "/logs/new"); eno == 0 {
+		t.Fatalf("logs/new should be expired")
+	}
+
+	// the objects are expired as the owner of bucket
+	rules := []lifecycleRule{conf.Rules[0]}
+	rules[0].Filter.Prefix = "locked/"
+	if eno := jfs.Mkdir(mctx, "/locked", 0755, 0); eno != 0 {
+		t.Fatalf("mkdir: %s", eno)
+	}
+	createTestFile(t, jfs, "/locked/old")
+	root, eno := jfs.Open(mctx, "/", 0)
+	if eno != 0 {
+		t.Fatalf("open /: %s", eno)
+	}
+	if eno = root.Chown(mctx, 1000, 1000); eno != 0 {
+		t.Fatalf("chown /: %s", eno)
+	}
+	_ = root.Close(mctx)
+	jfsObj.applyLifecycle(ctx, bucket, rules, minio.ObjectOptions{}, now.Add(time.Hour*48))
+	if _, eno := jfs.Stat(mctx, "/locked/old"); eno != 0 {
+		t.Fatalf("locked/old should not be expired by the owner of bucket: %s", eno)
+	}
+
+	if ok, err := jfs.Meta().ClaimJob(lifecycleJob, lifecycleInterval); err != nil || !ok {
+		t.Fatalf("claim lifecycle: %v %s", ok, err)
+	}
+	if ok, err := jfs.Meta().ClaimJob(lifecycleJob, lifecycleInterval); err != nil || ok {
+		t.Fatalf("lifecycle should be claimed already: %v %s", ok, err)
+	}
+}
+
+func TestLifecycleVersions(t *testing.T) {
+	ctx := context.Background()
+	jfsObj, jfs, bucket := newTestGateway(t, Config{})
+	versioned := minio.ObjectOptions{Versioned: true}
+	var conf lifecycleConfig
+	err := xml.Unmarshal([]byte(`<LifecycleConfiguration>
+  <Rule><ID>versions</ID><Status>Enabled</Status><Filter><Prefix></Prefix></Filter>
+    <Expiration><ExpiredObjectDeleteMarker>true</ExpiredObjectDeleteMarker></Expiration>
+    <NoncurrentVersionExpiration><NoncurrentDays>1</NoncurrentDays><NewerNoncurrentVersions>1</NewerNoncurrentVersions></NoncurrentVersionExpiration>
+  </Rule>
+</LifecycleConfiguration>`), &conf)
+	if err != nil || len(conf.Rules) != 1 || conf.Rules[0].NoncurrentExpiration.Newer != 1 || !conf.Rules[0].Expiration.DeleteMarker {
+		t.Fatalf("parse lifecycle: %s %+v", err, conf)
+	}
+	versions := func() []minio.ObjectInfo {
+		loi, err := jfsObj.ListObjectVersions(ctx, bucket, "", "", "", "", 0)
+		if err != nil {
+			t.Fatalf("list versions: %s", err)
+		}
+		return loi.Objects
+	}
+
+	v1 := putTestVersion(t, jfsObj, jfs, "obj", "v1", versioned)
+	v2 := putTestVersion(t, jfsObj, jfs, "obj", "v2", versioned)
+	v3 := putTestVersion(t, jfsObj, jfs, "obj", "v3", versioned)
+	now := time.Now()
+	jfsObj.applyLifecycle(ctx, bucket, conf.Rules, versioned, now)
+	if vs := versions(); len(vs) != 3 {
+		t.Fatalf("no version should be expired: %+v", vs)
+	}
+	// the newest noncurrent version is kept
+	jfsObj.applyLifecycle(ctx, bucket, conf.Rules, versioned, now.Add(time.Hour*48))
+	if vs := versions(); len(vs) != 2 || vs[0].VersionID != v3 || vs[1].VersionID != v2 {
+		t.Fatalf("expect %s and %s, got %+v (%s expired)", v3, v2, vs, v1)
+	}
+
+	if _, err = jfsObj.DeleteObject(ctx, bucket, "obj", versioned); err != nil {
+		t.Fatalf("delete obj: %s", err)
+	}
+	conf.Rules[0].NoncurrentExpiration.Newer = 0
+	jfsObj.applyLifecycle(ctx, bucket, conf.Rules, versioned, now.Add(time.Hour*96))
+	if vs := versions(); len(vs) != 0 {
+		t.Fatalf("all the versions and the delete marker should be expired: %+v", vs)
+	}
+	if _, eno := jfs.Stat(mctx, jfsObj.vdir(bucket, "obj")); eno == 0 {
+		t.Fatalf("versions of obj should be removed")
+	}
+}
@@ -0,0 +1,580 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/juicedata/juicefs/pkg/vfs"
+	minio "github.com/minio/minio/cmd"
+	mlogger "github.com/minio/minio/cmd/logger"
+	iampolicy "github.com/minio/minio/pkg/iam/policy"
+	"github.com/minio/minio/pkg/madmin"
+)
+
+const (
+	userTokenKind  = "s3-gateway-user" // to tell the users from other tokens in meta engine
+	userPolicy     = "jfs-user-"       // prefix of the policies of users, named by the access key
+	keepRemoved    = time.Hour * 24 * 7
+	reloadUsers    = time.Minute
+	reloadUsersMin = time.Second * 5 // for the users just added
+)
+
+// the actions changing the config of bucket, which is applied by the gateway as the process, so
+// they are denied for the users other than root
+var bucketConfigActions = []string{
+	"s3:PutLifecycleConfiguration",
+	"s3:PutBucketVersioning",
+	"s3:PutBucketNotification",
+	"s3:PutBucketPolicy",
+	"s3:DeleteBucketPolicy",
+	"s3:PutBucketTagging",
+	"s3:PutEncryptionConfiguration",
+	"s3:PutBucketObjectLockConfiguration",
+	"s3:PutReplicationConfiguration",
+}
+
+// User is a user of gateway stored in the meta engine, the objects are accessed as Uid/Gid with its
+// access key, and only the Buckets can be accessed if they are specified.
+type User struct {
+	Kind      string
+	AccessKey string
+	SecretKey string `json:",omitempty"` // only for the users added by old versions
+	Sealed    string `json:",omitempty"` // the secret key encrypted with the root credential
+	Uid       uint32
+	Gid       uint32
+	Groups    []uint32 `json:",omitempty"`
+	Buckets   []string `json:",omitempty"`
+	Created   int64
+	Removed   int64 `json:",omitempty"` // kept for a while to be removed from all the gateways
+
+	id     uint32
+	denied bool
+}
+
+// deniedUser is used for the access keys removed or not known, which can't access anything.
+var deniedUser = &User{denied: true}
+
+func (u *User) canAccess(bucket string) bool {
+	if u.denied || bucket == minio.MinioMetaBucket {
+		return false
+	}
+	if len(u.Buckets) == 0 {
+		return true
+	}
+	for _, b := range u.Buckets {
+		if b == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// canAccessMeta returns whether the user can access the object in the meta bucket of MinIO, which is
+// accessed internally for the config of the buckets accessible by it.
+func (u *User) canAccessMeta(object string) bool {
+	prefix := minio.BucketMetaPrefix + sep
+	if !strings.HasPrefix(object, prefix) {
+		return false
+	}
+	bucket, _, _ := strings.Cut(object[len(prefix):], sep)
+	return u.canAccess(bucket)
+}
+
+func (u *User) context() meta.Context {
+	return meta.NewContext(uint32(os.Getpid()), u.Uid, append([]uint32{u.Gid}, u.Groups...))
+}
+
+// policy returns the IAM policy of the user, which allows the buckets accessible by it and denies
+// changing the config of buckets for non-root users.
+func (u *User) policy() (*iampolicy.Policy, error) {
+	resources := []string{"arn:aws:s3:::*"}
+	if len(u.Buckets) > 0 {
+		resources = resources[:0]
+		for _, b := range u.Buckets {
+			resources = append(resources, "arn:aws:s3:::"+b, "arn:aws:s3:::"+b+"/*")
+		}
+	}
+	statements := []map[string]any{
+		{"Effect": "Allow", "Action": []string{"s3:ListAllMyBuckets", "s3:GetBucketLocation"}, "Resource": []string{"arn:aws:s3:::*"}},
+		{"Effect": "Allow", "Action": []string{"s3:*"}, "Resource": resources},
+	}
+	if u.Uid != 0 {
+		statements = append(statements, map[string]any{"Effect": "Deny", "Action": bucketConfigActions, "Resource": []string{"arn:aws:s3:::*"}})
+	}
+	data, _ := json.Marshal(map[string]any{"Version": "2012-10-17", "Statement": statements})
+	return iampolicy.ParseConfig(bytes.NewReader(data))
+}
+
+func sealKey(rootSecret string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte("juicefs-gateway-user:" + rootSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSecret encrypts the secret key of user with the root credential of gateway, which is not
+// stored in the meta engine.
+func sealSecret(rootSecret, secret string) (string, error) {
+	aead, err := sealKey(rootSecret)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+// Secret returns the secret key of the user, which is decrypted with the root credential of gateway.
+func (u *User) Secret(rootSecret string) (string, error) {
+	if u.Sealed == "" {
+		return u.SecretKey, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(u.Sealed)
+	if err != nil {
+		return "", err
+	}
+	aead, err := sealKey(rootSecret)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", fmt.Errorf("invalid secret key of %s", u.AccessKey)
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt secret key of %s: %s, the root credential may be different", u.AccessKey, err)
+	}
+	return string(plain), nil
+}
+
+func loadUsers(m meta.Meta) ([]*User, error) {
+	tokens, eno := m.ListTokens(meta.Background())
+	if eno != 0 {
+		return nil, fmt.Errorf("list tokens: %s", eno)
+	}
+	var users []*User
+	for id, data := range tokens {
+		var u User
+		if json.Unmarshal(data, &u) != nil || u.Kind != userTokenKind {
+			continue
+		}
+		u.id = id
+		users = append(users, &u)
+	}
+	return users, nil
+}
+
+// ListUsers returns the users of gateway, not including the removed ones.
+func ListUsers(m meta.Meta) ([]*User, error) {
+	all, err := loadUsers(m)
+	if err != nil {
+		return nil, err
+	}
+	var users []*User
+	for _, u := range all {
+		if u.Removed == 0 {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func randomKey(n int, chars string) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	for i := range b {
+		b[i] = chars[int(b[i])%len(chars)]
+	}
+	return string(b)
+}
+
+// AddUser stores a user of gateway, the access key and secret key are generated if not specified.
+// The secret key is stored encrypted with the root credential of gateway.
+func AddUser(m meta.Meta, u *User, rootSecret string) error {
+	if rootSecret == "" {
+		return fmt.Errorf("the root credential of gateway is required to encrypt the secret key")
+	}
+	if u.AccessKey == "" {
+		u.AccessKey = randomKey(20, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+	}
+	if u.SecretKey == "" {
+		u.SecretKey = randomKey(40, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789")
+	}
+	if len(u.AccessKey) < 3 || strings.ContainsAny(u.AccessKey, "=,") {
+		return fmt.Errorf("invalid access key %q", u.AccessKey)
+	}
+	if len(u.SecretKey) < 8 {
+		return fmt.Errorf("secret key should have at least 8 characters")
+	}
+	users, err := loadUsers(m)
+	if err != nil {
+		return err
+	}
+	for _, o := range users {
+		if o.AccessKey == u.AccessKey && o.Removed == 0 {
+			return fmt.Errorf("access key %s exists", u.AccessKey)
+		}
+	}
+	if u.Sealed, err = sealSecret(rootSecret, u.SecretKey); err != nil {
+		return fmt.Errorf("encrypt secret key: %s", err)
+	}
+	u.Kind = userTokenKind
+	u.Created = time.Now().Unix()
+	stored := *u
+	stored.SecretKey = ""
+	data, _ := json.Marshal(&stored)
+	id, eno := m.StoreToken(meta.Background(), data)
+	if eno != 0 {
+		return fmt.Errorf("store user: %s", eno)
+	}
+	u.id = id
+	return nil
+}
+
+// RemoveUser marks the user as removed, it's denied by all the gateways until deleted from the meta
+// engine after a while.
+func RemoveUser(m meta.Meta, accessKey string) error {
+	users, err := loadUsers(m)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.AccessKey == accessKey && u.Removed == 0 {
+			u.Removed = time.Now().Unix()
+			data, _ := json.Marshal(u)
+			if eno := m.UpdateToken(meta.Background(), u.id, data); eno != 0 {
+				return fmt.Errorf("update user: %s", eno)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("access key %s not found", accessKey)
+}
+
+// userStore caches the users of gateway by access key, including the removed ones. The access keys
+// of the root credential and the other users in the IAM of MinIO access the objects as the gateway
+// process, the others are denied.
+type userStore struct {
+	m        meta.Meta
+	rootUser string
+
+	sync.Mutex
+	users  map[string]*User
+	loaded time.Time
+	others map[string]bool // the users (and service accounts) in the IAM of MinIO not from meta engine
+}
+
+func newUserStore(m meta.Meta, rootUser string) *userStore {
+	return &userStore{m: m, rootUser: rootUser}
+}
+
+func (s *userStore) get(accessKey string) *User {
+	if accessKey == s.rootUser {
+		return nil
+	}
+	s.Lock()
+	defer s.Unlock()
+	u, ok := s.users[accessKey]
+	if age := time.Since(s.loaded); age > reloadUsers || !ok && age > reloadUsersMin {
+		s.loaded = time.Now()
+		if users, err := loadUsers(s.m); err != nil {
+			logger.Warnf("Load users of gateway: %s", err)
+		} else {
+			s.users = make(map[string]*User, len(users))
+			for _, u := range users {
+				if o := s.users[u.AccessKey]; o == nil || o.Removed > 0 {
+					s.users[u.AccessKey] = u
+				}
+			}
+			u, ok = s.users[accessKey]
+		}
+	}
+	switch {
+	case ok && u.Removed == 0:
+		return u
+	case !ok && s.others[accessKey]:
+		return nil
+	default:
+		return deniedUser
+	}
+}
+
+// setOthers updates the users in the IAM of MinIO which are not the users of gateway.
+func (s *userStore) setOthers(others map[string]bool) {
+	s.Lock()
+	s.others = others
+	s.Unlock()
+}
+
+// reqUser returns the user of gateway making the request, or nil for the anonymous requests (allowed
+// by bucket policy), the root credential and the users created by MinIO admin API, which access the
+// objects as the gateway process. The removed and unknown access keys get a user denied.
+func (n *jfsObjects) reqUser(ctx context.Context) *User {
+	if n.users == nil {
+		return nil
+	}
+	info := mlogger.GetReqInfo(ctx)
+	if info == nil || info.AccessKey == "" {
+		return nil
+	}
+	return n.users.get(info.AccessKey)
+}
+
+func accessDenied(bucket, object string) error {
+	return minio.PrefixAccessDenied{Bucket: bucket, Object: object}
+}
+
+// checkUser checks the user making the request, it returns nil and no error if the permissions of
+// file system don't need to be checked.
+func (n *jfsObjects) checkUser(ctx context.Context, bucket, object string) (*User, error) {
+	u := n.reqUser(ctx)
+	switch {
+	case u == nil:
+		return nil, nil
+	case u.denied:
+		return nil, accessDenied(bucket, object)
+	case bucket == minio.MinioMetaBucket:
+		if !u.canAccessMeta(object) {
+			return nil, accessDenied(bucket, object)
+		}
+		return nil, nil
+	case u.Uid == 0:
+		return nil, nil
+	}
+	return u, nil
+}
+
+// checkBucketUser checks whether the user making the request can access the bucket, the objects in
+// the meta bucket of MinIO are checked by the object.
+func (n *jfsObjects) checkBucketUser(ctx context.Context, bucket string) error {
+	if bucket == minio.MinioMetaBucket {
+		return nil
+	}
+	if u := n.reqUser(ctx); u != nil && !u.canAccess(bucket) {
+		return accessDenied(bucket, "")
+	}
+	return nil
+}
+
+// checkAccess checks the permission of the user making the request to access p, including the ACL.
+func (n *jfsObjects) checkAccess(ctx context.Context, bucket, object, p string, mode int) error {
+	u, err := n.checkUser(ctx, bucket, object)
+	if u == nil {
+		return err
+	}
+	if eno := n.fs.Access(u.context(), p, mode); eno == syscall.EACCES || eno == syscall.EPERM {
+		return accessDenied(bucket, object)
+	}
+	return nil
+}
+
+// checkWrite checks the permission to create, replace or remove p, which needs writing to the
+// nearest existing parent (and p itself if it exists).
+func (n *jfsObjects) checkWrite(ctx context.Context, bucket, object, p string, replace bool) error {
+	u, err := n.checkUser(ctx, bucket, object)
+	if u == nil {
+		return err
+	}
+	uctx := u.context()
+	if replace {
+		if eno := n.fs.Access(uctx, p, vfs.MODE_MASK_W); eno == syscall.EACCES || eno == syscall.EPERM {
+			return accessDenied(bucket, object)
+		}
+	}
+	for d := path.Dir(p); ; d = path.Dir(d) {
+		eno := n.fs.Access(uctx, d, vfs.MODE_MASK_W|vfs.MODE_MASK_X)
+		switch {
+		case eno == syscall.EACCES || eno == syscall.EPERM:
+			return accessDenied(bucket, object)
+		case eno == syscall.ENOENT && d != sep:
+			continue
+		}
+		return nil
+	}
+}
+
+// checkList checks the permission to list the directory of prefix, or its nearest existing parent.
+func (n *jfsObjects) checkList(ctx context.Context, bucket, prefix string) error {
+	u, err := n.checkUser(ctx, bucket, prefix)
+	if u == nil {
+		return err
+	}
+	uctx := u.context()
+	for d := n.path(bucket, prefix[:strings.LastIndex(prefix, sep)+1]); ; d = path.Dir(d) {
+		eno := n.fs.Access(uctx, d, vfs.MODE_MASK_R|vfs.MODE_MASK_X)
+		switch {
+		case eno == syscall.EACCES || eno == syscall.EPERM:
+			return accessDenied(bucket, prefix)
+		case eno == syscall.ENOENT && d != sep:
+			continue
+		}
+		return nil
+	}
+}
+
+// chown makes the user making the request the owner of p, which is created for it.
+func (n *jfsObjects) chown(ctx context.Context, p string) {
+	u := n.reqUser(ctx)
+	if u == nil || u.denied {
+		return
+	}
+	f, eno := n.fs.Open(mctx, p, 0)
+	if eno == 0 {
+		eno = f.Chown(mctx, u.Uid, u.Gid)
+		_ = f.Close(mctx)
+	}
+	if eno != 0 {
+		logger.Warnf("chown %s to %d:%d: %s", p, u.Uid, u.Gid, eno)
+	}
+}
+
+// SyncUsers adds the users of gateway into the IAM of MinIO running at endpoint, so their access keys
+// can be authenticated, and removes the ones removed. The other users in the IAM are also refreshed,
+// which are allowed to access the objects as the gateway process. The IAM is not changed if readOnly.
+func SyncUsers(ctx context.Context, gw minio.ObjectLayer, endpoint, accessKey, secretKey string, secure, readOnly bool, interval time.Duration) {
+	n, ok := gw.(*jfsObjects)
+	if !ok {
+		return
+	}
+	adm, err := madmin.New(endpoint, accessKey, secretKey, secure)
+	if err != nil {
+		logger.Errorf("Create admin client of %s: %s", endpoint, err)
+		return
+	}
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	synced := make(map[string]string) // access key -> secret key
+	wait := time.Second               // for the server to start
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if err = syncUsers(ctx, n.fs.Meta(), adm, synced, secretKey, readOnly, n.users); err != nil {
+			logger.Warnf("Sync users of gateway: %s", err)
+		}
+		wait = interval
+	}
+}
+
+func syncUsers(ctx context.Context, m meta.Meta, adm *madmin.AdminClient, synced map[string]string, rootSecret string, readOnly bool, store *userStore) error {
+	users, err := loadUsers(m)
+	if err != nil {
+		return err
+	}
+	existed, err := adm.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool)
+	active := make(map[string]bool)
+	for _, u := range users {
+		known[u.AccessKey] = true
+		if u.Removed == 0 {
+			active[u.AccessKey] = true
+		}
+	}
+	others := make(map[string]bool)
+	owners := []string{store.rootUser}
+	for ak := range existed {
+		if !known[ak] {
+			others[ak] = true
+			owners = append(owners, ak)
+		}
+	}
+	for _, owner := range owners {
+		if accounts, err := adm.ListServiceAccounts(ctx, owner); err == nil {
+			for _, a := range accounts.Accounts {
+				others[a] = true
+			}
+		}
+	}
+	store.setOthers(others)
+	if readOnly {
+		return nil
+	}
+
+	var added, removed int
+	for _, u := range users {
+		_, ok := existed[u.AccessKey]
+		if u.Removed > 0 {
+			if ok && !active[u.AccessKey] {
+				if err = adm.RemoveUser(ctx, u.AccessKey); err != nil {
+					return fmt.Errorf("remove user %s: %s", u.AccessKey, err)
+				}
+				_ = adm.RemoveCannedPolicy(ctx, userPolicy+u.AccessKey)
+				removed++
+			}
+			if !active[u.AccessKey] {
+				delete(synced, u.AccessKey)
+			}
+			if time.Since(time.Unix(u.Removed, 0)) > keepRemoved {
+				_ = m.DeleteTokens(meta.Background(), []uint32{u.id})
+			}
+			continue
+		}
+		secret, err := u.Secret(rootSecret)
+		if err != nil {
+			logger.Warnf("Skip user %s: %s", u.AccessKey, err)
+			continue
+		}
+		if ok && synced[u.AccessKey] == secret {
+			continue
+		}
+		p, err := u.policy()
+		if err != nil {
+			return fmt.Errorf("policy of user %s: %s", u.AccessKey, err)
+		}
+		if err = adm.AddCannedPolicy(ctx, userPolicy+u.AccessKey, p); err != nil {
+			return fmt.Errorf("add policy of user %s: %s", u.AccessKey, err)
+		}
+		if err = adm.AddUser(ctx, u.AccessKey, secret); err != nil {
+			return fmt.Errorf("add user %s: %s", u.AccessKey, err)
+		}
+		if err = adm.SetPolicy(ctx, userPolicy+u.AccessKey, u.AccessKey, false); err != nil {
+			return fmt.Errorf("set policy of user %s: %s", u.AccessKey, err)
+		}
+		synced[u.AccessKey] = secret
+		added++
+	}
+	if added+removed > 0 {
+		logger.Infof("Synced users of gateway: %d added, %d removed", added, removed)
+	}
+	return nil
+}
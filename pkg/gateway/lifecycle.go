@@ -0,0 +1,395 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gateway
+
+import (
+	"context"
+	"encoding/xml"
+	"math"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/juicedata/juicefs/pkg/vfs"
+	minio "github.com/minio/minio/cmd"
+)
+
+const (
+	lifecycleInterval = time.Hour
+	lifecycleJob      = "lastGatewayLifecycle" // claimed by one of the gateways in every interval
+)
+
+// The lifecycle configuration saved by PutBucketLifecycleConfiguration, only the expiration of objects
+// (and their noncurrent versions) and the abortion of multipart uploads are supported.
+type lifecycleConfig struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Rules   []lifecycleRule `xml:"Rule"`
+}
+
+type lifecycleTag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+type lifecycleRule struct {
+	ID     string `xml:"ID"`
+	Status string `xml:"Status"`
+	Prefix string `xml:"Prefix"` // deprecated, replaced by Filter
+	Filter struct {
+		Prefix string        `xml:"Prefix"`
+		Tag    *lifecycleTag `xml:"Tag"`
+		And    struct {
+			Prefix string         `xml:"Prefix"`
+			Tags   []lifecycleTag `xml:"Tag"`
+		} `xml:"And"`
+	} `xml:"Filter"`
+	Expiration struct {
+		Days         int    `xml:"Days"`
+		Date         string `xml:"Date"`
+		DeleteMarker bool   `xml:"ExpiredObjectDeleteMarker"`
+	} `xml:"Expiration"`
+	NoncurrentExpiration struct {
+		Days  int `xml:"NoncurrentDays"`
+		Newer int `xml:"NewerNoncurrentVersions"` // the number of newest noncurrent versions to keep
+	} `xml:"NoncurrentVersionExpiration"`
+	AbortMultipartUpload struct {
+		Days int `xml:"DaysAfterInitiation"`
+	} `xml:"AbortIncompleteMultipartUpload"`
+}
+
+func (r *lifecycleRule) prefix() string {
+	switch {
+	case r.Filter.Prefix != "":
+		return r.Filter.Prefix
+	case r.Filter.And.Prefix != "":
+		return r.Filter.And.Prefix
+	}
+	return r.Prefix
+}
+
+func (r *lifecycleRule) tags() []lifecycleTag {
+	if r.Filter.Tag != nil {
+		return []lifecycleTag{*r.Filter.Tag}
+	}
+	return r.Filter.And.Tags
+}
+
+// expireBefore returns the objects modified before which are expired, or zero if no expiration.
+func (r *lifecycleRule) expireBefore(now time.Time) time.Time {
+	if r.Expiration.Days > 0 {
+		return now.Add(-time.Duration(r.Expiration.Days) * 24 * time.Hour)
+	}
+	if r.Expiration.Date != "" {
+		if date, err := time.Parse(time.RFC3339, r.Expiration.Date); err == nil && !now.Before(date) {
+			return now // all the objects are expired since the date
+		}
+	}
+	return time.Time{}
+}
+
+// matchTags checks the tags of object (key1=value1&key2=value2) against the ones of rule.
+func (r *lifecycleRule) matchTags(objTags string) bool {
+	tags := r.tags()
+	if len(tags) == 0 {
+		return true
+	}
+	values, err := url.ParseQuery(objTags)
+	if err != nil {
+		return false
+	}
+	for _, t := range tags {
+		if values.Get(t.Key) != t.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *jfsObjects) lifecycleRules(ctx context.Context, bucket string) []lifecycleRule {
+	bm, err := minio.LoadBucketMetadata(ctx, n, bucket)
+	if err != nil || len(bm.LifecycleConfigXML) == 0 {
+		return nil
+	}
+	var conf lifecycleConfig
+	if err = xml.Unmarshal(bm.LifecycleConfigXML, &conf); err != nil {
+		logger.Warnf("parse lifecycle configuration of bucket %s: %s", bucket, err)
+		return nil
+	}
+	var rules []lifecycleRule
+	for _, r := range conf.Rules {
+		if strings.EqualFold(r.Status, "Enabled") {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// bucketVersioning returns the options to delete objects in bucket as the versioning state of it.
+func (n *jfsObjects) bucketVersioning(ctx context.Context, bucket string) minio.ObjectOptions {
+	var opts minio.ObjectOptions
+	bm, err := minio.LoadBucketMetadata(ctx, n, bucket)
+	if err != nil || len(bm.VersioningConfigXML) == 0 {
+		return opts
+	}
+	var conf struct {
+		Status string `xml:"Status"`
+	}
+	if xml.Unmarshal(bm.VersioningConfigXML, &conf) == nil {
+		opts.Versioned = conf.Status == "Enabled"
+		opts.VersionSuspended = conf.Status == "Suspended"
+	}
+	return opts
+}
+
+// lifecycle runs the lifecycle rules of all buckets periodically until ctx is canceled, only one of the
+// gateways of the volume runs them in each interval.
+func (n *jfsObjects) lifecycle(ctx context.Context) {
+	ticker := time.NewTicker(lifecycleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if ok, err := n.fs.Meta().ClaimJob(lifecycleJob, lifecycleInterval); err != nil {
+			logger.Warnf("checking counter %s: %s", lifecycleJob, err)
+			continue
+		} else if !ok {
+			continue
+		}
+		buckets, err := n.ListBuckets(ctx)
+		if err != nil {
+			logger.Errorf("list buckets error: %v", err)
+			continue
+		}
+		for _, b := range buckets {
+			if rules := n.lifecycleRules(ctx, b.Name); len(rules) > 0 {
+				n.applyLifecycle(ctx, b.Name, rules, n.bucketVersioning(ctx, b.Name), time.Now())
+			}
+		}
+	}
+}
+
+// bucketOwner returns the context of the owner of bucket, on behalf of whom the objects are expired, or
+// nil if it's root.
+func (n *jfsObjects) bucketOwner(bucket string) (meta.Context, error) {
+	fi, eno := n.fs.Stat(mctx, n.path(bucket))
+	if eno != 0 {
+		return nil, eno
+	}
+	if fi.Uid() == 0 {
+		return nil, nil
+	}
+	return meta.NewContext(uint32(os.Getpid()), uint32(fi.Uid()), []uint32{uint32(fi.Gid())}), nil
+}
+
+// canRemove checks whether the owner of bucket can remove the file in p, which needs writing to the
+// nearest existing parent.
+func (n *jfsObjects) canRemove(owner meta.Context, p string) bool {
+	if owner == nil {
+		return true
+	}
+	for d := path.Dir(p); ; d = path.Dir(d) {
+		eno := n.fs.Access(owner, d, vfs.MODE_MASK_W|vfs.MODE_MASK_X)
+		if eno == syscall.ENOENT && d != sep {
+			continue
+		}
+		return eno == 0
+	}
+}
+
+// applyLifecycle removes the expired objects (or versions) and aborts the multipart uploads initiated long
+// ago, the objects are moved into trash if it's enabled. The objects are removed as the owner of bucket,
+// the ones it can't remove are skipped.
+func (n *jfsObjects) applyLifecycle(ctx context.Context, bucket string, rules []lifecycleRule, opts minio.ObjectOptions, now time.Time) {
+	owner, err := n.bucketOwner(bucket)
+	if err != nil {
+		logger.Warnf("stat bucket %s: %s", bucket, err)
+		return
+	}
+	var expired, versions, aborted int
+	for i := range rules {
+		r := &rules[i]
+		if before := r.expireBefore(now); !before.IsZero() {
+			expired += n.expireObjects(ctx, owner, bucket, r, before, opts)
+		}
+		if isVersioned(opts) && (r.NoncurrentExpiration.Days > 0 || r.Expiration.DeleteMarker) {
+			versions += n.expireVersions(ctx, owner, bucket, r, now)
+		}
+		if r.AbortMultipartUpload.Days > 0 {
+			aborted += n.abortUploads(ctx, bucket, r.prefix(), now.Add(-time.Duration(r.AbortMultipartUpload.Days)*24*time.Hour))
+		}
+	}
+	if expired+versions+aborted > 0 {
+		logger.Infof("Lifecycle of bucket %s: %d objects expired, %d versions removed, %d multipart uploads aborted", bucket, expired, versions, aborted)
+	}
+}
+
+func (n *jfsObjects) expireObjects(ctx context.Context, owner meta.Context, bucket string, r *lifecycleRule, before time.Time, opts minio.ObjectOptions) (count int) {
+	prefix := r.prefix()
+	root := n.path(bucket)
+	var walk func(dir string)
+	walk = func(dir string) {
+		f, eno := n.fs.Open(mctx, dir, 0)
+		if eno != 0 {
+			return
+		}
+		entries, eno := f.ReaddirPlus(mctx, 0)
+		_ = f.Close(mctx)
+		if eno != 0 {
+			logger.Warnf("readdir %s: %s", dir, eno)
+			return
+		}
+		for _, e := range entries {
+			name := string(e.Name)
+			p := path.Join(dir, name)
+			if dir == sep && (name == metaBucket || name == minio.MinioMetaBucket || name == meta.TrashName) {
+				continue
+			}
+			object := strings.TrimPrefix(p, root)
+			object = strings.TrimPrefix(object, sep)
+			if e.Attr.Typ == meta.TypeDirectory {
+				if d := object + sep; strings.HasPrefix(d, prefix) || strings.HasPrefix(prefix, d) {
+					walk(p)
+				}
+				continue
+			}
+			if !strings.HasPrefix(object, prefix) || !time.Unix(e.Attr.Mtime, int64(e.Attr.Mtimensec)).Before(before) {
+				continue
+			}
+			var tags []byte
+			if len(r.tags()) > 0 {
+				tags, _ = n.fs.GetXattr(mctx, p, s3Tags)
+				if !r.matchTags(string(tags)) {
+					continue
+				}
+			}
+			if !n.canRemove(owner, p) {
+				continue
+			}
+			info := minio.ObjectInfo{Bucket: bucket, Name: object}
+			var err error
+			if isVersioned(opts) {
+				info, err = n.deleteVersioned(ctx, bucket, object, opts)
+			} else {
+				err = n.delObj(bucket, object)
+			}
+			if err != nil {
+				logger.Warnf("expire object %s/%s: %s", bucket, object, err)
+				continue
+			}
+			n.notify(ctx, deleteEvent(info, ""), info)
+			count++
+		}
+	}
+	walk(root)
+	return
+}
+
+// expireVersions removes the versions of objects which have been noncurrent for the days of rule, except
+// the newer ones to keep, and the delete markers left without any other version.
+func (n *jfsObjects) expireVersions(ctx context.Context, owner meta.Context, bucket string, r *lifecycleRule, now time.Time) (count int) {
+	nc := r.NoncurrentExpiration
+	before := now.Add(-time.Duration(nc.Days) * 24 * time.Hour)
+	var objects []string
+	n.scanVersionedKeys(bucket, r.prefix(), "", func(key string) bool {
+		objects = append(objects, key)
+		return true
+	})
+	for _, object := range objects {
+		p := n.path(bucket, object)
+		if !n.canRemove(owner, p) {
+			continue
+		}
+		vs, err := n.archivedVersions(bucket, object)
+		if err != nil {
+			logger.Warnf("list versions of %s/%s: %s", bucket, object, err)
+			continue
+		}
+		// a version becomes noncurrent when the newer one is created
+		fi, eno := n.fs.Stat(mctx, p)
+		current := eno == 0 && !fi.IsDir()
+		var since time.Time
+		if current {
+			since = fi.ModTime()
+		}
+		var noncurrent, removed int
+		for i, v := range vs {
+			if i == 0 && !current {
+				continue // the latest one
+			}
+			if i > 0 {
+				since = vs[i-1].ModTime()
+			}
+			noncurrent++
+			if nc.Days == 0 || noncurrent <= nc.Newer || !since.Before(before) {
+				continue
+			}
+			if len(r.tags()) > 0 {
+				tags, _ := n.fs.GetXattr(mctx, n.vpath(bucket, object, v.id), s3Tags)
+				if !r.matchTags(string(tags)) {
+					continue
+				}
+			}
+			if n.removeVersion(ctx, bucket, object, v.id) {
+				removed++
+			}
+		}
+		if r.Expiration.DeleteMarker && !current && len(vs) == removed+1 && vs[0].marker && len(r.tags()) == 0 {
+			if n.removeVersion(ctx, bucket, object, vs[0].id) {
+				removed++
+			}
+		}
+		count += removed
+	}
+	return
+}
+
+func (n *jfsObjects) removeVersion(ctx context.Context, bucket, object, versionID string) bool {
+	info, err := n.deleteVersioned(ctx, bucket, object, minio.ObjectOptions{Versioned: true, VersionID: versionID})
+	if err != nil {
+		logger.Warnf("expire version %s of %s/%s: %s", versionID, bucket, object, err)
+		return false
+	}
+	n.notify(ctx, deleteEvent(info, ""), info)
+	return true
+}
+
+// abortUploads aborts the multipart uploads of the objects with prefix initiated before the time.
+func (n *jfsObjects) abortUploads(ctx context.Context, bucket, prefix string, before time.Time) (count int) {
+	lmi, err := n.ListMultipartUploads(ctx, bucket, prefix, "", "", "", math.MaxInt32)
+	if err != nil {
+		logger.Warnf("list multipart uploads of %s: %s", bucket, err)
+		return
+	}
+	for _, u := range lmi.Uploads {
+		if !u.Initiated.Before(before) {
+			continue
+		}
+		if err := n.AbortMultipartUpload(ctx, bucket, u.Object, u.UploadID, minio.ObjectOptions{}); err != nil {
+			logger.Warnf("abort multipart upload %s of %s/%s: %s", u.UploadID, bucket, u.Object, err)
+			continue
+		}
+		count++
+	}
+	return
+}
@@ -0,0 +1,626 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	minio "github.com/minio/minio/cmd"
+	"github.com/minio/minio/pkg/event"
+)
+
+// Event names of S3 notifications.
+const (
+	EventObjectCreatedPut      = "s3:ObjectCreated:Put"
+	EventObjectCreatedCopy     = "s3:ObjectCreated:Copy"
+	EventObjectCreatedComplete = "s3:ObjectCreated:CompleteMultipartUpload"
+	EventObjectRemovedDelete   = "s3:ObjectRemoved:Delete"
+	EventObjectRemovedMarker   = "s3:ObjectRemoved:DeleteMarkerCreated"
+)
+
+const (
+	maxQueuedEvents   = 100000 // events kept for a target
+	maxEventAttempts  = 10     // an event is moved into the failed dir after the attempts
+	failedEventsDir   = "failed"
+	rulesRefreshEvery = time.Minute
+)
+
+// The bucket notification configuration saved by PutBucketNotificationConfiguration, the targets are
+// referenced by ARN: arn:minio:sqs::<ID>:<type>.
+type notificationConfig struct {
+	XMLName xml.Name      `xml:"NotificationConfiguration"`
+	Queues  []queueConfig `xml:"QueueConfiguration"`
+}
+
+type queueConfig struct {
+	ID     string   `xml:"Id"`
+	ARN    string   `xml:"Queue"`
+	Events []string `xml:"Event"`
+	Rules  []struct {
+		Name  string `xml:"Name"`
+		Value string `xml:"Value"`
+	} `xml:"Filter>S3Key>FilterRule"`
+}
+
+// targetID returns the ID of target in the ARN.
+func (q *queueConfig) targetID() string {
+	parts := strings.Split(q.ARN, ":")
+	if len(parts) != 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[4]
+}
+
+func (q *queueConfig) match(event, key string) bool {
+	var matched bool
+	for _, e := range q.Events {
+		if e == event || strings.HasSuffix(e, ":*") && strings.HasPrefix(event, strings.TrimSuffix(e, "*")) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+	for _, r := range q.Rules {
+		switch strings.ToLower(r.Name) {
+		case "prefix":
+			matched = matched && strings.HasPrefix(key, r.Value)
+		case "suffix":
+			matched = matched && strings.HasSuffix(key, r.Value)
+		}
+	}
+	return matched
+}
+
+// The event in the format of S3 (and MinIO).
+type eventLog struct {
+	EventName string        `json:"EventName"`
+	Key       string        `json:"Key"`
+	Records   []eventRecord `json:"Records"`
+}
+
+type eventRecord struct {
+	EventVersion string  `json:"eventVersion"`
+	EventSource  string  `json:"eventSource"`
+	AwsRegion    string  `json:"awsRegion"`
+	EventTime    string  `json:"eventTime"`
+	EventName    string  `json:"eventName"`
+	S3           eventS3 `json:"s3"`
+}
+
+type eventS3 struct {
+	SchemaVersion   string `json:"s3SchemaVersion"`
+	ConfigurationID string `json:"configurationId"`
+	Bucket          struct {
+		Name string `json:"name"`
+		ARN  string `json:"arn"`
+	} `json:"bucket"`
+	Object struct {
+		Key         string `json:"key"`
+		Size        int64  `json:"size,omitempty"`
+		ETag        string `json:"eTag,omitempty"`
+		ContentType string `json:"contentType,omitempty"`
+		VersionID   string `json:"versionId,omitempty"`
+		Sequencer   string `json:"sequencer"`
+	} `json:"object"`
+}
+
+// eventTarget delivers an encoded event, it's retried up to maxEventAttempts times.
+type eventTarget interface {
+	send(ctx context.Context, data []byte) error
+	Close() error
+}
+
+// newEventTarget creates a target from a URL: http(s)://host/path for webhook, nats://host:port/subject
+// for NATS, and file:///dir for local queue, which keeps the events as files in the dir.
+func newEventTarget(uri string) (eventTarget, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("parse event target %s: %s", uri, err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return &webhookTarget{url: uri, client: &http.Client{Timeout: time.Minute}}, nil
+	case "nats":
+		subject := strings.TrimPrefix(u.Path, "/")
+		if subject == "" {
+			return nil, fmt.Errorf("no subject in event target %s", uri)
+		}
+		return &natsTarget{addr: u.Host, user: u.User, subject: subject}, nil
+	case "file":
+		if err = os.MkdirAll(u.Path, 0755); err != nil {
+			return nil, err
+		}
+		return &dirTarget{dir: u.Path}, nil
+	default:
+		return nil, fmt.Errorf("unsupported event target: %s", uri)
+	}
+}
+
+type webhookTarget struct {
+	url    string
+	client *http.Client
+}
+
+func (t *webhookTarget) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+func (t *webhookTarget) Close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
+
+// natsTarget publishes the events with the text protocol of NATS, and waits for the PONG after each
+// one to make sure it's received by the server.
+type natsTarget struct {
+	addr    string
+	user    *url.Userinfo
+	subject string
+	conn    net.Conn
+	r       *bufio.Reader
+}
+
+func (t *natsTarget) connect(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(time.Minute))
+	r := bufio.NewReader(conn)
+	if line, err := r.ReadString('\n'); err != nil || !strings.HasPrefix(line, "INFO") {
+		_ = conn.Close()
+		return fmt.Errorf("unexpected greeting from %s: %q %v", t.addr, line, err)
+	}
+	opts := map[string]interface{}{"verbose": false, "pedantic": false, "name": "juicefs-gateway"}
+	if t.user != nil {
+		opts["user"] = t.user.Username()
+		opts["pass"], _ = t.user.Password()
+	}
+	connect, _ := json.Marshal(opts)
+	if _, err = fmt.Fprintf(conn, "CONNECT %s\r\n", connect); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	t.conn, t.r = conn, r
+	return nil
+}
+
+func (t *natsTarget) send(ctx context.Context, data []byte) (err error) {
+	if t.conn == nil {
+		if err = t.connect(ctx); err != nil {
+			return err
+		}
+	}
+	defer func() {
+		if err != nil {
+			_ = t.Close()
+		}
+	}()
+	_ = t.conn.SetDeadline(time.Now().Add(time.Minute))
+	if _, err = fmt.Fprintf(t.conn, "PUB %s %d\r\n%s\r\nPING\r\n", t.subject, len(data), data); err != nil {
+		return err
+	}
+	for {
+		line, err := t.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		switch {
+		case strings.HasPrefix(line, "PONG"):
+			return nil
+		case strings.HasPrefix(line, "PING"):
+			_, _ = io.WriteString(t.conn, "PONG\r\n")
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("publish to %s: %s", t.addr, strings.TrimSpace(line))
+		}
+	}
+}
+
+func (t *natsTarget) Close() error {
+	if t.conn != nil {
+		err := t.conn.Close()
+		t.conn, t.r = nil, nil
+		return err
+	}
+	return nil
+}
+
+// dirTarget keeps the events as files in a dir, in the order of names, for the consumers to pick up.
+type dirTarget struct {
+	dir string
+}
+
+func (t *dirTarget) send(ctx context.Context, data []byte) error {
+	return writeEventFile(t.dir, eventFileName(), data)
+}
+
+func (t *dirTarget) Close() error {
+	return nil
+}
+
+var eventSeq atomic.Uint64
+
+func eventFileName() string {
+	return fmt.Sprintf("%020d-%06d.json", time.Now().UnixNano(), eventSeq.Add(1)%1000000)
+}
+
+func writeEventFile(dir, name string, data []byte) error {
+	tmp := filepath.Join(dir, name+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+// eventQueue keeps the events of a target in a local dir until they are delivered, so they survive
+// the failures of target and restarts of gateway.
+type eventQueue struct {
+	id       string
+	dir      string
+	target   eventTarget
+	attempts int
+	pending  atomic.Int64
+	wake     chan struct{}
+}
+
+func newEventQueue(id, dir string, target eventTarget) (*eventQueue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	q := &eventQueue{id: id, dir: dir, target: target, attempts: maxEventAttempts, wake: make(chan struct{}, 1)}
+	names, err := q.list()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > 0 {
+		logger.Infof("Found %d events of target %s not delivered", len(names), id)
+	}
+	q.pending.Store(int64(len(names)))
+	return q, nil
+}
+
+func (q *eventQueue) list() ([]string, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (q *eventQueue) put(data []byte) error {
+	if q.pending.Load() >= maxQueuedEvents {
+		return fmt.Errorf("too many events (%d) not delivered to %s", maxQueuedEvents, q.id)
+	}
+	if err := writeEventFile(q.dir, eventFileName(), data); err != nil {
+		return err
+	}
+	q.pending.Add(1)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// deadLetter moves an event failed too many times into the failed dir, which is kept for inspection
+// and can be moved back to be delivered again.
+func (q *eventQueue) deadLetter(name string, err error) {
+	dir := filepath.Join(q.dir, failedEventsDir)
+	if e := os.MkdirAll(dir, 0700); e == nil {
+		e = os.Rename(filepath.Join(q.dir, name), filepath.Join(dir, name))
+		if e != nil {
+			logger.Errorf("move event %s of target %s into %s: %s", name, q.id, dir, e)
+			_ = os.Remove(filepath.Join(q.dir, name))
+		}
+	}
+	q.pending.Add(-1)
+	logger.Errorf("Give up delivering event %s to target %s after %d attempts: %s", name, q.id, q.attempts, err)
+}
+
+// run delivers the events in order, and retries the failed one with backoff, which is moved into the
+// failed dir after a few attempts to not block the others.
+func (q *eventQueue) run(ctx context.Context) {
+	backoff := 100 * time.Millisecond
+	var head string // the event failed
+	var attempts int
+	for {
+		names, err := q.list()
+		if err != nil {
+			logger.Errorf("list events of target %s: %s", q.id, err)
+		}
+		var failed bool
+		for _, name := range names {
+			p := filepath.Join(q.dir, name)
+			data, err := os.ReadFile(p)
+			if err != nil {
+				q.deadLetter(name, err)
+				continue
+			}
+			if err = q.target.send(ctx, data); err != nil {
+				if name != head {
+					head, attempts = name, 0
+				}
+				if attempts++; attempts >= q.attempts {
+					q.deadLetter(name, err)
+					head, attempts = "", 0
+					continue
+				}
+				logger.Warnf("deliver event %s to target %s: %s, retry in %s", name, q.id, err, backoff)
+				failed = true
+				break
+			}
+			_ = os.Remove(p)
+			q.pending.Add(-1)
+			backoff = 100 * time.Millisecond
+		}
+		wait := time.Minute
+		if failed {
+			wait = backoff
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+		}
+		select {
+		case <-ctx.Done():
+			_ = q.target.Close()
+			return
+		case <-q.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// arnTarget registers a target in the notification system of MinIO, so the ARN of it is accepted in
+// the notification configuration of buckets. The events are delivered by eventNotifier instead.
+type arnTarget struct {
+	id event.TargetID
+}
+
+func (t *arnTarget) ID() event.TargetID      { return t.id }
+func (t *arnTarget) IsActive() (bool, error) { return true, nil }
+func (t *arnTarget) Save(event.Event) error  { return nil }
+func (t *arnTarget) Send(string) error       { return nil }
+func (t *arnTarget) Close() error            { return nil }
+func (t *arnTarget) HasQueueStore() bool     { return false }
+
+//go:linkname globalNotificationSys github.com/minio/minio/cmd.globalNotificationSys
+var globalNotificationSys *minio.NotificationSys
+
+// minioTargets returns the targets of the notification system of MinIO, which validates the ARNs of
+// bucket notification configurations, or nil if it's not initialized.
+func minioTargets() *event.TargetList {
+	if globalNotificationSys == nil {
+		return nil
+	}
+	f := reflect.ValueOf(globalNotificationSys).Elem().FieldByName("targetList")
+	if !f.IsValid() || f.Type() != reflect.TypeOf((*event.TargetList)(nil)) {
+		logger.Warnf("Unexpected notification system of MinIO, event targets are not registered")
+		return nil
+	}
+	return *(**event.TargetList)(unsafe.Pointer(f.UnsafeAddr()))
+}
+
+type bucketRules struct {
+	queues []queueConfig
+	loaded time.Time
+}
+
+// eventNotifier sends the events of objects to the targets configured in the notification
+// configuration of buckets.
+type eventNotifier struct {
+	n          *jfsObjects
+	queues     map[string]*eventQueue // by target ID
+	types      map[string]string      // the type in the ARN of target
+	cancel     context.CancelFunc
+	registered atomic.Bool // registered into MinIO
+
+	sync.Mutex
+	rules map[string]*bucketRules
+}
+
+// newEventNotifier creates the targets from "ID=URL", the events are queued in dir before delivered.
+func newEventNotifier(n *jfsObjects, targets []string, dir string) (*eventNotifier, error) {
+	en := &eventNotifier{n: n, queues: make(map[string]*eventQueue), types: make(map[string]string), rules: make(map[string]*bucketRules)}
+	for _, t := range targets {
+		id, uri, ok := strings.Cut(t, "=")
+		if !ok || id == "" || strings.ContainsAny(id, ":/") {
+			return nil, fmt.Errorf("invalid event target %q, should be ID=URL", t)
+		}
+		if _, ok = en.queues[id]; ok {
+			return nil, fmt.Errorf("duplicated event target %s", id)
+		}
+		target, err := newEventTarget(uri)
+		if err != nil {
+			return nil, err
+		}
+		q, err := newEventQueue(id, filepath.Join(dir, id), target)
+		if err != nil {
+			return nil, fmt.Errorf("event queue of %s: %s", id, err)
+		}
+		en.queues[id] = q
+		en.types[id] = targetType(uri)
+		logger.Infof("Event target %s: %s (arn:minio:sqs::%s:%s)", id, uri, id, en.types[id])
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	en.cancel = cancel
+	for _, q := range en.queues {
+		go q.run(ctx)
+	}
+	return en, nil
+}
+
+func targetType(uri string) string {
+	switch {
+	case strings.HasPrefix(uri, "nats:"):
+		return "nats"
+	case strings.HasPrefix(uri, "file:"):
+		return "queue"
+	default:
+		return "webhook"
+	}
+}
+
+func (en *eventNotifier) close() {
+	en.cancel()
+}
+
+// registerTargets adds the targets into list, to be referenced by the ARNs.
+func (en *eventNotifier) registerTargets(list *event.TargetList) error {
+	for id, typ := range en.types {
+		t := &arnTarget{id: event.TargetID{ID: id, Name: typ}}
+		if list.Exists(t.id) {
+			continue
+		}
+		if err := list.Add(t); err != nil {
+			return fmt.Errorf("register event target %s: %s", id, err)
+		}
+	}
+	return nil
+}
+
+// bucketRules returns the notification configuration of bucket, which is reloaded every minute. It's
+// loaded as the gateway process, and the previous one is used if failed to load.
+func (en *eventNotifier) bucketRules(bucket string) []queueConfig {
+	en.Lock()
+	old := en.rules[bucket]
+	en.Unlock()
+	if old != nil && time.Since(old.loaded) < rulesRefreshEvery {
+		return old.queues
+	}
+	meta, err := minio.LoadBucketMetadata(context.Background(), en.n, bucket)
+	if err != nil {
+		logger.Warnf("load metadata of bucket %s: %s", bucket, err)
+		if old != nil {
+			return old.queues
+		}
+		return nil
+	}
+	r := &bucketRules{loaded: time.Now()}
+	if len(meta.NotificationConfigXML) > 0 {
+		var conf notificationConfig
+		if err = xml.Unmarshal(meta.NotificationConfigXML, &conf); err != nil {
+			logger.Warnf("parse notification configuration of bucket %s: %s", bucket, err)
+		} else {
+			r.queues = conf.Queues
+		}
+	}
+	en.Lock()
+	en.rules[bucket] = r
+	en.Unlock()
+	return r.queues
+}
+
+// notify queues the event of an object for the targets matched.
+func (en *eventNotifier) notify(event string, oi minio.ObjectInfo) {
+	for _, q := range en.bucketRules(oi.Bucket) {
+		if !q.match(event, oi.Name) {
+			continue
+		}
+		target := en.queues[q.targetID()]
+		if target == nil {
+			logger.Warnf("unknown event target %s of bucket %s", q.ARN, oi.Bucket)
+			continue
+		}
+		rec := eventRecord{
+			EventVersion: "2.0",
+			EventSource:  "minio:s3",
+			EventTime:    time.Now().UTC().Format(time.RFC3339Nano),
+			EventName:    event,
+		}
+		rec.S3.SchemaVersion = "1.0"
+		rec.S3.ConfigurationID = q.ID
+		rec.S3.Bucket.Name = oi.Bucket
+		rec.S3.Bucket.ARN = "arn:aws:s3:::" + oi.Bucket
+		rec.S3.Object.Key = url.QueryEscape(oi.Name)
+		rec.S3.Object.Size = oi.Size
+		rec.S3.Object.ETag = oi.ETag
+		rec.S3.Object.ContentType = oi.ContentType
+		rec.S3.Object.VersionID = oi.VersionID
+		rec.S3.Object.Sequencer = fmt.Sprintf("%X", time.Now().UnixNano())
+		data, err := json.Marshal(&eventLog{EventName: event, Key: oi.Bucket + "/" + oi.Name, Records: []eventRecord{rec}})
+		if err != nil {
+			logger.Errorf("encode event %s of %s/%s: %s", event, oi.Bucket, oi.Name, err)
+			continue
+		}
+		if err = target.put(data); err != nil {
+			logger.Errorf("queue event %s of %s/%s: %s", event, oi.Bucket, oi.Name, err)
+		}
+	}
+}
+
+// notify sends the event of an object if there are event targets, the rules of bucket are loaded as
+// the gateway process rather than with the context of request.
+func (n *jfsObjects) notify(ctx context.Context, event string, oi minio.ObjectInfo) {
+	if n.events != nil && oi.Bucket != minio.MinioMetaBucket {
+		n.events.notify(event, oi)
+	}
+}
@@ -0,0 +1,248 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	minio "github.com/minio/minio/cmd"
+	"github.com/minio/minio/pkg/event"
+)
+
+const testNotificationXML = `<NotificationConfiguration>
+  <QueueConfiguration>
+    <Id>etl</Id>
+    <Queue>arn:minio:sqs::hook:webhook</Queue>
+    <Event>s3:ObjectCreated:*</Event>
+    <Filter><S3Key>
+      <FilterRule><Name>prefix</Name><Value>raw/</Value></FilterRule>
+      <FilterRule><Name>suffix</Name><Value>.csv</Value></FilterRule>
+    </S3Key></Filter>
+  </QueueConfiguration>
+  <QueueConfiguration>
+    <Id>audit</Id>
+    <Queue>arn:minio:sqs::local:queue</Queue>
+    <Event>s3:ObjectRemoved:Delete</Event>
+  </QueueConfiguration>
+</NotificationConfiguration>`
+
+func TestNotificationRules(t *testing.T) {
+	var conf notificationConfig
+	if err := xml.Unmarshal([]byte(testNotificationXML), &conf); err != nil {
+		t.Fatalf("parse: %s", err)
+	}
+	if len(conf.Queues) != 2 || conf.Queues[0].targetID() != "hook" || conf.Queues[1].targetID() != "local" {
+		t.Fatalf("unexpected config: %+v", conf)
+	}
+	cases := []struct {
+		q     int
+		event string
+		key   string
+		match bool
+	}{
+		{0, EventObjectCreatedPut, "raw/a.csv", true},
+		{0, EventObjectCreatedComplete, "raw/b/c.csv", true},
+		{0, EventObjectCreatedPut, "raw/a.json", false},
+		{0, EventObjectCreatedPut, "out/a.csv", false},
+		{0, EventObjectRemovedDelete, "raw/a.csv", false},
+		{1, EventObjectRemovedDelete, "a", true},
+		{1, EventObjectRemovedMarker, "a", false},
+	}
+	for _, c := range cases {
+		if m := conf.Queues[c.q].match(c.event, c.key); m != c.match {
+			t.Fatalf("match %s %s of %s: expect %v, got %v", c.event, c.key, conf.Queues[c.q].ID, c.match, m)
+		}
+	}
+}
+
+func TestNotificationDelivery(t *testing.T) {
+	var mu sync.Mutex
+	var received []eventLog
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if calls++; calls == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable) // delivered again later
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		var e eventLog
+		if err := json.Unmarshal(data, &e); err != nil {
+			t.Errorf("decode event: %s", err)
+		}
+		received = append(received, e)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local")
+	jfsObj, _, bucket := newTestGateway(t, Config{})
+	en, err := newEventNotifier(jfsObj, []string{"hook=" + srv.URL, "local=file://" + local}, filepath.Join(dir, "queue"))
+	if err != nil {
+		t.Fatalf("create notifier: %s", err)
+	}
+	defer en.close()
+	jfsObj.events = en
+
+	// the targets are validated by MinIO as PutBucketNotificationHandler
+	targets := event.NewTargetList()
+	if _, err = event.ParseConfig(strings.NewReader(testNotificationXML), "us-east-1", targets); err == nil {
+		t.Fatalf("unknown targets should be rejected")
+	}
+	if err = en.registerTargets(targets); err != nil {
+		t.Fatalf("register targets: %s", err)
+	}
+	if _, err = event.ParseConfig(strings.NewReader(testNotificationXML), "us-east-1", targets); err != nil {
+		t.Fatalf("parse notification configuration: %s", err)
+	}
+	ctx := context.Background()
+	if err = jfsObj.MakeBucketWithLocation(ctx, minio.MinioMetaBucket, minio.BucketOptions{}); err != nil {
+		t.Fatalf("make meta bucket: %s", err)
+	}
+	bm := minio.NewBucketMetadata(bucket)
+	bm.NotificationConfigXML = []byte(testNotificationXML)
+	if err = bm.Save(ctx, jfsObj); err != nil {
+		t.Fatalf("save notification configuration: %s", err)
+	}
+
+	// the rules are loaded as the gateway process, even with the request canceled
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	jfsObj.notify(cancelled, EventObjectCreatedPut, minio.ObjectInfo{Bucket: bucket, Name: "raw/1.csv", Size: 10, ETag: "etag1"})
+	jfsObj.notify(ctx, EventObjectCreatedPut, minio.ObjectInfo{Bucket: bucket, Name: "raw/1.json"})
+	jfsObj.notify(ctx, EventObjectCreatedCopy, minio.ObjectInfo{Bucket: bucket, Name: "raw/2.csv"})
+	jfsObj.notify(ctx, EventObjectRemovedDelete, minio.ObjectInfo{Bucket: bucket, Name: "raw/1.csv"})
+
+	deadline := time.Now().Add(time.Second * 10)
+	for {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expect 2 events, got %d", n)
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	mu.Lock()
+	r0, r1 := received[0], received[1]
+	mu.Unlock()
+	if r0.Key != bucket+"/raw/1.csv" || r0.EventName != EventObjectCreatedPut || r0.Records[0].S3.Object.Size != 10 ||
+		r0.Records[0].S3.ConfigurationID != "etl" {
+		t.Fatalf("unexpected event: %+v", r0)
+	}
+	if r1.Key != bucket+"/raw/2.csv" || r1.EventName != EventObjectCreatedCopy {
+		t.Fatalf("unexpected event: %+v", r1)
+	}
+	for time.Now().Before(deadline) {
+		if names, _ := en.queues["hook"].list(); len(names) == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	if names, _ := en.queues["hook"].list(); len(names) != 0 {
+		t.Fatalf("events left in queue: %v", names)
+	}
+
+	var files []os.DirEntry
+	for time.Now().Before(deadline) {
+		if files, _ = os.ReadDir(local); len(files) == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expect 1 event in local queue, got %d", len(files))
+	}
+	data, _ := os.ReadFile(filepath.Join(local, files[0].Name()))
+	var e eventLog
+	if err = json.Unmarshal(data, &e); err != nil || e.EventName != EventObjectRemovedDelete || e.Records[0].S3.ConfigurationID != "audit" {
+		t.Fatalf("unexpected event %s: %s", data, err)
+	}
+}
+
+type failedTarget struct {
+	sync.Mutex
+	sent []string
+}
+
+func (t *failedTarget) send(ctx context.Context, data []byte) error {
+	t.Lock()
+	defer t.Unlock()
+	t.sent = append(t.sent, string(data))
+	if string(data) == "bad" {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+func (t *failedTarget) Close() error {
+	return nil
+}
+
+func TestEventDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	target := &failedTarget{}
+	q, err := newEventQueue("test", dir, target)
+	if err != nil {
+		t.Fatalf("create queue: %s", err)
+	}
+	q.attempts = 3
+	for _, data := range []string{"bad", "good"} {
+		if err = q.put([]byte(data)); err != nil {
+			t.Fatalf("put event: %s", err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.run(ctx)
+
+	deadline := time.Now().Add(time.Second * 10)
+	for q.pending.Load() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("events are not delivered: %d", q.pending.Load())
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	target.Lock()
+	sent := strings.Join(target.sent, ",")
+	target.Unlock()
+	if sent != "bad,bad,bad,good" {
+		t.Fatalf("unexpected attempts: %s", sent)
+	}
+	failed, _ := os.ReadDir(filepath.Join(dir, failedEventsDir))
+	if len(failed) != 1 {
+		t.Fatalf("expect 1 failed event, got %d", len(failed))
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, failedEventsDir, failed[0].Name())); string(data) != "bad" {
+		t.Fatalf("unexpected failed event: %s", data)
+	}
+}
@@ -0,0 +1,504 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gateway
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	minio "github.com/minio/minio/cmd"
+
+	"github.com/juicedata/juicefs/pkg/fs"
+)
+
+// With versioning, the current version of an object is kept in its path as before, with the version ID
+// in xattr (none for the null version). The prior versions and delete markers are kept in a hidden dir
+// of the bucket: .sys/[bucket/]versions/<object>.versions/<version ID>, cloned from the current one so
+// they share the slices with it.
+
+const (
+	s3VersionID    = "s3-version-id"
+	s3DeleteMarker = "s3-delete-marker"
+	nullVersionID  = "null"
+	versionsDir    = "versions"
+	versionsSuffix = ".versions"
+	maxVersionList = 1000
+)
+
+var versionLockTimeout = minio.NewDynamicTimeout(30*time.Second, 10*time.Second)
+
+// lockVersions locks the versions of an object in this gateway while they are changed, so the current one
+// is archived once before it's replaced or deleted.
+func (n *jfsObjects) lockVersions(ctx context.Context, bucket, object string) (func(), error) {
+	lk := n.nsMutex.NewNSLock(nil, bucket, object)
+	if _, err := lk.GetLock(ctx, versionLockTimeout); err != nil {
+		return nil, err
+	}
+	return lk.Unlock, nil
+}
+
+func (n *jfsObjects) vdir(bucket, object string) string {
+	return n.tpath(bucket, versionsDir, object) + versionsSuffix
+}
+
+func (n *jfsObjects) vpath(bucket, object, versionID string) string {
+	return path.Join(n.vdir(bucket, object), versionID)
+}
+
+func isVersioned(opts minio.ObjectOptions) bool {
+	return opts.Versioned || opts.VersionSuspended
+}
+
+func isSuspended(opts minio.ObjectOptions) bool {
+	return opts.VersionSuspended && !opts.Versioned
+}
+
+// the version ID in ObjectInfo, empty for the null version
+func objVersionID(id string) string {
+	if id == nullVersionID {
+		return ""
+	}
+	return id
+}
+
+// versionID returns the version ID of the file in p.
+func (n *jfsObjects) versionID(p string) string {
+	if id, eno := n.fs.GetXattr(mctx, p, s3VersionID); eno == 0 && len(id) > 0 {
+		return string(id)
+	}
+	return nullVersionID
+}
+
+type objectVersion struct {
+	*fs.FileStat
+	id     string
+	marker bool
+}
+
+// archivedVersions returns the prior versions and delete markers of an object, the newest first.
+func (n *jfsObjects) archivedVersions(bucket, object string) ([]*objectVersion, error) {
+	dir := n.vdir(bucket, object)
+	f, eno := n.fs.Open(mctx, dir, 0)
+	if eno == syscall.ENOENT || eno == syscall.ENOTDIR {
+		return nil, nil
+	} else if eno != 0 {
+		return nil, eno
+	}
+	defer f.Close(mctx)
+	fis, eno := f.Readdir(mctx, 0)
+	if eno != 0 {
+		return nil, eno
+	}
+	var vs []*objectVersion
+	for _, fi := range fis {
+		if fi.IsDir() { // versions of the objects under it
+			continue
+		}
+		v := &objectVersion{FileStat: fi.(*fs.FileStat), id: fi.Name()}
+		_, eno = n.fs.GetXattr(mctx, path.Join(dir, v.id), s3DeleteMarker)
+		v.marker = eno == 0
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		if ti, tj := vs[i].ModTime(), vs[j].ModTime(); !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return vs[i].id > vs[j].id
+	})
+	return vs, nil
+}
+
+// archiveCurrent clones the current version of an object into the version dir before it's replaced
+// or deleted. In suspended mode the null version is replaced rather than kept.
+func (n *jfsObjects) archiveCurrent(ctx context.Context, bucket, object string, suspended bool) error {
+	p := n.path(bucket, object)
+	fi, eno := n.fs.Stat(mctx, p)
+	if eno == syscall.ENOENT || eno == 0 && fi.IsDir() {
+		return nil
+	} else if eno != 0 {
+		return eno
+	}
+	id := n.versionID(p)
+	if suspended || id == nullVersionID {
+		if eno = n.fs.Delete(mctx, n.vpath(bucket, object, nullVersionID)); eno != 0 && eno != syscall.ENOENT {
+			return eno
+		}
+		if suspended && id == nullVersionID {
+			return nil
+		}
+	}
+	dst := n.vpath(bucket, object, id)
+	if err := n.mkdirAll(ctx, path.Dir(dst)); err != nil {
+		return err
+	}
+	if eno = n.fs.Clone(mctx, p, dst, true); eno != 0 && eno != syscall.EEXIST { // archived by another gateway
+		return eno
+	}
+	return nil
+}
+
+// newVersion archives the current version of an object, and assigns a version ID to the new one in tmp
+// which is going to replace it. It returns the version ID of the new one (empty for the null version), and
+// the function to unlock the versions after tmp is renamed to the object.
+func (n *jfsObjects) newVersion(ctx context.Context, bucket, object, tmp string, opts minio.ObjectOptions) (string, func(), error) {
+	if !isVersioned(opts) || strings.HasSuffix(object, sep) {
+		return "", func() {}, nil
+	}
+	unlock, err := n.lockVersions(ctx, bucket, object)
+	if err != nil {
+		return "", nil, err
+	}
+	if err = n.archiveCurrent(ctx, bucket, object, isSuspended(opts)); err != nil {
+		unlock()
+		logger.Errorf("archive the current version of %s: %s", object, err)
+		return "", nil, err
+	}
+	if isSuspended(opts) {
+		return "", unlock, nil
+	}
+	id := minio.MustGetUUID()
+	if eno := n.fs.SetXattr(mctx, tmp, s3VersionID, []byte(id), 0); eno != 0 {
+		unlock()
+		return "", nil, eno
+	}
+	return id, unlock, nil
+}
+
+// resolveVersion returns the path of the requested version of an object, the current one if versionID is empty.
+func (n *jfsObjects) resolveVersion(bucket, object, versionID string) (p, id string, latest bool, err error) {
+	p = n.path(bucket, object)
+	if strings.HasSuffix(object, sep) {
+		return p, nullVersionID, true, nil
+	}
+	if fi, eno := n.fs.Stat(mctx, p); eno == 0 && !fi.IsDir() {
+		if id = n.versionID(p); versionID == "" || versionID == id {
+			return p, id, true, nil
+		}
+	}
+	if versionID == "" {
+		return p, nullVersionID, true, nil
+	}
+	p = n.vpath(bucket, object, versionID)
+	if _, eno := n.fs.GetXattr(mctx, p, s3DeleteMarker); eno == 0 {
+		return "", "", false, minio.MethodNotAllowed{Bucket: bucket, Object: object}
+	}
+	if _, eno := n.fs.Stat(mctx, p); eno != 0 {
+		if fs.IsNotExist(eno) {
+			return "", "", false, minio.VersionNotFound{Bucket: bucket, Object: object, VersionID: versionID}
+		}
+		return "", "", false, eno
+	}
+	return p, versionID, false, nil
+}
+
+// deleteVersioned adds a delete marker if no version is specified, or removes the specified version permanently.
+func (n *jfsObjects) deleteVersioned(ctx context.Context, bucket, object string, opts minio.ObjectOptions) (info minio.ObjectInfo, err error) {
+	info.Bucket = bucket
+	info.Name = object
+	if strings.HasSuffix(object, sep) {
+		return info, n.delObj(bucket, object)
+	}
+	unlock, err := n.lockVersions(ctx, bucket, object)
+	if err != nil {
+		return
+	}
+	defer unlock()
+	if opts.VersionID == "" {
+		suspended := isSuspended(opts)
+		if err = n.archiveCurrent(ctx, bucket, object, suspended); err != nil {
+			return
+		}
+		if err = n.delObj(bucket, object); err != nil {
+			return
+		}
+		id := nullVersionID
+		if !suspended {
+			id = minio.MustGetUUID()
+		}
+		p := n.vpath(bucket, object, id)
+		if err = n.mkdirAll(ctx, path.Dir(p)); err != nil {
+			return
+		}
+		_ = n.fs.Delete(mctx, p) // the null version is replaced
+		f, eno := n.fs.Create(mctx, p, 0666, n.gConf.Umask)
+		if eno != 0 {
+			return info, eno
+		}
+		_ = f.Close(mctx)
+		n.chown(ctx, p)
+		if eno = n.fs.SetXattr(mctx, p, s3DeleteMarker, []byte("true"), 0); eno != 0 {
+			_ = n.fs.Delete(mctx, p)
+			return info, eno
+		}
+		info.DeleteMarker = true
+		info.VersionID = objVersionID(id)
+		return
+	}
+
+	p := n.path(bucket, object)
+	if fi, eno := n.fs.Stat(mctx, p); eno == 0 && !fi.IsDir() && n.versionID(p) == opts.VersionID {
+		if err = n.delObj(bucket, object); err != nil {
+			return
+		}
+	} else {
+		vp := n.vpath(bucket, object, opts.VersionID)
+		if _, eno := n.fs.GetXattr(mctx, vp, s3DeleteMarker); eno == 0 {
+			info.DeleteMarker = true
+		}
+		if eno := n.fs.Delete(mctx, vp); eno != 0 && !fs.IsNotExist(eno) {
+			return info, eno
+		}
+	}
+	info.VersionID = objVersionID(opts.VersionID)
+	return info, n.restoreLatest(ctx, bucket, object)
+}
+
+// restoreLatest moves the newest prior version back after the current one is removed, unless it's a delete marker.
+func (n *jfsObjects) restoreLatest(ctx context.Context, bucket, object string) error {
+	p := n.path(bucket, object)
+	if _, eno := n.fs.Stat(mctx, p); eno != syscall.ENOENT {
+		return nil
+	}
+	vs, err := n.archivedVersions(bucket, object)
+	if err != nil {
+		return err
+	}
+	if len(vs) == 0 {
+		_ = n.fs.Delete(mctx, n.vdir(bucket, object)) // fails if there are versions of the objects under it
+		return nil
+	}
+	if vs[0].marker {
+		return nil
+	}
+	if err = n.mkdirAll(ctx, path.Dir(p)); err != nil {
+		return err
+	}
+	if eno := n.fs.Rename(mctx, n.vpath(bucket, object, vs[0].id), p, 0); eno != 0 {
+		return eno
+	}
+	return nil
+}
+
+// scanVersionedKeys iterates the objects having prior versions or delete markers in the bucket in order,
+// which have the prefix and are not before marker, until fn returns false.
+func (n *jfsObjects) scanVersionedKeys(bucket, prefix, marker string, fn func(key string) bool) {
+	type item struct {
+		name string // of the dir
+		key  string // the object of the versions, or the prefix of the objects under it
+		dir  bool
+	}
+	var walk func(dir, kp string) bool
+	walk = func(dir, kp string) bool {
+		f, eno := n.fs.Open(mctx, dir, 0)
+		if eno != 0 {
+			return true
+		}
+		fis, eno := f.Readdir(mctx, 0)
+		_ = f.Close(mctx)
+		if eno != 0 {
+			return true
+		}
+		items := make([]item, 0, len(fis))
+		for _, fi := range fis {
+			if !fi.IsDir() {
+				continue
+			}
+			name := fi.Name()
+			if key := strings.TrimSuffix(kp+name, versionsSuffix); len(key) < len(kp+name) {
+				items = append(items, item{name: name, key: key})
+			}
+			items = append(items, item{name: name, key: kp + name + sep, dir: true})
+		}
+		// the keys under a dir are all after the ones before its prefix, and before the ones after it
+		sort.Slice(items, func(i, j int) bool { return items[i].key < items[j].key })
+		for _, it := range items {
+			if !it.dir {
+				if strings.HasPrefix(it.key, prefix) && it.key >= marker && !fn(it.key) {
+					return false
+				}
+				continue
+			}
+			// the keys under it are all before the marker, or none of them has the prefix
+			if it.key < marker && !strings.HasPrefix(marker, it.key) ||
+				!strings.HasPrefix(it.key, prefix) && !strings.HasPrefix(prefix, it.key) {
+				continue
+			}
+			if !walk(path.Join(dir, it.name), it.key) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(n.tpath(bucket, versionsDir), "")
+}
+
+// objectVersions returns all the versions of an object, the latest first.
+func (n *jfsObjects) objectVersions(bucket, object string, current *minio.ObjectInfo) ([]minio.ObjectInfo, error) {
+	var objs []minio.ObjectInfo
+	if current != nil {
+		oi := *current
+		if !oi.IsDir {
+			oi.VersionID = objVersionID(n.versionID(n.path(bucket, object)))
+		}
+		oi.IsLatest = true
+		objs = append(objs, oi)
+	}
+	if strings.HasSuffix(object, sep) {
+		return objs, nil
+	}
+	vs, err := n.archivedVersions(bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vs {
+		oi := minio.ObjectInfo{
+			Bucket:       bucket,
+			Name:         object,
+			VersionID:    objVersionID(v.id),
+			DeleteMarker: v.marker,
+			ModTime:      v.ModTime(),
+			AccTime:      v.ModTime(),
+			IsLatest:     len(objs) == 0,
+		}
+		if !v.marker {
+			oi.Size = v.Size()
+			if n.gConf.KeepEtag {
+				etag, _ := n.fs.GetXattr(mctx, n.vpath(bucket, object, v.id), s3Etag)
+				oi.ETag = string(etag)
+			}
+		}
+		objs = append(objs, oi)
+	}
+	return objs, nil
+}
+
+// ListObjectVersions lists the current objects and their prior versions and delete markers in the bucket.
+func (n *jfsObjects) ListObjectVersions(ctx context.Context, bucket, prefix, marker, versionMarker, delimiter string, maxKeys int) (loi minio.ListObjectVersionsInfo, err error) {
+	cur, err := n.ListObjects(ctx, bucket, prefix, marker, delimiter, maxKeys)
+	if err != nil {
+		return loi, err
+	}
+
+	// merge the objects having prior versions only
+	type entry struct {
+		name    string
+		prefix  bool
+		current *minio.ObjectInfo
+	}
+	entries := make(map[string]*entry)
+	for i := range cur.Objects {
+		entries[cur.Objects[i].Name] = &entry{name: cur.Objects[i].Name, current: &cur.Objects[i]}
+	}
+	for _, p := range cur.Prefixes {
+		entries[p] = &entry{name: p, prefix: true}
+	}
+	if maxKeys <= 0 {
+		maxKeys = maxVersionList
+	}
+	// the keys come in order, so no more are needed after the ones filling the page
+	var added int
+	n.scanVersionedKeys(bucket, prefix, marker, func(key string) bool {
+		name, isPrefix := key, false
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				name, isPrefix = key[:len(prefix)+i+len(delimiter)], true
+			}
+		}
+		if versionMarker != "" && name == marker && !isPrefix {
+			// continue with the versions of the marker
+		} else if name <= marker {
+			return true
+		} else if cur.IsTruncated && name > cur.NextMarker {
+			return false
+		}
+		if _, ok := entries[name]; !ok {
+			entries[name] = &entry{name: name, prefix: isPrefix}
+			added++
+		}
+		return added < maxKeys
+	})
+	if e := entries[marker]; e != nil && versionMarker != "" && e.current == nil {
+		// the current version of the marker is listed already
+		p := n.path(bucket, marker)
+		if fi, eno := n.fs.Stat(mctx, p); eno == 0 && !fi.IsDir() {
+			e.current = &minio.ObjectInfo{Bucket: bucket, Name: marker, ModTime: fi.ModTime(), AccTime: fi.ModTime(), Size: fi.Size()}
+			if n.gConf.KeepEtag {
+				etag, _ := n.fs.GetXattr(mctx, p, s3Etag)
+				e.current.ETag = string(etag)
+			}
+		}
+	}
+	sorted := make([]*entry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].name < sorted[j].name })
+
+	var count int
+	var lastKey, lastVersion string
+	for _, e := range sorted {
+		if e.prefix {
+			if count == maxKeys {
+				loi.IsTruncated = true
+				break
+			}
+			loi.Prefixes = append(loi.Prefixes, e.name)
+			lastKey, lastVersion = e.name, ""
+			count++
+			continue
+		}
+		objs, err := n.objectVersions(bucket, e.name, e.current)
+		if err != nil {
+			return loi, jfsToObjectErr(ctx, err, bucket, e.name)
+		}
+		if e.name == marker && versionMarker != "" {
+			for i, oi := range objs {
+				if vid := oi.VersionID; vid == versionMarker || vid == "" && versionMarker == nullVersionID {
+					objs = objs[i+1:]
+					break
+				}
+			}
+		}
+		for _, oi := range objs {
+			if count == maxKeys {
+				loi.IsTruncated = true
+				break
+			}
+			loi.Objects = append(loi.Objects, oi)
+			lastKey, lastVersion = oi.Name, oi.VersionID
+			if lastVersion == "" {
+				lastVersion = nullVersionID
+			}
+			count++
+		}
+		if loi.IsTruncated {
+			break
+		}
+	}
+	if loi.IsTruncated {
+		loi.NextMarker, loi.NextVersionIDMarker = lastKey, lastVersion
+	} else if cur.IsTruncated {
+		// all the versions of the last one are listed
+		loi.IsTruncated = true
+		loi.NextMarker = lastKey
+	}
+	return loi, nil
+}
@@ -54,25 +54,29 @@ const (
 	segTypeQuota
 	segTypeParent // for redis/tkv only
 	segTypeChangeLog
+	segTypeDedupBlock
+	segTypeDedupRef
 	segTypeMax
 )
 
 var SegType2Name = map[int]string{
-	segTypeFormat:    "format",
-	segTypeCounter:   "counter",
-	segTypeNode:      "node",
-	segTypeEdge:      "edge",
-	segTypeChunk:     "chunk",
-	segTypeSliceRef:  "sliceRef",
-	segTypeSymlink:   "symlink",
-	segTypeSustained: "sustained",
-	segTypeDelFile:   "delFile",
-	segTypeXattr:     "xattr",
-	segTypeAcl:       "acl",
-	segTypeStat:      "stat",
-	segTypeQuota:     "quota",
-	segTypeParent:    "parent",
-	segTypeChangeLog: "changeLog",
+	segTypeFormat:     "format",
+	segTypeCounter:    "counter",
+	segTypeNode:       "node",
+	segTypeEdge:       "edge",
+	segTypeChunk:      "chunk",
+	segTypeSliceRef:   "sliceRef",
+	segTypeSymlink:    "symlink",
+	segTypeSustained:  "sustained",
+	segTypeDelFile:    "delFile",
+	segTypeXattr:      "xattr",
+	segTypeAcl:        "acl",
+	segTypeStat:       "stat",
+	segTypeQuota:      "quota",
+	segTypeParent:     "parent",
+	segTypeChangeLog:  "changeLog",
+	segTypeDedupBlock: "dedupBlock",
+	segTypeDedupRef:   "dedupRef",
 }
 
 var errBakEOF = fmt.Errorf("reach backup EOF")
@@ -275,6 +279,10 @@ func newBakSegment(val proto.Message) *BakSegment {
 			s.typ = uint32(segTypeParent)
 		} else if v.Changelogs != nil {
 			s.typ = uint32(segTypeChangeLog)
+		} else if v.DedupBlocks != nil {
+			s.typ = uint32(segTypeDedupBlock)
+		} else if v.DedupRefs != nil {
+			s.typ = uint32(segTypeDedupRef)
 		} else {
 			return nil
 		}
@@ -317,6 +325,10 @@ func (s *BakSegment) num() uint64 {
 			return uint64(len(b.Parents))
 		case segTypeChangeLog:
 			return uint64(len(b.Changelogs))
+		case segTypeDedupBlock:
+			return uint64(len(b.DedupBlocks))
+		case segTypeDedupRef:
+			return uint64(len(b.DedupRefs))
 		}
 		return 0
 	}
@@ -125,6 +125,7 @@ type engine interface {
 	doReadlink(ctx Context, inode Ino, noatime bool) (int64, []byte, error)
 	doReaddir(ctx Context, inode Ino, plus uint8, entries *[]*Entry, limit int) syscall.Errno
 	doRename(ctx Context, parentSrc Ino, nameSrc string, parentDst Ino, nameDst string, flags uint32, inode, tinode *Ino, attr, tattr *Attr) syscall.Errno
+	doGetXattr(ctx Context, inode Ino, name string, vbuff *[]byte) syscall.Errno
 	doSetXattr(ctx Context, inode Ino, name string, value []byte, flags uint32) syscall.Errno
 	doRemoveXattr(ctx Context, inode Ino, name string) syscall.Errno
 	doRepair(ctx Context, inode Ino, attr *Attr) syscall.Errno
@@ -160,7 +161,41 @@ type engine interface {
 	doDeleteTokens(ctx Context, ids []uint32) syscall.Errno
 	doListTokens(ctx Context) (tokens map[uint32][]byte, st syscall.Errno)
 
-	doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines int64) error
+	// Remove the changelog entries exceeding maxAge or maxLines, but entries with version larger than keep
+	// are not removed unless they are older than forceAge (0 means never).
+	doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines int64, keep int64, forceAge time.Duration) error
+	// consumer groups of changelog, info is the encoded ChangelogConsumer
+	doSaveChangelogConsumer(ctx Context, group string, info []byte) syscall.Errno
+	doDeleteChangelogConsumer(ctx Context, group string) syscall.Errno
+	doListChangelogConsumers(ctx Context) (consumers map[string][]byte, st syscall.Errno)
+	// the version of the latest changelog entry, 0 if there is none
+	doGetChangelogVersion(ctx Context) (int64, syscall.Errno)
+
+	// snapshot registry, info is the encoded Snapshot
+	doSaveSnapshot(ctx Context, name string, info []byte) syscall.Errno
+	doDeleteSnapshot(ctx Context, name string) syscall.Errno
+	doListSnapshots(ctx Context) (snapshots map[string][]byte, st syscall.Errno)
+
+	// lifecycle policies of directories, info is the encoded TierPolicy
+	doSaveTierPolicy(ctx Context, inode Ino, info []byte) syscall.Errno
+	doDeleteTierPolicy(ctx Context, inode Ino) syscall.Errno
+	doListTierPolicies(ctx Context) (policies map[Ino][]byte, st syscall.Errno)
+
+	// compression of directories
+	doSetDirCompression(ctx Context, inode Ino, algr string) syscall.Errno
+	doDeleteDirCompression(ctx Context, inode Ino) syscall.Errno
+	doListDirCompressions(ctx Context) (compressions map[Ino]string, st syscall.Errno)
+
+	// index of deduplicated blocks, a missing block has no chunks
+	doAddDedupBlock(ctx Context, id uint64, indx uint32, chunks []DedupChunk) (exists []bool, st syscall.Errno)
+	doGetDedupBlock(ctx Context, id uint64, indx uint32) (chunks []DedupChunk, st syscall.Errno)
+	doRemoveDedupBlock(ctx Context, id uint64, indx uint32) (chunks []DedupChunk, st syscall.Errno)
+	doScanDedupBlocks(ctx Context, scan func(id uint64, indx uint32, chunks []DedupChunk) bool) syscall.Errno
+	doScanDedupRefs(ctx Context, scan func(ref *DedupRef) bool) syscall.Errno
+	// mark an object as being deleted if it's not referenced since before
+	doMarkDedupRef(ctx Context, hash string, size uint32, before int64) (marked bool, st syscall.Errno)
+	doDeleteDedupRef(ctx Context, hash string, size uint32) syscall.Errno
+	loadDedup(ctx Context, msg proto.Message) error
 
 	newDirHandler(inode Ino, plus bool, entries []*Entry) DirHandler
 
@@ -315,6 +350,12 @@ type baseMeta struct {
 	userQuotas      map[uint64]*Quota // uid -> quota
 	groupQuotas     map[uint64]*Quota // gid -> quota
 
+	compressMu  sync.RWMutex
+	dirCompress map[Ino]string // directory inode -> compression
+
+	policyMu    sync.Mutex
+	dirPolicies map[Ino]dirPolicy // directory inode -> cache policy
+
 	quotaMetricMu        sync.Mutex
 	dirQuotaMetricKeys   map[uint64]bool
 	userQuotaMetricKeys  map[uint64]bool
@@ -381,6 +422,8 @@ func newBaseMeta(addr string, conf *Config) *baseMeta {
 		dirQuotas:   make(map[uint64]*Quota),
 		userQuotas:  make(map[uint64]*Quota),
 		groupQuotas: make(map[uint64]*Quota),
+		dirCompress: make(map[Ino]string),
+		dirPolicies: make(map[Ino]dirPolicy),
 		msgCallbacks: &msgCallbacks{
 			callbacks: make(map[uint32]MsgCallback),
 		},
@@ -758,6 +801,8 @@ func (m *baseMeta) newSessionInfo() []byte {
 		MountPoint: m.conf.MountPoint,
 		MountTime:  time.Now(),
 		ProcessID:  os.Getpid(),
+		CacheGroup: m.conf.CacheGroup,
+		GroupAddr:  m.conf.GroupAddr,
 	})
 	if err != nil {
 		panic(err) // marshal SessionInfo should never fail
@@ -806,6 +851,7 @@ func (m *baseMeta) NewSession(record bool) error {
 	}
 
 	m.loadQuotas()
+	m.loadDirCompressions()
 
 	m.sessWG.Add(3)
 	go m.flushStats(ctx)
@@ -814,10 +860,11 @@ func (m *baseMeta) NewSession(record bool) error {
 	m.startDeleteSliceTasks() // start MaxDeletes tasks
 
 	if !m.conf.NoBGJob {
-		m.sessWG.Add(4)
+		m.sessWG.Add(5)
 		go m.cleanupDeletedFiles(ctx)
 		go m.cleanupSlices(ctx)
 		go m.cleanupTrash(ctx)
+		go m.applyTierPolicies(ctx)
 		go m.symlinks.clean(ctx, &m.sessWG)
 		if m.getFormat().ChangeLog {
 			m.sessWG.Add(1)
@@ -944,6 +991,8 @@ func (m *baseMeta) refresh(ctx Context) {
 			logger.Warnf("Get counter %s: %s", totalInodes, err)
 		}
 		m.loadQuotas()
+		m.loadDirCompressions()
+		m.cleanupDirPolicies()
 
 		if m.conf.ReadOnly || m.conf.NoBGJob || m.conf.Heartbeat == 0 {
 			continue
@@ -1123,13 +1172,26 @@ func (m *baseMeta) cleanupChangelog(ctx Context) {
 		if ok, err := m.en.setIfSmall("lastCleanupChangelog", time.Now().Unix(), int64((interval * 9 / 10).Seconds())); err != nil {
 			logger.Warnf("checking counter lastCleanupChangelog: %s", err)
 		} else if ok {
-			if err := m.en.doCleanupChangelog(ctx, maxAge, maxLines); err != nil {
+			keep, st := m.changelogKeepVersion(ctx)
+			if st != 0 {
+				logger.Warnf("list changelog consumers: %s", st)
+				continue
+			}
+			forceAge := time.Duration(format.ChangeLogForceAge) * time.Second
+			if err := m.en.doCleanupChangelog(ctx, maxAge, maxLines, keep, forceAge); err != nil {
 				logger.Warnf("cleanup changelog: %s", err)
 			}
 		}
 	}
 }
 
+func (m *baseMeta) ClaimJob(name string, interval time.Duration) (bool, error) {
+	if m.conf.ReadOnly {
+		return false, nil
+	}
+	return m.en.setIfSmall(name, time.Now().Unix(), int64((interval * 9 / 10).Seconds()))
+}
+
 func (m *baseMeta) StatFS(ctx Context, ino Ino, totalspace, availspace, iused, iavail *uint64) syscall.Errno {
 	defer m.timeit("StatFS", time.Now())
 	if st := m.statRootFs(ctx, totalspace, availspace, iused, iavail); st != 0 {
@@ -1295,6 +1357,13 @@ func (m *baseMeta) Lookup(ctx Context, parent Ino, name string, inode *Ino, attr
 		*inode = TrashInode
 		return 0
 	}
+	if parent == RootInode && name == SnapshotName {
+		if st := m.GetAttr(ctx, SnapshotInode, attr); st != 0 {
+			return st
+		}
+		*inode = SnapshotInode
+		return 0
+	}
 	st := m.en.doLookup(ctx, parent, name, inode, attr)
 	if st == syscall.ENOENT && m.conf.CaseInsensi {
 		if e := m.resolveCase(ctx, parent, name); e != nil {
@@ -1451,7 +1520,7 @@ func (m *baseMeta) GetAttr(ctx Context, inode Ino, attr *Attr) syscall.Errno {
 	}
 	defer m.timeit("GetAttr", time.Now())
 	var err syscall.Errno
-	if inode == RootInode || inode == TrashInode {
+	if inode == RootInode || inode == TrashInode || inode == SnapshotInode {
 		// doGetAttr could overwrite the `attr` after timeout
 		var a Attr
 		e := utils.WithTimeout(ctx, func(context.Context) error {
@@ -1466,7 +1535,7 @@ func (m *baseMeta) GetAttr(ctx Context, inode Ino, attr *Attr) syscall.Errno {
 			attr.Mode = 0777
 			attr.Nlink = 2
 			attr.Length = 4 << 10
-			if inode == TrashInode {
+			if inode == TrashInode || inode == SnapshotInode {
 				attr.Mode = 0555
 			}
 			attr.Parent = RootInode
@@ -1609,7 +1678,7 @@ func (m *baseMeta) Mknod(ctx Context, parent Ino, name string, _type uint8, mode
 	if parent.IsTrash() {
 		return syscall.EPERM
 	}
-	if parent == RootInode && name == TrashName {
+	if parent == RootInode && (name == TrashName || name == SnapshotName) || parent == SnapshotInode {
 		return syscall.EPERM
 	}
 	if m.conf.ReadOnly {
@@ -1708,7 +1777,7 @@ func (m *baseMeta) Link(ctx Context, inode, parent Ino, name string, attr *Attr)
 	if parent.IsTrash() {
 		return syscall.EPERM
 	}
-	if parent == RootInode && name == TrashName {
+	if parent == RootInode && (name == TrashName || name == SnapshotName) || parent == SnapshotInode {
 		return syscall.EPERM
 	}
 	if m.conf.ReadOnly {
@@ -1797,7 +1866,7 @@ func (m *baseMeta) ReadLink(ctx Context, inode Ino, path *[]byte) syscall.Errno
 }
 
 func (m *baseMeta) Unlink(ctx Context, parent Ino, name string, skipCheckTrash ...bool) syscall.Errno {
-	if parent == RootInode && name == TrashName || parent.IsTrash() && ctx.Uid() != 0 {
+	if parent == RootInode && (name == TrashName || name == SnapshotName) || parent == SnapshotInode || parent.IsTrash() && ctx.Uid() != 0 {
 		return syscall.EPERM
 	}
 	if m.conf.ReadOnly {
@@ -1828,9 +1897,13 @@ func (m *baseMeta) Rmdir(ctx Context, parent Ino, name string, skipCheckTrash ..
 	if name == ".." {
 		return syscall.ENOTEMPTY
 	}
-	if parent == RootInode && name == TrashName || parent == TrashInode || parent.IsTrash() && ctx.Uid() != 0 {
+	if parent == RootInode && (name == TrashName || name == SnapshotName) || parent == TrashInode || parent == SnapshotInode || parent.IsTrash() && ctx.Uid() != 0 {
 		return syscall.EPERM
 	}
+	return m.rmdir(ctx, parent, name, skipCheckTrash...)
+}
+
+func (m *baseMeta) rmdir(ctx Context, parent Ino, name string, skipCheckTrash ...bool) syscall.Errno {
 	if m.conf.ReadOnly {
 		return syscall.EROFS
 	}
@@ -1896,6 +1969,10 @@ func (m *baseMeta) Rename(ctx Context, parentSrc Ino, nameSrc string, parentDst
 	if parentSrc == RootInode && nameSrc == TrashName || parentDst == RootInode && nameDst == TrashName {
 		return syscall.EPERM
 	}
+	if parentSrc == RootInode && nameSrc == SnapshotName || parentDst == RootInode && nameDst == SnapshotName ||
+		parentSrc == SnapshotInode || parentDst == SnapshotInode {
+		return syscall.EPERM
+	}
 	if parentDst.IsTrash() || parentSrc.IsTrash() && ctx.Uid() != 0 {
 		return syscall.EPERM
 	}
@@ -2295,12 +2372,17 @@ func (m *baseMeta) Readdir(ctx Context, inode Ino, plus uint8, entries *[]*Entry
 		Attr:  &Attr{Typ: TypeDirectory},
 	})
 	st := m.en.doReaddir(ctx, inode, plus, entries, -1)
-	if st == syscall.ENOENT && inode == TrashInode {
+	if st == syscall.ENOENT && (inode == TrashInode || inode == SnapshotInode) {
 		st = 0
 	}
 	return st
 }
 
+func (m *baseMeta) GetXattr(ctx Context, inode Ino, name string, vbuff *[]byte) syscall.Errno {
+	defer m.timeit("GetXattr", time.Now())
+	return m.en.doGetXattr(ctx, m.checkRoot(inode), name, vbuff)
+}
+
 func (m *baseMeta) SetXattr(ctx Context, inode Ino, name string, value []byte, flags uint32) syscall.Errno {
 	if m.conf.ReadOnly {
 		return syscall.EROFS
@@ -2315,7 +2397,15 @@ func (m *baseMeta) SetXattr(ctx Context, inode Ino, name string, value []byte, f
 	}
 
 	defer m.timeit("SetXattr", time.Now())
-	return m.en.doSetXattr(ctx, m.checkRoot(inode), name, value, flags)
+	inode = m.checkRoot(inode)
+	if name == CachePolicyXattr {
+		defer m.invalidateDirPolicy(inode)
+		if err := m.enableCachePolicy(); err != nil {
+			logger.Warnf("enable cache policy: %s", err)
+			return syscall.EIO
+		}
+	}
+	return m.en.doSetXattr(ctx, inode, name, value, flags)
 }
 
 func (m *baseMeta) RemoveXattr(ctx Context, inode Ino, name string) syscall.Errno {
@@ -2327,11 +2417,15 @@ func (m *baseMeta) RemoveXattr(ctx Context, inode Ino, name string) syscall.Errn
 	}
 
 	defer m.timeit("RemoveXattr", time.Now())
-	return m.en.doRemoveXattr(ctx, m.checkRoot(inode), name)
+	inode = m.checkRoot(inode)
+	if name == CachePolicyXattr {
+		defer m.invalidateDirPolicy(inode)
+	}
+	return m.en.doRemoveXattr(ctx, inode, name)
 }
 
 func (m *baseMeta) GetParents(ctx Context, inode Ino) map[Ino]int {
-	if inode == RootInode || inode == TrashInode {
+	if inode == RootInode || inode == TrashInode || inode == SnapshotInode {
 		return map[Ino]int{1: 1}
 	}
 	var attr Attr
@@ -2355,6 +2449,10 @@ func (m *baseMeta) GetPaths(ctx Context, inode Ino) []string {
 		return []string{"/.trash"}
 	}
 
+	if inode == SnapshotInode {
+		return []string{"/" + SnapshotName}
+	}
+
 	outside := "path not shown because it's outside of the mounted root"
 	getDirPath := func(ino Ino) (string, error) {
 		var names []string
@@ -2380,6 +2478,9 @@ func (m *baseMeta) GetPaths(ctx Context, inode Ino) []string {
 			if attr.Parent == RootInode && ino == TrashInode {
 				name = TrashName
 			}
+			if attr.Parent == RootInode && ino == SnapshotInode {
+				name = SnapshotName
+			}
 			if name == "" {
 				return "", fmt.Errorf("entry %d/%d not found", attr.Parent, ino)
 			}
@@ -3357,7 +3458,13 @@ func (m *baseMeta) ScanDeletedObject(ctx Context, tss trashSliceScan, pss pendin
 }
 
 func (m *baseMeta) Clone(ctx Context, srcParentIno, srcIno, parent Ino, name string, cmode uint8, cumask uint16, concurrency uint8, count, total *uint64) syscall.Errno {
+	if parent == SnapshotInode || (parent == RootInode && name == SnapshotName) {
+		return syscall.EPERM
+	}
+	return m.clone(ctx, srcParentIno, srcIno, parent, name, cmode, cumask, concurrency, count, total)
+}
 
+func (m *baseMeta) clone(ctx Context, srcParentIno, srcIno, parent Ino, name string, cmode uint8, cumask uint16, concurrency uint8, count, total *uint64) syscall.Errno {
 	if srcIno.IsTrash() || srcParentIno.IsTrash() || parent.IsTrash() || (parent == RootInode && name == TrashName) {
 		return syscall.EPERM
 	}
@@ -3423,7 +3530,7 @@ func (m *baseMeta) Clone(ctx Context, srcParentIno, srcIno, parent Ino, name str
 	return eno
 }
 
-func (m *baseMeta) cloneEntry(ctx Context, srcIno Ino, parent Ino, name string, dstIno *Ino, cmode uint8, cumask uint16, count *uint64, top bool, concurrent chan struct{}) syscall.Errno {
+func (m *baseMeta) cloneEntry(ctx Context, srcIno Ino, parent Ino, name string, dstIno *Ino, cmode uint8, cumask uint16, count *uint64, top bool, concurrent chan struct{}) (eno syscall.Errno) {
 	ino, err := m.nextInode()
 	if err != nil {
 		return errno(err)
@@ -3432,7 +3539,7 @@ func (m *baseMeta) cloneEntry(ctx Context, srcIno Ino, parent Ino, name string,
 		*dstIno = ino
 	}
 	var attr Attr
-	eno := m.en.doCloneEntry(ctx, srcIno, parent, name, ino, &attr, cmode, cumask, top)
+	eno = m.en.doCloneEntry(ctx, srcIno, parent, name, ino, &attr, cmode, cumask, top)
 	if eno != 0 {
 		return eno
 	}
@@ -3445,6 +3552,17 @@ func (m *baseMeta) cloneEntry(ctx Context, srcIno Ino, parent Ino, name string,
 	if eno = m.Access(ctx, srcIno, MODE_MASK_R|MODE_MASK_X, &attr); eno != 0 {
 		return eno
 	}
+	if attr.Flags&FlagImmutable != 0 {
+		// children can't be added into an immutable directory, set the flag after they are cloned
+		if eno = m.en.doSetAttr(ctx, ino, SetAttrFlag, 0, &Attr{Flags: attr.Flags &^ FlagImmutable}, nil); eno != 0 {
+			return eno
+		}
+		defer func() {
+			if eno == 0 {
+				eno = m.en.doSetAttr(ctx, ino, SetAttrFlag, 0, &Attr{Flags: attr.Flags}, nil)
+			}
+		}()
+	}
 	// Use DirHandler for batch processing to avoid loading all entries at once
 	handler, eno := m.NewDirHandler(ctx, srcIno, true, nil)
 	if eno == syscall.ENOENT {
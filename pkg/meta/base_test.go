@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"os"
 	"reflect"
@@ -38,6 +39,7 @@ import (
 	"time"
 
 	aclAPI "github.com/juicedata/juicefs/pkg/acl"
+	"github.com/juicedata/juicefs/pkg/object"
 	"github.com/juicedata/juicefs/pkg/utils"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
@@ -208,6 +210,12 @@ func testMeta(t *testing.T, m Meta) {
 	testBatchClone(t, m)
 	testACL(t, m)
 	testKerberosToken(t, m)
+	testSnapshot(t, m)
+	testChangelogConsumer(t, m)
+	testTierPolicy(t, m)
+	testDirCompression(t, m)
+	testCachePolicy(t, m)
+	testDedup(t, m)
 	base.conf.ReadOnly = true
 	testReadOnly(t, m)
 }
@@ -451,6 +459,541 @@ func testACL(t *testing.T, m Meta) {
 	assert.Equal(t, sz, m.getBase().aclCache.Size())
 }
 
+func testSnapshot(t *testing.T, m Meta) {
+	if err := m.Init(testFormat(), false); err != nil {
+		t.Fatalf("init: %s", err)
+	}
+	ctx := Background()
+	var src, file Ino
+	if st := m.Mkdir(ctx, RootInode, "snapSrc", 0777, 022, 0, &src, nil); st != 0 {
+		t.Fatalf("mkdir: %s", st)
+	}
+	if st := m.Mknod(ctx, src, "f", TypeFile, 0644, 022, 0, "", &file, nil); st != 0 {
+		t.Fatalf("mknod: %s", st)
+	}
+	var sliceId uint64
+	if st := m.NewSlice(ctx, &sliceId); st != 0 {
+		t.Fatalf("new slice: %s", st)
+	}
+	if st := m.Write(ctx, file, 0, 0, Slice{sliceId, 4096, 0, 4096}, time.Now()); st != 0 {
+		t.Fatalf("write: %s", st)
+	}
+
+	var count, total uint64
+	if st := m.CreateSnapshot(ctx, src, "s1", 2, &count, &total); st != 0 {
+		t.Fatalf("create snapshot: %s", st)
+	}
+	if st := m.CreateSnapshot(ctx, src, "s1", 2, &count, &total); st != syscall.EEXIST {
+		t.Fatalf("create existed snapshot should fail with EEXIST: %s", st)
+	}
+	snaps, st := m.ListSnapshots(ctx)
+	if st != 0 || len(snaps) != 1 || snaps[0].Name != "s1" || snaps[0].Source != src || snaps[0].Path != "/snapSrc" {
+		t.Fatalf("list snapshots: %+v %s", snaps, st)
+	}
+
+	var inode, snap, snapFile Ino
+	var attr Attr
+	if st := m.Lookup(ctx, RootInode, SnapshotName, &inode, &attr, false); st != 0 || inode != SnapshotInode {
+		t.Fatalf("lookup %s: %d %s", SnapshotName, inode, st)
+	}
+	if st := m.Lookup(ctx, SnapshotInode, "s1", &snap, &attr, false); st != 0 || snap != snaps[0].Inode {
+		t.Fatalf("lookup snapshot: %d %s", snap, st)
+	}
+	if ps := m.GetPaths(ctx, snap); len(ps) != 1 || ps[0] != "/"+SnapshotName+"/s1" {
+		t.Fatalf("paths of snapshot: %v", ps)
+	}
+	if st := m.Lookup(ctx, snap, "f", &snapFile, &attr, false); st != 0 || attr.Flags&FlagImmutable == 0 {
+		t.Fatalf("lookup file in snapshot: %s, flags %d", st, attr.Flags)
+	}
+	if st := m.Mknod(ctx, snap, "g", TypeFile, 0644, 022, 0, "", &inode, nil); st != syscall.EPERM {
+		t.Fatalf("mknod in snapshot should fail with EPERM: %s", st)
+	}
+	if st := m.Open(ctx, snapFile, syscall.O_RDWR, &attr); st != syscall.EPERM {
+		t.Fatalf("open snapshot file for write should fail with EPERM: %s", st)
+	}
+	if st := m.Unlink(ctx, snap, "f"); st != syscall.EPERM {
+		t.Fatalf("unlink in snapshot should fail with EPERM: %s", st)
+	}
+	if st := m.Rmdir(ctx, SnapshotInode, "s1"); st != syscall.EPERM {
+		t.Fatalf("rmdir snapshot should fail with EPERM: %s", st)
+	}
+	if st := m.Mkdir(ctx, SnapshotInode, "d", 0777, 022, 0, &inode, nil); st != syscall.EPERM {
+		t.Fatalf("mkdir in %s should fail with EPERM: %s", SnapshotName, st)
+	}
+	if st := m.Rename(ctx, RootInode, SnapshotName, RootInode, "x", 0, &inode, &attr); st != syscall.EPERM {
+		t.Fatalf("rename %s should fail with EPERM: %s", SnapshotName, st)
+	}
+
+	// the data is kept by the snapshot after the original file is removed
+	if st := m.Unlink(ctx, src, "f", true); st != 0 {
+		t.Fatalf("unlink: %s", st)
+	}
+	var slices []Slice
+	if st := m.Read(ctx, snapFile, 0, &slices); st != 0 || len(slices) != 1 || slices[0].Id != sliceId {
+		t.Fatalf("read snapshot file: %+v %s", slices, st)
+	}
+
+	// the root of snapshots has no entry, but it's not leaked
+	if st := m.getBase().en.doGetAttr(ctx, SnapshotInode, &attr); st != 0 {
+		t.Fatalf("getattr snapshot root: %s", st)
+	}
+	attr.Ctime = time.Now().Add(-time.Hour * 2).Unix()
+	if st := m.getBase().en.doRepair(ctx, SnapshotInode, &attr); st != 0 {
+		t.Fatalf("set ctime of snapshot root: %s", st)
+	}
+	if st := m.CleanupSlices(ctx); st != 0 {
+		t.Fatalf("cleanup slices: %s", st)
+	}
+	if st := m.Lookup(ctx, SnapshotInode, "s1", &inode, &attr, false); st != 0 || inode != snap {
+		t.Fatalf("lookup snapshot after gc: %d %s", inode, st)
+	}
+	if st := m.GetAttr(ctx, SnapshotInode, &attr); st != 0 {
+		t.Fatalf("getattr snapshot root after gc: %s", st)
+	}
+
+	if st := m.RestoreSnapshot(ctx, "s1", RootInode, "snapRestored", 2, &count, &total); st != 0 {
+		t.Fatalf("restore snapshot: %s", st)
+	}
+	var restored, restoredFile Ino
+	if st := m.Lookup(ctx, RootInode, "snapRestored", &restored, &attr, false); st != 0 || attr.Flags&FlagImmutable != 0 {
+		t.Fatalf("lookup restored: %s, flags %d", st, attr.Flags)
+	}
+	if st := m.Lookup(ctx, restored, "f", &restoredFile, &attr, false); st != 0 {
+		t.Fatalf("lookup restored file: %s", st)
+	}
+	if st := m.Open(ctx, restoredFile, syscall.O_RDWR, &attr); st != 0 {
+		t.Fatalf("open restored file: %s", st)
+	}
+	_ = m.Close(ctx, restoredFile)
+	if st := m.Mknod(ctx, restored, "g", TypeFile, 0644, 022, 0, "", &inode, nil); st != 0 {
+		t.Fatalf("mknod in restored dir: %s", st)
+	}
+
+	if st := m.DeleteSnapshot(ctx, "s1", &count); st != 0 {
+		t.Fatalf("delete snapshot: %s", st)
+	}
+	if st := m.DeleteSnapshot(ctx, "s1", &count); st != syscall.ENOENT {
+		t.Fatalf("delete removed snapshot should fail with ENOENT: %s", st)
+	}
+	if snaps, st = m.ListSnapshots(ctx); st != 0 || len(snaps) != 0 {
+		t.Fatalf("list snapshots: %+v %s", snaps, st)
+	}
+	if st := m.Lookup(ctx, SnapshotInode, "s1", &inode, &attr, false); st != syscall.ENOENT {
+		t.Fatalf("lookup removed snapshot: %s", st)
+	}
+	slices = nil
+	if st := m.Read(ctx, restoredFile, 0, &slices); st != 0 || len(slices) != 1 || slices[0].Id != sliceId {
+		t.Fatalf("read restored file: %+v %s", slices, st)
+	}
+}
+
+func testChangelogConsumer(t *testing.T, m Meta) {
+	format := testFormat()
+	format.ChangeLog = true
+	if err := m.Init(format, false); err != nil {
+		t.Fatalf("init: %s", err)
+	}
+	ctx := Background()
+	if st := m.AckChangelog(ctx, "", 1); st != syscall.EINVAL {
+		t.Fatalf("ack with empty group should fail with EINVAL: %s", st)
+	}
+	if st := m.AckChangelog(ctx, "g2", 20); st != 0 {
+		t.Fatalf("ack g2: %s", st)
+	}
+	if st := m.AckChangelog(ctx, "g1", 5); st != 0 {
+		t.Fatalf("ack g1: %s", st)
+	}
+	if st := m.AckChangelog(ctx, "g1", 10); st != 0 {
+		t.Fatalf("ack g1: %s", st)
+	}
+	cs, st := m.ListChangelogConsumers(ctx)
+	if st != 0 || len(cs) != 2 || cs[0].Group != "g1" || cs[0].Version != 10 || cs[1].Group != "g2" || cs[1].Version != 20 {
+		t.Fatalf("list consumers: %+v %s", cs, st)
+	}
+	if keep, st := m.getBase().changelogKeepVersion(ctx); st != 0 || keep != 10 {
+		t.Fatalf("keep version: %d %s", keep, st)
+	}
+	if st := m.RemoveChangelogConsumer(ctx, "g1"); st != 0 {
+		t.Fatalf("remove g1: %s", st)
+	}
+	if st := m.RemoveChangelogConsumer(ctx, "g1"); st != syscall.ENOENT {
+		t.Fatalf("remove removed group should fail with ENOENT: %s", st)
+	}
+	if keep, st := m.getBase().changelogKeepVersion(ctx); st != 0 || keep != 20 {
+		t.Fatalf("keep version: %d %s", keep, st)
+	}
+	if st := m.RemoveChangelogConsumer(ctx, "g2"); st != 0 {
+		t.Fatalf("remove g2: %s", st)
+	}
+	if keep, st := m.getBase().changelogKeepVersion(ctx); st != 0 || keep != math.MaxInt64 {
+		t.Fatalf("keep version without consumers: %d %s", keep, st)
+	}
+
+	// a new group starts from the latest version
+	var inode Ino
+	if st := m.Mkdir(ctx, RootInode, "changelogConsumer", 0777, 022, 0, &inode, nil); st != 0 {
+		t.Fatalf("mkdir: %s", st)
+	}
+	latest, st := m.ChangelogVersion(ctx)
+	if st != 0 || latest == 0 {
+		t.Fatalf("changelog version: %d %s", latest, st)
+	}
+	if last, err := changelogCursor(ctx, m, "g3"); err != nil || last != latest {
+		t.Fatalf("cursor of new group: %d %v, expect %d", last, err, latest)
+	}
+	if keep, st := m.getBase().changelogKeepVersion(ctx); st != 0 || keep != latest {
+		t.Fatalf("keep version of new group: %d %s", keep, st)
+	}
+	if st := m.RemoveChangelogConsumer(ctx, "g3"); st != 0 {
+		t.Fatalf("remove g3: %s", st)
+	}
+}
+
+func testTierPolicy(t *testing.T, m Meta) {
+	format := testFormat()
+	format.Tiers = object.Tiers{0: {}, 1: {ID: 1, Sc: "STANDARD_IA"}}
+	if err := m.Init(format, false); err != nil {
+		t.Fatalf("init: %s", err)
+	}
+	ctx := Background()
+	var dir, sub, inode Ino
+	if st := m.Mkdir(ctx, RootInode, "tierPolicy", 0777, 022, 0, &dir, nil); st != 0 {
+		t.Fatalf("mkdir: %s", st)
+	}
+	if st := m.Mkdir(ctx, dir, "sub", 0777, 022, 0, &sub, nil); st != 0 {
+		t.Fatalf("mkdir: %s", st)
+	}
+	now := time.Now().Unix()
+	files := map[string]int64{"new": 1, "idle": 40, "old": 400, "sub/f": 20}
+	for name, days := range files {
+		parent, fname := dir, name
+		if strings.HasPrefix(name, "sub/") {
+			parent, fname = sub, name[4:]
+		}
+		if st := m.Mknod(ctx, parent, fname, TypeFile, 0644, 022, 0, "", &inode, nil); st != 0 {
+			t.Fatalf("mknod %s: %s", name, st)
+		}
+		ts := now - days*86400
+		if st := m.SetAttr(ctx, inode, SetAttrAtime|SetAttrMtime, 0, &Attr{Atime: ts, Mtime: ts}); st != 0 {
+			t.Fatalf("setattr %s: %s", name, st)
+		}
+	}
+
+	if st := m.SetTierPolicy(ctx, dir, &TierPolicy{Rules: []TierRule{{Tier: 2, Days: 30}}}); st != syscall.EINVAL {
+		t.Fatalf("set policy with unknown tier should fail with EINVAL: %s", st)
+	}
+	if st := m.SetTierPolicy(ctx, dir, &TierPolicy{Rules: []TierRule{{Tier: 1, Days: 30}}, ExpireDays: 10}); st != syscall.EINVAL {
+		t.Fatalf("set policy expiring before transition should fail with EINVAL: %s", st)
+	}
+	if st := m.SetTierPolicy(ctx, inode, &TierPolicy{ExpireDays: 10}); st != syscall.ENOTDIR {
+		t.Fatalf("set policy to file should fail with ENOTDIR: %s", st)
+	}
+	if st := m.SetTierPolicy(ctx, dir, &TierPolicy{Rules: []TierRule{{Tier: 1, Days: 30}}, ExpireDays: 365}); st != 0 {
+		t.Fatalf("set policy: %s", st)
+	}
+	if st := m.SetTierPolicy(ctx, sub, &TierPolicy{ExpireDays: 10}); st != 0 {
+		t.Fatalf("set policy: %s", st)
+	}
+	ps, st := m.ListTierPolicies(ctx)
+	if st != 0 || len(ps) != 2 || ps[0].Inode != dir || ps[0].Path != "/tierPolicy" || ps[1].ExpireDays != 10 {
+		t.Fatalf("list policies: %+v %s", ps, st)
+	}
+
+	var stats TierPolicyStats
+	if st := m.ApplyTierPolicies(ctx, &stats); st != 0 {
+		t.Fatalf("apply policies: %s", st)
+	}
+	if stats.Scanned != 4 || stats.Transitioned != 1 || stats.Expired != 2 {
+		t.Fatalf("apply stats: %+v", stats)
+	}
+	var attr Attr
+	if st := m.Lookup(ctx, dir, "idle", &inode, &attr, false); st != 0 || attr.Tier != 1 {
+		t.Fatalf("lookup idle: tier %d %s", attr.Tier, st)
+	}
+	if st := m.Lookup(ctx, dir, "new", &inode, &attr, false); st != 0 || attr.Tier != 0 {
+		t.Fatalf("lookup new: tier %d %s", attr.Tier, st)
+	}
+	for _, name := range []string{"old", "sub/f"} {
+		parent, fname := dir, name
+		if strings.HasPrefix(name, "sub/") {
+			parent, fname = sub, name[4:]
+		}
+		if st := m.Lookup(ctx, parent, fname, &inode, &attr, false); st != syscall.ENOENT {
+			t.Fatalf("expired %s should be removed: %s", name, st)
+		}
+	}
+
+	// the tier is not changed until the data is rewritten
+	if st := m.Mknod(ctx, dir, "data", TypeFile, 0644, 022, 0, "", &inode, nil); st != 0 {
+		t.Fatalf("mknod data: %s", st)
+	}
+	var sliceId uint64
+	if st := m.NewSlice(ctx, &sliceId); st != 0 {
+		t.Fatalf("new slice: %s", st)
+	}
+	if st := m.Write(ctx, inode, 0, 0, Slice{Id: sliceId, Size: 100, Len: 100}, time.Now()); st != 0 {
+		t.Fatalf("write data: %s", st)
+	}
+	ts := now - 40*86400
+	if st := m.SetAttr(ctx, inode, SetAttrAtime|SetAttrMtime, 0, &Attr{Atime: ts, Mtime: ts}); st != 0 {
+		t.Fatalf("setattr data: %s", st)
+	}
+	var rewritten []uint8
+	m.OnMsg(CompactChunk, func(args ...interface{}) error {
+		rewritten = append(rewritten, args[2].(uint8))
+		if len(rewritten) == 1 {
+			return syscall.EIO
+		}
+		return nil
+	})
+	m.OnMsg(DeleteSlice, func(args ...interface{}) error { return nil })
+	stats = TierPolicyStats{}
+	if st := m.ApplyTierPolicies(ctx, &stats); st != 0 || stats.Transitioned != 0 {
+		t.Fatalf("apply policies: %s %+v", st, stats)
+	}
+	if st := m.GetAttr(ctx, inode, &attr); st != 0 || attr.Tier != 0 {
+		t.Fatalf("tier of data should not be changed if rewriting failed: %d %s", attr.Tier, st)
+	}
+	if st := m.ApplyTierPolicies(ctx, &stats); st != 0 || stats.Transitioned != 1 {
+		t.Fatalf("apply policies: %s %+v", st, stats)
+	}
+	if st := m.GetAttr(ctx, inode, &attr); st != 0 || attr.Tier != 1 || len(rewritten) != 2 || rewritten[1] != 1 {
+		t.Fatalf("data should be moved to tier 1: %d %v %s", attr.Tier, rewritten, st)
+	}
+
+	if st := m.DeleteTierPolicy(ctx, sub); st != 0 {
+		t.Fatalf("delete policy: %s", st)
+	}
+	if st := m.DeleteTierPolicy(ctx, sub); st != syscall.ENOENT {
+		t.Fatalf("delete deleted policy should fail with ENOENT: %s", st)
+	}
+	if st := m.Rmdir(ctx, dir, "sub"); st != 0 {
+		t.Fatalf("rmdir: %s", st)
+	}
+	var count uint64
+	if st := m.Remove(ctx, RootInode, "tierPolicy", false, RmrDefaultThreads, &count); st != 0 {
+		t.Fatalf("remove: %s", st)
+	}
+	// the policy of a removed directory is dropped
+	if st := m.ApplyTierPolicies(ctx, &stats); st != 0 {
+		t.Fatalf("apply policies: %s", st)
+	}
+	if ps, st = m.ListTierPolicies(ctx); st != 0 || len(ps) != 0 {
+		t.Fatalf("list policies: %+v %s", ps, st)
+	}
+}
+
+func testDirCompression(t *testing.T, m Meta) {
+	if err := m.Init(testFormat(), false); err != nil {
+		t.Fatalf("init: %s", err)
+	}
+	ctx := Background()
+	var dir, sub, inode Ino
+	if st := m.Mkdir(ctx, RootInode, "dirCompression", 0777, 022, 0, &dir, nil); st != 0 {
+		t.Fatalf("mkdir: %s", st)
+	}
+	if st := m.Mkdir(ctx, dir, "sub", 0777, 022, 0, &sub, nil); st != 0 {
+		t.Fatalf("mkdir: %s", st)
+	}
+	if st := m.Mknod(ctx, sub, "f", TypeFile, 0644, 022, 0, "", &inode, nil); st != 0 {
+		t.Fatalf("mknod: %s", st)
+	}
+	if algr := m.GetCompression(ctx, inode); algr != "" {
+		t.Fatalf("compression without setting: %q", algr)
+	}
+	if st := m.SetDirCompression(ctx, dir, "gzip"); st != syscall.EINVAL {
+		t.Fatalf("set unknown compression should fail with EINVAL: %s", st)
+	}
+	if st := m.SetDirCompression(ctx, inode, "zstd"); st != syscall.ENOTDIR {
+		t.Fatalf("set compression to file should fail with ENOTDIR: %s", st)
+	}
+	if st := m.SetDirCompression(ctx, dir, "zstd:9"); st != 0 {
+		t.Fatalf("set compression: %s", st)
+	}
+	if algr := m.GetCompression(ctx, inode); algr != "zstd:9" {
+		t.Fatalf("compression of file: %q", algr)
+	}
+	if st := m.SetDirCompression(ctx, sub, "none"); st != 0 {
+		t.Fatalf("set compression: %s", st)
+	}
+	if algr := m.GetCompression(ctx, inode); algr != "none" {
+		t.Fatalf("compression of file: %q", algr)
+	}
+	if algr := m.GetCompression(ctx, dir); algr != "zstd:9" {
+		t.Fatalf("compression of directory: %q", algr)
+	}
+	cs, st := m.ListDirCompressions(ctx)
+	if st != 0 || len(cs) != 2 || cs[dir] != "zstd:9" || cs[sub] != "none" {
+		t.Fatalf("list compressions: %+v %s", cs, st)
+	}
+
+	if st := m.DeleteDirCompression(ctx, sub); st != 0 {
+		t.Fatalf("delete compression: %s", st)
+	}
+	if st := m.DeleteDirCompression(ctx, sub); st != syscall.ENOENT {
+		t.Fatalf("delete deleted compression should fail with ENOENT: %s", st)
+	}
+	if algr := m.GetCompression(ctx, inode); algr != "zstd:9" {
+		t.Fatalf("compression of file: %q", algr)
+	}
+	if st := m.DeleteDirCompression(ctx, dir); st != 0 {
+		t.Fatalf("delete compression: %s", st)
+	}
+	if algr := m.GetCompression(ctx, inode); algr != "" {
+		t.Fatalf("compression after deleted: %q", algr)
+	}
+	var count uint64
+	if st := m.Remove(ctx, RootInode, "dirCompression", false, RmrDefaultThreads, &count); st != 0 {
+		t.Fatalf("remove: %s", st)
+	}
+}
+
+func testCachePolicy(t *testing.T, m Meta) {
+	ctx := Background()
+	var dir, sub, inode Ino
+	if st := m.Mkdir(ctx, RootInode, "cachePolicy", 0777, 022, 0, &dir, nil); st != 0 {
+		t.Fatalf("mkdir: %s", st)
+	}
+	if st := m.Mkdir(ctx, dir, "sub", 0777, 022, 0, &sub, nil); st != 0 {
+		t.Fatalf("mkdir: %s", st)
+	}
+	if st := m.Mknod(ctx, sub, "f", TypeFile, 0644, 022, 0, "", &inode, nil); st != 0 {
+		t.Fatalf("mknod: %s", st)
+	}
+	if p := m.GetCachePolicy(ctx, inode); p != "" {
+		t.Fatalf("cache policy without setting: %q", p)
+	}
+	// the cached policies are invalidated by the changes
+	if st := m.SetXattr(ctx, dir, CachePolicyXattr, []byte("cache=none"), 0); st != 0 {
+		t.Fatalf("set cache policy: %s", st)
+	}
+	if format, err := m.Load(false); err != nil || !format.CachePolicy {
+		t.Fatalf("cache policy should be enabled: %v", err)
+	}
+	if p := m.GetCachePolicy(ctx, inode); p != "cache=none" {
+		t.Fatalf("cache policy of file: %q", p)
+	}
+	if st := m.SetXattr(ctx, sub, CachePolicyXattr, []byte("readahead=1G"), 0); st != 0 {
+		t.Fatalf("set cache policy: %s", st)
+	}
+	if p := m.GetCachePolicy(ctx, inode); p != "readahead=1G" {
+		t.Fatalf("cache policy of file: %q", p)
+	}
+	if p := m.GetCachePolicy(ctx, dir); p != "cache=none" {
+		t.Fatalf("cache policy of directory: %q", p)
+	}
+	if st := m.RemoveXattr(ctx, sub, CachePolicyXattr); st != 0 {
+		t.Fatalf("remove cache policy: %s", st)
+	}
+	if p := m.GetCachePolicy(ctx, inode); p != "cache=none" {
+		t.Fatalf("cache policy of file: %q", p)
+	}
+	if st := m.RemoveXattr(ctx, dir, CachePolicyXattr); st != 0 {
+		t.Fatalf("remove cache policy: %s", st)
+	}
+	if p := m.GetCachePolicy(ctx, inode); p != "" {
+		t.Fatalf("cache policy after removed: %q", p)
+	}
+	var count uint64
+	if st := m.Remove(ctx, RootInode, "cachePolicy", false, RmrDefaultThreads, &count); st != 0 {
+		t.Fatalf("remove: %s", st)
+	}
+}
+
+func testDedup(t *testing.T, m Meta) {
+	ctx := Background()
+	a := DedupChunk{strings.Repeat("a", 64), 100}
+	b := DedupChunk{strings.Repeat("b", 64), 100}
+	adds := []struct {
+		id     uint64
+		indx   uint32
+		chunks []DedupChunk
+		exists []bool
+	}{
+		{1, 0, []DedupChunk{a, a}, []bool{false, false}},
+		{1, 1, []DedupChunk{b}, []bool{false}},
+		{2, 0, []DedupChunk{a, b}, []bool{true, true}},
+		{1, 0, []DedupChunk{a, a}, []bool{true, true}},
+	}
+	for _, ad := range adds {
+		if exists, st := m.AddDedupBlock(ctx, ad.id, ad.indx, ad.chunks); st != 0 || !reflect.DeepEqual(exists, ad.exists) {
+			t.Fatalf("add block %d:%d: exists %v %s", ad.id, ad.indx, exists, st)
+		}
+	}
+	if _, st := m.AddDedupBlock(ctx, 3, 0, nil); st != syscall.EINVAL {
+		t.Fatalf("add empty block: %s", st)
+	}
+	if chunks, st := m.GetDedupBlock(ctx, 1, 0); st != 0 || !reflect.DeepEqual(chunks, []DedupChunk{a, a}) {
+		t.Fatalf("get block 1:0: %v %s", chunks, st)
+	}
+	if chunks, st := m.GetDedupBlock(ctx, 3, 0); st != 0 || chunks != nil {
+		t.Fatalf("get missing block: %v %s", chunks, st)
+	}
+	stats, st := m.DedupStats(ctx)
+	if st != 0 || stats.Objects != 2 || stats.Refs != 5 || stats.Bytes != 200 || stats.RefBytes != 500 {
+		t.Fatalf("dedup stats: %+v %s", stats, st)
+	}
+
+	if chunks, st := m.RemoveDedupBlock(ctx, 1, 0); st != 0 || !reflect.DeepEqual(chunks, []DedupChunk{a, a}) {
+		t.Fatalf("remove block 1:0: %v %s", chunks, st)
+	}
+	if chunks, st := m.RemoveDedupBlock(ctx, 2, 0); st != 0 || !reflect.DeepEqual(chunks, []DedupChunk{a, b}) {
+		t.Fatalf("remove block 2:0: %v %s", chunks, st)
+	}
+	if chunks, st := m.RemoveDedupBlock(ctx, 1, 0); st != 0 || chunks != nil {
+		t.Fatalf("remove removed block: %v %s", chunks, st)
+	}
+	if chunks, st := m.GetDedupBlock(ctx, 1, 1); st != 0 || !reflect.DeepEqual(chunks, []DedupChunk{b}) {
+		t.Fatalf("get block 1:1: %v %s", chunks, st)
+	}
+	if stats, st = m.DedupStats(ctx); st != 0 || stats.Objects != 1 || stats.Freed != 1 || stats.FreedBytes != 100 {
+		t.Fatalf("dedup stats: %+v %s", stats, st)
+	}
+
+	var deleted []string
+	var delErr error
+	del := func(ref *DedupRef) error {
+		// the object being deleted can't be referenced again
+		if _, st := m.AddDedupBlock(ctx, 3, 0, []DedupChunk{a}); st != syscall.EAGAIN {
+			t.Fatalf("add block referencing deleting object: %s", st)
+		}
+		if delErr != nil {
+			return delErr
+		}
+		deleted = append(deleted, ref.Hash)
+		return nil
+	}
+	if n, st := m.CleanupDedupRefs(ctx, time.Now().Add(-time.Hour), del); st != 0 || n != 0 {
+		t.Fatalf("cleanup recently freed objects: %d %s", n, st)
+	}
+	delErr = errors.New("delete failed")
+	if n, st := m.CleanupDedupRefs(ctx, time.Now().Add(time.Second), del); st != 0 || n != 0 {
+		t.Fatalf("cleanup freed objects with failure: %d %s", n, st)
+	}
+	if _, st := m.AddDedupBlock(ctx, 3, 0, []DedupChunk{a}); st != syscall.EAGAIN {
+		t.Fatalf("add block referencing object failed to delete: %s", st)
+	}
+	delErr = nil
+	if n, st := m.CleanupDedupRefs(ctx, time.Now().Add(time.Second), del); st != 0 || n != 1 || deleted[0] != a.Hash {
+		t.Fatalf("cleanup freed objects: %d %v %s", n, deleted, st)
+	}
+	// the content is uploaded again
+	if exists, st := m.AddDedupBlock(ctx, 3, 0, []DedupChunk{a}); st != 0 || !reflect.DeepEqual(exists, []bool{false}) {
+		t.Fatalf("add block 3:0: exists %v %s", exists, st)
+	}
+	for _, bk := range [][2]uint64{{1, 1}, {3, 0}} {
+		if _, st := m.RemoveDedupBlock(ctx, bk[0], uint32(bk[1])); st != 0 {
+			t.Fatalf("remove block %d:%d: %s", bk[0], bk[1], st)
+		}
+	}
+	if _, st := m.CleanupDedupRefs(ctx, time.Now().Add(time.Second), del); st != 0 {
+		t.Fatalf("cleanup freed objects: %s", st)
+	}
+	if stats, st = m.DedupStats(ctx); st != 0 || *stats != (DedupStats{}) {
+		t.Fatalf("dedup stats: %+v %s", stats, st)
+	}
+}
+
 func testKerberosToken(t *testing.T, m Meta) {
 	type token struct {
 		User     string
@@ -0,0 +1,129 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"syscall"
+	"time"
+)
+
+// CachePolicyXattr is the extended attribute of directories holding their cache policy.
+const CachePolicyXattr = "user.juicefs.cache"
+
+// the policies changed by other clients are seen after this
+var dirPolicyTTL = time.Minute
+
+type dirPolicy struct {
+	policy string
+	parent Ino
+	expire time.Time
+}
+
+func (m *baseMeta) invalidateDirPolicy(inode Ino) {
+	m.policyMu.Lock()
+	delete(m.dirPolicies, inode)
+	m.policyMu.Unlock()
+}
+
+func (m *baseMeta) cleanupDirPolicies() {
+	now := time.Now()
+	m.policyMu.Lock()
+	for inode, p := range m.dirPolicies {
+		if now.After(p.expire) {
+			delete(m.dirPolicies, inode)
+		}
+	}
+	m.policyMu.Unlock()
+}
+
+// enableCachePolicy marks the volume as having cache policies, so the clients look up the policies
+// of directories only after any one is set.
+func (m *baseMeta) enableCachePolicy() error {
+	if m.getFormat().CachePolicy {
+		return nil
+	}
+	body, err := m.en.doLoad()
+	if err != nil {
+		return err
+	}
+	var format Format
+	if err = json.Unmarshal(body, &format); err != nil {
+		return fmt.Errorf("json: %s", err)
+	}
+	if format.CachePolicy {
+		return nil
+	}
+	format.CachePolicy = true
+	return m.en.doInit(&format, false)
+}
+
+func (m *baseMeta) getDirPolicy(ctx Context, inode Ino) (dirPolicy, syscall.Errno) {
+	now := time.Now()
+	m.policyMu.Lock()
+	p, ok := m.dirPolicies[inode]
+	m.policyMu.Unlock()
+	if ok && now.Before(p.expire) {
+		return p, 0
+	}
+	var value []byte
+	st := m.en.doGetXattr(ctx, inode, CachePolicyXattr, &value)
+	if st != 0 && st != ENOATTR {
+		return p, st
+	}
+	p = dirPolicy{policy: string(value), expire: now.Add(dirPolicyTTL)}
+	if inode != RootInode {
+		if p.parent, st = m.getDirParent(ctx, inode); st != 0 {
+			return p, st
+		}
+	}
+	m.policyMu.Lock()
+	m.dirPolicies[inode] = p
+	m.policyMu.Unlock()
+	return p, 0
+}
+
+// GetCachePolicy returns the cache policy of the nearest directory (of inode or its parents)
+// which has one, or empty string to follow the mount options.
+func (m *baseMeta) GetCachePolicy(ctx Context, inode Ino) string {
+	if !m.getFormat().CachePolicy {
+		return ""
+	}
+	var attr Attr
+	if st := m.GetAttr(ctx, inode, &attr); st != 0 {
+		return ""
+	}
+	if attr.Typ != TypeDirectory {
+		inode = attr.Parent // 0 for hard links
+	}
+	for inode >= RootInode {
+		p, st := m.getDirPolicy(ctx, inode)
+		if st != 0 {
+			logger.Warnf("Get cache policy of inode %d: %s", inode, st)
+			break
+		}
+		if p.policy != "" {
+			return p.policy
+		}
+		if inode == RootInode {
+			break
+		}
+		inode = p.parent
+	}
+	return ""
+}
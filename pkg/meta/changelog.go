@@ -0,0 +1,362 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ChangelogEntry is a parsed changelog entry, which looks like
+// "UNIX_SECONDS.NANOSECONDS|OPERATION(arguments)[:result]|(SESSION_ID,TXN_ID)".
+type ChangelogEntry struct {
+	Version int64
+	Time    time.Time
+	Op      string   // operation, such as CREATE, UNLINK or MOVE
+	Args    []string // raw arguments, names are unescaped
+	Result  string   // the part after ':', empty if there is none
+
+	// Fields extracted from the arguments, zero if the operation does not carry them.
+	Inode     Ino    // the inode changed or created by the operation
+	Parent    Ino    // the parent directory of Name
+	Name      string // the entry changed by the operation
+	NewParent Ino    // the destination directory of MOVE
+	NewName   string // the destination name of MOVE
+	Attr      *Attr  // attributes set by CREATE or SETATTR
+
+	Sid   uint64 // session that generated the entry
+	TxnId uint64 // transaction id within the session
+}
+
+func (e *ChangelogEntry) String() string {
+	s := fmt.Sprintf("%d: %s %s(%s)", e.Version, e.Time.Format(time.RFC3339Nano), e.Op, strings.Join(e.Args, ","))
+	if e.Result != "" {
+		s += ":" + e.Result
+	}
+	return s
+}
+
+func logDecode(s string) string {
+	return string(unescape(s))
+}
+
+// ParseChangelogEntry parses a raw entry returned by ScanChangelog.
+func ParseChangelogEntry(ver int64, entry string) (*ChangelogEntry, error) {
+	first, last := strings.IndexByte(entry, '|'), strings.LastIndexByte(entry, '|')
+	if first < 0 || last <= first {
+		return nil, fmt.Errorf("invalid changelog entry: %s", entry)
+	}
+	t, err := parseChangelogTime(entry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time in changelog entry %s: %s", entry, err)
+	}
+	e := &ChangelogEntry{Version: ver, Time: t}
+	if _, err = fmt.Sscanf(entry[last+1:], "(%d,%d)", &e.Sid, &e.TxnId); err != nil {
+		return nil, fmt.Errorf("invalid session in changelog entry %s: %s", entry, err)
+	}
+	op := entry[first+1 : last]
+	lp, rp := strings.IndexByte(op, '('), strings.IndexByte(op, ')')
+	if lp <= 0 || rp < lp {
+		return nil, fmt.Errorf("invalid operation in changelog entry: %s", entry)
+	}
+	e.Op = op[:lp]
+	if rp > lp+1 {
+		e.Args = strings.Split(op[lp+1:rp], ",")
+		for i, a := range e.Args {
+			e.Args[i] = logDecode(a)
+		}
+	}
+	if strings.HasPrefix(op[rp+1:], ":") {
+		e.Result = op[rp+2:]
+	}
+	e.fill()
+	return e, nil
+}
+
+func (e *ChangelogEntry) ino(i int) Ino {
+	return Ino(e.uint(i))
+}
+
+func (e *ChangelogEntry) uint(i int) uint64 {
+	if i >= len(e.Args) {
+		return 0
+	}
+	v, _ := strconv.ParseUint(e.Args[i], 10, 64)
+	return v
+}
+
+func (e *ChangelogEntry) arg(i int) string {
+	if i >= len(e.Args) {
+		return ""
+	}
+	return e.Args[i]
+}
+
+func (e *ChangelogEntry) fill() {
+	result, _ := strconv.ParseUint(e.Result, 10, 64)
+	switch e.Op {
+	case "CREATE": // parent,name,uid,gid,type,mode,cumask,path,behavior,updateParent:inode
+		e.Parent, e.Name, e.Inode = e.ino(0), e.arg(1), Ino(result)
+		e.Attr = &Attr{Uid: uint32(e.uint(2)), Gid: uint32(e.uint(3)), Typ: uint8(e.uint(4)), Mode: uint16(e.uint(5)), Parent: e.Parent}
+	case "UNLINK", "RMDIR": // parent,name,trash,...:inode
+		e.Parent, e.Name, e.Inode = e.ino(0), e.arg(1), Ino(result)
+	case "UNLINKBATCH": // parent,names...,trash,updateParent:inodes
+		e.Parent = e.ino(0)
+	case "MOVE": // parentSrc,nameSrc,parentDst,nameDst,flags,dino,trash:inode
+		e.Parent, e.Name, e.NewParent, e.NewName, e.Inode = e.ino(0), e.arg(1), e.ino(2), e.arg(3), Ino(result)
+	case "LINK", "ATTACH": // inode,parent,name,...
+		e.Inode, e.Parent, e.Name = e.ino(0), e.ino(1), e.arg(2)
+	case "CLONE": // srcIno,parent,name,ino,cmode,cumask,top:ino
+		e.Parent, e.Name, e.Inode = e.ino(1), e.arg(2), e.ino(3)
+	case "SETATTR": // inode,set,sugidclearmode,uid,gid,mode,flags,atime,mtime,atimensec,mtimensec,ctime,ctimensec,acl
+		e.Inode = e.ino(0)
+		e.Attr = &Attr{
+			Uid:       uint32(e.uint(3)),
+			Gid:       uint32(e.uint(4)),
+			Mode:      uint16(e.uint(5)),
+			Flags:     uint8(e.uint(6)),
+			Atime:     int64(e.uint(7)),
+			Mtime:     int64(e.uint(8)),
+			Atimensec: uint32(e.uint(9)),
+			Mtimensec: uint32(e.uint(10)),
+			Ctime:     int64(e.uint(11)),
+			Ctimensec: uint32(e.uint(12)),
+			AccessACL: uint32(e.uint(13)),
+		}
+	case "COPYFILERANGE": // fin,offIn,fout,offOut,size:length
+		e.Inode = e.ino(2)
+	case "SETXATTR", "REMOVEXATTR": // inode,name,...
+		e.Inode, e.Name = e.ino(0), e.arg(1)
+	case "WRITE", "TRUNCATE", "FALLOCATE", "ACCESS", "SETFACL", "CLEANUP", "REPAIRDIR", "DELCHUNK", "COMPACTCHUNK":
+		e.Inode = e.ino(0)
+	}
+}
+
+// ChangelogConsumer is a named consumer group of changelog, the entries after Version
+// are kept in the meta engine until they are acknowledged by all the groups.
+type ChangelogConsumer struct {
+	Group   string
+	Version int64 // last acknowledged version, or the latest one when the group was registered
+	Updated int64 // unix time of the last acknowledgement
+}
+
+func (m *baseMeta) AckChangelog(ctx Context, group string, version int64) syscall.Errno {
+	if group == "" || version < 0 {
+		return syscall.EINVAL
+	}
+	info, _ := json.Marshal(&ChangelogConsumer{Group: group, Version: version, Updated: time.Now().Unix()})
+	return m.en.doSaveChangelogConsumer(ctx, group, info)
+}
+
+func (m *baseMeta) ListChangelogConsumers(ctx Context) ([]*ChangelogConsumer, syscall.Errno) {
+	vals, st := m.en.doListChangelogConsumers(ctx)
+	if st != 0 {
+		return nil, st
+	}
+	cs := make([]*ChangelogConsumer, 0, len(vals))
+	for group, v := range vals {
+		var c ChangelogConsumer
+		if err := json.Unmarshal(v, &c); err != nil {
+			logger.Warnf("decode changelog consumer %s: %s", group, err)
+			continue
+		}
+		cs = append(cs, &c)
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].Group < cs[j].Group })
+	return cs, 0
+}
+
+func (m *baseMeta) ChangelogVersion(ctx Context) (int64, syscall.Errno) {
+	return m.en.doGetChangelogVersion(ctx)
+}
+
+func (m *baseMeta) RemoveChangelogConsumer(ctx Context, group string) syscall.Errno {
+	return m.en.doDeleteChangelogConsumer(ctx, group)
+}
+
+// changelogKeepVersion returns the version acknowledged by the slowest consumer group,
+// the entries after it should be kept.
+func (m *baseMeta) changelogKeepVersion(ctx Context) (int64, syscall.Errno) {
+	cs, st := m.ListChangelogConsumers(ctx)
+	if st != 0 {
+		return 0, st
+	}
+	var keep int64 = math.MaxInt64
+	for _, c := range cs {
+		if c.Version < keep {
+			keep = c.Version
+		}
+	}
+	return keep, 0
+}
+
+// changelogCursor returns the last acknowledged version of group. A new group is registered with
+// the latest version before the first entry, so nothing after it will be cleaned up in between.
+func changelogCursor(ctx Context, m Meta, group string) (int64, error) {
+	cs, st := m.ListChangelogConsumers(ctx)
+	if st != 0 {
+		return 0, st
+	}
+	for _, c := range cs {
+		if c.Group == group {
+			return c.Version, nil
+		}
+	}
+	last, st := m.ChangelogVersion(ctx)
+	if st != 0 {
+		return 0, st
+	}
+	if st = m.AckChangelog(ctx, group, last); st != 0 {
+		return 0, st
+	}
+	return last, nil
+}
+
+// ConsumeChangelog feeds the changelog entries after the cursor of group to handler, and acknowledges
+// the handled ones at most once per second. A new group starts from the latest version.
+// It keeps running until the context is canceled or handler returns an error.
+func ConsumeChangelog(ctx Context, m Meta, group string, handler func(e *ChangelogEntry) error) error {
+	last, err := changelogCursor(ctx, m, group)
+	if err != nil {
+		return err
+	}
+	var acked = last
+	var lastAck time.Time
+	ack := func() error {
+		if last > acked {
+			// the context may be canceled already
+			if st := m.AckChangelog(Background(), group, last); st != 0 {
+				return st
+			}
+			acked = last
+		}
+		lastAck = time.Now()
+		return nil
+	}
+	defer func() {
+		if err := ack(); err != nil {
+			logger.Warnf("acknowledge changelog %d of %s: %s", last, group, err)
+		}
+	}()
+	return m.ScanChangelog(ctx, last, func(ver int64, entry string) error {
+		if ver <= last { // rewind of TKV
+			return nil
+		}
+		e, err := ParseChangelogEntry(ver, entry)
+		if err != nil {
+			logger.Warnf("skip changelog %d: %s", ver, err)
+		} else if err = handler(e); err != nil {
+			return err
+		}
+		last = ver
+		if time.Since(lastAck) > time.Second {
+			return ack()
+		}
+		return nil
+	})
+}
+
+// ConsumeChangelogBatch is like ConsumeChangelog, but feeds the entries to handler in batches of at most
+// size entries, or the ones collected within interval (one second by default), and acknowledges a batch
+// after handler returns.
+// The scanning is blocked while handler is running, so a slow handler will not be overrun.
+func ConsumeChangelogBatch(ctx Context, m Meta, group string, size int, interval time.Duration, handler func(es []*ChangelogEntry) error) error {
+	if size <= 0 {
+		size = 1
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	last, err := changelogCursor(ctx, m, group)
+	if err != nil {
+		return err
+	}
+	sctx := WrapWithCancel(ctx, ctx.Pid(), ctx.Uid(), ctx.Gids())
+	entries := make(chan *ChangelogEntry, size)
+	done := make(chan struct{})
+	var flushErr error
+	go func() {
+		defer close(done)
+		var batch []*ChangelogEntry
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			if err := handler(batch); err != nil {
+				return err
+			}
+			ver := batch[len(batch)-1].Version
+			batch = nil
+			if st := m.AckChangelog(Background(), group, ver); st != 0 {
+				return fmt.Errorf("acknowledge changelog %d of %s: %s", ver, group, st)
+			}
+			return nil
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case e, ok := <-entries:
+				if ok {
+					batch = append(batch, e)
+					if len(batch) < size {
+						continue
+					}
+				}
+				if flushErr = flush(); flushErr != nil || !ok {
+					sctx.Cancel()
+					return
+				}
+			case <-ticker.C:
+				if flushErr = flush(); flushErr != nil {
+					sctx.Cancel()
+					return
+				}
+			}
+		}
+	}()
+	err = m.ScanChangelog(sctx, last, func(ver int64, entry string) error {
+		if ver <= last { // rewind of TKV
+			return nil
+		}
+		e, err := ParseChangelogEntry(ver, entry)
+		if err != nil {
+			logger.Warnf("skip changelog %d: %s", ver, err)
+			return nil
+		}
+		select {
+		case entries <- e:
+			last = ver
+			return nil
+		case <-done:
+			return context.Canceled
+		}
+	})
+	close(entries)
+	<-done
+	if flushErr != nil {
+		return flushErr
+	}
+	return err
+}
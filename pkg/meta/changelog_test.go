@@ -0,0 +1,58 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meta
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChangelogEntry(t *testing.T) {
+	e, err := ParseChangelogEntry(3, "1700000000.000000123|CREATE(1,a%2Cb,0,0,1,420,18,,0,1):5|(2,7)")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), e.Version)
+	assert.Equal(t, int64(1700000000000000123), e.Time.UnixNano())
+	assert.Equal(t, "CREATE", e.Op)
+	assert.Equal(t, "5", e.Result)
+	assert.Equal(t, Ino(1), e.Parent)
+	assert.Equal(t, "a,b", e.Name)
+	assert.Equal(t, Ino(5), e.Inode)
+	assert.Equal(t, uint8(TypeFile), e.Attr.Typ)
+	assert.Equal(t, uint16(420), e.Attr.Mode)
+	assert.Equal(t, uint64(2), e.Sid)
+	assert.Equal(t, uint64(7), e.TxnId)
+
+	e, err = ParseChangelogEntry(4, "1700000000.000000000|MOVE(1,a,2,b,0,0,0):5|(2,8)")
+	assert.Nil(t, err)
+	assert.Equal(t, Ino(1), e.Parent)
+	assert.Equal(t, "a", e.Name)
+	assert.Equal(t, Ino(2), e.NewParent)
+	assert.Equal(t, "b", e.NewName)
+	assert.Equal(t, Ino(5), e.Inode)
+
+	e, err = ParseChangelogEntry(5, "1700000000.000000000|SETATTR(5,2,0,0,0,493,0,0,0,0,0,0,0,0)|(2,9)")
+	assert.Nil(t, err)
+	assert.Equal(t, Ino(5), e.Inode)
+	assert.Equal(t, uint16(493), e.Attr.Mode)
+	assert.Equal(t, "", e.Result)
+
+	for _, s := range []string{"", "1700000000.0|CREATE(1,a)", "abc|CREATE(1,a)|(1,1)", "1700000000.0|CREATE|(1,1)"} {
+		_, err = ParseChangelogEntry(1, s)
+		assert.NotNil(t, err, s)
+	}
+}
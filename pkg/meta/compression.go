@@ -0,0 +1,115 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meta
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/compress"
+)
+
+func (m *baseMeta) loadDirCompressions() {
+	cs, st := m.en.doListDirCompressions(Background())
+	if st != 0 {
+		logger.Warnf("Load compression of directories: %s", st)
+		return
+	}
+	m.compressMu.Lock()
+	m.dirCompress = cs
+	m.compressMu.Unlock()
+}
+
+func (m *baseMeta) SetDirCompression(ctx Context, inode Ino, algr string) syscall.Errno {
+	if m.conf.ReadOnly {
+		return syscall.EROFS
+	}
+	defer m.timeit("SetDirCompression", time.Now())
+	if compress.NewCompressor(algr) == nil {
+		return syscall.EINVAL
+	}
+	inode = m.checkRoot(inode)
+	var attr Attr
+	if st := m.GetAttr(ctx, inode, &attr); st != 0 {
+		return st
+	}
+	if attr.Typ != TypeDirectory {
+		return syscall.ENOTDIR
+	}
+	if st := m.en.doSetDirCompression(ctx, inode, algr); st != 0 {
+		return st
+	}
+	m.compressMu.Lock()
+	m.dirCompress[inode] = algr
+	m.compressMu.Unlock()
+	return 0
+}
+
+func (m *baseMeta) DeleteDirCompression(ctx Context, inode Ino) syscall.Errno {
+	if m.conf.ReadOnly {
+		return syscall.EROFS
+	}
+	defer m.timeit("DeleteDirCompression", time.Now())
+	inode = m.checkRoot(inode)
+	if st := m.en.doDeleteDirCompression(ctx, inode); st != 0 {
+		return st
+	}
+	m.compressMu.Lock()
+	delete(m.dirCompress, inode)
+	m.compressMu.Unlock()
+	return 0
+}
+
+func (m *baseMeta) ListDirCompressions(ctx Context) (map[Ino]string, syscall.Errno) {
+	return m.en.doListDirCompressions(ctx)
+}
+
+// GetCompression returns the compression of the nearest directory (of inode or its parents)
+// which has one, or empty string for the default one of volume.
+func (m *baseMeta) GetCompression(ctx Context, inode Ino) string {
+	m.compressMu.RLock()
+	n := len(m.dirCompress)
+	m.compressMu.RUnlock()
+	if n == 0 {
+		return ""
+	}
+	var attr Attr
+	if st := m.GetAttr(ctx, inode, &attr); st != 0 {
+		return ""
+	}
+	if attr.Typ != TypeDirectory {
+		inode = attr.Parent // 0 for hard links
+	}
+	for inode >= RootInode {
+		m.compressMu.RLock()
+		algr, ok := m.dirCompress[inode]
+		m.compressMu.RUnlock()
+		if ok {
+			return algr
+		}
+		if inode == RootInode {
+			break
+		}
+		lastInode := inode
+		var st syscall.Errno
+		if inode, st = m.getDirParent(ctx, inode); st != 0 {
+			logger.Warnf("Get directory parent of inode %d: %s", lastInode, st)
+			break
+		}
+	}
+	return ""
+}
@@ -22,6 +22,7 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -54,6 +55,8 @@ type Config struct {
 	SortDir            bool
 	FastStatfs         bool
 	NetworkInterfaces  []string // list of network interfaces to use for IP discovery (empty means all)
+	CacheGroup         string
+	GroupAddr          string // address serving the other members of cache group
 }
 
 func DefaultConf() *Config {
@@ -74,6 +77,30 @@ func (c *Config) SelfCheck() {
 	}
 }
 
+// Replica is an object storage keeping a copy of all the data.
+type Replica struct {
+	Storage      string
+	Bucket       string
+	AccessKey    string `json:",omitempty"`
+	SecretKey    string `json:",omitempty"`
+	SessionToken string `json:",omitempty"`
+}
+
+// RetiredKey is a private key replaced by key rotation, which is kept to read the objects
+// encrypted before the rotation.
+type RetiredKey struct {
+	Key  string
+	Algo string `json:",omitempty"`
+}
+
+// PendingKey is a new private key added by key rotation, which is only used to read the objects
+// until the clients have loaded it, then it replaces the current key.
+type PendingKey struct {
+	Key   string
+	Algo  string `json:",omitempty"`
+	Added int64  // when it's added, in seconds
+}
+
 type Format struct {
 	Name              string
 	UUID              string
@@ -86,8 +113,13 @@ type Format struct {
 	SessionToken      string `json:",omitempty"`
 	BlockSize         int
 	Compression       string `json:",omitempty"`
+	UntaggedCompress  string `json:",omitempty"` // compression of the blocks without codec tag
 	Shards            int    `json:",omitempty"`
+	Erasure           string `json:",omitempty"` // K+M, data shards and parity shards of blocks
 	HashPrefix        bool   `json:",omitempty"`
+	Dedup             bool   `json:",omitempty"` // blocks with the same content are stored once
+	DedupSecret       string `json:",omitempty"` // key of the HMAC of deduplicated chunks
+	CachePolicy       bool   `json:",omitempty"` // some directories have their own cache policy
 	Capacity          uint64 `json:",omitempty"`
 	Inodes            uint64 `json:",omitempty"`
 	EncryptKey        string `json:",omitempty"`
@@ -107,9 +139,19 @@ type Format struct {
 	ChangeLog         bool   `json:",omitempty"`
 	ChangeLogMaxAge   int64  `json:",omitempty"`
 	ChangeLogMaxLines int64  `json:",omitempty"`
+	ChangeLogForceAge int64  `json:",omitempty"`
 
 	//kerberos
 	KerbConf string `json:",omitempty"`
+
+	//replicas
+	Replicas       []Replica `json:",omitempty"`
+	ReplicaAsync   bool      `json:",omitempty"` // replicate the writes in background
+	ReplicaTimeout int       `json:",omitempty"` // latency budget of the primary storage in milliseconds
+
+	//key rotation
+	PendingKey  *PendingKey  `json:",omitempty"`
+	RetiredKeys []RetiredKey `json:",omitempty"`
 }
 
 func (f *Format) update(old *Format, force bool) error {
@@ -122,10 +164,16 @@ func (f *Format) update(old *Format, force bool) error {
 			args = []interface{}{"name", old.Name, f.Name}
 		case f.BlockSize != old.BlockSize:
 			args = []interface{}{"block size", old.BlockSize, f.BlockSize}
-		case f.Compression != old.Compression:
+		case f.Compression != old.Compression && f.UntaggedCompress == "":
 			args = []interface{}{"compression", old.Compression, f.Compression}
+		case old.UntaggedCompress != "" && f.UntaggedCompress != old.UntaggedCompress:
+			args = []interface{}{"untagged compression", old.UntaggedCompress, f.UntaggedCompress}
+		case old.Dedup && !f.Dedup:
+			args = []interface{}{"dedup", old.Dedup, f.Dedup}
 		case f.Shards != old.Shards:
 			args = []interface{}{"shards", old.Shards, f.Shards}
+		case f.Erasure != old.Erasure:
+			args = []interface{}{"erasure", old.Erasure, f.Erasure}
 		case f.HashPrefix != old.HashPrefix:
 			args = []interface{}{"hash prefix", old.HashPrefix, f.HashPrefix}
 		case f.MetaVersion != old.MetaVersion:
@@ -148,6 +196,20 @@ func (f *Format) update(old *Format, force bool) error {
 	return nil
 }
 
+// EnableCompressionTag makes the new blocks tagged with their codec unless it's the original one,
+// which is required before changing the compression of the volume or any directory.
+// It returns false if it was enabled already.
+func (f *Format) EnableCompressionTag() bool {
+	if f.UntaggedCompress != "" {
+		return false
+	}
+	f.UntaggedCompress = f.Compression
+	if f.UntaggedCompress == "" {
+		f.UntaggedCompress = "none"
+	}
+	return true
+}
+
 func (f *Format) RemoveSecret() {
 	if f.SecretKey != "" {
 		f.SecretKey = "removed"
@@ -158,6 +220,27 @@ func (f *Format) RemoveSecret() {
 	if f.EncryptKey != "" {
 		f.EncryptKey = "removed"
 	}
+	if f.DedupSecret != "" {
+		f.DedupSecret = "removed"
+	}
+	f.Replicas = append([]Replica(nil), f.Replicas...) // don't change the copied ones
+	for i := range f.Replicas {
+		if f.Replicas[i].SecretKey != "" {
+			f.Replicas[i].SecretKey = "removed"
+		}
+		if f.Replicas[i].SessionToken != "" {
+			f.Replicas[i].SessionToken = "removed"
+		}
+	}
+	f.RetiredKeys = append([]RetiredKey(nil), f.RetiredKeys...)
+	for i := range f.RetiredKeys {
+		f.RetiredKeys[i].Key = "removed"
+	}
+	if f.PendingKey != nil {
+		pk := *f.PendingKey
+		pk.Key = "removed"
+		f.PendingKey = &pk
+	}
 }
 
 func (f *Format) String() string {
@@ -230,8 +313,25 @@ func newCipher(algo string, key string) (cipher.AEAD, error) {
 	}
 }
 
+// NewDedupSecret sets a random key for the HMAC of deduplicated chunks, the secrets should be
+// decrypted before it.
+func (f *Format) NewDedupSecret() {
+	buf := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, buf); err != nil {
+		logger.Fatalf("generate dedup secret: %s", err)
+	}
+	f.DedupSecret = hex.EncodeToString(buf)
+}
+
+// PlainDedupSecret returns the decrypted key of the HMAC of deduplicated chunks.
+func (f *Format) PlainDedupSecret() (string, error) {
+	c := Format{UUID: f.UUID, EncryptAlgo: f.EncryptAlgo, KeyEncrypted: f.KeyEncrypted, DedupSecret: f.DedupSecret}
+	err := c.Decrypt()
+	return c.DedupSecret, err
+}
+
 func (f *Format) Encrypt() error {
-	if f.KeyEncrypted || f.SecretKey == "" && f.EncryptKey == "" && f.SessionToken == "" {
+	if f.KeyEncrypted || f.SecretKey == "" && f.EncryptKey == "" && f.SessionToken == "" && f.DedupSecret == "" && len(f.Replicas) == 0 && len(f.RetiredKeys) == 0 && f.PendingKey == nil {
 		return nil
 	}
 	ci, err := newCipher(f.EncryptAlgo, f.UUID)
@@ -256,6 +356,21 @@ func (f *Format) Encrypt() error {
 	encrypt(&f.SecretKey)
 	encrypt(&f.SessionToken)
 	encrypt(&f.EncryptKey)
+	encrypt(&f.DedupSecret)
+	f.Replicas = append([]Replica(nil), f.Replicas...)
+	for i := range f.Replicas {
+		encrypt(&f.Replicas[i].SecretKey)
+		encrypt(&f.Replicas[i].SessionToken)
+	}
+	f.RetiredKeys = append([]RetiredKey(nil), f.RetiredKeys...)
+	for i := range f.RetiredKeys {
+		encrypt(&f.RetiredKeys[i].Key)
+	}
+	if f.PendingKey != nil {
+		pk := *f.PendingKey
+		encrypt(&pk.Key)
+		f.PendingKey = &pk
+	}
 	f.KeyEncrypted = true
 	return nil
 }
@@ -293,6 +408,21 @@ func (f *Format) Decrypt() error {
 	decrypt(&f.EncryptKey)
 	decrypt(&f.SecretKey)
 	decrypt(&f.SessionToken)
+	decrypt(&f.DedupSecret)
+	f.Replicas = append([]Replica(nil), f.Replicas...)
+	for i := range f.Replicas {
+		decrypt(&f.Replicas[i].SecretKey)
+		decrypt(&f.Replicas[i].SessionToken)
+	}
+	f.RetiredKeys = append([]RetiredKey(nil), f.RetiredKeys...)
+	for i := range f.RetiredKeys {
+		decrypt(&f.RetiredKeys[i].Key)
+	}
+	if f.PendingKey != nil {
+		pk := *f.PendingKey
+		decrypt(&pk.Key)
+		f.PendingKey = &pk
+	}
 	f.KeyEncrypted = false
 	return err
 }
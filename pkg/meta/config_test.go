@@ -25,13 +25,13 @@ import (
 )
 
 func TestRemoveSecret(t *testing.T) {
-	format := Format{Name: "test", SecretKey: "testSecret", EncryptKey: "testEncrypt", SessionToken: "token"}
+	format := Format{Name: "test", SecretKey: "testSecret", EncryptKey: "testEncrypt", SessionToken: "token", DedupSecret: "dedup"}
 	if err := format.Encrypt(); err != nil {
 		t.Fatal(err)
 	}
 
 	format.RemoveSecret()
-	if format.SecretKey != "removed" || format.EncryptKey != "removed" || format.SessionToken != "removed" {
+	if format.SecretKey != "removed" || format.EncryptKey != "removed" || format.SessionToken != "removed" || format.DedupSecret != "removed" {
 		t.Fatalf("invalid format: %+v", format)
 	}
 
@@ -48,26 +48,66 @@ func TestEncrypt(t *testing.T) {
 		{object.CHACHA20_RSA},
 		{object.SM4GCM},
 	}
-	format := Format{Name: "test", SecretKey: "testSecret", SessionToken: "token", EncryptKey: "testEncrypt"}
+	format := Format{Name: "test", SecretKey: "testSecret", SessionToken: "token", EncryptKey: "testEncrypt", DedupSecret: "dedup"}
 	for _, c := range cases {
 		format.EncryptAlgo = c.algo
 		t.Run(c.algo, func(t *testing.T) {
 			if err := format.Encrypt(); err != nil {
 				t.Fatalf("Format encrypt: %s", err)
 			}
-			if format.SecretKey == "testSecret" || format.SessionToken == "token" || format.EncryptKey == "testEncrypt" {
+			if format.SecretKey == "testSecret" || format.SessionToken == "token" || format.EncryptKey == "testEncrypt" || format.DedupSecret == "dedup" {
 				t.Fatalf("invalid format: %+v", format)
 			}
 			if err := format.Decrypt(); err != nil {
 				t.Fatalf("Format decrypt: %s", err)
 			}
-			if format.SecretKey != "testSecret" || format.SessionToken != "token" || format.EncryptKey != "testEncrypt" {
+			if format.SecretKey != "testSecret" || format.SessionToken != "token" || format.EncryptKey != "testEncrypt" || format.DedupSecret != "dedup" {
 				t.Fatalf("invalid format: %+v", format)
 			}
 		})
 	}
 }
 
+func TestEncryptReplicas(t *testing.T) {
+	format := Format{Name: "test", Replicas: []Replica{{Storage: "s3", Bucket: "b", SecretKey: "replicaSecret"}}}
+	if err := format.Encrypt(); err != nil {
+		t.Fatalf("Format encrypt: %s", err)
+	}
+	if format.Replicas[0].SecretKey == "replicaSecret" {
+		t.Fatalf("secret of replica is not encrypted: %+v", format)
+	}
+	copied := format
+	if err := copied.Decrypt(); err != nil {
+		t.Fatalf("Format decrypt: %s", err)
+	}
+	if copied.Replicas[0].SecretKey != "replicaSecret" || format.Replicas[0].SecretKey == "replicaSecret" {
+		t.Fatalf("invalid format: %+v %+v", copied, format)
+	}
+	if format.RemoveSecret(); format.Replicas[0].SecretKey != "removed" || copied.Replicas[0].SecretKey != "replicaSecret" {
+		t.Fatalf("invalid format: %+v %+v", copied, format)
+	}
+}
+
+func TestEncryptRetiredKeys(t *testing.T) {
+	format := Format{Name: "test", EncryptKey: "current", RetiredKeys: []RetiredKey{{Key: "retired"}}, PendingKey: &PendingKey{Key: "pending"}}
+	if err := format.Encrypt(); err != nil {
+		t.Fatalf("Format encrypt: %s", err)
+	}
+	copied := format
+	if err := copied.Decrypt(); err != nil {
+		t.Fatalf("Format decrypt: %s", err)
+	}
+	if copied.RetiredKeys[0].Key != "retired" || format.RetiredKeys[0].Key == "retired" {
+		t.Fatalf("invalid format: %+v %+v", copied, format)
+	}
+	if copied.PendingKey.Key != "pending" || format.PendingKey.Key == "pending" {
+		t.Fatalf("invalid pending key: %+v %+v", copied.PendingKey, format.PendingKey)
+	}
+	if copied.RemoveSecret(); copied.RetiredKeys[0].Key != "removed" || copied.PendingKey.Key != "removed" {
+		t.Fatalf("retired key is not removed: %+v", copied)
+	}
+}
+
 func TestFormat_Update_KeyConflict(t *testing.T) {
 	oldFormat := Format{Name: "test", UUID: "UUID-A"}
 
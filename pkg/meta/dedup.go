@@ -0,0 +1,280 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meta
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/meta/pb"
+)
+
+// DedupChunk is a chunk of block, which is stored in the object addressed by its content.
+type DedupChunk struct {
+	Hash string // hex encoded SHA-256 of the chunk
+	Size uint32
+}
+
+// DedupRef is an object shared by the chunks with the same content.
+type DedupRef struct {
+	Hash  string
+	Size  uint32
+	Refs  int64
+	Freed int64 // when the last reference was dropped (in seconds), 0 for referenced ones, -1 for the ones being deleted
+}
+
+// dedupDeleting marks the objects being deleted by gc, which can't be referenced any more.
+const dedupDeleting = -1
+
+// DedupStats is the statistics of deduplicated objects.
+type DedupStats struct {
+	Objects    int64  // number of referenced objects
+	Bytes      uint64 // size of referenced objects
+	Refs       int64  // number of chunks referencing the objects
+	RefBytes   uint64 // size of chunks referencing the objects
+	Freed      int64  // number of objects waiting to be deleted by gc
+	FreedBytes uint64
+}
+
+// dedupRefName is the name of a reference in Redis, like the slice refs.
+func dedupRefName(hash string, size uint32) string {
+	return fmt.Sprintf("%s_%d", hash, size)
+}
+
+func parseDedupRefName(name string) (string, uint32, bool) {
+	p := strings.LastIndexByte(name, '_')
+	if p < 0 {
+		return "", 0, false
+	}
+	size, err := strconv.ParseUint(name[p+1:], 10, 32)
+	return name[:p], uint32(size), err == nil
+}
+
+// encodeDedupChunks encodes the chunks of a block as the names of their objects, separated by comma.
+func encodeDedupChunks(chunks []DedupChunk) string {
+	names := make([]string, len(chunks))
+	for i, c := range chunks {
+		names[i] = dedupRefName(c.Hash, c.Size)
+	}
+	return strings.Join(names, ",")
+}
+
+func parseDedupChunks(s string) []DedupChunk {
+	if s == "" {
+		return nil
+	}
+	names := strings.Split(s, ",")
+	chunks := make([]DedupChunk, 0, len(names))
+	for _, name := range names {
+		hash, size, ok := parseDedupRefName(name)
+		if !ok {
+			logger.Errorf("invalid dedup chunk: %s", name)
+			return nil
+		}
+		chunks = append(chunks, DedupChunk{hash, size})
+	}
+	return chunks
+}
+
+// dedupRefCounts returns the number of references to each object from the chunks of a block, the same
+// content may appear more than once in a block.
+func dedupRefCounts(chunks []DedupChunk) map[string]int64 {
+	counts := make(map[string]int64, len(chunks))
+	for _, c := range chunks {
+		counts[dedupRefName(c.Hash, c.Size)]++
+	}
+	return counts
+}
+
+func (m *baseMeta) AddDedupBlock(ctx Context, id uint64, indx uint32, chunks []DedupChunk) ([]bool, syscall.Errno) {
+	if m.conf.ReadOnly {
+		return nil, syscall.EROFS
+	}
+	if len(chunks) == 0 {
+		return nil, syscall.EINVAL
+	}
+	defer m.timeit("AddDedupBlock", time.Now())
+	return m.en.doAddDedupBlock(ctx, id, indx, chunks)
+}
+
+func (m *baseMeta) GetDedupBlock(ctx Context, id uint64, indx uint32) ([]DedupChunk, syscall.Errno) {
+	defer m.timeit("GetDedupBlock", time.Now())
+	return m.en.doGetDedupBlock(ctx, id, indx)
+}
+
+func (m *baseMeta) RemoveDedupBlock(ctx Context, id uint64, indx uint32) ([]DedupChunk, syscall.Errno) {
+	defer m.timeit("RemoveDedupBlock", time.Now())
+	return m.en.doRemoveDedupBlock(ctx, id, indx)
+}
+
+func (m *baseMeta) ScanDedupRefs(ctx Context, scan func(ref *DedupRef) bool) syscall.Errno {
+	return m.en.doScanDedupRefs(ctx, scan)
+}
+
+func (m *baseMeta) DedupStats(ctx Context) (*DedupStats, syscall.Errno) {
+	var stats DedupStats
+	st := m.en.doScanDedupRefs(ctx, func(ref *DedupRef) bool {
+		if ref.Refs > 0 {
+			stats.Objects++
+			stats.Bytes += uint64(ref.Size)
+			stats.Refs += ref.Refs
+			stats.RefBytes += uint64(ref.Refs) * uint64(ref.Size)
+		} else {
+			stats.Freed++
+			stats.FreedBytes += uint64(ref.Size)
+		}
+		return true
+	})
+	return &stats, st
+}
+
+// CleanupDedupRefs removes the objects not referenced since before. The references are marked as
+// being deleted before calling delete, so they can't be referenced again, and they are removed
+// after the objects are deleted.
+func (m *baseMeta) CleanupDedupRefs(ctx Context, before time.Time, delete func(ref *DedupRef) error) (int64, syscall.Errno) {
+	if m.conf.ReadOnly {
+		return 0, syscall.EROFS
+	}
+	defer m.timeit("CleanupDedupRefs", time.Now())
+	var freed []*DedupRef
+	st := m.en.doScanDedupRefs(ctx, func(ref *DedupRef) bool {
+		if ref.Refs <= 0 && ref.Freed < before.Unix() {
+			freed = append(freed, ref)
+		}
+		return true
+	})
+	if st != 0 {
+		return 0, st
+	}
+	var count int64
+	for _, ref := range freed {
+		if ctx.Canceled() {
+			return count, syscall.EINTR
+		}
+		marked, st := m.en.doMarkDedupRef(ctx, ref.Hash, ref.Size, before.Unix())
+		if st != 0 {
+			logger.Warnf("mark dedup reference %s: %s", ref.Hash, st)
+			continue
+		}
+		if !marked {
+			continue
+		}
+		// the mark is kept if it fails, so it will be deleted by the next gc
+		if err := delete(ref); err != nil {
+			logger.Warnf("delete dedup object %s: %s", ref.Hash, err)
+			continue
+		}
+		if st = m.en.doDeleteDedupRef(ctx, ref.Hash, ref.Size); st != 0 {
+			logger.Warnf("delete dedup reference %s: %s", ref.Hash, st)
+			continue
+		}
+		count++
+	}
+	return count, 0
+}
+
+// dumpDedup dumps the index of deduplicated blocks in batches.
+func (m *baseMeta) dumpDedup(ctx Context, opt *DumpOption, ch chan<- *dumpedResult) error {
+	if !m.getFormat().Dedup {
+		return nil
+	}
+	const batchSize = 10000
+	var err error
+	blocks := make([]*pb.DedupBlock, 0, batchSize)
+	st := m.en.doScanDedupBlocks(ctx, func(id uint64, indx uint32, chunks []DedupChunk) bool {
+		blocks = append(blocks, &pb.DedupBlock{Id: id, Indx: indx, Chunks: encodeDedupChunks(chunks)})
+		if len(blocks) >= batchSize {
+			if err = dumpResult(ctx, ch, &dumpedResult{msg: &pb.Batch{DedupBlocks: blocks}}); err != nil {
+				return false
+			}
+			blocks = make([]*pb.DedupBlock, 0, batchSize)
+		}
+		return true
+	})
+	if err == nil && st != 0 {
+		err = fmt.Errorf("scan dedup blocks: %s", st)
+	}
+	if err != nil {
+		return err
+	}
+	if err = dumpResult(ctx, ch, &dumpedResult{msg: &pb.Batch{DedupBlocks: blocks}}); err != nil {
+		return err
+	}
+
+	refs := make([]*pb.DedupRef, 0, batchSize)
+	st = m.en.doScanDedupRefs(ctx, func(ref *DedupRef) bool {
+		refs = append(refs, &pb.DedupRef{Hash: ref.Hash, Size: ref.Size, Refs: ref.Refs, Freed: ref.Freed})
+		if len(refs) >= batchSize {
+			if err = dumpResult(ctx, ch, &dumpedResult{msg: &pb.Batch{DedupRefs: refs}}); err != nil {
+				return false
+			}
+			refs = make([]*pb.DedupRef, 0, batchSize)
+		}
+		return true
+	})
+	if err == nil && st != 0 {
+		err = fmt.Errorf("scan dedup refs: %s", st)
+	}
+	if err != nil {
+		return err
+	}
+	return dumpResult(ctx, ch, &dumpedResult{msg: &pb.Batch{DedupRefs: refs}})
+}
+
+// dumpedDedup fills the index of deduplicated blocks into dm.
+func (m *baseMeta) dumpedDedup(ctx Context, dm *DumpedMeta) error {
+	if !m.getFormat().Dedup {
+		return nil
+	}
+	if st := m.en.doScanDedupBlocks(ctx, func(id uint64, indx uint32, chunks []DedupChunk) bool {
+		dm.DedupBlocks = append(dm.DedupBlocks, &DumpedDedupBlock{id, indx, encodeDedupChunks(chunks)})
+		return true
+	}); st != 0 {
+		return fmt.Errorf("scan dedup blocks: %s", st)
+	}
+	if st := m.en.doScanDedupRefs(ctx, func(ref *DedupRef) bool {
+		dm.DedupRefs = append(dm.DedupRefs, &DumpedDedupRef{ref.Hash, ref.Size, ref.Refs, ref.Freed})
+		return true
+	}); st != 0 {
+		return fmt.Errorf("scan dedup refs: %s", st)
+	}
+	return nil
+}
+
+// loadDumpedDedup loads the index of deduplicated blocks from dm.
+func (m *baseMeta) loadDumpedDedup(ctx Context, dm *DumpedMeta) error {
+	if len(dm.DedupBlocks) == 0 && len(dm.DedupRefs) == 0 {
+		return nil
+	}
+	batch := &pb.Batch{
+		DedupBlocks: make([]*pb.DedupBlock, 0, len(dm.DedupBlocks)),
+		DedupRefs:   make([]*pb.DedupRef, 0, len(dm.DedupRefs)),
+	}
+	for _, b := range dm.DedupBlocks {
+		batch.DedupBlocks = append(batch.DedupBlocks, &pb.DedupBlock{Id: b.Id, Indx: b.Indx, Chunks: b.Chunks})
+	}
+	for _, r := range dm.DedupRefs {
+		batch.DedupRefs = append(batch.DedupRefs, &pb.DedupRef{Hash: r.Hash, Size: r.Size, Refs: r.Refs, Freed: r.Freed})
+	}
+	if err := m.en.loadDedup(ctx, batch); err != nil {
+		return fmt.Errorf("load dedup index: %s", err)
+	}
+	return nil
+}
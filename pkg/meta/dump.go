@@ -134,6 +134,19 @@ type DumpedChangeLog struct {
 	Entry   string `json:"entry"`
 }
 
+type DumpedDedupBlock struct {
+	Id     uint64 `json:"id"`
+	Indx   uint32 `json:"indx"`
+	Chunks string `json:"chunks"`
+}
+
+type DumpedDedupRef struct {
+	Hash  string `json:"hash"`
+	Size  uint32 `json:"size"`
+	Refs  int64  `json:"refs"`
+	Freed int64  `json:"freed,omitempty"`
+}
+
 type wrapEntryPool struct {
 	sync.Pool
 }
@@ -340,8 +353,12 @@ type DumpedMeta struct {
 	UserQuotas  map[uint64]*DumpedQuota `json:",omitempty"`
 	GroupQuotas map[uint64]*DumpedQuota `json:",omitempty"`
 	ChangeLog   []*DumpedChangeLog      `json:",omitempty"`
+	DedupBlocks []*DumpedDedupBlock     `json:",omitempty"`
+	DedupRefs   []*DumpedDedupRef       `json:",omitempty"`
+	Snapshots   []*Snapshot             `json:",omitempty"`
 	FSTree      *DumpedEntry            `json:",omitempty"`
 	Trash       *DumpedEntry            `json:",omitempty"`
+	SnapTree    *DumpedEntry            `json:",omitempty"` // the copies of snapshots under SnapshotInode
 }
 
 func (dm *DumpedMeta) validate() error {
@@ -352,7 +369,7 @@ func (dm *DumpedMeta) validate() error {
 }
 
 func (dm *DumpedMeta) writeJsonWithOutTree(w io.Writer) (*bufio.Writer, error) {
-	if dm.FSTree != nil || dm.Trash != nil {
+	if dm.FSTree != nil || dm.Trash != nil || dm.SnapTree != nil {
 		return nil, fmt.Errorf("invalid dumped meta")
 	}
 	data, err := json.MarshalIndent(dm, "", jsonIndent)
@@ -487,9 +504,15 @@ func loadEntries(r io.Reader, load func(*DumpedEntry), addChunk func(*chunkKey))
 			err = dec.Decode(&dm.GroupQuotas)
 		case "ChangeLog":
 			err = dec.Decode(&dm.ChangeLog)
+		case "DedupBlocks":
+			err = dec.Decode(&dm.DedupBlocks)
+		case "DedupRefs":
+			err = dec.Decode(&dm.DedupRefs)
+		case "Snapshots":
+			err = dec.Decode(&dm.Snapshots)
 		case "FSTree":
 			_, err = decodeEntry(dec, 0, counters, parents, dm.Quotas, refs, bar, load, addChunk)
-		case "Trash":
+		case "Trash", "SnapTree":
 			_, err = decodeEntry(dec, 1, counters, parents, nil, refs, bar, load, addChunk)
 		}
 		if err != nil {
@@ -537,15 +560,15 @@ func decodeEntry(dec *json.Decoder, parent Ino, cs *DumpedCounters, parents map[
 				e.Parents = append(parents[inode], parent)
 				parents[inode] = e.Parents
 				if len(e.Parents) == 1 {
-					if inode > 1 && inode != TrashInode {
+					if inode > 1 && inode != TrashInode && inode != SnapshotInode {
 						cs.UsedSpace += align4K(e.Attr.Length)
 						cs.UsedInodes += 1
 					}
-					if inode < TrashInode {
+					if inode < TrashInode && inode != SnapshotInode {
 						if cs.NextInode <= int64(inode) {
 							cs.NextInode = int64(inode) + 1
 						}
-					} else {
+					} else if inode > TrashInode {
 						if cs.NextTrash < int64(inode-TrashInode) {
 							cs.NextTrash = int64(inode - TrashInode)
 						}
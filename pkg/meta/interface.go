@@ -57,6 +57,8 @@ const (
 	OpSummary = 1007
 	// CompactPath is a message to trigger compact
 	CompactPath = 1008
+	// UploadStaging is a message to upload the staging blocks now.
+	UploadStaging = 1009
 )
 
 const (
@@ -117,7 +119,8 @@ const MaxSymlink = 4096
 type Ino uint64
 
 const RootInode Ino = 1
-const TrashInode Ino = 0x7FFFFFFF10000000 // larger than vfs.minInternalNode
+const TrashInode Ino = 0x7FFFFFFF10000000    // larger than vfs.minInternalNode
+const SnapshotInode Ino = 0x7FFFFFFF0F000000 // between vfs.minInternalNode and TrashInode
 
 const RmrDefaultThreads = 50
 
@@ -140,6 +143,7 @@ func (i Ino) IsNormal() bool {
 }
 
 var TrashName = ".trash"
+var SnapshotName = ".snapshots"
 
 type internalNode struct {
 	inode Ino
@@ -360,6 +364,8 @@ type SessionInfo struct {
 	MountPoint string
 	MountTime  time.Time
 	ProcessID  int
+	CacheGroup string `json:",omitempty"`
+	GroupAddr  string `json:",omitempty"` // address serving the other members of cache group
 }
 
 type Flock struct {
@@ -562,6 +568,61 @@ type Meta interface {
 	ListTokens(ctx Context) (tokens map[uint32][]byte, st syscall.Errno)
 
 	ScanChangelog(ctx Context, last int64, handler func(ver int64, entry string) error) error
+	// ChangelogVersion returns the version of the latest changelog entry, 0 if there is none.
+	ChangelogVersion(ctx Context) (int64, syscall.Errno)
+	// AckChangelog records the last version handled by a consumer group, the group is created if not existed.
+	AckChangelog(ctx Context, group string, version int64) syscall.Errno
+	// ListChangelogConsumers returns all the consumer groups of changelog.
+	ListChangelogConsumers(ctx Context) ([]*ChangelogConsumer, syscall.Errno)
+	// RemoveChangelogConsumer removes a consumer group, so the entries are no longer kept for it.
+	RemoveChangelogConsumer(ctx Context, group string) syscall.Errno
+
+	// CreateSnapshot makes a read-only copy of the tree at inode, sharing slices with it.
+	CreateSnapshot(ctx Context, inode Ino, name string, concurrency uint8, count, total *uint64) syscall.Errno
+	// ListSnapshots returns all the snapshots of the volume.
+	ListSnapshots(ctx Context) ([]*Snapshot, syscall.Errno)
+	// DeleteSnapshot removes a snapshot and releases the slices referenced by it.
+	DeleteSnapshot(ctx Context, name string, count *uint64) syscall.Errno
+	// RestoreSnapshot clones a snapshot into a writable tree named dstName under parent.
+	RestoreSnapshot(ctx Context, name string, parent Ino, dstName string, concurrency uint8, count, total *uint64) syscall.Errno
+
+	// SetTierPolicy sets (or replaces) the lifecycle policy of a directory.
+	SetTierPolicy(ctx Context, inode Ino, policy *TierPolicy) syscall.Errno
+	// ListTierPolicies returns the lifecycle policies of all the directories.
+	ListTierPolicies(ctx Context) ([]*TierPolicy, syscall.Errno)
+	// DeleteTierPolicy removes the lifecycle policy of a directory.
+	DeleteTierPolicy(ctx Context, inode Ino) syscall.Errno
+	// ApplyTierPolicies moves the idle files to the tiers in the policies and removes the expired ones.
+	ApplyTierPolicies(ctx Context, stats *TierPolicyStats) syscall.Errno
+
+	// SetDirCompression sets the compression of new data under a directory.
+	SetDirCompression(ctx Context, inode Ino, algr string) syscall.Errno
+	// DeleteDirCompression removes the compression of a directory, so it follows its parents.
+	DeleteDirCompression(ctx Context, inode Ino) syscall.Errno
+	// ListDirCompressions returns the compression of all the directories having one.
+	ListDirCompressions(ctx Context) (map[Ino]string, syscall.Errno)
+	// GetCompression returns the compression for new data of inode, empty for the default one.
+	GetCompression(ctx Context, inode Ino) string
+	// GetCachePolicy returns the cache policy of inode inherited from its directories, empty for the mount options.
+	GetCachePolicy(ctx Context, inode Ino) string
+
+	// AddDedupBlock references the objects of the chunks of a block, it returns whether each object
+	// exists already, or EAGAIN if any of them is being deleted.
+	AddDedupBlock(ctx Context, id uint64, indx uint32, chunks []DedupChunk) ([]bool, syscall.Errno)
+	// GetDedupBlock returns the chunks of a block, or nil if it's not deduplicated.
+	GetDedupBlock(ctx Context, id uint64, indx uint32) ([]DedupChunk, syscall.Errno)
+	// RemoveDedupBlock drops the references of a block, and returns the chunks of it.
+	RemoveDedupBlock(ctx Context, id uint64, indx uint32) ([]DedupChunk, syscall.Errno)
+	// ScanDedupRefs iterates the deduplicated objects.
+	ScanDedupRefs(ctx Context, scan func(ref *DedupRef) bool) syscall.Errno
+	// DedupStats returns the statistics of deduplicated objects.
+	DedupStats(ctx Context) (*DedupStats, syscall.Errno)
+	// CleanupDedupRefs removes the objects not referenced since before.
+	CleanupDedupRefs(ctx Context, before time.Time, delete func(ref *DedupRef) error) (int64, syscall.Errno)
+
+	// ClaimJob returns true if the background job is not run by any client within the interval, then
+	// it's claimed by this one for the interval.
+	ClaimJob(name string, interval time.Duration) (bool, error)
 }
 
 type ScanSlicesOption struct {
@@ -502,6 +502,66 @@ func TestLoadDump_MemKV(t *testing.T) {
 	})
 }
 
+func TestLoadDumpSnapshot(t *testing.T) {
+	m := NewClient("sqlite3://"+path.Join(t.TempDir(), "jfs-snapshot.db"), nil)
+	if err := m.Reset(); err != nil {
+		t.Fatalf("reset meta: %s", err)
+	}
+	if err := m.Init(testFormat(), false); err != nil {
+		t.Fatalf("init: %s", err)
+	}
+	if err := m.NewSession(false); err != nil {
+		t.Fatalf("new session: %s", err)
+	}
+	defer m.Shutdown()
+	ctx := Background()
+	var src, inode Ino
+	if st := m.Mkdir(ctx, RootInode, "src", 0777, 022, 0, &src, nil); st != 0 {
+		t.Fatalf("mkdir: %s", st)
+	}
+	if st := m.Mknod(ctx, src, "f", TypeFile, 0644, 022, 0, "", &inode, nil); st != 0 {
+		t.Fatalf("mknod: %s", st)
+	}
+	var count, total uint64
+	if st := m.CreateSnapshot(ctx, src, "s1", 2, &count, &total); st != 0 {
+		t.Fatalf("create snapshot: %s", st)
+	}
+	nextInode, err := m.getBase().en.getCounter("nextInode")
+	if err != nil {
+		t.Fatalf("get nextInode: %s", err)
+	}
+
+	for _, fast := range []bool{true, false} {
+		var buf bytes.Buffer
+		if err := m.DumpMeta(&buf, RootInode, 2, true, fast, false); err != nil {
+			t.Fatalf("dump meta: %s", err)
+		}
+		m2 := NewClient("sqlite3://"+path.Join(t.TempDir(), fmt.Sprintf("jfs-snapshot-%v.db", fast)), nil)
+		if err := m2.Reset(); err != nil {
+			t.Fatalf("reset meta: %s", err)
+		}
+		if err := m2.LoadMeta(&buf); err != nil {
+			t.Fatalf("load meta: %s", err)
+		}
+		snaps, st := m2.ListSnapshots(ctx)
+		if st != 0 || len(snaps) != 1 || snaps[0].Name != "s1" || snaps[0].Source != src {
+			t.Fatalf("list loaded snapshots: %+v %s", snaps, st)
+		}
+		var attr Attr
+		var snap Ino
+		if st := m2.Lookup(ctx, SnapshotInode, "s1", &snap, &attr, false); st != 0 || snap != snaps[0].Inode {
+			t.Fatalf("lookup loaded snapshot: %d %s", snap, st)
+		}
+		if st := m2.Lookup(ctx, snap, "f", &inode, &attr, false); st != 0 || attr.Flags&FlagImmutable == 0 {
+			t.Fatalf("lookup file in loaded snapshot: %s, flags %d", st, attr.Flags)
+		}
+		if next, err := m2.getBase().en.getCounter("nextInode"); err != nil || next > nextInode {
+			t.Fatalf("nextInode of loaded meta: %d > %d, %v", next, nextInode, err)
+		}
+		m2.Shutdown()
+	}
+}
+
 func testSecretAndTrash(t *testing.T, addr, addr2 string) {
 	m := testLoad(t, addr, sampleFile, false)
 	testDumpV2(t, m, "sqlite-secret.dump", &DumpOption{Threads: 10, KeepSecret: true})
@@ -1,6 +1,6 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.35.2
+// 	protoc-gen-go v1.36.11
 // 	protoc        v5.29.0
 // source: pkg/meta/pb/backup.proto
 
@@ -11,6 +11,7 @@ import (
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
 	sync "sync"
+	unsafe "unsafe"
 )
 
 const (
@@ -21,11 +22,10 @@ const (
 )
 
 type Format struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"` // meta.Format's json format
 	unknownFields protoimpl.UnknownFields
-
-	Data []byte `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"` // meta.Format's json format
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Format) Reset() {
@@ -66,12 +66,11 @@ func (x *Format) GetData() []byte {
 }
 
 type Counter struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         int64                  `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Key   string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
-	Value int64  `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Counter) Reset() {
@@ -119,12 +118,11 @@ func (x *Counter) GetValue() int64 {
 }
 
 type Sustained struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sid           uint64                 `protobuf:"varint,1,opt,name=sid,proto3" json:"sid,omitempty"`
+	Inodes        []uint64               `protobuf:"varint,2,rep,packed,name=inodes,proto3" json:"inodes,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Sid    uint64   `protobuf:"varint,1,opt,name=sid,proto3" json:"sid,omitempty"`
-	Inodes []uint64 `protobuf:"varint,2,rep,packed,name=inodes,proto3" json:"inodes,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Sustained) Reset() {
@@ -172,13 +170,12 @@ func (x *Sustained) GetInodes() []uint64 {
 }
 
 type DelFile struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inode         uint64                 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Length        uint64                 `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
+	Expire        int64                  `protobuf:"varint,3,opt,name=expire,proto3" json:"expire,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Inode  uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
-	Length uint64 `protobuf:"varint,2,opt,name=length,proto3" json:"length,omitempty"`
-	Expire int64  `protobuf:"varint,3,opt,name=expire,proto3" json:"expire,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *DelFile) Reset() {
@@ -233,13 +230,12 @@ func (x *DelFile) GetExpire() int64 {
 }
 
 type SliceRef struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Size          uint32                 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Refs          int64                  `protobuf:"varint,3,opt,name=refs,proto3" json:"refs,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Id   uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
-	Size uint32 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
-	Refs int64  `protobuf:"varint,3,opt,name=refs,proto3" json:"refs,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *SliceRef) Reset() {
@@ -294,12 +290,11 @@ func (x *SliceRef) GetRefs() int64 {
 }
 
 type Acl struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint32                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"` // acl.Rule's binary format
 	unknownFields protoimpl.UnknownFields
-
-	Id   uint32 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
-	Data []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"` // acl.Rule's binary format
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Acl) Reset() {
@@ -347,13 +342,12 @@ func (x *Acl) GetData() []byte {
 }
 
 type Xattr struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inode         uint64                 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Value         []byte                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
-	Name  string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Value []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Xattr) Reset() {
@@ -408,16 +402,15 @@ func (x *Xattr) GetValue() []byte {
 }
 
 type Quota struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           uint64                 `protobuf:"varint,1,opt,name=key,proto3" json:"key,omitempty"`
+	MaxSpace      int64                  `protobuf:"varint,2,opt,name=maxSpace,proto3" json:"maxSpace,omitempty"`
+	MaxInodes     int64                  `protobuf:"varint,3,opt,name=maxInodes,proto3" json:"maxInodes,omitempty"`
+	UsedSpace     int64                  `protobuf:"varint,4,opt,name=usedSpace,proto3" json:"usedSpace,omitempty"`
+	UsedInodes    int64                  `protobuf:"varint,5,opt,name=usedInodes,proto3" json:"usedInodes,omitempty"`
+	Type          uint32                 `protobuf:"varint,6,opt,name=type,proto3" json:"type,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Key        uint64 `protobuf:"varint,1,opt,name=key,proto3" json:"key,omitempty"`
-	MaxSpace   int64  `protobuf:"varint,2,opt,name=maxSpace,proto3" json:"maxSpace,omitempty"`
-	MaxInodes  int64  `protobuf:"varint,3,opt,name=maxInodes,proto3" json:"maxInodes,omitempty"`
-	UsedSpace  int64  `protobuf:"varint,4,opt,name=usedSpace,proto3" json:"usedSpace,omitempty"`
-	UsedInodes int64  `protobuf:"varint,5,opt,name=usedInodes,proto3" json:"usedInodes,omitempty"`
-	Type       uint32 `protobuf:"varint,6,opt,name=type,proto3" json:"type,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Quota) Reset() {
@@ -493,14 +486,13 @@ func (x *Quota) GetType() uint32 {
 }
 
 type Stat struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inode         uint64                 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	DataLength    int64                  `protobuf:"varint,2,opt,name=dataLength,proto3" json:"dataLength,omitempty"`
+	UsedSpace     int64                  `protobuf:"varint,3,opt,name=usedSpace,proto3" json:"usedSpace,omitempty"`
+	UsedInodes    int64                  `protobuf:"varint,4,opt,name=usedInodes,proto3" json:"usedInodes,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Inode      uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
-	DataLength int64  `protobuf:"varint,2,opt,name=dataLength,proto3" json:"dataLength,omitempty"`
-	UsedSpace  int64  `protobuf:"varint,3,opt,name=usedSpace,proto3" json:"usedSpace,omitempty"`
-	UsedInodes int64  `protobuf:"varint,4,opt,name=usedInodes,proto3" json:"usedInodes,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Stat) Reset() {
@@ -562,12 +554,11 @@ func (x *Stat) GetUsedInodes() int64 {
 }
 
 type Node struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inode         uint64                 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"` // meta.Attr's binary format
 	unknownFields protoimpl.UnknownFields
-
-	Inode uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
-	Data  []byte `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"` // meta.Attr's binary format
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Node) Reset() {
@@ -615,14 +606,13 @@ func (x *Node) GetData() []byte {
 }
 
 type Edge struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Parent        uint64                 `protobuf:"varint,1,opt,name=parent,proto3" json:"parent,omitempty"`
+	Inode         uint64                 `protobuf:"varint,2,opt,name=inode,proto3" json:"inode,omitempty"`
+	Name          []byte                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Type          uint32                 `protobuf:"varint,4,opt,name=type,proto3" json:"type,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Parent uint64 `protobuf:"varint,1,opt,name=parent,proto3" json:"parent,omitempty"`
-	Inode  uint64 `protobuf:"varint,2,opt,name=inode,proto3" json:"inode,omitempty"`
-	Name   []byte `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
-	Type   uint32 `protobuf:"varint,4,opt,name=type,proto3" json:"type,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Edge) Reset() {
@@ -685,13 +675,12 @@ func (x *Edge) GetType() uint32 {
 
 // for redis and tikv only
 type Parent struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inode         uint64                 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Parent        uint64                 `protobuf:"varint,2,opt,name=parent,proto3" json:"parent,omitempty"`
+	Cnt           int64                  `protobuf:"varint,3,opt,name=cnt,proto3" json:"cnt,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Inode  uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
-	Parent uint64 `protobuf:"varint,2,opt,name=parent,proto3" json:"parent,omitempty"`
-	Cnt    int64  `protobuf:"varint,3,opt,name=cnt,proto3" json:"cnt,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Parent) Reset() {
@@ -746,13 +735,12 @@ func (x *Parent) GetCnt() int64 {
 }
 
 type Chunk struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inode         uint64                 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Index         uint32                 `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
+	Slices        []byte                 `protobuf:"bytes,3,opt,name=slices,proto3" json:"slices,omitempty"` // array of meta.slice
 	unknownFields protoimpl.UnknownFields
-
-	Inode  uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
-	Index  uint32 `protobuf:"varint,2,opt,name=index,proto3" json:"index,omitempty"`
-	Slices []byte `protobuf:"bytes,3,opt,name=slices,proto3" json:"slices,omitempty"` // array of meta.slice
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Chunk) Reset() {
@@ -807,12 +795,11 @@ func (x *Chunk) GetSlices() []byte {
 }
 
 type Symlink struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Inode         uint64                 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
+	Target        []byte                 `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Inode  uint64 `protobuf:"varint,1,opt,name=inode,proto3" json:"inode,omitempty"`
-	Target []byte `protobuf:"bytes,2,opt,name=target,proto3" json:"target,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Symlink) Reset() {
@@ -860,12 +847,11 @@ func (x *Symlink) GetTarget() []byte {
 }
 
 type ChangeLog struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       int64                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	Entry         []byte                 `protobuf:"bytes,2,opt,name=entry,proto3" json:"entry,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Version int64  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
-	Entry   []byte `protobuf:"bytes,2,opt,name=entry,proto3" json:"entry,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *ChangeLog) Reset() {
@@ -912,32 +898,161 @@ func (x *ChangeLog) GetEntry() []byte {
 	return nil
 }
 
-type Batch struct {
-	state         protoimpl.MessageState
+type DedupBlock struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            uint64                 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Indx          uint32                 `protobuf:"varint,2,opt,name=indx,proto3" json:"indx,omitempty"`
+	Chunks        string                 `protobuf:"bytes,3,opt,name=chunks,proto3" json:"chunks,omitempty"` // objects of the chunks ($hash_$size), separated by comma
+	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DedupBlock) Reset() {
+	*x = DedupBlock{}
+	mi := &file_pkg_meta_pb_backup_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DedupBlock) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DedupBlock) ProtoMessage() {}
+
+func (x *DedupBlock) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_meta_pb_backup_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DedupBlock.ProtoReflect.Descriptor instead.
+func (*DedupBlock) Descriptor() ([]byte, []int) {
+	return file_pkg_meta_pb_backup_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *DedupBlock) GetId() uint64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *DedupBlock) GetIndx() uint32 {
+	if x != nil {
+		return x.Indx
+	}
+	return 0
+}
+
+func (x *DedupBlock) GetChunks() string {
+	if x != nil {
+		return x.Chunks
+	}
+	return ""
+}
+
+type DedupRef struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Hash          string                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Size          uint32                 `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Refs          int64                  `protobuf:"varint,3,opt,name=refs,proto3" json:"refs,omitempty"`
+	Freed         int64                  `protobuf:"varint,4,opt,name=freed,proto3" json:"freed,omitempty"`
 	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DedupRef) Reset() {
+	*x = DedupRef{}
+	mi := &file_pkg_meta_pb_backup_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DedupRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DedupRef) ProtoMessage() {}
+
+func (x *DedupRef) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_meta_pb_backup_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
 
-	Nodes       []*Node      `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
-	Edges       []*Edge      `protobuf:"bytes,2,rep,name=edges,proto3" json:"edges,omitempty"`
-	Chunks      []*Chunk     `protobuf:"bytes,3,rep,name=chunks,proto3" json:"chunks,omitempty"`
-	SliceRefs   []*SliceRef  `protobuf:"bytes,4,rep,name=sliceRefs,proto3" json:"sliceRefs,omitempty"`
-	Xattrs      []*Xattr     `protobuf:"bytes,5,rep,name=xattrs,proto3" json:"xattrs,omitempty"`
-	Parents     []*Parent    `protobuf:"bytes,6,rep,name=parents,proto3" json:"parents,omitempty"`
-	Symlinks    []*Symlink   `protobuf:"bytes,7,rep,name=symlinks,proto3" json:"symlinks,omitempty"`
-	Sustained   []*Sustained `protobuf:"bytes,8,rep,name=sustained,proto3" json:"sustained,omitempty"`
-	Delfiles    []*DelFile   `protobuf:"bytes,9,rep,name=delfiles,proto3" json:"delfiles,omitempty"`
-	Dirstats    []*Stat      `protobuf:"bytes,10,rep,name=dirstats,proto3" json:"dirstats,omitempty"`
-	Quotas      []*Quota     `protobuf:"bytes,11,rep,name=quotas,proto3" json:"quotas,omitempty"`
-	Acls        []*Acl       `protobuf:"bytes,12,rep,name=acls,proto3" json:"acls,omitempty"`
-	Counters    []*Counter   `protobuf:"bytes,13,rep,name=counters,proto3" json:"counters,omitempty"`
-	Changelogs  []*ChangeLog `protobuf:"bytes,14,rep,name=changelogs,proto3" json:"changelogs,omitempty"`
-	UserQuotas  []*Quota     `protobuf:"bytes,15,rep,name=userQuotas,proto3" json:"userQuotas,omitempty"`
-	GroupQuotas []*Quota     `protobuf:"bytes,16,rep,name=groupQuotas,proto3" json:"groupQuotas,omitempty"`
+// Deprecated: Use DedupRef.ProtoReflect.Descriptor instead.
+func (*DedupRef) Descriptor() ([]byte, []int) {
+	return file_pkg_meta_pb_backup_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DedupRef) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *DedupRef) GetSize() uint32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *DedupRef) GetRefs() int64 {
+	if x != nil {
+		return x.Refs
+	}
+	return 0
+}
+
+func (x *DedupRef) GetFreed() int64 {
+	if x != nil {
+		return x.Freed
+	}
+	return 0
+}
+
+type Batch struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Nodes         []*Node                `protobuf:"bytes,1,rep,name=nodes,proto3" json:"nodes,omitempty"`
+	Edges         []*Edge                `protobuf:"bytes,2,rep,name=edges,proto3" json:"edges,omitempty"`
+	Chunks        []*Chunk               `protobuf:"bytes,3,rep,name=chunks,proto3" json:"chunks,omitempty"`
+	SliceRefs     []*SliceRef            `protobuf:"bytes,4,rep,name=sliceRefs,proto3" json:"sliceRefs,omitempty"`
+	Xattrs        []*Xattr               `protobuf:"bytes,5,rep,name=xattrs,proto3" json:"xattrs,omitempty"`
+	Parents       []*Parent              `protobuf:"bytes,6,rep,name=parents,proto3" json:"parents,omitempty"`
+	Symlinks      []*Symlink             `protobuf:"bytes,7,rep,name=symlinks,proto3" json:"symlinks,omitempty"`
+	Sustained     []*Sustained           `protobuf:"bytes,8,rep,name=sustained,proto3" json:"sustained,omitempty"`
+	Delfiles      []*DelFile             `protobuf:"bytes,9,rep,name=delfiles,proto3" json:"delfiles,omitempty"`
+	Dirstats      []*Stat                `protobuf:"bytes,10,rep,name=dirstats,proto3" json:"dirstats,omitempty"`
+	Quotas        []*Quota               `protobuf:"bytes,11,rep,name=quotas,proto3" json:"quotas,omitempty"`
+	Acls          []*Acl                 `protobuf:"bytes,12,rep,name=acls,proto3" json:"acls,omitempty"`
+	Counters      []*Counter             `protobuf:"bytes,13,rep,name=counters,proto3" json:"counters,omitempty"`
+	Changelogs    []*ChangeLog           `protobuf:"bytes,14,rep,name=changelogs,proto3" json:"changelogs,omitempty"`
+	UserQuotas    []*Quota               `protobuf:"bytes,15,rep,name=userQuotas,proto3" json:"userQuotas,omitempty"`
+	GroupQuotas   []*Quota               `protobuf:"bytes,16,rep,name=groupQuotas,proto3" json:"groupQuotas,omitempty"`
+	DedupBlocks   []*DedupBlock          `protobuf:"bytes,17,rep,name=dedupBlocks,proto3" json:"dedupBlocks,omitempty"`
+	DedupRefs     []*DedupRef            `protobuf:"bytes,18,rep,name=dedupRefs,proto3" json:"dedupRefs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Batch) Reset() {
 	*x = Batch{}
-	mi := &file_pkg_meta_pb_backup_proto_msgTypes[15]
+	mi := &file_pkg_meta_pb_backup_proto_msgTypes[17]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -949,7 +1064,7 @@ func (x *Batch) String() string {
 func (*Batch) ProtoMessage() {}
 
 func (x *Batch) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_meta_pb_backup_proto_msgTypes[15]
+	mi := &file_pkg_meta_pb_backup_proto_msgTypes[17]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -962,7 +1077,7 @@ func (x *Batch) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Batch.ProtoReflect.Descriptor instead.
 func (*Batch) Descriptor() ([]byte, []int) {
-	return file_pkg_meta_pb_backup_proto_rawDescGZIP(), []int{15}
+	return file_pkg_meta_pb_backup_proto_rawDescGZIP(), []int{17}
 }
 
 func (x *Batch) GetNodes() []*Node {
@@ -1077,19 +1192,32 @@ func (x *Batch) GetGroupQuotas() []*Quota {
 	return nil
 }
 
+func (x *Batch) GetDedupBlocks() []*DedupBlock {
+	if x != nil {
+		return x.DedupBlocks
+	}
+	return nil
+}
+
+func (x *Batch) GetDedupRefs() []*DedupRef {
+	if x != nil {
+		return x.DedupRefs
+	}
+	return nil
+}
+
 type Footer struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState     `protogen:"open.v1"`
+	Magic         uint32                     `protobuf:"varint,1,opt,name=magic,proto3" json:"magic,omitempty"`
+	Version       uint32                     `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	Infos         map[string]*Footer_SegInfo `protobuf:"bytes,3,rep,name=infos,proto3" json:"infos,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields protoimpl.UnknownFields
-
-	Magic   uint32                     `protobuf:"varint,1,opt,name=magic,proto3" json:"magic,omitempty"`
-	Version uint32                     `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
-	Infos   map[string]*Footer_SegInfo `protobuf:"bytes,3,rep,name=infos,proto3" json:"infos,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Footer) Reset() {
 	*x = Footer{}
-	mi := &file_pkg_meta_pb_backup_proto_msgTypes[16]
+	mi := &file_pkg_meta_pb_backup_proto_msgTypes[18]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1101,7 +1229,7 @@ func (x *Footer) String() string {
 func (*Footer) ProtoMessage() {}
 
 func (x *Footer) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_meta_pb_backup_proto_msgTypes[16]
+	mi := &file_pkg_meta_pb_backup_proto_msgTypes[18]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1114,7 +1242,7 @@ func (x *Footer) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Footer.ProtoReflect.Descriptor instead.
 func (*Footer) Descriptor() ([]byte, []int) {
-	return file_pkg_meta_pb_backup_proto_rawDescGZIP(), []int{16}
+	return file_pkg_meta_pb_backup_proto_rawDescGZIP(), []int{18}
 }
 
 func (x *Footer) GetMagic() uint32 {
@@ -1139,17 +1267,16 @@ func (x *Footer) GetInfos() map[string]*Footer_SegInfo {
 }
 
 type Footer_SegInfo struct {
-	state         protoimpl.MessageState
-	sizeCache     protoimpl.SizeCache
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Offset        []uint64               `protobuf:"varint,1,rep,packed,name=offset,proto3" json:"offset,omitempty"`
+	Num           uint64                 `protobuf:"varint,2,opt,name=num,proto3" json:"num,omitempty"`
 	unknownFields protoimpl.UnknownFields
-
-	Offset []uint64 `protobuf:"varint,1,rep,packed,name=offset,proto3" json:"offset,omitempty"`
-	Num    uint64   `protobuf:"varint,2,opt,name=num,proto3" json:"num,omitempty"`
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Footer_SegInfo) Reset() {
 	*x = Footer_SegInfo{}
-	mi := &file_pkg_meta_pb_backup_proto_msgTypes[17]
+	mi := &file_pkg_meta_pb_backup_proto_msgTypes[19]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -1161,7 +1288,7 @@ func (x *Footer_SegInfo) String() string {
 func (*Footer_SegInfo) ProtoMessage() {}
 
 func (x *Footer_SegInfo) ProtoReflect() protoreflect.Message {
-	mi := &file_pkg_meta_pb_backup_proto_msgTypes[17]
+	mi := &file_pkg_meta_pb_backup_proto_msgTypes[19]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -1174,7 +1301,7 @@ func (x *Footer_SegInfo) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Footer_SegInfo.ProtoReflect.Descriptor instead.
 func (*Footer_SegInfo) Descriptor() ([]byte, []int) {
-	return file_pkg_meta_pb_backup_proto_rawDescGZIP(), []int{16, 0}
+	return file_pkg_meta_pb_backup_proto_rawDescGZIP(), []int{18, 0}
 }
 
 func (x *Footer_SegInfo) GetOffset() []uint64 {
@@ -1193,149 +1320,132 @@ func (x *Footer_SegInfo) GetNum() uint64 {
 
 var File_pkg_meta_pb_backup_proto protoreflect.FileDescriptor
 
-var file_pkg_meta_pb_backup_proto_rawDesc = []byte{
-	0x0a, 0x18, 0x70, 0x6b, 0x67, 0x2f, 0x6d, 0x65, 0x74, 0x61, 0x2f, 0x70, 0x62, 0x2f, 0x62, 0x61,
-	0x63, 0x6b, 0x75, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x02, 0x70, 0x62, 0x22, 0x1c,
-	0x0a, 0x06, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x31, 0x0a, 0x07,
-	0x43, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
-	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22,
-	0x35, 0x0a, 0x09, 0x53, 0x75, 0x73, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64, 0x12, 0x10, 0x0a, 0x03,
-	0x73, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x73, 0x69, 0x64, 0x12, 0x16,
-	0x0a, 0x06, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x04, 0x52, 0x06,
-	0x69, 0x6e, 0x6f, 0x64, 0x65, 0x73, 0x22, 0x4f, 0x0a, 0x07, 0x44, 0x65, 0x6c, 0x46, 0x69, 0x6c,
-	0x65, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
-	0x52, 0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x65, 0x6e, 0x67, 0x74,
-	0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x12,
-	0x16, 0x0a, 0x06, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52,
-	0x06, 0x65, 0x78, 0x70, 0x69, 0x72, 0x65, 0x22, 0x42, 0x0a, 0x08, 0x53, 0x6c, 0x69, 0x63, 0x65,
-	0x52, 0x65, 0x66, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52,
-	0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x0d, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x72, 0x65, 0x66, 0x73, 0x18,
-	0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x72, 0x65, 0x66, 0x73, 0x22, 0x29, 0x0a, 0x03, 0x41,
-	0x63, 0x6c, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x02,
-	0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c,
-	0x52, 0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x47, 0x0a, 0x05, 0x58, 0x61, 0x74, 0x74, 0x72, 0x12,
-	0x14, 0x0a, 0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05,
-	0x69, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
-	0x75, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x22,
-	0xa5, 0x01, 0x0a, 0x05, 0x51, 0x75, 0x6f, 0x74, 0x61, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x1a, 0x0a, 0x08, 0x6d,
-	0x61, 0x78, 0x53, 0x70, 0x61, 0x63, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x6d,
-	0x61, 0x78, 0x53, 0x70, 0x61, 0x63, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x6d, 0x61, 0x78, 0x49, 0x6e,
-	0x6f, 0x64, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x6d, 0x61, 0x78, 0x49,
-	0x6e, 0x6f, 0x64, 0x65, 0x73, 0x12, 0x1c, 0x0a, 0x09, 0x75, 0x73, 0x65, 0x64, 0x53, 0x70, 0x61,
-	0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x75, 0x73, 0x65, 0x64, 0x53, 0x70,
-	0x61, 0x63, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x75, 0x73, 0x65, 0x64, 0x49, 0x6e, 0x6f, 0x64, 0x65,
-	0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x75, 0x73, 0x65, 0x64, 0x49, 0x6e, 0x6f,
-	0x64, 0x65, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28,
-	0x0d, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x22, 0x7a, 0x0a, 0x04, 0x53, 0x74, 0x61, 0x74, 0x12,
-	0x14, 0x0a, 0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05,
-	0x69, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x64, 0x61, 0x74, 0x61, 0x4c, 0x65, 0x6e,
-	0x67, 0x74, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x64, 0x61, 0x74, 0x61, 0x4c,
-	0x65, 0x6e, 0x67, 0x74, 0x68, 0x12, 0x1c, 0x0a, 0x09, 0x75, 0x73, 0x65, 0x64, 0x53, 0x70, 0x61,
-	0x63, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x75, 0x73, 0x65, 0x64, 0x53, 0x70,
-	0x61, 0x63, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x75, 0x73, 0x65, 0x64, 0x49, 0x6e, 0x6f, 0x64, 0x65,
-	0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0a, 0x75, 0x73, 0x65, 0x64, 0x49, 0x6e, 0x6f,
-	0x64, 0x65, 0x73, 0x22, 0x30, 0x0a, 0x04, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x69,
-	0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x69, 0x6e, 0x6f, 0x64,
-	0x65, 0x12, 0x12, 0x0a, 0x04, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52,
-	0x04, 0x64, 0x61, 0x74, 0x61, 0x22, 0x5c, 0x0a, 0x04, 0x45, 0x64, 0x67, 0x65, 0x12, 0x16, 0x0a,
-	0x06, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06, 0x70,
-	0x61, 0x72, 0x65, 0x6e, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e,
-	0x61, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
-	0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x74,
-	0x79, 0x70, 0x65, 0x22, 0x48, 0x0a, 0x06, 0x50, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x12, 0x14, 0x0a,
-	0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x69, 0x6e,
-	0x6f, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x04, 0x52, 0x06, 0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x63,
-	0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x03, 0x63, 0x6e, 0x74, 0x22, 0x4b, 0x0a,
-	0x05, 0x43, 0x68, 0x75, 0x6e, 0x6b, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x14, 0x0a, 0x05,
-	0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x05, 0x69, 0x6e, 0x64,
-	0x65, 0x78, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x73, 0x18, 0x03, 0x20, 0x01,
-	0x28, 0x0c, 0x52, 0x06, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x73, 0x22, 0x37, 0x0a, 0x07, 0x53, 0x79,
-	0x6d, 0x6c, 0x69, 0x6e, 0x6b, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x18, 0x01,
-	0x20, 0x01, 0x28, 0x04, 0x52, 0x05, 0x69, 0x6e, 0x6f, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x74,
-	0x61, 0x72, 0x67, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x74, 0x61, 0x72,
-	0x67, 0x65, 0x74, 0x22, 0x3b, 0x0a, 0x09, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x4c, 0x6f, 0x67,
-	0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x03, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x65, 0x6e,
-	0x74, 0x72, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x65, 0x6e, 0x74, 0x72, 0x79,
-	0x22, 0xf4, 0x04, 0x0a, 0x05, 0x42, 0x61, 0x74, 0x63, 0x68, 0x12, 0x1e, 0x0a, 0x05, 0x6e, 0x6f,
-	0x64, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x08, 0x2e, 0x70, 0x62, 0x2e, 0x4e,
-	0x6f, 0x64, 0x65, 0x52, 0x05, 0x6e, 0x6f, 0x64, 0x65, 0x73, 0x12, 0x1e, 0x0a, 0x05, 0x65, 0x64,
-	0x67, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x08, 0x2e, 0x70, 0x62, 0x2e, 0x45,
-	0x64, 0x67, 0x65, 0x52, 0x05, 0x65, 0x64, 0x67, 0x65, 0x73, 0x12, 0x21, 0x0a, 0x06, 0x63, 0x68,
-	0x75, 0x6e, 0x6b, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x70, 0x62, 0x2e,
-	0x43, 0x68, 0x75, 0x6e, 0x6b, 0x52, 0x06, 0x63, 0x68, 0x75, 0x6e, 0x6b, 0x73, 0x12, 0x2a, 0x0a,
-	0x09, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x52, 0x65, 0x66, 0x73, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b,
-	0x32, 0x0c, 0x2e, 0x70, 0x62, 0x2e, 0x53, 0x6c, 0x69, 0x63, 0x65, 0x52, 0x65, 0x66, 0x52, 0x09,
-	0x73, 0x6c, 0x69, 0x63, 0x65, 0x52, 0x65, 0x66, 0x73, 0x12, 0x21, 0x0a, 0x06, 0x78, 0x61, 0x74,
-	0x74, 0x72, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x70, 0x62, 0x2e, 0x58,
-	0x61, 0x74, 0x74, 0x72, 0x52, 0x06, 0x78, 0x61, 0x74, 0x74, 0x72, 0x73, 0x12, 0x24, 0x0a, 0x07,
-	0x70, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e,
-	0x70, 0x62, 0x2e, 0x50, 0x61, 0x72, 0x65, 0x6e, 0x74, 0x52, 0x07, 0x70, 0x61, 0x72, 0x65, 0x6e,
-	0x74, 0x73, 0x12, 0x27, 0x0a, 0x08, 0x73, 0x79, 0x6d, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x18, 0x07,
-	0x20, 0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x70, 0x62, 0x2e, 0x53, 0x79, 0x6d, 0x6c, 0x69, 0x6e,
-	0x6b, 0x52, 0x08, 0x73, 0x79, 0x6d, 0x6c, 0x69, 0x6e, 0x6b, 0x73, 0x12, 0x2b, 0x0a, 0x09, 0x73,
-	0x75, 0x73, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64, 0x18, 0x08, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0d,
-	0x2e, 0x70, 0x62, 0x2e, 0x53, 0x75, 0x73, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64, 0x52, 0x09, 0x73,
-	0x75, 0x73, 0x74, 0x61, 0x69, 0x6e, 0x65, 0x64, 0x12, 0x27, 0x0a, 0x08, 0x64, 0x65, 0x6c, 0x66,
-	0x69, 0x6c, 0x65, 0x73, 0x18, 0x09, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x70, 0x62, 0x2e,
-	0x44, 0x65, 0x6c, 0x46, 0x69, 0x6c, 0x65, 0x52, 0x08, 0x64, 0x65, 0x6c, 0x66, 0x69, 0x6c, 0x65,
-	0x73, 0x12, 0x24, 0x0a, 0x08, 0x64, 0x69, 0x72, 0x73, 0x74, 0x61, 0x74, 0x73, 0x18, 0x0a, 0x20,
-	0x03, 0x28, 0x0b, 0x32, 0x08, 0x2e, 0x70, 0x62, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x52, 0x08, 0x64,
-	0x69, 0x72, 0x73, 0x74, 0x61, 0x74, 0x73, 0x12, 0x21, 0x0a, 0x06, 0x71, 0x75, 0x6f, 0x74, 0x61,
-	0x73, 0x18, 0x0b, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x70, 0x62, 0x2e, 0x51, 0x75, 0x6f,
-	0x74, 0x61, 0x52, 0x06, 0x71, 0x75, 0x6f, 0x74, 0x61, 0x73, 0x12, 0x1b, 0x0a, 0x04, 0x61, 0x63,
-	0x6c, 0x73, 0x18, 0x0c, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x07, 0x2e, 0x70, 0x62, 0x2e, 0x41, 0x63,
-	0x6c, 0x52, 0x04, 0x61, 0x63, 0x6c, 0x73, 0x12, 0x27, 0x0a, 0x08, 0x63, 0x6f, 0x75, 0x6e, 0x74,
-	0x65, 0x72, 0x73, 0x18, 0x0d, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0b, 0x2e, 0x70, 0x62, 0x2e, 0x43,
-	0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x52, 0x08, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x73,
-	0x12, 0x2d, 0x0a, 0x0a, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x6c, 0x6f, 0x67, 0x73, 0x18, 0x0e,
-	0x20, 0x03, 0x28, 0x0b, 0x32, 0x0d, 0x2e, 0x70, 0x62, 0x2e, 0x43, 0x68, 0x61, 0x6e, 0x67, 0x65,
-	0x4c, 0x6f, 0x67, 0x52, 0x0a, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x6c, 0x6f, 0x67, 0x73, 0x12,
-	0x29, 0x0a, 0x0a, 0x75, 0x73, 0x65, 0x72, 0x51, 0x75, 0x6f, 0x74, 0x61, 0x73, 0x18, 0x0f, 0x20,
-	0x03, 0x28, 0x0b, 0x32, 0x09, 0x2e, 0x70, 0x62, 0x2e, 0x51, 0x75, 0x6f, 0x74, 0x61, 0x52, 0x0a,
-	0x75, 0x73, 0x65, 0x72, 0x51, 0x75, 0x6f, 0x74, 0x61, 0x73, 0x12, 0x2b, 0x0a, 0x0b, 0x67, 0x72,
-	0x6f, 0x75, 0x70, 0x51, 0x75, 0x6f, 0x74, 0x61, 0x73, 0x18, 0x10, 0x20, 0x03, 0x28, 0x0b, 0x32,
-	0x09, 0x2e, 0x70, 0x62, 0x2e, 0x51, 0x75, 0x6f, 0x74, 0x61, 0x52, 0x0b, 0x67, 0x72, 0x6f, 0x75,
-	0x70, 0x51, 0x75, 0x6f, 0x74, 0x61, 0x73, 0x22, 0xe8, 0x01, 0x0a, 0x06, 0x46, 0x6f, 0x6f, 0x74,
-	0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x61, 0x67, 0x69, 0x63, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x0d, 0x52, 0x05, 0x6d, 0x61, 0x67, 0x69, 0x63, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73,
-	0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69,
-	0x6f, 0x6e, 0x12, 0x2b, 0x0a, 0x05, 0x69, 0x6e, 0x66, 0x6f, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28,
-	0x0b, 0x32, 0x15, 0x2e, 0x70, 0x62, 0x2e, 0x46, 0x6f, 0x6f, 0x74, 0x65, 0x72, 0x2e, 0x49, 0x6e,
-	0x66, 0x6f, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x69, 0x6e, 0x66, 0x6f, 0x73, 0x1a,
-	0x33, 0x0a, 0x07, 0x53, 0x65, 0x67, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x16, 0x0a, 0x06, 0x6f, 0x66,
-	0x66, 0x73, 0x65, 0x74, 0x18, 0x01, 0x20, 0x03, 0x28, 0x04, 0x52, 0x06, 0x6f, 0x66, 0x66, 0x73,
-	0x65, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6e, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
-	0x03, 0x6e, 0x75, 0x6d, 0x1a, 0x4c, 0x0a, 0x0a, 0x49, 0x6e, 0x66, 0x6f, 0x73, 0x45, 0x6e, 0x74,
-	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
-	0x03, 0x6b, 0x65, 0x79, 0x12, 0x28, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x70, 0x62, 0x2e, 0x46, 0x6f, 0x6f, 0x74, 0x65, 0x72, 0x2e,
-	0x53, 0x65, 0x67, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02,
-	0x38, 0x01, 0x42, 0x06, 0x5a, 0x04, 0x2e, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
-	0x6f, 0x33,
-}
+const file_pkg_meta_pb_backup_proto_rawDesc = "" +
+	"\n" +
+	"\x18pkg/meta/pb/backup.proto\x12\x02pb\"\x1c\n" +
+	"\x06Format\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"1\n" +
+	"\aCounter\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value\"5\n" +
+	"\tSustained\x12\x10\n" +
+	"\x03sid\x18\x01 \x01(\x04R\x03sid\x12\x16\n" +
+	"\x06inodes\x18\x02 \x03(\x04R\x06inodes\"O\n" +
+	"\aDelFile\x12\x14\n" +
+	"\x05inode\x18\x01 \x01(\x04R\x05inode\x12\x16\n" +
+	"\x06length\x18\x02 \x01(\x04R\x06length\x12\x16\n" +
+	"\x06expire\x18\x03 \x01(\x03R\x06expire\"B\n" +
+	"\bSliceRef\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\rR\x04size\x12\x12\n" +
+	"\x04refs\x18\x03 \x01(\x03R\x04refs\")\n" +
+	"\x03Acl\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\rR\x02id\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\"G\n" +
+	"\x05Xattr\x12\x14\n" +
+	"\x05inode\x18\x01 \x01(\x04R\x05inode\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\fR\x05value\"\xa5\x01\n" +
+	"\x05Quota\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x04R\x03key\x12\x1a\n" +
+	"\bmaxSpace\x18\x02 \x01(\x03R\bmaxSpace\x12\x1c\n" +
+	"\tmaxInodes\x18\x03 \x01(\x03R\tmaxInodes\x12\x1c\n" +
+	"\tusedSpace\x18\x04 \x01(\x03R\tusedSpace\x12\x1e\n" +
+	"\n" +
+	"usedInodes\x18\x05 \x01(\x03R\n" +
+	"usedInodes\x12\x12\n" +
+	"\x04type\x18\x06 \x01(\rR\x04type\"z\n" +
+	"\x04Stat\x12\x14\n" +
+	"\x05inode\x18\x01 \x01(\x04R\x05inode\x12\x1e\n" +
+	"\n" +
+	"dataLength\x18\x02 \x01(\x03R\n" +
+	"dataLength\x12\x1c\n" +
+	"\tusedSpace\x18\x03 \x01(\x03R\tusedSpace\x12\x1e\n" +
+	"\n" +
+	"usedInodes\x18\x04 \x01(\x03R\n" +
+	"usedInodes\"0\n" +
+	"\x04Node\x12\x14\n" +
+	"\x05inode\x18\x01 \x01(\x04R\x05inode\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\"\\\n" +
+	"\x04Edge\x12\x16\n" +
+	"\x06parent\x18\x01 \x01(\x04R\x06parent\x12\x14\n" +
+	"\x05inode\x18\x02 \x01(\x04R\x05inode\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\fR\x04name\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\rR\x04type\"H\n" +
+	"\x06Parent\x12\x14\n" +
+	"\x05inode\x18\x01 \x01(\x04R\x05inode\x12\x16\n" +
+	"\x06parent\x18\x02 \x01(\x04R\x06parent\x12\x10\n" +
+	"\x03cnt\x18\x03 \x01(\x03R\x03cnt\"K\n" +
+	"\x05Chunk\x12\x14\n" +
+	"\x05inode\x18\x01 \x01(\x04R\x05inode\x12\x14\n" +
+	"\x05index\x18\x02 \x01(\rR\x05index\x12\x16\n" +
+	"\x06slices\x18\x03 \x01(\fR\x06slices\"7\n" +
+	"\aSymlink\x12\x14\n" +
+	"\x05inode\x18\x01 \x01(\x04R\x05inode\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\fR\x06target\";\n" +
+	"\tChangeLog\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\x12\x14\n" +
+	"\x05entry\x18\x02 \x01(\fR\x05entry\"H\n" +
+	"\n" +
+	"DedupBlock\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x04R\x02id\x12\x12\n" +
+	"\x04indx\x18\x02 \x01(\rR\x04indx\x12\x16\n" +
+	"\x06chunks\x18\x03 \x01(\tR\x06chunks\"\\\n" +
+	"\bDedupRef\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\rR\x04size\x12\x12\n" +
+	"\x04refs\x18\x03 \x01(\x03R\x04refs\x12\x14\n" +
+	"\x05freed\x18\x04 \x01(\x03R\x05freed\"\xd2\x05\n" +
+	"\x05Batch\x12\x1e\n" +
+	"\x05nodes\x18\x01 \x03(\v2\b.pb.NodeR\x05nodes\x12\x1e\n" +
+	"\x05edges\x18\x02 \x03(\v2\b.pb.EdgeR\x05edges\x12!\n" +
+	"\x06chunks\x18\x03 \x03(\v2\t.pb.ChunkR\x06chunks\x12*\n" +
+	"\tsliceRefs\x18\x04 \x03(\v2\f.pb.SliceRefR\tsliceRefs\x12!\n" +
+	"\x06xattrs\x18\x05 \x03(\v2\t.pb.XattrR\x06xattrs\x12$\n" +
+	"\aparents\x18\x06 \x03(\v2\n" +
+	".pb.ParentR\aparents\x12'\n" +
+	"\bsymlinks\x18\a \x03(\v2\v.pb.SymlinkR\bsymlinks\x12+\n" +
+	"\tsustained\x18\b \x03(\v2\r.pb.SustainedR\tsustained\x12'\n" +
+	"\bdelfiles\x18\t \x03(\v2\v.pb.DelFileR\bdelfiles\x12$\n" +
+	"\bdirstats\x18\n" +
+	" \x03(\v2\b.pb.StatR\bdirstats\x12!\n" +
+	"\x06quotas\x18\v \x03(\v2\t.pb.QuotaR\x06quotas\x12\x1b\n" +
+	"\x04acls\x18\f \x03(\v2\a.pb.AclR\x04acls\x12'\n" +
+	"\bcounters\x18\r \x03(\v2\v.pb.CounterR\bcounters\x12-\n" +
+	"\n" +
+	"changelogs\x18\x0e \x03(\v2\r.pb.ChangeLogR\n" +
+	"changelogs\x12)\n" +
+	"\n" +
+	"userQuotas\x18\x0f \x03(\v2\t.pb.QuotaR\n" +
+	"userQuotas\x12+\n" +
+	"\vgroupQuotas\x18\x10 \x03(\v2\t.pb.QuotaR\vgroupQuotas\x120\n" +
+	"\vdedupBlocks\x18\x11 \x03(\v2\x0e.pb.DedupBlockR\vdedupBlocks\x12*\n" +
+	"\tdedupRefs\x18\x12 \x03(\v2\f.pb.DedupRefR\tdedupRefs\"\xe8\x01\n" +
+	"\x06Footer\x12\x14\n" +
+	"\x05magic\x18\x01 \x01(\rR\x05magic\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\rR\aversion\x12+\n" +
+	"\x05infos\x18\x03 \x03(\v2\x15.pb.Footer.InfosEntryR\x05infos\x1a3\n" +
+	"\aSegInfo\x12\x16\n" +
+	"\x06offset\x18\x01 \x03(\x04R\x06offset\x12\x10\n" +
+	"\x03num\x18\x02 \x01(\x04R\x03num\x1aL\n" +
+	"\n" +
+	"InfosEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12(\n" +
+	"\x05value\x18\x02 \x01(\v2\x12.pb.Footer.SegInfoR\x05value:\x028\x01B\x06Z\x04./pbb\x06proto3"
 
 var (
 	file_pkg_meta_pb_backup_proto_rawDescOnce sync.Once
-	file_pkg_meta_pb_backup_proto_rawDescData = file_pkg_meta_pb_backup_proto_rawDesc
+	file_pkg_meta_pb_backup_proto_rawDescData []byte
 )
 
 func file_pkg_meta_pb_backup_proto_rawDescGZIP() []byte {
 	file_pkg_meta_pb_backup_proto_rawDescOnce.Do(func() {
-		file_pkg_meta_pb_backup_proto_rawDescData = protoimpl.X.CompressGZIP(file_pkg_meta_pb_backup_proto_rawDescData)
+		file_pkg_meta_pb_backup_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pkg_meta_pb_backup_proto_rawDesc), len(file_pkg_meta_pb_backup_proto_rawDesc)))
 	})
 	return file_pkg_meta_pb_backup_proto_rawDescData
 }
 
-var file_pkg_meta_pb_backup_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
+var file_pkg_meta_pb_backup_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
 var file_pkg_meta_pb_backup_proto_goTypes = []any{
 	(*Format)(nil),         // 0: pb.Format
 	(*Counter)(nil),        // 1: pb.Counter
@@ -1352,10 +1462,12 @@ var file_pkg_meta_pb_backup_proto_goTypes = []any{
 	(*Chunk)(nil),          // 12: pb.Chunk
 	(*Symlink)(nil),        // 13: pb.Symlink
 	(*ChangeLog)(nil),      // 14: pb.ChangeLog
-	(*Batch)(nil),          // 15: pb.Batch
-	(*Footer)(nil),         // 16: pb.Footer
-	(*Footer_SegInfo)(nil), // 17: pb.Footer.SegInfo
-	nil,                    // 18: pb.Footer.InfosEntry
+	(*DedupBlock)(nil),     // 15: pb.DedupBlock
+	(*DedupRef)(nil),       // 16: pb.DedupRef
+	(*Batch)(nil),          // 17: pb.Batch
+	(*Footer)(nil),         // 18: pb.Footer
+	(*Footer_SegInfo)(nil), // 19: pb.Footer.SegInfo
+	nil,                    // 20: pb.Footer.InfosEntry
 }
 var file_pkg_meta_pb_backup_proto_depIdxs = []int32{
 	9,  // 0: pb.Batch.nodes:type_name -> pb.Node
@@ -1374,13 +1486,15 @@ var file_pkg_meta_pb_backup_proto_depIdxs = []int32{
 	14, // 13: pb.Batch.changelogs:type_name -> pb.ChangeLog
 	7,  // 14: pb.Batch.userQuotas:type_name -> pb.Quota
 	7,  // 15: pb.Batch.groupQuotas:type_name -> pb.Quota
-	18, // 16: pb.Footer.infos:type_name -> pb.Footer.InfosEntry
-	17, // 17: pb.Footer.InfosEntry.value:type_name -> pb.Footer.SegInfo
-	18, // [18:18] is the sub-list for method output_type
-	18, // [18:18] is the sub-list for method input_type
-	18, // [18:18] is the sub-list for extension type_name
-	18, // [18:18] is the sub-list for extension extendee
-	0,  // [0:18] is the sub-list for field type_name
+	15, // 16: pb.Batch.dedupBlocks:type_name -> pb.DedupBlock
+	16, // 17: pb.Batch.dedupRefs:type_name -> pb.DedupRef
+	20, // 18: pb.Footer.infos:type_name -> pb.Footer.InfosEntry
+	19, // 19: pb.Footer.InfosEntry.value:type_name -> pb.Footer.SegInfo
+	20, // [20:20] is the sub-list for method output_type
+	20, // [20:20] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
 }
 
 func init() { file_pkg_meta_pb_backup_proto_init() }
@@ -1392,9 +1506,9 @@ func file_pkg_meta_pb_backup_proto_init() {
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
-			RawDescriptor: file_pkg_meta_pb_backup_proto_rawDesc,
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_meta_pb_backup_proto_rawDesc), len(file_pkg_meta_pb_backup_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   19,
+			NumMessages:   21,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
@@ -1403,7 +1517,6 @@ func file_pkg_meta_pb_backup_proto_init() {
 		MessageInfos:      file_pkg_meta_pb_backup_proto_msgTypes,
 	}.Build()
 	File_pkg_meta_pb_backup_proto = out.File
-	file_pkg_meta_pb_backup_proto_rawDesc = nil
 	file_pkg_meta_pb_backup_proto_goTypes = nil
 	file_pkg_meta_pb_backup_proto_depIdxs = nil
 }
@@ -30,6 +30,7 @@ import (
 	"fmt"
 	"hash/fnv"
 	"io"
+	"math"
 	"math/rand"
 	"net"
 	"net/url"
@@ -79,6 +80,13 @@ import (
 	Quota used inodes: dirQuotaUsedInodes -> { $inode -> usedInodes }
 	Acl: acl -> { $acl_id -> acl }
 	KrbToken: krbToken -> { $token_id -> token }
+	Snapshots: snapshots -> { $name -> snapshot info }
+	Tier policies: tierPolicies -> { $inode -> policy info }
+	Directory compression: dirCompression -> { $inode -> algorithm }
+	Dedup blocks: dedupBlocks -> { $sliceId_$indx -> $hash_$size,... }
+	Dedup refs:   dedupRefs -> { $hash_$size -> refcount }
+	Dedup freed:  dedupFreed -> { $hash_$size -> seconds (-1 for being deleted) }
+	Changelog consumers: changelogConsumers -> { $group -> consumer info }
 
 	Redis features:
 	  Sorted Set: 1.2+
@@ -774,6 +782,34 @@ func (m *redisMeta) krbTokenKey() string {
 	return m.prefix + "krbToken"
 }
 
+func (m *redisMeta) snapshotsKey() string {
+	return m.prefix + "snapshots"
+}
+
+func (m *redisMeta) tierPoliciesKey() string {
+	return m.prefix + "tierPolicies"
+}
+
+func (m *redisMeta) dirCompressionKey() string {
+	return m.prefix + "dirCompression"
+}
+
+func (m *redisMeta) dedupBlocksKey() string {
+	return m.prefix + "dedupBlocks"
+}
+
+func (m *redisMeta) dedupRefsKey() string {
+	return m.prefix + "dedupRefs"
+}
+
+func (m *redisMeta) dedupFreedKey() string {
+	return m.prefix + "dedupFreed"
+}
+
+func (m *redisMeta) changelogConsumersKey() string {
+	return m.prefix + "changelogConsumers"
+}
+
 func (m *redisMeta) delfiles() string {
 	return m.prefix + "delfiles"
 }
@@ -1686,7 +1722,28 @@ func (m *redisMeta) ScanChangelog(ctx Context, last int64, handler func(ver int6
 	}
 }
 
-func (m *redisMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines int64) error {
+// countExpiredChangelog returns the number of leading changelog entries older than cutoff.
+func (m *redisMeta) countExpiredChangelog(ctx Context, llen int64, cutoff time.Time) (int64, error) {
+	const batchSize = 1000
+	for pos := int64(0); pos < llen; pos += batchSize {
+		entries, e := m.rdb.LRange(ctx, m.txnLogKey(), pos, pos+batchSize-1).Result()
+		if e != nil {
+			return 0, e
+		}
+		for i, entry := range entries {
+			t, e := parseChangelogTime(entry)
+			if e != nil {
+				continue
+			}
+			if !t.Before(cutoff) {
+				return pos + int64(i), nil
+			}
+		}
+	}
+	return llen, nil
+}
+
+func (m *redisMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines int64, keep int64, forceAge time.Duration) error {
 	llen, err := m.rdb.LLen(ctx, m.txnLogKey()).Result()
 	if err != nil || llen == 0 {
 		return err
@@ -1697,33 +1754,33 @@ func (m *redisMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLin
 		trimPos = llen - maxLines
 	}
 	if maxAge > 0 {
-		cutoff := time.Now().Add(-maxAge)
-		const batchSize = 1000
-		allExpired := true
-		for pos := int64(0); pos < llen; pos += batchSize {
-			entries, e := m.rdb.LRange(ctx, m.txnLogKey(), pos, pos+batchSize-1).Result()
-			if e != nil {
-				return e
-			}
-			for i, entry := range entries {
-				t, e := parseChangelogTime(entry)
-				if e != nil {
-					continue
-				}
-				if !t.Before(cutoff) {
-					if tp := pos + int64(i); tp > trimPos {
-						trimPos = tp
-					}
-					allExpired = false
-					break
-				}
+		expired, err := m.countExpiredChangelog(ctx, llen, time.Now().Add(-maxAge))
+		if err != nil {
+			return err
+		}
+		if expired > trimPos {
+			trimPos = expired
+		}
+	}
+	if trimPos > 0 && keep < math.MaxInt64 {
+		lastLog, err := m.rdb.Get(ctx, m.txnLastLog()).Int64()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		// the versions of entries are (lastLog-llen, lastLog], keep the ones not acknowledged by consumers
+		limit := keep - (lastLog - llen)
+		if limit < trimPos && forceAge > 0 {
+			forced, err := m.countExpiredChangelog(ctx, llen, time.Now().Add(-forceAge))
+			if err != nil {
+				return err
 			}
-			if !allExpired {
-				break
+			if forced > limit {
+				logger.Warnf("remove %d changelog entries older than %s which are not consumed yet", forced-limit, forceAge)
+				limit = forced
 			}
 		}
-		if allExpired && llen > trimPos {
-			trimPos = llen
+		if limit < trimPos {
+			trimPos = limit
 		}
 	}
 	if trimPos <= 0 {
@@ -3872,6 +3929,7 @@ func (m *redisMeta) cleanupLeakedInodes(delete bool) {
 	var foundInodes = make(map[Ino]struct{})
 	foundInodes[RootInode] = struct{}{}
 	foundInodes[TrashInode] = struct{}{}
+	foundInodes[SnapshotInode] = struct{}{} // no entry in the root
 	cutoff := time.Now().Add(time.Hour * -1)
 	prefix := len(m.prefix)
 
@@ -4272,9 +4330,7 @@ func (m *redisMeta) doRepair(ctx Context, inode Ino, attr *Attr) syscall.Errno {
 	}, m.inodeKey(inode), m.entryKey(inode)))
 }
 
-func (m *redisMeta) GetXattr(ctx Context, inode Ino, name string, vbuff *[]byte) syscall.Errno {
-	defer m.timeit("GetXattr", time.Now())
-	inode = m.checkRoot(inode)
+func (m *redisMeta) doGetXattr(ctx Context, inode Ino, name string, vbuff *[]byte) syscall.Errno {
 	var err error
 	*vbuff, err = m.rdb.HGet(ctx, m.xattrKey(inode), name).Bytes()
 	if err == redis.Nil {
@@ -5021,6 +5077,10 @@ func (m *redisMeta) DumpMeta(w io.Writer, root Ino, threads int, keepSecret, fas
 	if root != RootInode {
 		dm.UserQuotas = nil
 		dm.GroupQuotas = nil
+	} else if err := m.dumpedDedup(ctx, dm); err != nil {
+		return err
+	} else if err := m.dumpedSnapshots(ctx, dm); err != nil {
+		return err
 	}
 	if !keepSecret && dm.Setting.SecretKey != "" {
 		dm.Setting.SecretKey = "removed"
@@ -5081,6 +5141,24 @@ func (m *redisMeta) DumpMeta(w io.Writer, root Ino, threads int, keepSecret, fas
 			return err
 		}
 	}
+	if len(dm.Snapshots) > 0 {
+		snaps := &DumpedEntry{
+			Name: "SnapTree",
+			Attr: &DumpedAttr{
+				Inode: SnapshotInode,
+				Type:  typeToString(TypeDirectory),
+			},
+		}
+		if err = m.dumpEntries(snaps); err != nil {
+			return err
+		}
+		if _, err = bw.WriteString(","); err != nil {
+			return err
+		}
+		if err = m.dumpDir(SnapshotInode, snaps, bw, 1, threads, showProgress); err != nil {
+			return err
+		}
+	}
 	if _, err = bw.WriteString("\n}\n"); err != nil {
 		return err
 	}
@@ -5279,9 +5357,14 @@ func (m *redisMeta) LoadMeta(r io.Reader) (err error) {
 			}
 		}
 	}
-	_, err = p.Exec(ctx)
+	if _, err = p.Exec(ctx); err != nil {
+		return err
+	}
 	m.loadDumpedQuotas(ctx, dm)
-	return err
+	if err = m.loadDumpedDedup(ctx, dm); err != nil {
+		return err
+	}
+	return m.loadDumpedSnapshots(ctx, dm)
 }
 
 func (m *redisMeta) loadQuotasForDump(ctx Context, quotaKey string) map[uint64]*DumpedQuota {
@@ -6132,6 +6215,366 @@ func (m *redisMeta) doListTokens(ctx Context) (tokens map[uint32][]byte, st sysc
 	return tokens, 0
 }
 
+func (m *redisMeta) doSaveSnapshot(ctx Context, name string, info []byte) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *redis.Tx) error {
+		exist, err := tx.HExists(ctx, m.snapshotsKey(), name).Result()
+		if err != nil {
+			return err
+		}
+		if exist {
+			return syscall.EEXIST
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, m.snapshotsKey(), name, info)
+			m.genLog(ctx, pipe, time.Now(), "SAVESNAPSHOT(%s,%s)", logEncode([]byte(name)), logEncode(info))
+			return nil
+		})
+		return err
+	}, m.snapshotsKey()))
+}
+
+func (m *redisMeta) doDeleteSnapshot(ctx Context, name string) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *redis.Tx) error {
+		exist, err := tx.HExists(ctx, m.snapshotsKey(), name).Result()
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return syscall.ENOENT
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, m.snapshotsKey(), name)
+			m.genLog(ctx, pipe, time.Now(), "DELETESNAPSHOT(%s)", logEncode([]byte(name)))
+			return nil
+		})
+		return err
+	}, m.snapshotsKey()))
+}
+
+func (m *redisMeta) doListSnapshots(ctx Context) (snapshots map[string][]byte, st syscall.Errno) {
+	vals, err := m.rdb.HGetAll(ctx, m.snapshotsKey()).Result()
+	if err != nil {
+		return nil, errno(err)
+	}
+	snapshots = make(map[string][]byte, len(vals))
+	for k, v := range vals {
+		snapshots[k] = []byte(v)
+	}
+	return snapshots, 0
+}
+
+func (m *redisMeta) doSaveTierPolicy(ctx Context, inode Ino, info []byte) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *redis.Tx) error {
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, m.tierPoliciesKey(), inode.String(), info)
+			m.genLog(ctx, pipe, time.Now(), "SETTIERPOLICY(%d,%s)", inode, logEncode(info))
+			return nil
+		})
+		return err
+	}, m.tierPoliciesKey()))
+}
+
+func (m *redisMeta) doDeleteTierPolicy(ctx Context, inode Ino) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *redis.Tx) error {
+		exist, err := tx.HExists(ctx, m.tierPoliciesKey(), inode.String()).Result()
+		if err != nil {
+			return err
+		}
+		if !exist {
+			return syscall.ENOENT
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HDel(ctx, m.tierPoliciesKey(), inode.String())
+			m.genLog(ctx, pipe, time.Now(), "DELETETIERPOLICY(%d)", inode)
+			return nil
+		})
+		return err
+	}, m.tierPoliciesKey()))
+}
+
+func (m *redisMeta) doListTierPolicies(ctx Context) (policies map[Ino][]byte, st syscall.Errno) {
+	vals, err := m.rdb.HGetAll(ctx, m.tierPoliciesKey()).Result()
+	if err != nil {
+		return nil, errno(err)
+	}
+	policies = make(map[Ino][]byte, len(vals))
+	for k, v := range vals {
+		inode, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			logger.Errorf("invalid inode of tier policy: %s", k)
+			continue
+		}
+		policies[Ino(inode)] = []byte(v)
+	}
+	return policies, 0
+}
+
+func (m *redisMeta) doSetDirCompression(ctx Context, inode Ino, algr string) syscall.Errno {
+	return errno(m.rdb.HSet(ctx, m.dirCompressionKey(), inode.String(), algr).Err())
+}
+
+func (m *redisMeta) doDeleteDirCompression(ctx Context, inode Ino) syscall.Errno {
+	n, err := m.rdb.HDel(ctx, m.dirCompressionKey(), inode.String()).Result()
+	if err == nil && n == 0 {
+		return syscall.ENOENT
+	}
+	return errno(err)
+}
+
+func (m *redisMeta) doListDirCompressions(ctx Context) (compressions map[Ino]string, st syscall.Errno) {
+	vals, err := m.rdb.HGetAll(ctx, m.dirCompressionKey()).Result()
+	if err != nil {
+		return nil, errno(err)
+	}
+	compressions = make(map[Ino]string, len(vals))
+	for k, v := range vals {
+		inode, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			logger.Errorf("invalid inode of directory compression: %s", k)
+			continue
+		}
+		compressions[Ino(inode)] = v
+	}
+	return compressions, 0
+}
+
+func (m *redisMeta) dedupBlockField(id uint64, indx uint32) string {
+	return fmt.Sprintf("%d_%d", id, indx)
+}
+
+func (m *redisMeta) doAddDedupBlock(ctx Context, id uint64, indx uint32, chunks []DedupChunk) ([]bool, syscall.Errno) {
+	field := m.dedupBlockField(id, indx)
+	counts := dedupRefCounts(chunks)
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	var added *redis.BoolCmd
+	var refs map[string]*redis.IntCmd
+	var freed []interface{}
+	// the objects being deleted are marked in dedupFreed, which is watched so they are not referenced again
+	err := m.txn(ctx, func(tx *redis.Tx) error {
+		var err error
+		if freed, err = tx.HMGet(ctx, m.dedupFreedKey(), names...).Result(); err != nil {
+			return err
+		}
+		for _, f := range freed {
+			if s, ok := f.(string); ok && s == strconv.Itoa(dedupDeleting) {
+				return syscall.EAGAIN
+			}
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			added = pipe.HSetNX(ctx, m.dedupBlocksKey(), field, encodeDedupChunks(chunks))
+			refs = make(map[string]*redis.IntCmd, len(names))
+			for _, name := range names {
+				refs[name] = pipe.HIncrBy(ctx, m.dedupRefsKey(), name, counts[name])
+			}
+			pipe.HDel(ctx, m.dedupFreedKey(), names...)
+			return nil
+		})
+		return err
+	}, m.dedupFreedKey())
+	if err != nil {
+		return nil, errno(err)
+	}
+	exists := make([]bool, len(chunks))
+	if !added.Val() { // the block is uploaded again
+		for _, name := range names {
+			if err = m.rdb.HIncrBy(ctx, m.dedupRefsKey(), name, -counts[name]).Err(); err != nil {
+				logger.Warnf("drop duplicated reference of %s: %s", name, err)
+			}
+		}
+		for i := range exists {
+			exists[i] = true
+		}
+		return exists, 0
+	}
+	existed := make(map[string]bool, len(names))
+	for i, name := range names {
+		existed[name] = freed[i] != nil || refs[name].Val() > counts[name]
+	}
+	for i, c := range chunks {
+		exists[i] = existed[dedupRefName(c.Hash, c.Size)]
+	}
+	return exists, 0
+}
+
+func (m *redisMeta) doGetDedupBlock(ctx Context, id uint64, indx uint32) ([]DedupChunk, syscall.Errno) {
+	val, err := m.rdb.HGet(ctx, m.dedupBlocksKey(), m.dedupBlockField(id, indx)).Result()
+	if err == redis.Nil {
+		return nil, 0
+	} else if err != nil {
+		return nil, errno(err)
+	}
+	return parseDedupChunks(val), 0
+}
+
+func (m *redisMeta) doRemoveDedupBlock(ctx Context, id uint64, indx uint32) ([]DedupChunk, syscall.Errno) {
+	field := m.dedupBlockField(id, indx)
+	val, err := m.rdb.HGet(ctx, m.dedupBlocksKey(), field).Result()
+	if err == redis.Nil {
+		return nil, 0
+	} else if err != nil {
+		return nil, errno(err)
+	}
+	chunks := parseDedupChunks(val)
+	// only one of the clients removing the same block could drop the references
+	if n, err := m.rdb.HDel(ctx, m.dedupBlocksKey(), field).Result(); err != nil {
+		return nil, errno(err)
+	} else if n == 0 {
+		return chunks, 0
+	}
+	counts := dedupRefCounts(chunks)
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	// the objects are still referenced by this block, so they can't be marked as being deleted
+	refs, err := m.rdb.HMGet(ctx, m.dedupRefsKey(), names...).Result()
+	if err != nil {
+		return chunks, errno(err)
+	}
+	now := time.Now().Unix()
+	_, err = m.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, name := range names {
+			pipe.HIncrBy(ctx, m.dedupRefsKey(), name, -counts[name])
+			var n int64
+			if s, ok := refs[i].(string); ok {
+				n, _ = strconv.ParseInt(s, 10, 64)
+			}
+			if n <= counts[name] {
+				pipe.HSet(ctx, m.dedupFreedKey(), name, now)
+			}
+		}
+		return nil
+	})
+	return chunks, errno(err)
+}
+
+func (m *redisMeta) doScanDedupBlocks(ctx Context, scan func(id uint64, indx uint32, chunks []DedupChunk) bool) syscall.Errno {
+	var stopped bool
+	err := m.hscan(ctx, m.dedupBlocksKey(), func(keys []string) error {
+		for i := 0; i < len(keys); i += 2 {
+			var id uint64
+			var indx uint32
+			if _, err := fmt.Sscanf(keys[i], "%d_%d", &id, &indx); err != nil {
+				logger.Errorf("invalid dedup block: %s", keys[i])
+				continue
+			}
+			if !scan(id, indx, parseDedupChunks(keys[i+1])) {
+				stopped = true
+				return syscall.EINTR
+			}
+		}
+		return nil
+	})
+	if stopped {
+		err = nil
+	}
+	return errno(err)
+}
+
+func (m *redisMeta) doScanDedupRefs(ctx Context, scan func(ref *DedupRef) bool) syscall.Errno {
+	freed, err := m.rdb.HGetAll(ctx, m.dedupFreedKey()).Result()
+	if err != nil {
+		return errno(err)
+	}
+	var stopped bool
+	err = m.hscan(ctx, m.dedupRefsKey(), func(keys []string) error {
+		for i := 0; i < len(keys); i += 2 {
+			hash, size, ok := parseDedupRefName(keys[i])
+			if !ok {
+				logger.Errorf("invalid dedup reference: %s", keys[i])
+				continue
+			}
+			refs, _ := strconv.ParseInt(keys[i+1], 10, 64)
+			ref := &DedupRef{Hash: hash, Size: size, Refs: refs}
+			if refs <= 0 {
+				ref.Freed, _ = strconv.ParseInt(freed[keys[i]], 10, 64)
+			}
+			if !scan(ref) {
+				stopped = true
+				return syscall.EINTR
+			}
+		}
+		return nil
+	})
+	if stopped {
+		err = nil
+	}
+	return errno(err)
+}
+
+func (m *redisMeta) doMarkDedupRef(ctx Context, hash string, size uint32, before int64) (marked bool, st syscall.Errno) {
+	ref := dedupRefName(hash, size)
+	st = errno(m.txn(ctx, func(tx *redis.Tx) error {
+		marked = false
+		refs, err := tx.HGet(ctx, m.dedupRefsKey(), ref).Int64()
+		if err == redis.Nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		freed, err := tx.HGet(ctx, m.dedupFreedKey(), ref).Int64()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if refs > 0 || freed >= before {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, m.dedupFreedKey(), ref, dedupDeleting)
+			return nil
+		})
+		marked = err == nil
+		return err
+	}, m.dedupRefsKey(), m.dedupFreedKey()))
+	return
+}
+
+func (m *redisMeta) doDeleteDedupRef(ctx Context, hash string, size uint32) syscall.Errno {
+	ref := dedupRefName(hash, size)
+	_, err := m.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, m.dedupRefsKey(), ref)
+		pipe.HDel(ctx, m.dedupFreedKey(), ref)
+		return nil
+	})
+	return errno(err)
+}
+
+// the cursors of consumers are not logged, otherwise acknowledging would generate new entries
+func (m *redisMeta) doSaveChangelogConsumer(ctx Context, group string, info []byte) syscall.Errno {
+	return errno(m.rdb.HSet(ctx, m.changelogConsumersKey(), group, info).Err())
+}
+
+func (m *redisMeta) doDeleteChangelogConsumer(ctx Context, group string) syscall.Errno {
+	n, err := m.rdb.HDel(ctx, m.changelogConsumersKey(), group).Result()
+	if err == nil && n == 0 {
+		return syscall.ENOENT
+	}
+	return errno(err)
+}
+
+func (m *redisMeta) doListChangelogConsumers(ctx Context) (consumers map[string][]byte, st syscall.Errno) {
+	vals, err := m.rdb.HGetAll(ctx, m.changelogConsumersKey()).Result()
+	if err != nil {
+		return nil, errno(err)
+	}
+	consumers = make(map[string][]byte, len(vals))
+	for k, v := range vals {
+		consumers[k] = []byte(v)
+	}
+	return consumers, 0
+}
+
+func (m *redisMeta) doGetChangelogVersion(ctx Context) (int64, syscall.Errno) {
+	last, err := m.rdb.Get(ctx, m.txnLastLog()).Int64()
+	if err == redis.Nil {
+		return 0, 0
+	}
+	return last, errno(err)
+}
+
 func (m *redisMeta) newDirHandler(inode Ino, plus bool, entries []*Entry) DirHandler {
 	return &redisDirHandler{
 		en:          m,
@@ -52,6 +52,7 @@ func (m *redisMeta) dump(ctx Context, opt *DumpOption, ch chan<- *dumpedResult)
 		m.dumpACL,
 		m.dumpQuota,
 		m.dumpDirStat,
+		m.dumpDedup,
 	}
 	for _, f := range dumps {
 		err := f(ctx, opt, ch)
@@ -713,6 +714,8 @@ func (m *redisMeta) load(ctx Context, typ int, opt *LoadOption, val proto.Messag
 		return m.loadDirStats(ctx, val)
 	case segTypeParent:
 		return m.loadParents(ctx, val)
+	case segTypeDedupBlock, segTypeDedupRef:
+		return m.loadDedup(ctx, val)
 	default:
 		logger.Warnf("skip segment type %d", typ)
 		return nil
@@ -864,6 +867,52 @@ func (m *redisMeta) loadSliceRefs(ctx Context, msg proto.Message) error {
 	return m.rdb.HSet(ctx, m.sliceRefs(), slices).Err()
 }
 
+func (m *redisMeta) loadDedup(ctx Context, msg proto.Message) error {
+	batch := msg.(*pb.Batch)
+	fields := make(map[string]interface{})
+	flush := func(key string) error {
+		if len(fields) == 0 {
+			return nil
+		}
+		err := m.rdb.HSet(ctx, key, fields).Err()
+		fields = make(map[string]interface{})
+		return err
+	}
+	for _, b := range batch.DedupBlocks {
+		fields[m.dedupBlockField(b.Id, b.Indx)] = b.Chunks
+		if len(fields) >= redisBatchSize {
+			if err := flush(m.dedupBlocksKey()); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(m.dedupBlocksKey()); err != nil {
+		return err
+	}
+	for _, r := range batch.DedupRefs {
+		fields[dedupRefName(r.Hash, r.Size)] = r.Refs
+		if len(fields) >= redisBatchSize {
+			if err := flush(m.dedupRefsKey()); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(m.dedupRefsKey()); err != nil {
+		return err
+	}
+	for _, r := range batch.DedupRefs {
+		if r.Freed != 0 {
+			fields[dedupRefName(r.Hash, r.Size)] = r.Freed
+		}
+		if len(fields) >= redisBatchSize {
+			if err := flush(m.dedupFreedKey()); err != nil {
+				return err
+			}
+		}
+	}
+	return flush(m.dedupFreedKey())
+}
+
 var loadLock sync.Mutex
 var maxAclId uint32
 
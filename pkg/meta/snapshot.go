@@ -0,0 +1,251 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// Snapshot is a named, read-only copy of a directory tree. The copy lives under
+// SnapshotInode and shares all the slices with the original tree, so the data
+// is kept alive by the slice references until the snapshot is deleted.
+type Snapshot struct {
+	Name    string
+	Inode   Ino    // root of the copy under SnapshotInode
+	Source  Ino    // root of the original tree
+	Path    string // path of the original tree when the snapshot was taken
+	Created int64
+}
+
+func (m *baseMeta) initSnapshotRoot(ctx Context) syscall.Errno {
+	st := m.en.doGetAttr(ctx, SnapshotInode, nil)
+	if st != syscall.ENOENT {
+		return st
+	}
+	now := time.Now()
+	attr := Attr{
+		Typ:    TypeDirectory,
+		Mode:   0555,
+		Nlink:  2,
+		Length: 4 << 10,
+		Parent: RootInode,
+		Atime:  now.Unix(),
+		Mtime:  now.Unix(),
+		Ctime:  now.Unix(),
+		Full:   true,
+	}
+	return m.en.doRepair(ctx, SnapshotInode, &attr)
+}
+
+// freezeTree sets (or clears) the immutable flag on every node of the tree,
+// which is what keeps a snapshot read-only for all the clients.
+func (m *baseMeta) freezeTree(ctx Context, inode Ino, frozen bool) syscall.Errno {
+	var attr Attr
+	if st := m.en.doGetAttr(ctx, inode, &attr); st != 0 {
+		return st
+	}
+	var st syscall.Errno
+	walkSt := m.walk(ctx, inode, "", &attr, func(ctx Context, ino Ino, p string, a *Attr) {
+		if st != 0 {
+			return
+		}
+		flags := a.Flags | FlagImmutable
+		if !frozen {
+			flags = a.Flags &^ FlagImmutable
+		}
+		if a.Full && flags == a.Flags {
+			return
+		}
+		if !a.Full {
+			var cur Attr
+			if st = m.en.doGetAttr(ctx, ino, &cur); st != 0 {
+				return
+			}
+			flags = cur.Flags | FlagImmutable
+			if !frozen {
+				flags = cur.Flags &^ FlagImmutable
+			}
+		}
+		if st = m.SetAttr(ctx, ino, SetAttrFlag, 0, &Attr{Flags: flags}); st != 0 {
+			logger.Warnf("set flags of inode %d (%s): %s", ino, p, st)
+		}
+	})
+	if walkSt != 0 {
+		return walkSt
+	}
+	return st
+}
+
+func (m *baseMeta) getSnapshot(ctx Context, name string) (*Snapshot, syscall.Errno) {
+	snaps, st := m.ListSnapshots(ctx)
+	if st != 0 {
+		return nil, st
+	}
+	for _, s := range snaps {
+		if s.Name == name {
+			return s, 0
+		}
+	}
+	return nil, syscall.ENOENT
+}
+
+func (m *baseMeta) CreateSnapshot(ctx Context, inode Ino, name string, concurrency uint8, count, total *uint64) syscall.Errno {
+	if m.conf.ReadOnly {
+		return syscall.EROFS
+	}
+	if st := checkInodeName(name); st != 0 {
+		return st
+	}
+	defer m.timeit("CreateSnapshot", time.Now())
+	inode = m.checkRoot(inode)
+	if _, st := m.getSnapshot(ctx, name); st == 0 {
+		return syscall.EEXIST
+	} else if st != syscall.ENOENT {
+		return st
+	}
+	var attr Attr
+	if st := m.GetAttr(ctx, inode, &attr); st != 0 {
+		return st
+	}
+	if attr.Typ != TypeDirectory {
+		return syscall.ENOTDIR
+	}
+	if st := m.initSnapshotRoot(ctx); st != 0 {
+		return st
+	}
+	if st := m.clone(ctx, attr.Parent, inode, SnapshotInode, name, CLONE_MODE_PRESERVE_ATTR, 0, concurrency, count, total); st != 0 {
+		return st
+	}
+	var snap Ino
+	if st := m.en.doLookup(ctx, SnapshotInode, name, &snap, nil); st != 0 {
+		return st
+	}
+	st := m.freezeTree(ctx, snap, true)
+	if st == 0 {
+		var p string
+		if ps := m.GetPaths(ctx, inode); len(ps) > 0 {
+			p = ps[0]
+		}
+		info, _ := json.Marshal(&Snapshot{Name: name, Inode: snap, Source: inode, Path: p, Created: time.Now().Unix()})
+		st = m.en.doSaveSnapshot(ctx, name, info)
+	}
+	if st != 0 {
+		logger.Warnf("create snapshot %s: %s, remove the partial copy", name, st)
+		if e := m.removeSnapshotTree(ctx, name, snap, nil); e != 0 {
+			logger.Errorf("remove snapshot tree %s (%d): %s", name, snap, e)
+		}
+	}
+	return st
+}
+
+func (m *baseMeta) ListSnapshots(ctx Context) ([]*Snapshot, syscall.Errno) {
+	vals, st := m.en.doListSnapshots(ctx)
+	if st != 0 {
+		return nil, st
+	}
+	snaps := make([]*Snapshot, 0, len(vals))
+	for name, v := range vals {
+		var s Snapshot
+		if err := json.Unmarshal(v, &s); err != nil {
+			logger.Warnf("decode snapshot %s: %s", name, err)
+			continue
+		}
+		snaps = append(snaps, &s)
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		if snaps[i].Created != snaps[j].Created {
+			return snaps[i].Created < snaps[j].Created
+		}
+		return snaps[i].Name < snaps[j].Name
+	})
+	return snaps, 0
+}
+
+func (m *baseMeta) removeSnapshotTree(ctx Context, name string, inode Ino, count *uint64) syscall.Errno {
+	if st := m.freezeTree(ctx, inode, false); st != 0 && st != syscall.ENOENT {
+		return st
+	}
+	// Rmdir refuses to touch entries under SnapshotInode, so do it directly
+	concurrent := make(chan int, RmrDefaultThreads)
+	st := m.emptyDir(ctx, inode, true, count, concurrent)
+	if st == 0 {
+		st = m.rmdir(ctx, SnapshotInode, name, true)
+	}
+	if st == syscall.ENOENT {
+		st = 0
+	}
+	return st
+}
+
+func (m *baseMeta) DeleteSnapshot(ctx Context, name string, count *uint64) syscall.Errno {
+	if m.conf.ReadOnly {
+		return syscall.EROFS
+	}
+	defer m.timeit("DeleteSnapshot", time.Now())
+	s, st := m.getSnapshot(ctx, name)
+	if st != 0 {
+		return st
+	}
+	if st = m.removeSnapshotTree(ctx, name, s.Inode, count); st != 0 {
+		return st
+	}
+	return m.en.doDeleteSnapshot(ctx, name)
+}
+
+func (m *baseMeta) RestoreSnapshot(ctx Context, name string, parent Ino, dstName string, concurrency uint8, count, total *uint64) syscall.Errno {
+	if m.conf.ReadOnly {
+		return syscall.EROFS
+	}
+	defer m.timeit("RestoreSnapshot", time.Now())
+	s, st := m.getSnapshot(ctx, name)
+	if st != 0 {
+		return st
+	}
+	if st = m.Clone(ctx, SnapshotInode, s.Inode, parent, dstName, CLONE_MODE_PRESERVE_ATTR, 0, concurrency, count, total); st != 0 {
+		return st
+	}
+	var inode Ino
+	if st = m.en.doLookup(ctx, m.checkRoot(parent), dstName, &inode, nil); st != 0 {
+		return st
+	}
+	return m.freezeTree(ctx, inode, false)
+}
+
+// dumpedSnapshots fills the registry of snapshots into dm, the copies are dumped as SnapTree.
+func (m *baseMeta) dumpedSnapshots(ctx Context, dm *DumpedMeta) error {
+	snaps, st := m.ListSnapshots(ctx)
+	if st != 0 {
+		return fmt.Errorf("list snapshots: %s", st)
+	}
+	dm.Snapshots = snaps
+	return nil
+}
+
+// loadDumpedSnapshots loads the registry of snapshots from dm.
+func (m *baseMeta) loadDumpedSnapshots(ctx Context, dm *DumpedMeta) error {
+	for _, s := range dm.Snapshots {
+		info, _ := json.Marshal(s)
+		if st := m.en.doSaveSnapshot(ctx, s.Name, info); st != 0 {
+			return fmt.Errorf("load snapshot %s: %s", s.Name, st)
+		}
+	}
+	return nil
+}
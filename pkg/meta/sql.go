@@ -159,6 +159,39 @@ type delegationToken struct {
 	Token []byte
 }
 
+type snapshot struct {
+	Name string `xorm:"pk"`
+	Info []byte `xorm:"blob notnull"`
+}
+
+type changelogConsumer struct {
+	Name string `xorm:"pk"`
+	Info []byte `xorm:"blob notnull"`
+}
+
+type tierPolicy struct {
+	Inode Ino    `xorm:"pk"`
+	Info  []byte `xorm:"blob notnull"`
+}
+
+type dirCompression struct {
+	Inode Ino    `xorm:"pk"`
+	Algr  string `xorm:"varchar(32) notnull"`
+}
+
+type dedupBlock struct {
+	Id     uint64 `xorm:"pk chunkid"`
+	Indx   uint32 `xorm:"pk"`
+	Chunks []byte `xorm:"blob notnull"` // $hash_$size of the chunks, separated by comma
+}
+
+type dedupRef struct {
+	Hash  string `xorm:"pk char(64)"`
+	Size  uint32 `xorm:"notnull"`
+	Refs  int64  `xorm:"notnull"`
+	Freed int64  `xorm:"notnull"`
+}
+
 type namedNode struct {
 	node `xorm:"extends"`
 	Name []byte `xorm:"varbinary(255)"`
@@ -609,6 +642,21 @@ func (m *dbMeta) syncAllTables() error {
 	if err := m.syncTable(new(changeLog)); err != nil {
 		return fmt.Errorf("create table changeLog: %s", err)
 	}
+	if err := m.syncTable(new(snapshot)); err != nil {
+		return fmt.Errorf("create table snapshot: %s", err)
+	}
+	if err := m.syncTable(new(changelogConsumer)); err != nil {
+		return fmt.Errorf("create table changelogConsumer: %s", err)
+	}
+	if err := m.syncTable(new(tierPolicy)); err != nil {
+		return fmt.Errorf("create table tierPolicy: %s", err)
+	}
+	if err := m.syncTable(new(dirCompression)); err != nil {
+		return fmt.Errorf("create table dirCompression: %s", err)
+	}
+	if err := m.syncTable(new(dedupBlock), new(dedupRef)); err != nil {
+		return fmt.Errorf("create table dedupBlock, dedupRef: %s", err)
+	}
 	return nil
 }
 
@@ -741,7 +789,7 @@ func (m *dbMeta) Reset() error {
 		&node{}, &edge{}, &symlink{}, &xattr{},
 		&chunk{}, &sliceRef{}, &delslices{},
 		&session{}, &session2{}, &sustained{}, &delfile{},
-		&flock{}, &plock{}, &dirStats{}, &dirQuota{}, &userGroupQuota{}, &detachedNode{}, &acl{}, &delegationToken{}, &changeLog{})
+		&flock{}, &plock{}, &dirStats{}, &dirQuota{}, &userGroupQuota{}, &detachedNode{}, &acl{}, &delegationToken{}, &changeLog{}, &snapshot{}, &changelogConsumer{}, &tierPolicy{}, &dirCompression{}, &dedupBlock{}, &dedupRef{})
 }
 
 func (m *dbMeta) doLoad() (data []byte, err error) {
@@ -763,7 +811,7 @@ func (m *dbMeta) doLoad() (data []byte, err error) {
 
 func (m *dbMeta) doNewSession(sinfo []byte, update bool) error {
 	// add new table
-	err := m.syncTable(new(session2), new(delslices), new(dirStats), new(detachedNode), new(dirQuota), new(userGroupQuota), new(acl), new(delegationToken), new(changeLog))
+	err := m.syncTable(new(session2), new(delslices), new(dirStats), new(detachedNode), new(dirQuota), new(userGroupQuota), new(acl), new(delegationToken), new(changeLog), new(snapshot), new(changelogConsumer), new(tierPolicy), new(dirCompression), new(dedupBlock), new(dedupRef))
 	if err != nil {
 		return fmt.Errorf("update table session2, delslices, dirstats, detachedNode, dirQuota, userGroupQuota, acl, changeLog: %s", err)
 	}
@@ -1121,38 +1169,38 @@ func (m *dbMeta) ScanChangelog(ctx Context, last int64, handler func(ver int64,
 	}
 }
 
-func (m *dbMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines int64) error {
+// lastExpiredChangelog returns the id of the last entry in the leading ones after id which are older than cutoff.
+func (m *dbMeta) lastExpiredChangelog(ctx Context, id int64, cutoff time.Time) (int64, error) {
+	for {
+		var logs []changeLog
+		if err := m.roTxn(ctx, func(s *xorm.Session) error {
+			return s.Where("id > ?", id).Asc("id").Limit(1000).Find(&logs)
+		}); err != nil {
+			return 0, err
+		}
+		if len(logs) == 0 {
+			return id, nil
+		}
+		for _, log := range logs {
+			t, err := parseChangelogTime(log.Entry)
+			if err != nil {
+				continue
+			}
+			if !t.Before(cutoff) {
+				return id, nil
+			}
+			id = log.Id
+		}
+	}
+}
+
+func (m *dbMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines int64, keep int64, forceAge time.Duration) error {
 	var cutoffID int64
 
 	if maxAge > 0 {
-		cutoff := time.Now().Add(-maxAge)
-		for {
-			var logs []changeLog
-			if err := m.roTxn(ctx, func(s *xorm.Session) error {
-				return s.Where("id > ?", cutoffID).Asc("id").Limit(1000).Find(&logs)
-			}); err != nil {
-				return err
-			}
-			if len(logs) == 0 {
-				break
-			}
-			expired := false
-			for _, log := range logs {
-				t, err := parseChangelogTime(log.Entry)
-				if err != nil {
-					continue
-				}
-				if t.Before(cutoff) {
-					cutoffID = log.Id
-					expired = true
-				} else {
-					expired = false
-					break
-				}
-			}
-			if !expired {
-				break
-			}
+		var err error
+		if cutoffID, err = m.lastExpiredChangelog(ctx, 0, time.Now().Add(-maxAge)); err != nil {
+			return err
 		}
 	}
 
@@ -1176,6 +1224,24 @@ func (m *dbMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines
 		}
 	}
 
+	// keep the entries not acknowledged by consumers
+	if cutoffID > keep {
+		limit := keep
+		if forceAge > 0 {
+			forced, err := m.lastExpiredChangelog(ctx, keep, time.Now().Add(-forceAge))
+			if err != nil {
+				return err
+			}
+			if forced > limit {
+				logger.Warnf("remove changelog entries (%d, %d] older than %s which are not consumed yet", limit, min(forced, cutoffID), forceAge)
+				limit = forced
+			}
+		}
+		if limit < cutoffID {
+			cutoffID = limit
+		}
+	}
+
 	deleted := int64(0)
 	if cutoffID > 0 {
 		for {
@@ -4195,9 +4261,7 @@ func (m *dbMeta) doRepair(ctx Context, inode Ino, attr *Attr) syscall.Errno {
 	}, inode))
 }
 
-func (m *dbMeta) GetXattr(ctx Context, inode Ino, name string, vbuff *[]byte) syscall.Errno {
-	defer m.timeit("GetXattr", time.Now())
-	inode = m.checkRoot(inode)
+func (m *dbMeta) doGetXattr(ctx Context, inode Ino, name string, vbuff *[]byte) syscall.Errno {
 	return errno(m.simpleTxn(ctx, func(s *xorm.Session) error {
 		var x = xattr{Inode: inode, Name: name}
 		ok, err := s.Get(&x)
@@ -5045,6 +5109,10 @@ func (m *dbMeta) DumpMeta(w io.Writer, root Ino, threads int, keepSecret, fast,
 		if root != RootInode {
 			dm.UserQuotas = nil
 			dm.GroupQuotas = nil
+		} else if err := m.dumpedDedup(Background(), &dm); err != nil {
+			return err
+		} else if err := m.dumpedSnapshots(Background(), &dm); err != nil {
+			return err
 		}
 		if !keepSecret && dm.Setting.SecretKey != "" {
 			dm.Setting.SecretKey = "removed"
@@ -5102,6 +5170,30 @@ func (m *dbMeta) DumpMeta(w io.Writer, root Ino, threads int, keepSecret, fast,
 				}
 			}
 		}
+		if len(dm.Snapshots) > 0 {
+			var snaps *DumpedEntry
+			if m.snap != nil {
+				snaps = m.dumpEntryFast(SnapshotInode, TypeDirectory)
+			} else {
+				snaps = &DumpedEntry{Attr: &DumpedAttr{Inode: SnapshotInode, Type: typeToString(TypeDirectory)}}
+				if err = m.dumpEntry(s, SnapshotInode, TypeDirectory, snaps, nil); err != nil {
+					return err
+				}
+			}
+			snaps.Name = "SnapTree"
+			if _, err = bw.WriteString(","); err != nil {
+				return err
+			}
+			if m.snap != nil {
+				_ = m.dumpDirFast(SnapshotInode, snaps, bw, 1, showProgress)
+			} else {
+				showProgress(int64(len(snaps.Entries)), 0)
+				if err = m.dumpDir(s, SnapshotInode, snaps, bw, 1, threads, showProgress); err != nil {
+					logger.Errorf("dump snapshots failed: %s", err)
+					return fmt.Errorf("dump snapshots failed") // don't retry
+				}
+			}
+		}
 		if _, err = bw.WriteString("\n}\n"); err != nil {
 			return err
 		}
@@ -5306,8 +5398,14 @@ func (m *dbMeta) LoadMeta(r io.Reader) error {
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
 	m.loadDumpedQuotas(Background(), dm)
-	return err
+	if err = m.loadDumpedDedup(Background(), dm); err != nil {
+		return err
+	}
+	return m.loadDumpedSnapshots(Background(), dm)
 }
 
 type checkDupError func(error) bool
@@ -6060,6 +6158,351 @@ func (m *dbMeta) doListTokens(ctx Context) (tokens map[uint32][]byte, st syscall
 	return tokens, errno(err)
 }
 
+func (m *dbMeta) doSaveSnapshot(ctx Context, name string, info []byte) syscall.Errno {
+	return errno(m.txn(func(s *xorm.Session) error {
+		ok, err := s.Get(&snapshot{Name: name})
+		if err != nil {
+			return err
+		}
+		if ok {
+			return syscall.EEXIST
+		}
+		if _, err = s.Insert(&snapshot{Name: name, Info: info}); err != nil {
+			return err
+		}
+		m.genLog(ctx, s, time.Now().UnixNano(), "SAVESNAPSHOT(%s,%s)", logEncode([]byte(name)), logEncode(info))
+		return nil
+	}))
+}
+
+func (m *dbMeta) doDeleteSnapshot(ctx Context, name string) syscall.Errno {
+	return errno(m.txn(func(s *xorm.Session) error {
+		n, err := s.Delete(&snapshot{Name: name})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return syscall.ENOENT
+		}
+		m.genLog(ctx, s, time.Now().UnixNano(), "DELETESNAPSHOT(%s)", logEncode([]byte(name)))
+		return nil
+	}))
+}
+
+func (m *dbMeta) doListSnapshots(ctx Context) (snapshots map[string][]byte, st syscall.Errno) {
+	err := m.roTxn(ctx, func(s *xorm.Session) error {
+		var ss []snapshot
+		if err := s.Find(&ss); err != nil {
+			return err
+		}
+		snapshots = make(map[string][]byte, len(ss))
+		for _, t := range ss {
+			snapshots[t.Name] = t.Info
+		}
+		return nil
+	})
+	return snapshots, errno(err)
+}
+
+func (m *dbMeta) doSaveTierPolicy(ctx Context, inode Ino, info []byte) syscall.Errno {
+	return errno(m.txn(func(s *xorm.Session) error {
+		p := &tierPolicy{Inode: inode, Info: info}
+		ok, err := s.Get(&tierPolicy{Inode: inode})
+		if err != nil {
+			return err
+		}
+		if ok {
+			_, err = s.Cols("info").Update(p, &tierPolicy{Inode: inode})
+		} else {
+			err = mustInsert(s, p)
+		}
+		if err != nil {
+			return err
+		}
+		m.genLog(ctx, s, time.Now().UnixNano(), "SETTIERPOLICY(%d,%s)", inode, logEncode(info))
+		return nil
+	}))
+}
+
+func (m *dbMeta) doDeleteTierPolicy(ctx Context, inode Ino) syscall.Errno {
+	return errno(m.txn(func(s *xorm.Session) error {
+		n, err := s.Delete(&tierPolicy{Inode: inode})
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return syscall.ENOENT
+		}
+		m.genLog(ctx, s, time.Now().UnixNano(), "DELETETIERPOLICY(%d)", inode)
+		return nil
+	}))
+}
+
+func (m *dbMeta) doListTierPolicies(ctx Context) (policies map[Ino][]byte, st syscall.Errno) {
+	err := m.roTxn(ctx, func(s *xorm.Session) error {
+		var ps []tierPolicy
+		if err := s.Find(&ps); err != nil {
+			return err
+		}
+		policies = make(map[Ino][]byte, len(ps))
+		for _, p := range ps {
+			policies[p.Inode] = p.Info
+		}
+		return nil
+	})
+	return policies, errno(err)
+}
+
+func (m *dbMeta) doSetDirCompression(ctx Context, inode Ino, algr string) syscall.Errno {
+	return errno(m.txn(func(s *xorm.Session) error {
+		c := &dirCompression{Inode: inode, Algr: algr}
+		ok, err := s.Get(&dirCompression{Inode: inode})
+		if err != nil {
+			return err
+		}
+		if ok {
+			_, err = s.Cols("algr").Update(c, &dirCompression{Inode: inode})
+		} else {
+			err = mustInsert(s, c)
+		}
+		return err
+	}))
+}
+
+func (m *dbMeta) doDeleteDirCompression(ctx Context, inode Ino) syscall.Errno {
+	return errno(m.txn(func(s *xorm.Session) error {
+		n, err := s.Delete(&dirCompression{Inode: inode})
+		if err == nil && n == 0 {
+			return syscall.ENOENT
+		}
+		return err
+	}))
+}
+
+func (m *dbMeta) doListDirCompressions(ctx Context) (compressions map[Ino]string, st syscall.Errno) {
+	err := m.roTxn(ctx, func(s *xorm.Session) error {
+		var cs []dirCompression
+		if err := s.Find(&cs); err != nil {
+			return err
+		}
+		compressions = make(map[Ino]string, len(cs))
+		for _, c := range cs {
+			compressions[c.Inode] = c.Algr
+		}
+		return nil
+	})
+	return compressions, errno(err)
+}
+
+func (m *dbMeta) doAddDedupBlock(ctx Context, id uint64, indx uint32, chunks []DedupChunk) (exists []bool, st syscall.Errno) {
+	st = errno(m.txn(func(s *xorm.Session) error {
+		exists = make([]bool, len(chunks))
+		ok, err := s.MustCols("indx").Get(&dedupBlock{Id: id, Indx: indx})
+		if err != nil {
+			return err
+		}
+		if ok { // the block is uploaded again
+			for i := range exists {
+				exists[i] = true
+			}
+			return nil
+		}
+		counts := dedupRefCounts(chunks)
+		existed := make(map[string]bool, len(counts))
+		for _, c := range chunks {
+			name := dedupRefName(c.Hash, c.Size)
+			if _, done := existed[name]; done {
+				continue
+			}
+			ref := dedupRef{Hash: c.Hash}
+			if ok, err = s.ForUpdate().Get(&ref); err != nil {
+				return err
+			}
+			if ok && ref.Freed == dedupDeleting {
+				return syscall.EAGAIN
+			}
+			existed[name] = ok
+			if ok {
+				_, err = s.Cols("refs", "freed").Update(&dedupRef{Refs: ref.Refs + counts[name]}, &dedupRef{Hash: c.Hash})
+			} else {
+				err = mustInsert(s, &dedupRef{Hash: c.Hash, Size: c.Size, Refs: counts[name]})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		for i, c := range chunks {
+			exists[i] = existed[dedupRefName(c.Hash, c.Size)]
+		}
+		return mustInsert(s, &dedupBlock{Id: id, Indx: indx, Chunks: []byte(encodeDedupChunks(chunks))})
+	}))
+	return
+}
+
+func (m *dbMeta) doGetDedupBlock(ctx Context, id uint64, indx uint32) (chunks []DedupChunk, st syscall.Errno) {
+	st = errno(m.roTxn(ctx, func(s *xorm.Session) error {
+		b := dedupBlock{Id: id, Indx: indx}
+		ok, err := s.MustCols("indx").Get(&b)
+		if ok {
+			chunks = parseDedupChunks(string(b.Chunks))
+		}
+		return err
+	}))
+	return
+}
+
+func (m *dbMeta) doRemoveDedupBlock(ctx Context, id uint64, indx uint32) (chunks []DedupChunk, st syscall.Errno) {
+	st = errno(m.txn(func(s *xorm.Session) error {
+		chunks = nil
+		b := dedupBlock{Id: id, Indx: indx}
+		ok, err := s.ForUpdate().MustCols("indx").Get(&b)
+		if err != nil || !ok {
+			return err
+		}
+		chunks = parseDedupChunks(string(b.Chunks))
+		if _, err = s.MustCols("indx").Delete(&dedupBlock{Id: id, Indx: indx}); err != nil {
+			return err
+		}
+		counts := dedupRefCounts(chunks)
+		now := time.Now().Unix()
+		for _, c := range chunks {
+			name := dedupRefName(c.Hash, c.Size)
+			n, ok := counts[name]
+			if !ok {
+				continue
+			}
+			delete(counts, name)
+			ref := dedupRef{Hash: c.Hash}
+			if ok, err = s.ForUpdate().Get(&ref); err != nil {
+				return err
+			} else if !ok {
+				continue
+			}
+			if ref.Refs -= n; ref.Refs <= 0 {
+				ref.Freed = now
+			}
+			if _, err = s.Cols("refs", "freed").Update(&ref, &dedupRef{Hash: c.Hash}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	return
+}
+
+func (m *dbMeta) doScanDedupBlocks(ctx Context, scan func(id uint64, indx uint32, chunks []DedupChunk) bool) syscall.Errno {
+	var stopped bool
+	err := m.roTxn(ctx, func(s *xorm.Session) error {
+		return s.Table(&dedupBlock{}).Iterate(new(dedupBlock), func(idx int, bean interface{}) error {
+			b := bean.(*dedupBlock)
+			if !scan(b.Id, b.Indx, parseDedupChunks(string(b.Chunks))) {
+				stopped = true
+				return syscall.EINTR
+			}
+			return nil
+		})
+	})
+	if stopped {
+		err = nil
+	}
+	return errno(err)
+}
+
+func (m *dbMeta) doScanDedupRefs(ctx Context, scan func(ref *DedupRef) bool) syscall.Errno {
+	var stopped bool
+	err := m.roTxn(ctx, func(s *xorm.Session) error {
+		return s.Table(&dedupRef{}).Iterate(new(dedupRef), func(idx int, bean interface{}) error {
+			r := bean.(*dedupRef)
+			if !scan(&DedupRef{Hash: r.Hash, Size: r.Size, Refs: r.Refs, Freed: r.Freed}) {
+				stopped = true
+				return syscall.EINTR
+			}
+			return nil
+		})
+	})
+	if stopped {
+		err = nil
+	}
+	return errno(err)
+}
+
+func (m *dbMeta) doMarkDedupRef(ctx Context, hash string, size uint32, before int64) (marked bool, st syscall.Errno) {
+	st = errno(m.txn(func(s *xorm.Session) error {
+		marked = false
+		ref := dedupRef{Hash: hash}
+		ok, err := s.ForUpdate().Get(&ref)
+		if err != nil || !ok || ref.Refs > 0 || ref.Freed >= before {
+			return err
+		}
+		_, err = s.Cols("freed").Update(&dedupRef{Freed: dedupDeleting}, &dedupRef{Hash: hash})
+		marked = err == nil
+		return err
+	}))
+	return
+}
+
+func (m *dbMeta) doDeleteDedupRef(ctx Context, hash string, size uint32) syscall.Errno {
+	return errno(m.txn(func(s *xorm.Session) error {
+		_, err := s.Delete(&dedupRef{Hash: hash})
+		return err
+	}))
+}
+
+// the cursors of consumers are not logged, otherwise acknowledging would generate new entries
+func (m *dbMeta) doSaveChangelogConsumer(ctx Context, group string, info []byte) syscall.Errno {
+	return errno(m.txn(func(s *xorm.Session) error {
+		c := &changelogConsumer{Name: group, Info: info}
+		ok, err := s.Get(&changelogConsumer{Name: group})
+		if err != nil {
+			return err
+		}
+		if ok {
+			_, err = s.Cols("info").Update(c, &changelogConsumer{Name: group})
+		} else {
+			err = mustInsert(s, c)
+		}
+		return err
+	}))
+}
+
+func (m *dbMeta) doDeleteChangelogConsumer(ctx Context, group string) syscall.Errno {
+	return errno(m.txn(func(s *xorm.Session) error {
+		n, err := s.Delete(&changelogConsumer{Name: group})
+		if err == nil && n == 0 {
+			return syscall.ENOENT
+		}
+		return err
+	}))
+}
+
+func (m *dbMeta) doListChangelogConsumers(ctx Context) (consumers map[string][]byte, st syscall.Errno) {
+	err := m.roTxn(ctx, func(s *xorm.Session) error {
+		var cs []changelogConsumer
+		if err := s.Find(&cs); err != nil {
+			return err
+		}
+		consumers = make(map[string][]byte, len(cs))
+		for _, c := range cs {
+			consumers[c.Name] = c.Info
+		}
+		return nil
+	})
+	return consumers, errno(err)
+}
+
+func (m *dbMeta) doGetChangelogVersion(ctx Context) (int64, syscall.Errno) {
+	var last int64
+	err := m.roTxn(ctx, func(s *xorm.Session) error {
+		var maxLog changeLog
+		ok, err := s.Desc("id").Limit(1).Get(&maxLog)
+		if ok {
+			last = maxLog.Id
+		}
+		return err
+	})
+	return last, errno(err)
+}
+
 type dbDirHandler struct {
 	dirHandler
 }
@@ -54,6 +54,7 @@ func (m *dbMeta) dump(ctx Context, opt *DumpOption, ch chan<- *dumpedResult) err
 		m.dumpXattr,
 		m.dumpQuota,
 		m.dumpDirStat,
+		m.dumpDedup,
 	}
 
 	ctx = ctx.WithValue(txMaxRetryKey{}, 3)
@@ -538,6 +539,8 @@ func (m *dbMeta) load(ctx Context, typ int, opt *LoadOption, val proto.Message)
 		return m.loadQuota(ctx, val)
 	case segTypeStat:
 		return m.loadDirStats(ctx, val)
+	case segTypeDedupBlock, segTypeDedupRef:
+		return m.loadDedup(ctx, val)
 	case segTypeParent:
 		return nil // skip
 	default:
@@ -851,6 +854,27 @@ func (m *dbMeta) loadDirStats(ctx Context, msg proto.Message) error {
 	return m.insertRows(rows)
 }
 
+func (m *dbMeta) loadDedup(ctx Context, msg proto.Message) error {
+	batch := msg.(*pb.Batch)
+	if len(batch.DedupBlocks) > 0 {
+		rows := make([]interface{}, 0, len(batch.DedupBlocks))
+		for _, b := range batch.DedupBlocks {
+			rows = append(rows, &dedupBlock{Id: b.Id, Indx: b.Indx, Chunks: []byte(b.Chunks)})
+		}
+		if err := m.insertRows(rows); err != nil {
+			return err
+		}
+	}
+	if len(batch.DedupRefs) > 0 {
+		rows := make([]interface{}, 0, len(batch.DedupRefs))
+		for _, r := range batch.DedupRefs {
+			rows = append(rows, &dedupRef{Hash: r.Hash, Size: r.Size, Refs: r.Refs, Freed: r.Freed})
+		}
+		return m.insertRows(rows)
+	}
+	return nil
+}
+
 func (m *dbMeta) insertRows(beans []interface{}) error {
 	batch := m.getTxnBatchNum()
 	for len(beans) > 0 {
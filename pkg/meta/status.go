@@ -45,6 +45,7 @@ type Sections struct {
 	Setting  *Format
 	Sessions []*Session
 	Stat     *Statistic
+	Dedup    *DedupStats `json:",omitempty"`
 }
 
 // Status retrieves the status of the filesystem
@@ -109,10 +110,19 @@ func Status(ctx context.Context, m Meta, trash bool, sections *Sections) error {
 		stat.PendingDeletedFileCount, stat.PendingDeletedFileSize = pendingDeletedFileSpinner.Current()
 	}
 
+	var dedup *DedupStats
+	if format.Dedup {
+		var st syscall.Errno
+		if dedup, st = m.DedupStats(Background()); st != 0 {
+			return fmt.Errorf("dedup stats: %v", st)
+		}
+	}
+
 	if sections != nil {
 		sections.Setting = format
 		sections.Sessions = sessions
 		sections.Stat = stat
+		sections.Dedup = dedup
 	}
 	return nil
 }
@@ -0,0 +1,351 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package meta
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/utils"
+)
+
+// TierRule moves a file to Tier once it has not been accessed or modified for Days.
+type TierRule struct {
+	Tier uint8
+	Days int
+}
+
+// TierPolicy is the lifecycle policy of a directory tree. It applies to all the files
+// under Inode, except the subtrees which have their own policies.
+type TierPolicy struct {
+	Inode      Ino
+	Path       string // path of the directory when the policy was set
+	Rules      []TierRule
+	ExpireDays int // remove the files idle for ExpireDays, 0 means never
+	Updated    int64
+}
+
+func (p *TierPolicy) String() string {
+	var rs []string
+	for _, r := range p.Rules {
+		rs = append(rs, fmt.Sprintf("tier %d after %d days", r.Tier, r.Days))
+	}
+	if p.ExpireDays > 0 {
+		rs = append(rs, fmt.Sprintf("expire after %d days", p.ExpireDays))
+	}
+	return strings.Join(rs, ", ")
+}
+
+// target returns the tier for a file idle for days, and whether it's expired.
+func (p *TierPolicy) target(days int, current uint8) (uint8, bool) {
+	if p.ExpireDays > 0 && days >= p.ExpireDays {
+		return current, true
+	}
+	tier, best := current, -1
+	for _, r := range p.Rules {
+		if r.Days <= days && r.Days > best {
+			tier, best = r.Tier, r.Days
+		}
+	}
+	return tier, false
+}
+
+type TierPolicyStats struct {
+	Scanned      int64
+	Transitioned int64
+	Expired      int64
+}
+
+func (m *baseMeta) SetTierPolicy(ctx Context, inode Ino, policy *TierPolicy) syscall.Errno {
+	if m.conf.ReadOnly {
+		return syscall.EROFS
+	}
+	defer m.timeit("SetTierPolicy", time.Now())
+	inode = m.checkRoot(inode)
+	var attr Attr
+	if st := m.GetAttr(ctx, inode, &attr); st != 0 {
+		return st
+	}
+	if attr.Typ != TypeDirectory {
+		return syscall.ENOTDIR
+	}
+	if len(policy.Rules) == 0 && policy.ExpireDays <= 0 {
+		return syscall.EINVAL
+	}
+	tiers := m.getFormat().Tiers
+	for _, r := range policy.Rules {
+		if r.Days < 0 || policy.ExpireDays > 0 && r.Days >= policy.ExpireDays {
+			return syscall.EINVAL
+		}
+		if _, ok := tiers[r.Tier]; !ok && tiers != nil {
+			return syscall.EINVAL
+		}
+	}
+	sort.Slice(policy.Rules, func(i, j int) bool { return policy.Rules[i].Days < policy.Rules[j].Days })
+	policy.Inode = inode
+	if ps := m.GetPaths(ctx, inode); len(ps) > 0 {
+		policy.Path = ps[0]
+	}
+	policy.Updated = time.Now().Unix()
+	info, _ := json.Marshal(policy)
+	return m.en.doSaveTierPolicy(ctx, inode, info)
+}
+
+func (m *baseMeta) ListTierPolicies(ctx Context) ([]*TierPolicy, syscall.Errno) {
+	vals, st := m.en.doListTierPolicies(ctx)
+	if st != 0 {
+		return nil, st
+	}
+	policies := make([]*TierPolicy, 0, len(vals))
+	for inode, v := range vals {
+		var p TierPolicy
+		if err := json.Unmarshal(v, &p); err != nil {
+			logger.Warnf("decode tier policy of inode %d: %s", inode, err)
+			continue
+		}
+		policies = append(policies, &p)
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Inode < policies[j].Inode })
+	return policies, 0
+}
+
+func (m *baseMeta) DeleteTierPolicy(ctx Context, inode Ino) syscall.Errno {
+	if m.conf.ReadOnly {
+		return syscall.EROFS
+	}
+	defer m.timeit("DeleteTierPolicy", time.Now())
+	return m.en.doDeleteTierPolicy(ctx, m.checkRoot(inode))
+}
+
+// ApplyTierPolicies evaluates all the policies once, moving idle files to colder tiers and
+// removing the expired ones. The data of moved files is rewritten by CompactChunk messages.
+func (m *baseMeta) ApplyTierPolicies(ctx Context, stats *TierPolicyStats) syscall.Errno {
+	if m.conf.ReadOnly {
+		return syscall.EROFS
+	}
+	defer m.timeit("ApplyTierPolicies", time.Now())
+	policies, st := m.ListTierPolicies(ctx)
+	if st != 0 {
+		return st
+	}
+	byInode := make(map[Ino]*TierPolicy, len(policies))
+	for _, p := range policies {
+		byInode[p.Inode] = p
+	}
+	now := time.Now()
+	for _, p := range policies {
+		var attr Attr
+		if st = m.en.doGetAttr(ctx, p.Inode, &attr); st == syscall.ENOENT {
+			logger.Infof("directory %d (%s) of tier policy is gone, remove the policy", p.Inode, p.Path)
+			if st = m.en.doDeleteTierPolicy(ctx, p.Inode); st != 0 && st != syscall.ENOENT {
+				logger.Warnf("remove tier policy of inode %d: %s", p.Inode, st)
+			}
+			continue
+		} else if st != 0 {
+			return st
+		}
+		if st = m.applyTierPolicy(ctx, p, byInode, p.Inode, now, stats); st != 0 {
+			return st
+		}
+	}
+	return 0
+}
+
+func (m *baseMeta) applyTierPolicy(ctx Context, p *TierPolicy, policies map[Ino]*TierPolicy, dir Ino, now time.Time, stats *TierPolicyStats) syscall.Errno {
+	handler, st := m.NewDirHandler(ctx, dir, true, nil)
+	if st != 0 {
+		if st == syscall.ENOENT {
+			st = 0
+		}
+		return st
+	}
+	defer handler.Close()
+	for offset := 0; ; {
+		entries, st := handler.List(ctx, offset)
+		if st != 0 {
+			return st
+		}
+		if len(entries) == 0 {
+			return 0
+		}
+		offset += len(entries)
+		for _, e := range entries {
+			if ctx.Canceled() {
+				return syscall.EINTR
+			}
+			if string(e.Name) == "." || string(e.Name) == ".." {
+				continue
+			}
+			switch e.Attr.Typ {
+			case TypeDirectory:
+				// a subtree with its own policy is handled by that policy
+				if e.Inode == TrashInode || e.Inode == SnapshotInode || policies[e.Inode] != nil {
+					continue
+				}
+				if st := m.applyTierPolicy(ctx, p, policies, e.Inode, now, stats); st != 0 {
+					return st
+				}
+			case TypeFile:
+				if st := m.applyTierRule(ctx, p, dir, e, now, stats); st != 0 {
+					logger.Warnf("apply tier policy of %s to %s (inode %d): %s", p.Path, string(e.Name), e.Inode, st)
+				}
+			}
+		}
+	}
+}
+
+func (m *baseMeta) applyTierRule(ctx Context, p *TierPolicy, parent Ino, e *Entry, now time.Time, stats *TierPolicyStats) syscall.Errno {
+	atomic.AddInt64(&stats.Scanned, 1)
+	attr := e.Attr
+	if attr.Flags&(FlagImmutable|FlagAppend) != 0 {
+		return 0
+	}
+	last := attr.Atime
+	if attr.Mtime > last {
+		last = attr.Mtime
+	}
+	days := int(now.Unix()-last) / 86400
+	tier, expired := p.target(days, attr.Tier)
+	if expired {
+		st := m.Unlink(ctx, parent, string(e.Name))
+		if st == 0 {
+			atomic.AddInt64(&stats.Expired, 1)
+			logger.Debugf("remove expired file %s (inode %d) idle for %d days", string(e.Name), e.Inode, days)
+		}
+		return st
+	}
+	if tier == attr.Tier {
+		return 0
+	}
+	// the tier is set after all the data is rewritten, so the file is tried again in the next run if failed
+	for indx := uint32(0); uint64(indx)*ChunkSize < attr.Length; indx++ {
+		if ctx.Canceled() {
+			return syscall.EINTR
+		}
+		if st := m.retierChunk(e.Inode, indx, tier); st != 0 {
+			return st
+		}
+	}
+	if st := m.SetAttr(ctx, e.Inode, SetAttrTier, 0, &Attr{Tier: tier}); st != 0 {
+		return st
+	}
+	atomic.AddInt64(&stats.Transitioned, 1)
+	logger.Debugf("move file %s (inode %d) idle for %d days from tier %d to %d", string(e.Name), e.Inode, days, attr.Tier, tier)
+	return 0
+}
+
+// retierChunk rewrites all the slices of a chunk into a new slice in tier, unlike compactChunk
+// it also rewrites the chunks having a single slice. EAGAIN is returned if the chunk is changed
+// during rewriting.
+func (m *baseMeta) retierChunk(inode Ino, indx uint32, tier uint8) syscall.Errno {
+	k := uint64(inode) + (uint64(indx) << 40)
+	m.Lock()
+	for m.compacting[k] {
+		m.Unlock()
+		time.Sleep(time.Millisecond * 10)
+		m.Lock()
+	}
+	m.compacting[k] = true
+	m.Unlock()
+	defer func() {
+		m.Lock()
+		delete(m.compacting, k)
+		m.Unlock()
+	}()
+
+	ss, st := m.en.doRead(Background(), inode, indx)
+	if st != 0 || len(ss) == 0 {
+		return st
+	}
+	pos, size, slices := compactChunk(ss)
+	if size == 0 {
+		return 0
+	}
+	var id uint64
+	if st = m.NewSlice(Background(), &id); st != 0 {
+		return st
+	}
+	if err := m.newMsg(CompactChunk, slices, id, tier); err != nil {
+		logger.Warnf("rewrite %d:%d to tier %d: %s", inode, indx, tier, err)
+		return errno(err)
+	}
+	var dsbuf []byte
+	if m.toTrash(0) {
+		dsbuf = make([]byte, 0, len(ss)*12)
+		for _, s := range ss {
+			if s.id > 0 {
+				dsbuf = append(dsbuf, m.encodeDelayedSlice(s.id, s.size)...)
+			}
+		}
+	}
+	origin := make([]byte, 0, len(ss)*sliceBytes)
+	for _, s := range ss {
+		origin = append(origin, marshalSlice(s.pos, s.id, s.size, s.off, s.len)...)
+	}
+	st = m.en.doCompactChunk(inode, indx, origin, ss, 0, pos, id, size, dsbuf)
+	if st == syscall.EINVAL {
+		logger.Infof("rewriting %d:%d to tier %d is wasted, delete slice %d (%d bytes)", inode, indx, tier, id, size)
+		m.deleteSlice(id, size)
+		return syscall.EAGAIN
+	} else if st == 0 {
+		m.of.InvalidateChunk(inode, indx)
+	} else {
+		logger.Warnf("rewrite %d:%d to tier %d: %s; slice %d (%d bytes) may be orphaned, will be cleaned by gc",
+			inode, indx, tier, st, id, size)
+	}
+	return st
+}
+
+func (m *baseMeta) applyTierPolicies(ctx Context) {
+	defer m.sessWG.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(utils.JitterIt(time.Hour)):
+		}
+		if ok, err := m.en.setIfSmall("lastApplyTierPolicies", time.Now().Unix(), int64(time.Hour.Seconds())*9/10); err != nil {
+			logger.Warnf("checking counter lastApplyTierPolicies: %s", err)
+		} else if ok {
+			func() {
+				cCtx := WrapWithTimeout(ctx, 50*time.Minute)
+				defer cCtx.Cancel()
+				jobStart := time.Now()
+				stats := &TierPolicyStats{}
+				status := bgJobSucc
+				if st := m.ApplyTierPolicies(cCtx, stats); st != 0 {
+					if st == syscall.EINTR || st == syscall.ETIMEDOUT {
+						status = bgJobCanceled
+					} else {
+						status = bgJobFail
+						logger.Warnf("apply tier policies: %s", st)
+					}
+				}
+				if stats.Transitioned > 0 || stats.Expired > 0 {
+					logger.Infof("tier policies: scanned %d files, moved %d and removed %d", stats.Scanned, stats.Transitioned, stats.Expired)
+				}
+				m.bgjobDuration.WithLabelValues("applyTierPolicies", status).Observe(time.Since(jobStart).Seconds())
+				m.bgjobDels.WithLabelValues("applyTierPolicies").Add(float64(stats.Expired))
+			}()
+		}
+	}
+}
@@ -222,6 +222,12 @@ All keys:
   Raaaa			     POSIX acl
   ! The "X" prefix represents extensibility features.
   XKDaaaa			 delegation token
+  XSN...             snapshot info
+  XCG...             changelog consumer
+  XTPiiiiiiii        tier policy
+  XDCiiiiiiii        compression of directory
+  XDBccccccccnnnn    chunks of deduplicated block
+  XDR...             deduplicated object refs
   XLOGiiiiiiii       changelog
   XLOGsiiiiiiii      TiKV changelog
 */
@@ -302,6 +308,30 @@ func (m *kvMeta) krbTokenKey(id uint32) []byte {
 	return m.fmtKey("XKD", id)
 }
 
+func (m *kvMeta) snapshotKey(name string) []byte {
+	return m.fmtKey("XSN", name)
+}
+
+func (m *kvMeta) changelogConsumerKey(group string) []byte {
+	return m.fmtKey("XCG", group)
+}
+
+func (m *kvMeta) tierPolicyKey(inode Ino) []byte {
+	return m.fmtKey("XTP", inode)
+}
+
+func (m *kvMeta) dirCompressionKey(inode Ino) []byte {
+	return m.fmtKey("XDC", inode)
+}
+
+func (m *kvMeta) dedupBlockKey(id uint64, indx uint32) []byte {
+	return m.fmtKey("XDB", id, indx)
+}
+
+func (m *kvMeta) dedupRefKey(hash string) []byte {
+	return m.fmtKey("XDR", hash)
+}
+
 type tkvChangelogClient interface {
 	logKey(m *kvMeta, id uint64) []byte
 	scanLogRange(m *kvMeta, tx *kvTxn, beginID, endID uint64, keysOnly bool, handler func(id uint64, k, v []byte) bool)
@@ -999,7 +1029,7 @@ func (m *kvMeta) ScanChangelog(ctx Context, last int64, handler func(ver int64,
 	}
 }
 
-func (m *kvMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines int64) error {
+func (m *kvMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines int64, keep int64, forceAge time.Duration) error {
 	if maxAge <= 0 && maxLines <= 0 {
 		return nil
 	}
@@ -1025,10 +1055,14 @@ func (m *kvMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines
 		}
 	}
 
-	var cutoff time.Time
+	var cutoff, forceCutoff time.Time
 	if maxAge > 0 {
 		cutoff = time.Now().Add(-maxAge)
 	}
+	if forceAge > 0 {
+		forceCutoff = time.Now().Add(-forceAge)
+	}
+	keysOnly := maxAge <= 0 && (keep == math.MaxInt64 || forceAge <= 0)
 
 	const batchLimit = 1000
 	var deleted int64
@@ -1041,7 +1075,7 @@ func (m *kvMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines
 		var nextStartID uint64
 
 		err := m.client.simpleTxn(ctx, func(kt *kvTxn) error {
-			m.scanLogRange(kt, startID, ^uint64(0), maxAge <= 0, func(id uint64, k, v []byte) bool {
+			m.scanLogRange(kt, startID, ^uint64(0), keysOnly, func(id uint64, k, v []byte) bool {
 				if len(batch) >= batchLimit {
 					return false
 				}
@@ -1057,6 +1091,17 @@ func (m *kvMeta) doCleanupChangelog(ctx Context, maxAge time.Duration, maxLines
 					done = true
 					return false
 				}
+				if int64(id) > keep { // not acknowledged by consumers
+					var forced bool
+					if forceAge > 0 {
+						t, e := parseChangelogTime(string(v))
+						forced = e == nil && t.Before(forceCutoff)
+					}
+					if !forced {
+						done = true
+						return false
+					}
+				}
 				batch = append(batch, k)
 				return true
 			})
@@ -3387,9 +3432,7 @@ func (m *kvMeta) doRepair(ctx Context, inode Ino, attr *Attr) syscall.Errno {
 	}, inode))
 }
 
-func (m *kvMeta) GetXattr(ctx Context, inode Ino, name string, vbuff *[]byte) syscall.Errno {
-	defer m.timeit("GetXattr", time.Now())
-	inode = m.checkRoot(inode)
+func (m *kvMeta) doGetXattr(ctx Context, inode Ino, name string, vbuff *[]byte) syscall.Errno {
 	buf, err := m.get(m.xattrKey(inode, name))
 	if err != nil {
 		return errno(err)
@@ -4199,6 +4242,10 @@ func (m *kvMeta) DumpMeta(w io.Writer, root Ino, threads int, keepSecret, fast,
 	if root != RootInode {
 		dm.UserQuotas = nil
 		dm.GroupQuotas = nil
+	} else if err := m.dumpedDedup(Background(), &dm); err != nil {
+		return err
+	} else if err := m.dumpedSnapshots(Background(), &dm); err != nil {
+		return err
 	}
 	if !keepSecret && dm.Setting.SecretKey != "" {
 		dm.Setting.SecretKey = "removed"
@@ -4254,6 +4301,38 @@ func (m *kvMeta) DumpMeta(w io.Writer, root Ino, threads int, keepSecret, fast,
 			}
 		}
 	}
+	if len(dm.Snapshots) > 0 {
+		var snaps *DumpedEntry
+		if m.snap != nil {
+			snaps = m.snap[SnapshotInode]
+		} else {
+			snaps = &DumpedEntry{
+				Attr: &DumpedAttr{
+					Inode: SnapshotInode,
+					Type:  "directory",
+				},
+			}
+			if err = m.dumpEntry(SnapshotInode, snaps, nil); err != nil {
+				return err
+			}
+		}
+		if snaps == nil || snaps.Attr == nil {
+			return errors.New("The entry of the snapshot root was not found")
+		}
+		snaps.Name = "SnapTree"
+		if _, err = bw.WriteString(","); err != nil {
+			return err
+		}
+		if m.snap != nil {
+			err = m.dumpDirFast(SnapshotInode, snaps, bw, 1, showProgress)
+		} else {
+			showProgress(int64(len(snaps.Entries)), 0)
+			err = m.dumpDir(ctx, SnapshotInode, snaps, bw, 1, threads, showProgress)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	if _, err = bw.WriteString("\n}\n"); err != nil {
 		return err
 	}
@@ -4420,8 +4499,14 @@ func (m *kvMeta) LoadMeta(r io.Reader) error {
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
 	m.loadDumpedQuotas(Background(), dm)
-	return err
+	if err = m.loadDumpedDedup(Background(), dm); err != nil {
+		return err
+	}
+	return m.loadDumpedSnapshots(Background(), dm)
 }
 
 func (m *kvMeta) doCloneEntry(ctx Context, srcIno Ino, parent Ino, name string, ino Ino, originAttr *Attr, cmode uint8, cumask uint16, top bool) syscall.Errno {
@@ -5041,6 +5126,259 @@ func (m *kvMeta) doListTokens(ctx Context) (tokens map[uint32][]byte, st syscall
 	return tokens, errno(err)
 }
 
+func (m *kvMeta) doSaveSnapshot(ctx Context, name string, info []byte) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *kvTxn) error {
+		if tx.get(m.snapshotKey(name)) != nil {
+			return syscall.EEXIST
+		}
+		tx.set(m.snapshotKey(name), info)
+		m.genLog(tx, time.Now(), "SAVESNAPSHOT(%s,%s)", logEncode([]byte(name)), logEncode(info))
+		return nil
+	}))
+}
+
+func (m *kvMeta) doDeleteSnapshot(ctx Context, name string) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *kvTxn) error {
+		if tx.get(m.snapshotKey(name)) == nil {
+			return syscall.ENOENT
+		}
+		tx.delete(m.snapshotKey(name))
+		m.genLog(tx, time.Now(), "DELETESNAPSHOT(%s)", logEncode([]byte(name)))
+		return nil
+	}))
+}
+
+func (m *kvMeta) doListSnapshots(ctx Context) (snapshots map[string][]byte, st syscall.Errno) {
+	snapshots = make(map[string][]byte)
+	err := m.client.scan(m.fmtKey("XSN"), func(k, v []byte) bool {
+		snapshots[string(k[3:])] = v
+		return true
+	})
+	return snapshots, errno(err)
+}
+
+func (m *kvMeta) doSaveTierPolicy(ctx Context, inode Ino, info []byte) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *kvTxn) error {
+		tx.set(m.tierPolicyKey(inode), info)
+		m.genLog(tx, time.Now(), "SETTIERPOLICY(%d,%s)", inode, logEncode(info))
+		return nil
+	}))
+}
+
+func (m *kvMeta) doDeleteTierPolicy(ctx Context, inode Ino) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *kvTxn) error {
+		if tx.get(m.tierPolicyKey(inode)) == nil {
+			return syscall.ENOENT
+		}
+		tx.delete(m.tierPolicyKey(inode))
+		m.genLog(tx, time.Now(), "DELETETIERPOLICY(%d)", inode)
+		return nil
+	}))
+}
+
+func (m *kvMeta) doListTierPolicies(ctx Context) (policies map[Ino][]byte, st syscall.Errno) {
+	policies = make(map[Ino][]byte)
+	err := m.client.scan(m.fmtKey("XTP"), func(k, v []byte) bool {
+		policies[m.decodeInode(k[3:])] = v
+		return true
+	})
+	return policies, errno(err)
+}
+
+func (m *kvMeta) doSetDirCompression(ctx Context, inode Ino, algr string) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *kvTxn) error {
+		tx.set(m.dirCompressionKey(inode), []byte(algr))
+		return nil
+	}))
+}
+
+func (m *kvMeta) doDeleteDirCompression(ctx Context, inode Ino) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *kvTxn) error {
+		if tx.get(m.dirCompressionKey(inode)) == nil {
+			return syscall.ENOENT
+		}
+		tx.delete(m.dirCompressionKey(inode))
+		return nil
+	}))
+}
+
+func (m *kvMeta) doListDirCompressions(ctx Context) (compressions map[Ino]string, st syscall.Errno) {
+	compressions = make(map[Ino]string)
+	err := m.client.scan(m.fmtKey("XDC"), func(k, v []byte) bool {
+		compressions[m.decodeInode(k[3:])] = string(v)
+		return true
+	})
+	return compressions, errno(err)
+}
+
+// encoded dedup reference: size(4) + refs(8) + freed(8)
+func (m *kvMeta) parseDedupRef(hash string, buf []byte) *DedupRef {
+	rb := utils.FromBuffer(buf)
+	return &DedupRef{Hash: hash, Size: rb.Get32(), Refs: int64(rb.Get64()), Freed: int64(rb.Get64())}
+}
+
+func (m *kvMeta) encodeDedupRef(ref *DedupRef) []byte {
+	w := utils.NewBuffer(20)
+	w.Put32(ref.Size)
+	w.Put64(uint64(ref.Refs))
+	w.Put64(uint64(ref.Freed))
+	return w.Bytes()
+}
+
+func (m *kvMeta) doAddDedupBlock(ctx Context, id uint64, indx uint32, chunks []DedupChunk) (exists []bool, st syscall.Errno) {
+	st = errno(m.txn(ctx, func(tx *kvTxn) error {
+		exists = make([]bool, len(chunks))
+		if tx.get(m.dedupBlockKey(id, indx)) != nil { // the block is uploaded again
+			for i := range exists {
+				exists[i] = true
+			}
+			return nil
+		}
+		counts := dedupRefCounts(chunks)
+		existed := make(map[string]bool, len(counts))
+		for _, c := range chunks {
+			name := dedupRefName(c.Hash, c.Size)
+			if _, done := existed[name]; done {
+				continue
+			}
+			ref := &DedupRef{Hash: c.Hash, Size: c.Size}
+			buf := tx.get(m.dedupRefKey(c.Hash))
+			if buf != nil {
+				if ref = m.parseDedupRef(c.Hash, buf); ref.Freed == dedupDeleting {
+					return syscall.EAGAIN
+				}
+			}
+			existed[name] = buf != nil
+			ref.Refs += counts[name]
+			ref.Freed = 0
+			tx.set(m.dedupRefKey(c.Hash), m.encodeDedupRef(ref))
+		}
+		for i, c := range chunks {
+			exists[i] = existed[dedupRefName(c.Hash, c.Size)]
+		}
+		tx.set(m.dedupBlockKey(id, indx), []byte(encodeDedupChunks(chunks)))
+		return nil
+	}))
+	return
+}
+
+func (m *kvMeta) doGetDedupBlock(ctx Context, id uint64, indx uint32) (chunks []DedupChunk, st syscall.Errno) {
+	st = errno(m.client.simpleTxn(ctx, func(tx *kvTxn) error {
+		chunks = parseDedupChunks(string(tx.get(m.dedupBlockKey(id, indx))))
+		return nil
+	}, 0))
+	return
+}
+
+func (m *kvMeta) doRemoveDedupBlock(ctx Context, id uint64, indx uint32) (chunks []DedupChunk, st syscall.Errno) {
+	st = errno(m.txn(ctx, func(tx *kvTxn) error {
+		chunks = parseDedupChunks(string(tx.get(m.dedupBlockKey(id, indx))))
+		if len(chunks) == 0 {
+			return nil
+		}
+		tx.delete(m.dedupBlockKey(id, indx))
+		counts := dedupRefCounts(chunks)
+		now := time.Now().Unix()
+		for _, c := range chunks {
+			name := dedupRefName(c.Hash, c.Size)
+			n, ok := counts[name]
+			if !ok {
+				continue
+			}
+			delete(counts, name)
+			buf := tx.get(m.dedupRefKey(c.Hash))
+			if buf == nil {
+				continue
+			}
+			ref := m.parseDedupRef(c.Hash, buf)
+			if ref.Refs -= n; ref.Refs <= 0 {
+				ref.Freed = now
+			}
+			tx.set(m.dedupRefKey(c.Hash), m.encodeDedupRef(ref))
+		}
+		return nil
+	}))
+	return
+}
+
+func (m *kvMeta) doScanDedupBlocks(ctx Context, scan func(id uint64, indx uint32, chunks []DedupChunk) bool) syscall.Errno {
+	return errno(m.client.scan(m.fmtKey("XDB"), func(k, v []byte) bool {
+		if len(k) != 15 {
+			return true
+		}
+		rb := utils.FromBuffer(k[3:])
+		return scan(rb.Get64(), rb.Get32(), parseDedupChunks(string(v)))
+	}))
+}
+
+func (m *kvMeta) doScanDedupRefs(ctx Context, scan func(ref *DedupRef) bool) syscall.Errno {
+	return errno(m.client.scan(m.fmtKey("XDR"), func(k, v []byte) bool {
+		return scan(m.parseDedupRef(string(k[3:]), v))
+	}))
+}
+
+func (m *kvMeta) doMarkDedupRef(ctx Context, hash string, size uint32, before int64) (marked bool, st syscall.Errno) {
+	st = errno(m.txn(ctx, func(tx *kvTxn) error {
+		marked = false
+		buf := tx.get(m.dedupRefKey(hash))
+		if buf == nil {
+			return nil
+		}
+		ref := m.parseDedupRef(hash, buf)
+		if ref.Refs > 0 || ref.Freed >= before {
+			return nil
+		}
+		ref.Freed = dedupDeleting
+		tx.set(m.dedupRefKey(hash), m.encodeDedupRef(ref))
+		marked = true
+		return nil
+	}))
+	return
+}
+
+func (m *kvMeta) doDeleteDedupRef(ctx Context, hash string, size uint32) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *kvTxn) error {
+		tx.delete(m.dedupRefKey(hash))
+		return nil
+	}))
+}
+
+// the cursors of consumers are not logged, otherwise acknowledging would generate new entries
+func (m *kvMeta) doSaveChangelogConsumer(ctx Context, group string, info []byte) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *kvTxn) error {
+		tx.set(m.changelogConsumerKey(group), info)
+		return nil
+	}))
+}
+
+func (m *kvMeta) doDeleteChangelogConsumer(ctx Context, group string) syscall.Errno {
+	return errno(m.txn(ctx, func(tx *kvTxn) error {
+		if tx.get(m.changelogConsumerKey(group)) == nil {
+			return syscall.ENOENT
+		}
+		tx.delete(m.changelogConsumerKey(group))
+		return nil
+	}))
+}
+
+func (m *kvMeta) doListChangelogConsumers(ctx Context) (consumers map[string][]byte, st syscall.Errno) {
+	consumers = make(map[string][]byte)
+	err := m.client.scan(m.fmtKey("XCG"), func(k, v []byte) bool {
+		consumers[string(k[3:])] = v
+		return true
+	})
+	return consumers, errno(err)
+}
+
+func (m *kvMeta) doGetChangelogVersion(ctx Context) (int64, syscall.Errno) {
+	var last int64
+	err := m.client.txn(ctx, func(tx *kvTxn) error {
+		last = int64(m.findLastLogKey(tx))
+		return nil
+	}, 0)
+	return last, errno(err)
+}
+
 type kvDirHandler struct {
 	dirHandler
 }
@@ -48,6 +48,7 @@ func (m *kvMeta) dump(ctx Context, opt *DumpOption, ch chan<- *dumpedResult) err
 		m.dumpQuota,
 		m.dumpDirStat,
 		m.dumpChangeLog,
+		m.dumpDedup,
 	}
 	ts := m.client.config("startTS")
 	if ts == nil && m.Name() == "tikv" {
@@ -667,6 +668,23 @@ func (m *kvMeta) loadParents(ctx Context, msg proto.Message, pairs *[]*pair) {
 	}
 }
 
+func (m *kvMeta) loadDedupPairs(ctx Context, msg proto.Message, pairs *[]*pair) {
+	batch := msg.(*pb.Batch)
+	for _, b := range batch.DedupBlocks {
+		*pairs = append(*pairs, &pair{m.dedupBlockKey(b.Id, b.Indx), []byte(b.Chunks)})
+	}
+	for _, r := range batch.DedupRefs {
+		ref := &DedupRef{Hash: r.Hash, Size: r.Size, Refs: r.Refs, Freed: r.Freed}
+		*pairs = append(*pairs, &pair{m.dedupRefKey(r.Hash), m.encodeDedupRef(ref)})
+	}
+}
+
+func (m *kvMeta) loadDedup(ctx Context, msg proto.Message) error {
+	var pairs []*pair
+	m.loadDedupPairs(ctx, msg, &pairs)
+	return m.insertKVs(ctx, pairs, 1)
+}
+
 func (m *kvMeta) maxTxnBatchNum() int {
 	if m.Name() == "etcd" {
 		return 128
@@ -747,6 +765,8 @@ func (m *kvMeta) LoadMetaV2(ctx Context, r io.Reader, opt *LoadOption) error {
 				m.loadQuota(ctx, task.msg, &pairs)
 			case segTypeStat:
 				m.loadDirStats(ctx, task.msg, &pairs)
+			case segTypeDedupBlock, segTypeDedupRef:
+				m.loadDedupPairs(ctx, task.msg, &pairs)
 			}
 			if len(pairs) >= maxNum {
 				if err := m.insertKVs(ctx, pairs, opt.Threads); err != nil {
@@ -255,24 +255,33 @@ func (e *dataEncryptor) Encrypt(plaintext []byte) ([]byte, error) {
 	return buf[:headerSize+len(ciphertext)], nil
 }
 
-func (e *dataEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+// splitCiphertext returns the wrapped data key, the nonce and the sealed data of ciphertext.
+func splitCiphertext(ciphertext []byte) ([]byte, []byte, []byte, error) {
 	if len(ciphertext) < 3 {
-		return nil, fmt.Errorf("received encrypted text length is less than 3, the object is corrupted")
+		return nil, nil, nil, fmt.Errorf("received encrypted text length is less than 3, the object is corrupted")
 	}
 	keyLen := int(ciphertext[0])<<8 + int(ciphertext[1])
 	nonceLen := int(ciphertext[2])
 	if 3+keyLen+nonceLen >= len(ciphertext) {
-		return nil, fmt.Errorf("malformed ciphertext: %d %d", keyLen, nonceLen)
+		return nil, nil, nil, fmt.Errorf("malformed ciphertext: %d %d", keyLen, nonceLen)
 	}
 	ciphertext = ciphertext[3:]
-	cipherkey := ciphertext[:keyLen]
-	nonce := ciphertext[keyLen : keyLen+nonceLen]
-	ciphertext = ciphertext[keyLen+nonceLen:]
+	return ciphertext[:keyLen], ciphertext[keyLen : keyLen+nonceLen], ciphertext[keyLen+nonceLen:], nil
+}
 
+func (e *dataEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	cipherkey, nonce, ciphertext, err := splitCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
 	key, err := e.keyEncryptor.Decrypt(cipherkey)
 	if err != nil {
 		return nil, errors.New("decryt key: " + err.Error())
 	}
+	return e.open(key, nonce, ciphertext)
+}
+
+func (e *dataEncryptor) open(key, nonce, ciphertext []byte) ([]byte, error) {
 	aead, err := e.aead(key)
 	if err != nil {
 		return nil, err
@@ -280,6 +289,109 @@ func (e *dataEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
 	return aead.Open(ciphertext[:0], nonce, ciphertext, nil)
 }
 
+// KeyRotator is an Encryptor which can make the objects encrypted by the retired keys
+// encrypted by the current key.
+type KeyRotator interface {
+	Encryptor
+	Rotate(ciphertext []byte, reencrypt bool) ([]byte, error)
+}
+
+// rotatedEncryptor encrypts with the current key, and decrypts the objects encrypted by
+// the current key or any of the retired keys.
+type rotatedEncryptor struct {
+	*dataEncryptor
+	algo    string
+	retired []*dataEncryptor
+	algos   []string
+}
+
+// NewRotatedEncryptor returns an encryptor for a volume whose key was rotated, privKey and algo are
+// the current ones, retiredKeys and algos are the ones used before.
+func NewRotatedEncryptor(privKey any, algo string, retiredKeys []any, algos []string) (KeyRotator, error) {
+	current, err := NewDataEncryptor(NewKeyEncryptor(privKey), algo)
+	if err != nil {
+		return nil, err
+	}
+	e := &rotatedEncryptor{dataEncryptor: current, algo: algo, algos: algos}
+	for i, k := range retiredKeys {
+		r, err := NewDataEncryptor(NewKeyEncryptor(k), algos[i])
+		if err != nil {
+			return nil, err
+		}
+		e.retired = append(e.retired, r)
+	}
+	return e, nil
+}
+
+// unwrap decrypts the data key with all the keys, and returns the index of matched one (-1 for the current one).
+func (e *rotatedEncryptor) unwrap(cipherkey []byte) (int, []byte, error) {
+	key, err := e.keyEncryptor.Decrypt(cipherkey)
+	if err == nil {
+		return -1, key, nil
+	}
+	for i, r := range e.retired {
+		if key, err := r.keyEncryptor.Decrypt(cipherkey); err == nil {
+			return i, key, nil
+		}
+	}
+	return 0, nil, errors.New("decryt key: " + err.Error())
+}
+
+func (e *rotatedEncryptor) Decrypt(ciphertext []byte) ([]byte, error) {
+	cipherkey, nonce, ciphertext, err := splitCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	idx, key, err := e.unwrap(cipherkey)
+	if err != nil {
+		return nil, err
+	}
+	if idx < 0 {
+		return e.open(key, nonce, ciphertext)
+	}
+	return e.retired[idx].open(key, nonce, ciphertext)
+}
+
+// Rotate makes ciphertext encrypted by the current key. The data key is wrapped by the current key
+// if the algorithm is not changed, otherwise or if reencrypt is true, the data is encrypted again
+// with a new data key. It returns nil if ciphertext is encrypted by the current key already.
+// The content of ciphertext may be changed.
+func (e *rotatedEncryptor) Rotate(ciphertext []byte, reencrypt bool) ([]byte, error) {
+	cipherkey, nonce, sealed, err := splitCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	idx, key, err := e.unwrap(cipherkey)
+	if err != nil || idx < 0 {
+		return nil, err
+	}
+	if reencrypt || normalizeAlgo(e.algos[idx]) != normalizeAlgo(e.algo) {
+		plain, err := e.retired[idx].open(key, nonce, sealed)
+		if err != nil {
+			return nil, err
+		}
+		return e.Encrypt(plain)
+	}
+	if cipherkey, err = e.keyEncryptor.Encrypt(key); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 3+len(cipherkey)+len(nonce)+len(sealed))
+	buf[0] = byte(len(cipherkey) >> 8)
+	buf[1] = byte(len(cipherkey) & 0xFF)
+	buf[2] = byte(len(nonce))
+	n := 3 + copy(buf[3:], cipherkey)
+	n += copy(buf[n:], nonce)
+	copy(buf[n:], sealed)
+	return buf, nil
+}
+
+func normalizeAlgo(algo string) string {
+	if algo == "" {
+		return AES256GCM_RSA
+	}
+	return algo
+}
+
 // MaxOverhead returns the maximum number of extra bytes that Encrypt can add.
 // Layout is:
 //
@@ -376,5 +488,12 @@ func (e *encrypted) GetTier(ctx context.Context) Tier {
 	return Tier{}
 }
 
+func (e *encrypted) Repair(ctx context.Context, key string, dryRun bool) (int, error) {
+	if o, ok := e.ObjectStorage.(SupportRepair); ok {
+		return o.Repair(ctx, key, dryRun)
+	}
+	return 0, notSupported
+}
+
 var _ ObjectStorage = (*encrypted)(nil)
 var _ SupportTier = (*encrypted)(nil)
@@ -268,6 +268,57 @@ func TestDataEncryptor(t *testing.T) {
 	}
 }
 
+func TestRotatedEncryptor(t *testing.T) {
+	data := []byte("hello")
+	oldKey, olderKey, currentKey := genPrivateKey("rsa"), genPrivateKey("sm2"), genPrivateKey("rsa")
+	old, err := NewDataEncryptor(NewKeyEncryptor(oldKey), AES256GCM_RSA)
+	require.NoError(t, err)
+	older, err := NewDataEncryptor(NewKeyEncryptor(olderKey), SM4GCM)
+	require.NoError(t, err)
+	current, err := NewDataEncryptor(NewKeyEncryptor(currentKey), AES256GCM_RSA)
+	require.NoError(t, err)
+	re, err := NewRotatedEncryptor(currentKey, AES256GCM_RSA, []any{oldKey, olderKey}, []string{"", SM4GCM})
+	require.NoError(t, err)
+
+	for _, c := range []struct {
+		name      string
+		enc       *dataEncryptor
+		reencrypt bool
+	}{
+		{"rewrap", old, false},
+		{"reencrypt", old, true},
+		{"algo_changed", older, false},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ciphertext, err := c.enc.Encrypt(data)
+			require.NoError(t, err)
+			_, _, sealed, _ := splitCiphertext(ciphertext)
+			sealed = append([]byte(nil), sealed...)
+			plaintext, err := re.Decrypt(append([]byte(nil), ciphertext...))
+			require.NoError(t, err)
+			require.Equal(t, data, plaintext)
+
+			rotated, err := re.Rotate(ciphertext, c.reencrypt)
+			require.NoError(t, err)
+			_, _, sealed2, _ := splitCiphertext(rotated)
+			require.Equal(t, c.name == "rewrap", bytes.Equal(sealed, sealed2), "data should be encrypted again only if required")
+			rotated2, err := re.Rotate(rotated, c.reencrypt)
+			require.NoError(t, err)
+			require.Nil(t, rotated2, "data encrypted by the current key should not be rotated")
+			plaintext, err = current.Decrypt(rotated)
+			require.NoError(t, err, "rotated data should be decrypted by the current key")
+			require.Equal(t, data, plaintext)
+		})
+	}
+
+	other, _ := NewDataEncryptor(NewKeyEncryptor(genPrivateKey("rsa")), AES256GCM_RSA)
+	ciphertext, _ := other.Encrypt(data)
+	_, err = re.Decrypt(ciphertext)
+	require.Error(t, err, "data encrypted by unknown key should not be decrypted")
+	_, err = re.Rotate(ciphertext, false)
+	require.Error(t, err)
+}
+
 func TestEncryptorMaxOverhead(t *testing.T) {
 	rsa1024Key, err := rsa.GenerateKey(rand.Reader, 1024)
 	require.NoError(t, err)
@@ -0,0 +1,574 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package object
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// SupportRepair is implemented by the object storages with redundancy.
+type SupportRepair interface {
+	// Repair checks the parts of an object and rebuilds the lost or damaged ones unless dryRun is true,
+	// it returns the number of lost or damaged parts.
+	Repair(ctx context.Context, key string, dryRun bool) (int, error)
+}
+
+// Every shard starts with a header:
+//
+//	checksum(4) | size of object(8) | index(1) | data shards(1) | parity shards(1) | reserved(1)
+//
+// the checksum (CRC32C) covers the content of the shard after the header.
+const shardHeaderSize = 16
+
+var shardCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+type erasure struct {
+	DefaultObjectStorage
+	stores []ObjectStorage
+	data   int
+	parity int
+	enc    reedsolomon.Encoder
+}
+
+// ParseErasure parses the erasure code in the form of "K+M", K data shards and M parity shards.
+func ParseErasure(code string) (int, int, error) {
+	ps := strings.Split(code, "+")
+	if len(ps) != 2 {
+		return 0, 0, fmt.Errorf("invalid erasure code %q, it should be K+M", code)
+	}
+	k, err1 := strconv.Atoi(ps[0])
+	m, err2 := strconv.Atoi(ps[1])
+	if err1 != nil || err2 != nil || k < 1 || m < 1 || k+m > 256 {
+		return 0, 0, fmt.Errorf("invalid erasure code %q, K and M should be positive and K+M should be at most 256", code)
+	}
+	return k, m, nil
+}
+
+func newErasure(stores []ObjectStorage, data, parity int) (*erasure, error) {
+	if len(stores) != data+parity {
+		return nil, fmt.Errorf("%d stores are needed for %d+%d erasure code, but got %d", data+parity, data, parity, len(stores))
+	}
+	enc, err := reedsolomon.New(data, parity)
+	if err != nil {
+		return nil, err
+	}
+	return &erasure{stores: stores, data: data, parity: parity, enc: enc}, nil
+}
+
+func (e *erasure) String() string {
+	return fmt.Sprintf("ec%d+%d://%s", e.data, e.parity, e.stores[0])
+}
+
+func (e *erasure) Limits() Limits {
+	return Limits{}
+}
+
+func (e *erasure) Create(ctx context.Context) error {
+	for _, o := range e.stores {
+		if err := o.Create(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parallel runs f for the stores from start to end concurrently, and returns the errors of them.
+func (e *erasure) parallel(start, end int, f func(i int) error) []error {
+	errs := make([]error, len(e.stores))
+	var wg sync.WaitGroup
+	for i := start; i < end; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = f(i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+func (e *erasure) encodeHeader(buf []byte, i int, size int64) {
+	binary.BigEndian.PutUint32(buf[0:4], crc32.Checksum(buf[shardHeaderSize:], shardCRCTable))
+	binary.BigEndian.PutUint64(buf[4:12], uint64(size))
+	buf[12] = byte(i)
+	buf[13] = byte(e.data)
+	buf[14] = byte(e.parity)
+	buf[15] = 0
+}
+
+// readShard reads shard i of key, and returns the content and size of the object.
+func (e *erasure) readShard(ctx context.Context, key string, i int) ([]byte, int64, error) {
+	r, err := e.stores[i].Get(ctx, key, 0, -1)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer r.Close()
+	buf, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(buf) < shardHeaderSize {
+		return nil, 0, fmt.Errorf("shard %d of %s is too short: %d bytes", i, key, len(buf))
+	}
+	if int(buf[12]) != i || int(buf[13]) != e.data || int(buf[14]) != e.parity {
+		return nil, 0, fmt.Errorf("shard %d of %s has unexpected header: %v", i, key, buf[12:15])
+	}
+	if crc32.Checksum(buf[shardHeaderSize:], shardCRCTable) != binary.BigEndian.Uint32(buf[0:4]) {
+		return nil, 0, fmt.Errorf("checksum mismatch for shard %d of %s", i, key)
+	}
+	return buf[shardHeaderSize:], int64(binary.BigEndian.Uint64(buf[4:12])), nil
+}
+
+// readShards reads the shards of key, parity shards are read only when some of the data shards
+// are not available, unless all is true. The shards not available are nil.
+func (e *erasure) readShards(ctx context.Context, key string, all bool) ([][]byte, int64, []error) {
+	shards := make([][]byte, len(e.stores))
+	sizes := make([]int64, len(e.stores))
+	read := func(i int) error {
+		var err error
+		shards[i], sizes[i], err = e.readShard(ctx, key, i)
+		return err
+	}
+	errs := e.parallel(0, e.data, read)
+	var failed bool
+	for i := 0; i < e.data; i++ {
+		failed = failed || errs[i] != nil
+	}
+	if all || failed {
+		perrs := e.parallel(e.data, len(e.stores), read)
+		copy(errs[e.data:], perrs[e.data:])
+	}
+	var size int64 = -1
+	for i, s := range shards {
+		if s == nil {
+			continue
+		}
+		if size < 0 {
+			size = sizes[i]
+		} else if sizes[i] != size {
+			errs[i] = fmt.Errorf("shard %d of %s has different size: %d != %d", i, key, sizes[i], size)
+			shards[i] = nil
+		}
+	}
+	return shards, size, errs
+}
+
+// shardsError returns the error to report when shards are not enough, which is os.ErrNotExist
+// if the object does not exist in any store.
+func (e *erasure) shardsError(key string, errs []error) error {
+	var lost int
+	var last error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			lost++
+		}
+		last = err
+	}
+	if lost == len(e.stores) {
+		return errs[0]
+	}
+	return fmt.Errorf("not enough shards to read %s: %s", key, last)
+}
+
+func (e *erasure) reconstruct(key string, shards [][]byte, size int64, errs []error) ([]byte, error) {
+	var available int
+	for _, s := range shards {
+		if s != nil {
+			available++
+		}
+	}
+	if available < e.data {
+		return nil, e.shardsError(key, errs)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	if available < len(shards) {
+		if err := e.enc.ReconstructData(shards); err != nil {
+			return nil, fmt.Errorf("reconstruct %s: %s", key, err)
+		}
+		logger.Debugf("Reconstructed %s from %d shards", key, available)
+	}
+	var buf bytes.Buffer
+	buf.Grow(int(size))
+	if err := e.enc.Join(&buf, shards, int(size)); err != nil {
+		return nil, fmt.Errorf("join shards of %s: %s", key, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *erasure) Head(ctx context.Context, key string) (Object, error) {
+	var firstErr error
+	for i, s := range e.stores {
+		o, err := s.Head(ctx, key)
+		if err == nil {
+			var r io.ReadCloser
+			if r, err = s.Get(ctx, key, 0, shardHeaderSize); err == nil {
+				hdr := make([]byte, shardHeaderSize)
+				_, err = io.ReadFull(r, hdr)
+				_ = r.Close()
+				if err == nil {
+					return &obj{key, int64(binary.BigEndian.Uint64(hdr[4:12])), o.Mtime(), false, o.StorageClass(), ""}, nil
+				}
+			}
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Debugf("Head shard %d of %s: %s", i, key, err)
+		}
+	}
+	return nil, firstErr
+}
+
+// readHeader reads the header of shard i, and returns the size of the object.
+func (e *erasure) readHeader(ctx context.Context, key string, i int) (int64, error) {
+	r, err := e.stores[i].Get(ctx, key, 0, shardHeaderSize)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	hdr := make([]byte, shardHeaderSize)
+	if _, err = io.ReadFull(r, hdr); err != nil {
+		return 0, err
+	}
+	if int(hdr[12]) != i || int(hdr[13]) != e.data || int(hdr[14]) != e.parity {
+		return 0, fmt.Errorf("shard %d of %s has unexpected header: %v", i, key, hdr[12:15])
+	}
+	return int64(binary.BigEndian.Uint64(hdr[4:12])), nil
+}
+
+// getRange reads the range of object from the data shards having it, the whole shards are read to
+// verify their checksum. It fails if any of them is not available or has a different size.
+func (e *erasure) getRange(ctx context.Context, key string, off, limit int64) ([]byte, error) {
+	size, err := e.readHeader(ctx, key, 0)
+	if err != nil {
+		return nil, err
+	}
+	if off > size {
+		off = size
+	}
+	if limit == -1 || off+limit > size {
+		limit = size - off
+	}
+	if limit == 0 {
+		return nil, nil
+	}
+	perShard := (size + int64(e.data) - 1) / int64(e.data)
+	first, last := int(off/perShard), int((off+limit-1)/perShard)
+	data := make([]byte, limit)
+	errs := e.parallel(first, last+1, func(i int) error {
+		shard, ssize, err := e.readShard(ctx, key, i)
+		if err != nil {
+			return err
+		}
+		if ssize != size || int64(len(shard)) != perShard {
+			return fmt.Errorf("unexpected size %d (%d bytes in shard), expect %d", ssize, len(shard), size)
+		}
+		start, end := max(off, int64(i)*perShard), min(off+limit, int64(i+1)*perShard)
+		copy(data[start-off:end-off], shard[start-int64(i)*perShard:end-int64(i)*perShard])
+		return nil
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("read shard %d of %s: %s", i, key, err)
+		}
+	}
+	return data, nil
+}
+
+func (e *erasure) Get(ctx context.Context, key string, off, limit int64, getters ...AttrGetter) (io.ReadCloser, error) {
+	if off > 0 || limit != -1 {
+		// only the data shards having the range are read, and the whole object is rebuilt on failures
+		data, err := e.getRange(ctx, key, off, limit)
+		if err == nil {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		logger.Debugf("Read range %d-%d of %s: %s", off, limit, key, err)
+	}
+	shards, size, errs := e.readShards(ctx, key, false)
+	data, err := e.reconstruct(key, shards, size, errs)
+	if err != nil {
+		return nil, err
+	}
+	l := int64(len(data))
+	if off > l {
+		off = l
+	}
+	if limit == -1 || off+limit > l {
+		limit = l - off
+	}
+	return io.NopCloser(bytes.NewReader(data[off : off+limit])), nil
+}
+
+// encode splits data into shards with headers.
+func (e *erasure) encode(data []byte) ([][]byte, error) {
+	bufs := make([][]byte, len(e.stores))
+	if len(data) == 0 {
+		for i := range bufs {
+			bufs[i] = make([]byte, shardHeaderSize)
+			e.encodeHeader(bufs[i], i, 0)
+		}
+		return bufs, nil
+	}
+	perShard := (len(data) + e.data - 1) / e.data
+	shards := make([][]byte, len(e.stores))
+	for i := range bufs {
+		bufs[i] = make([]byte, shardHeaderSize+perShard)
+		shards[i] = bufs[i][shardHeaderSize:]
+	}
+	for i := 0; i < e.data; i++ {
+		if i*perShard < len(data) {
+			copy(shards[i], data[i*perShard:])
+		}
+	}
+	if err := e.enc.Encode(shards); err != nil {
+		return nil, err
+	}
+	for i := range bufs {
+		e.encodeHeader(bufs[i], i, int64(len(data)))
+	}
+	return bufs, nil
+}
+
+func (e *erasure) Put(ctx context.Context, key string, in io.Reader, getters ...AttrGetter) error {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	bufs, err := e.encode(data)
+	if err != nil {
+		return fmt.Errorf("encode %s: %s", key, err)
+	}
+	put := func(i int) error {
+		return e.stores[i].Put(ctx, key, bytes.NewReader(bufs[i]), getters...)
+	}
+	errs := e.parallel(0, len(e.stores), put)
+	var failed []int
+	for i, err := range errs {
+		if err != nil {
+			logger.Warnf("Put shard %d of %s: %s", i, key, err)
+			if err = put(i); err != nil { // try once more
+				failed = append(failed, i)
+			}
+		}
+	}
+	if len(failed) > e.parity {
+		return fmt.Errorf("put %d shards of %s: %s", len(failed), key, errs[failed[0]])
+	}
+	if len(failed) > 0 {
+		// it can be read from the other shards, the lost ones are rebuilt by `juicefs fsck --repair`
+		logger.Errorf("Shards %v of %s are lost, run `juicefs fsck --repair` to rebuild them", failed, key)
+	}
+	return nil
+}
+
+func (e *erasure) Copy(ctx context.Context, dst, src string) error {
+	return notSupported
+}
+
+func (e *erasure) Delete(ctx context.Context, key string, getters ...AttrGetter) error {
+	errs := e.parallel(0, len(e.stores), func(i int) error {
+		return e.stores[i].Delete(ctx, key, getters...)
+	})
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *erasure) Repair(ctx context.Context, key string, dryRun bool) (int, error) {
+	shards, size, errs := e.readShards(ctx, key, true)
+	var damaged []int
+	for i, s := range shards {
+		if s == nil {
+			logger.Debugf("Shard %d of %s is not available: %s", i, key, errs[i])
+			damaged = append(damaged, i)
+		}
+	}
+	if len(damaged) == 0 {
+		return 0, nil
+	}
+	if len(damaged) > e.parity {
+		return len(damaged), e.shardsError(key, errs)
+	}
+	if dryRun {
+		return len(damaged), nil
+	}
+	if size > 0 {
+		if err := e.enc.Reconstruct(shards); err != nil {
+			return len(damaged), fmt.Errorf("reconstruct %s: %s", key, err)
+		}
+	}
+	for _, i := range damaged {
+		buf := make([]byte, shardHeaderSize+len(shards[i]))
+		copy(buf[shardHeaderSize:], shards[i])
+		e.encodeHeader(buf, i, size)
+		if err := e.stores[i].Put(ctx, key, bytes.NewReader(buf)); err != nil {
+			return len(damaged), fmt.Errorf("put shard %d of %s: %s", i, key, err)
+		}
+	}
+	logger.Infof("Repaired %d shards of %s", len(damaged), key)
+	return len(damaged), nil
+}
+
+// ListAll merges the objects in all stores, an object is listed if any shard of it exists.
+// The size of objects is estimated from the shards, which is rounded up to a multiple of the
+// number of data shards.
+func (e *erasure) ListAll(ctx context.Context, prefix, marker string, followLink bool) (<-chan Object, error) {
+	heads := &nextObjects{make([]nextKey, 0)}
+	for i := range e.stores {
+		ch, err := ListAll(ctx, e.stores[i], prefix, marker, followLink, true)
+		if err != nil {
+			return nil, fmt.Errorf("list %s: %s", e.stores[i], err)
+		}
+		first := <-ch
+		if first != nil {
+			heads.Push(nextKey{first, ch})
+		}
+	}
+	heap.Init(heads)
+
+	out := make(chan Object, 1000)
+	go func() {
+		defer close(out)
+		var last string
+		var listed bool
+		for heads.Len() > 0 {
+			n := heap.Pop(heads).(nextKey)
+			if o := n.o; !listed || o.Key() != last {
+				last, listed = o.Key(), true
+				size := o.Size()
+				if !o.IsDir() && size >= shardHeaderSize {
+					size = (size - shardHeaderSize) * int64(e.data)
+				}
+				out <- &obj{o.Key(), size, o.Mtime(), o.IsDir(), o.StorageClass(), o.Status()}
+			}
+			if o := <-n.ch; o != nil {
+				heap.Push(heads, nextKey{o, n.ch})
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (e *erasure) Restore(ctx context.Context, key string, days int32) error {
+	errs := e.parallel(0, len(e.stores), func(i int) error {
+		return e.stores[i].Restore(ctx, key, days)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *erasure) InitTiers(init Tiers) error {
+	var err = notSupported
+	for _, o := range e.stores {
+		if o, ok := o.(SupportTier); ok {
+			err = o.InitTiers(init)
+		}
+	}
+	return err
+}
+
+func (e *erasure) GetTier(ctx context.Context) Tier {
+	for _, o := range e.stores {
+		if o, ok := o.(SupportTier); ok {
+			return o.GetTier(ctx)
+		}
+	}
+	return Tier{}
+}
+
+// NewErasure returns an object storage which splits objects into data shards and parity shards
+// with Reed-Solomon code, and stores them in dataShards+parityShards buckets, so objects can be
+// read from any dataShards of them. The endpoint is a list of the buckets separated by comma, or
+// has a "%d" to generate them. The credentials could also be lists separated by comma, one for each
+// bucket, or a single one shared by all of them.
+func NewErasure(name, endpoint, ak, sk, token string, dataShards, parityShards int) (ObjectStorage, error) {
+	n := dataShards + parityShards
+	split := func(kind, v string) ([]string, error) {
+		vs := strings.Split(v, ",")
+		if len(vs) == 1 {
+			vs = make([]string, n)
+			for i := range vs {
+				vs[i] = v
+			}
+		} else if len(vs) != n {
+			return nil, fmt.Errorf("%d %ss are needed for %d+%d erasure code, but got %d", n, kind, dataShards, parityShards, len(vs))
+		}
+		return vs, nil
+	}
+	endpoints, err := split("bucket", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(endpoint, ",") {
+		for i := range endpoints {
+			endpoints[i] = fmt.Sprintf(endpoint, i)
+			if strings.HasSuffix(endpoints[i], "%!(EXTRA int=0)") {
+				return nil, fmt.Errorf("can not generate different endpoint using %s", endpoint)
+			}
+		}
+	}
+	aks, err := split("access key", ak)
+	if err != nil {
+		return nil, err
+	}
+	sks, err := split("secret key", sk)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := split("session token", token)
+	if err != nil {
+		return nil, err
+	}
+	stores := make([]ObjectStorage, n)
+	for i := range stores {
+		stores[i], err = CreateStorage(name, endpoints[i], aks[i], sks[i], tokens[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return newErasure(stores, dataShards, parityShards)
+}
+
+var _ SupportTier = (*erasure)(nil)
+var _ SupportRepair = (*erasure)(nil)
+var _ ObjectStorage = (*erasure)(nil)
@@ -0,0 +1,270 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package object
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readErasure(t *testing.T, s ObjectStorage, key string, off, limit int64) []byte {
+	r, err := s.Get(context.Background(), key, off, limit)
+	if err != nil {
+		t.Fatalf("get %s: %s", key, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("read %s: %s", key, err)
+	}
+	return data
+}
+
+func TestErasure(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewErasure("file", filepath.Join(dir, "%d")+"/", "", "", "", 4, 2)
+	if err != nil {
+		t.Fatalf("create erasure storage: %s", err)
+	}
+	if err = s.Create(ctx); err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	data := make([]byte, 1<<20+13)
+	rand.Read(data)
+	if err = s.Put(ctx, "a/block", bytes.NewReader(data)); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	if err = s.Put(ctx, "a/empty", bytes.NewReader(nil)); err != nil {
+		t.Fatalf("put empty: %s", err)
+	}
+	if o, err := s.Head(ctx, "a/block"); err != nil || o.Size() != int64(len(data)) {
+		t.Fatalf("head: %v %s", o, err)
+	}
+	if got := readErasure(t, s, "a/block", 0, -1); !bytes.Equal(got, data) {
+		t.Fatalf("data mismatch")
+	}
+	if got := readErasure(t, s, "a/block", 1000, 5000); !bytes.Equal(got, data[1000:6000]) {
+		t.Fatalf("range mismatch")
+	}
+	if got := readErasure(t, s, "a/empty", 0, -1); len(got) != 0 {
+		t.Fatalf("empty object has %d bytes", len(got))
+	}
+
+	// lose a data shard and corrupt a parity shard
+	shard := func(i int) string { return filepath.Join(dir, fmt.Sprint(i), "a", "block") }
+	if err = os.Remove(shard(1)); err != nil {
+		t.Fatalf("remove shard: %s", err)
+	}
+	buf, _ := os.ReadFile(shard(5))
+	buf[len(buf)-1] ^= 0xFF
+	_ = os.WriteFile(shard(5), buf, 0644)
+	if got := readErasure(t, s, "a/block", 0, -1); !bytes.Equal(got, data) {
+		t.Fatalf("data mismatch with 2 lost shards")
+	}
+
+	r := s.(SupportRepair)
+	if n, err := r.Repair(ctx, "a/block", true); err != nil || n != 2 {
+		t.Fatalf("check: %d %s", n, err)
+	}
+	if _, err := os.Stat(shard(1)); !os.IsNotExist(err) {
+		t.Fatalf("shard should not be rebuilt in dry run")
+	}
+	if n, err := r.Repair(ctx, "a/block", false); err != nil || n != 2 {
+		t.Fatalf("repair: %d %s", n, err)
+	}
+	if n, err := r.Repair(ctx, "a/block", true); err != nil || n != 0 {
+		t.Fatalf("check after repair: %d %s", n, err)
+	}
+
+	// more than 2 lost shards can't be read
+	for i := 0; i < 3; i++ {
+		_ = os.Remove(shard(i))
+	}
+	if _, err = s.Get(ctx, "a/block", 0, -1); err == nil {
+		t.Fatalf("get should fail with 3 lost shards")
+	}
+	if n, err := r.Repair(ctx, "a/block", false); err == nil || n != 3 {
+		t.Fatalf("repair should fail with 3 lost shards: %d %s", n, err)
+	}
+
+	objs, err := ListAll(ctx, s, "a/", "", true, true)
+	if err != nil {
+		t.Fatalf("list: %s", err)
+	}
+	var keys []string
+	for o := range objs {
+		if !o.IsDir() {
+			keys = append(keys, o.Key())
+		}
+	}
+	if len(keys) != 2 || keys[0] != "a/block" || keys[1] != "a/empty" {
+		t.Fatalf("list: %v", keys)
+	}
+
+	for _, key := range []string{"a/block", "a/empty", "a/missing"} {
+		if err = s.Delete(ctx, key); err != nil {
+			t.Fatalf("delete %s: %s", key, err)
+		}
+	}
+	if _, err = s.Get(ctx, "a/block", 0, -1); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("get deleted object: %s", err)
+	}
+}
+
+func TestErasureEndpoints(t *testing.T) {
+	dir := t.TempDir()
+	var eps []string
+	for i := 0; i < 3; i++ {
+		eps = append(eps, filepath.Join(dir, fmt.Sprintf("disk%d", i), "jfs")+"/")
+	}
+	s, err := NewErasure("file", strings.Join(eps, ","), "ak", "sk0,sk1,sk2", "", 2, 1)
+	if err != nil {
+		t.Fatalf("create erasure storage: %s", err)
+	}
+	if err = s.Create(context.Background()); err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	data := []byte("hello erasure")
+	if err = s.Put(context.Background(), "k", bytes.NewReader(data)); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	for _, ep := range eps {
+		if _, err := os.Stat(filepath.Join(ep, "k")); err != nil {
+			t.Fatalf("shard in %s: %s", ep, err)
+		}
+	}
+	if got := readErasure(t, s, "k", 0, -1); !bytes.Equal(got, data) {
+		t.Fatalf("data mismatch")
+	}
+	if _, err = NewErasure("file", strings.Join(eps[:2], ","), "", "", "", 2, 1); err == nil {
+		t.Fatalf("2 buckets should not be enough for 2+1 erasure code")
+	}
+	if _, err = NewErasure("file", strings.Join(eps, ","), "", "sk0,sk1", "", 2, 1); err == nil {
+		t.Fatalf("2 secret keys should not be enough for 3 buckets")
+	}
+}
+
+func TestParseErasure(t *testing.T) {
+	if k, m, err := ParseErasure("4+2"); err != nil || k != 4 || m != 2 {
+		t.Fatalf("parse 4+2: %d %d %s", k, m, err)
+	}
+	for _, code := range []string{"", "4", "4+0", "0+2", "a+b", "200+100"} {
+		if _, _, err := ParseErasure(code); err == nil {
+			t.Fatalf("%q should be invalid", code)
+		}
+	}
+}
+
+// shardStore fails the writes if broken, and counts the bytes read.
+type shardStore struct {
+	ObjectStorage
+	broken bool
+	read   int64
+}
+
+func (s *shardStore) Get(ctx context.Context, key string, off, limit int64, getters ...AttrGetter) (io.ReadCloser, error) {
+	r, err := s.ObjectStorage.Get(ctx, key, off, limit, getters...)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(r)
+	_ = r.Close()
+	s.read += int64(len(data))
+	return io.NopCloser(bytes.NewReader(data)), err
+}
+
+func (s *shardStore) Put(ctx context.Context, key string, in io.Reader, getters ...AttrGetter) error {
+	if s.broken {
+		return errors.New("broken")
+	}
+	return s.ObjectStorage.Put(ctx, key, in, getters...)
+}
+
+func TestErasurePartial(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	shards := make([]*shardStore, 6)
+	stores := make([]ObjectStorage, len(shards))
+	for i := range shards {
+		fs, err := CreateStorage("file", filepath.Join(dir, fmt.Sprint(i))+"/", "", "", "")
+		if err != nil {
+			t.Fatalf("create store %d: %s", i, err)
+		}
+		shards[i] = &shardStore{ObjectStorage: fs}
+		stores[i] = shards[i]
+	}
+	s, err := newErasure(stores, 4, 2)
+	if err != nil {
+		t.Fatalf("create erasure storage: %s", err)
+	}
+	data := make([]byte, 4<<20)
+	rand.Read(data)
+
+	// it's written with enough shards
+	shards[1].broken = true
+	if err = s.Put(ctx, "block", bytes.NewReader(data)); err != nil {
+		t.Fatalf("put with a broken store: %s", err)
+	}
+	if got := readErasure(t, s, "block", 0, -1); !bytes.Equal(got, data) {
+		t.Fatalf("data mismatch")
+	}
+	shards[1].broken = false
+	if n, err := s.Repair(ctx, "block", false); err != nil || n != 1 {
+		t.Fatalf("repair: %d %s", n, err)
+	}
+	shards[1].broken, shards[2].broken, shards[3].broken = true, true, true
+	if err = s.Put(ctx, "block2", bytes.NewReader(data)); err == nil {
+		t.Fatalf("put should fail with 3 broken stores")
+	}
+
+	// only the shards having the range are read, and they are read as a whole to verify the checksum
+	for _, sh := range shards {
+		sh.read = 0
+	}
+	if got := readErasure(t, s, "block", 1<<20-100, 200); !bytes.Equal(got, data[1<<20-100:1<<20+100]) {
+		t.Fatalf("range mismatch")
+	}
+	for i, sh := range shards {
+		if expect := map[int]int64{0: 2*shardHeaderSize + 1<<20, 1: shardHeaderSize + 1<<20}[i]; sh.read != expect {
+			t.Fatalf("shard %d: read %d bytes, expect %d", i, sh.read, expect)
+		}
+	}
+	// the corrupted range is rebuilt from the other shards
+	p := filepath.Join(dir, "0", "block")
+	buf, _ := os.ReadFile(p)
+	buf[shardHeaderSize+1<<20-50] ^= 0xFF
+	_ = os.WriteFile(p, buf, 0644)
+	if got := readErasure(t, s, "block", 1<<20-100, 200); !bytes.Equal(got, data[1<<20-100:1<<20+100]) {
+		t.Fatalf("range mismatch with a corrupted shard")
+	}
+	// the range is rebuilt from the other shards
+	if err = os.Remove(filepath.Join(dir, "1", "block")); err != nil {
+		t.Fatalf("remove shard: %s", err)
+	}
+	if got := readErasure(t, s, "block", 1<<20-100, 200); !bytes.Equal(got, data[1<<20-100:1<<20+100]) {
+		t.Fatalf("range mismatch with a lost shard")
+	}
+}
@@ -129,11 +129,11 @@ func Shutdown(o ObjectStorage) {
 
 	switch o := o.(type) {
 	case *encrypted:
-		fn(o.ObjectStorage)
+		Shutdown(o.ObjectStorage)
 	case *chunkedEncrypted:
-		fn(o.ObjectStorage)
+		Shutdown(o.ObjectStorage)
 	case *withPrefix:
-		fn(o.os)
+		Shutdown(o.os)
 	case *sharded:
 		for _, s := range o.stores {
 			fn(s)
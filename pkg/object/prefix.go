@@ -45,6 +45,13 @@ func (s *withPrefix) GetTier(ctx context.Context) Tier {
 	return Tier{}
 }
 
+func (s *withPrefix) Repair(ctx context.Context, key string, dryRun bool) (int, error) {
+	if o, ok := s.os.(SupportRepair); ok {
+		return o.Repair(ctx, s.prefix+key, dryRun)
+	}
+	return 0, notSupported
+}
+
 // WithPrefix return an object storage that add a prefix to keys.
 func WithPrefix(os ObjectStorage, prefix string) ObjectStorage {
 	return &withPrefix{os, prefix}
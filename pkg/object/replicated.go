@@ -0,0 +1,593 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package object
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaConfig is the configuration of replicated object storage.
+type ReplicaConfig struct {
+	Async       bool          // replicate the writes in background
+	QueueDir    string        // directory to persist the pending replications, required in async mode
+	ReadTimeout time.Duration // latency budget of the primary before failing over to the replicas
+	Workers     int           // number of background replicators
+}
+
+const (
+	primaryDownTime  = time.Second * 30 // how long the reads skip the primary after it fails
+	replicaQueueSize = 1000             // operations buffered by a worker, the others are left in the dir
+	replicaRetries   = 3                // tries of an operation before it's left to be retried later
+	replicaRetryWait = time.Minute      // how long a failed operation waits to be retried
+)
+
+type replicated struct {
+	DefaultObjectStorage
+	stores    []ObjectStorage // the first one is the primary
+	conf      ReplicaConfig
+	queue     *replicaQueue
+	downUntil atomic.Int64
+}
+
+// NewReplicated returns an object storage which writes objects into the primary and all the replicas,
+// and reads them from the replicas when the primary fails or is too slow.
+func NewReplicated(primary ObjectStorage, replicas []ObjectStorage, conf ReplicaConfig) (ObjectStorage, error) {
+	r := &replicated{stores: append([]ObjectStorage{primary}, replicas...), conf: conf}
+	if conf.Async {
+		if conf.QueueDir == "" {
+			return nil, fmt.Errorf("async replication needs a queue dir")
+		}
+		if conf.Workers <= 0 {
+			conf.Workers = 10
+		}
+		q, err := newReplicaQueue(conf.QueueDir, conf.Workers, replicaQueueSize, r.replicate)
+		if err != nil {
+			return nil, fmt.Errorf("replication queue: %s", err)
+		}
+		r.queue = q
+	}
+	return r, nil
+}
+
+func (r *replicated) String() string {
+	return fmt.Sprintf("replicated%d://%s", len(r.stores)-1, r.stores[0])
+}
+
+func (r *replicated) Limits() Limits {
+	l := r.stores[0].Limits()
+	l.IsSupportMultipartUpload = false
+	l.IsSupportUploadPartCopy = false
+	return l
+}
+
+func (r *replicated) Create(ctx context.Context) error {
+	for _, s := range r.stores {
+		if err := s.Create(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *replicated) markDown(err error) {
+	now := time.Now()
+	if r.downUntil.Swap(now.Add(primaryDownTime).UnixNano()) < now.UnixNano() {
+		logger.Warnf("Primary storage %s failed: %s, read from replicas in the next %s", r.stores[0], err, primaryDownTime)
+	}
+}
+
+// readOrder returns the stores to read from, the primary is the last one if it failed recently.
+func (r *replicated) readOrder() []ObjectStorage {
+	if time.Now().UnixNano() < r.downUntil.Load() {
+		return append(append([]ObjectStorage{}, r.stores[1:]...), r.stores[0])
+	}
+	return r.stores
+}
+
+type readResult[T any] struct {
+	v   T
+	err error
+}
+
+func closeResult[T any](res readResult[T]) {
+	if c, ok := any(res.v).(io.Closer); ok && res.err == nil {
+		_ = c.Close()
+	}
+}
+
+// failover calls f for the primary, and the replicas in order if the primary fails or does not
+// respond within the latency budget. The error of the first store is returned if all of them fail.
+// It's used by the reads and the async writes.
+func failover[T any](r *replicated, f func(s ObjectStorage) (T, error)) (T, error) {
+	order := r.readOrder()
+	var pending chan readResult[T]
+	var firstErr error
+	for i, s := range order {
+		if i == 0 && s == r.stores[0] && r.conf.ReadTimeout > 0 {
+			ch := make(chan readResult[T], 1)
+			go func() {
+				v, err := f(s)
+				ch <- readResult[T]{v, err}
+			}()
+			timer := time.NewTimer(r.conf.ReadTimeout)
+			select {
+			case res := <-ch:
+				timer.Stop()
+				if res.err == nil {
+					return res.v, nil
+				}
+				firstErr = res.err
+				if !errors.Is(res.err, os.ErrNotExist) {
+					r.markDown(res.err)
+				}
+			case <-timer.C:
+				r.markDown(fmt.Errorf("no response in %s", r.conf.ReadTimeout))
+				pending = ch
+			}
+			continue
+		}
+		v, err := f(s)
+		if err == nil {
+			if pending != nil {
+				go func() { closeResult(<-pending) }()
+			}
+			return v, nil
+		}
+		if s == r.stores[0] && !errors.Is(err, os.ErrNotExist) {
+			r.markDown(err)
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if pending != nil {
+		// all the replicas failed, wait for the primary
+		res := <-pending
+		return res.v, res.err
+	}
+	var zero T
+	return zero, firstErr
+}
+
+func (r *replicated) Head(ctx context.Context, key string) (Object, error) {
+	return failover(r, func(s ObjectStorage) (Object, error) {
+		return s.Head(ctx, key)
+	})
+}
+
+func (r *replicated) Get(ctx context.Context, key string, off, limit int64, getters ...AttrGetter) (io.ReadCloser, error) {
+	return failover(r, func(s ObjectStorage) (io.ReadCloser, error) {
+		return s.Get(ctx, key, off, limit, getters...)
+	})
+}
+
+// parallel runs f for all the stores concurrently, and returns the first error.
+func (r *replicated) parallel(f func(s ObjectStorage) error) error {
+	errs := make([]error, len(r.stores))
+	var wg sync.WaitGroup
+	for i, s := range r.stores {
+		wg.Add(1)
+		go func(i int, s ObjectStorage) {
+			defer wg.Done()
+			errs[i] = f(s)
+		}(i, s)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.stores[i], err)
+		}
+	}
+	return nil
+}
+
+func (r *replicated) Put(ctx context.Context, key string, in io.Reader, getters ...AttrGetter) error {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if r.queue == nil {
+		return r.parallel(func(s ObjectStorage) error {
+			return s.Put(ctx, key, bytes.NewReader(data), getters...)
+		})
+	}
+	// write into the replicas if the primary fails or is too slow, the others are fixed in background
+	if _, err = failover(r, func(s ObjectStorage) (struct{}, error) {
+		err := s.Put(ctx, key, bytes.NewReader(data), getters...)
+		if err != nil {
+			logger.Warnf("Put %s into %s: %s", key, s, err)
+		}
+		return struct{}{}, err
+	}); err != nil {
+		return err
+	}
+	return r.queue.add(&replicaOp{key: key})
+}
+
+func (r *replicated) Copy(ctx context.Context, dst, src string) error {
+	return notSupported
+}
+
+func (r *replicated) Delete(ctx context.Context, key string, getters ...AttrGetter) error {
+	del := func(s ObjectStorage) error {
+		if err := s.Delete(ctx, key, getters...); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if r.queue == nil {
+		return r.parallel(del)
+	}
+	// the replicas, and the primary if it fails, are deleted in background
+	if err := del(r.stores[0]); err != nil {
+		logger.Warnf("Delete %s from %s: %s, delete it in background", key, r.stores[0], err)
+		r.markDown(err)
+	}
+	return r.queue.add(&replicaOp{key: key, delete: true})
+}
+
+// replicate copies the object from the first store having it to the others, or deletes it from all stores.
+func (r *replicated) replicate(op *replicaOp) error {
+	ctx := context.Background()
+	if op.delete {
+		return r.parallel(func(s ObjectStorage) error {
+			if err := s.Delete(ctx, op.key); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			return nil
+		})
+	}
+	var data []byte
+	var src ObjectStorage
+	var err error
+	for _, s := range r.stores {
+		var in io.ReadCloser
+		if in, err = s.Get(ctx, op.key, 0, -1); err == nil {
+			data, err = io.ReadAll(in)
+			_ = in.Close()
+		}
+		if err == nil {
+			src = s
+			break
+		}
+	}
+	if src == nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Debugf("Object %s is deleted before replicated", op.key)
+			return nil
+		}
+		return err
+	}
+	return r.parallel(func(s ObjectStorage) error {
+		if s == src {
+			return nil
+		}
+		return s.Put(ctx, op.key, bytes.NewReader(data))
+	})
+}
+
+// List lists the objects from the primary, or the replicas in order if it fails.
+func (r *replicated) List(ctx context.Context, prefix, marker, token, delimiter string, limit int64, followLink bool) ([]Object, bool, string, error) {
+	var firstErr error
+	for _, s := range r.stores {
+		objs, hasMore, nextToken, err := s.List(ctx, prefix, marker, token, delimiter, limit, followLink)
+		if err == nil || errors.Is(err, notSupported) {
+			return objs, hasMore, nextToken, err
+		}
+		logger.Warnf("List %s: %s", s, err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, false, "", firstErr
+}
+
+func (r *replicated) ListAll(ctx context.Context, prefix, marker string, followLink bool) (<-chan Object, error) {
+	var firstErr error
+	for _, s := range r.stores {
+		ch, err := ListAll(ctx, s, prefix, marker, followLink, true)
+		if err == nil {
+			return ch, nil
+		}
+		logger.Warnf("List %s: %s", s, err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+func (r *replicated) Restore(ctx context.Context, key string, days int32) error {
+	return r.stores[0].Restore(ctx, key, days)
+}
+
+func (r *replicated) InitTiers(init Tiers) error {
+	var err = notSupported
+	for _, s := range r.stores {
+		if s, ok := s.(SupportTier); ok {
+			err = s.InitTiers(init)
+		}
+	}
+	return err
+}
+
+func (r *replicated) GetTier(ctx context.Context) Tier {
+	if s, ok := r.stores[0].(SupportTier); ok {
+		return s.GetTier(ctx)
+	}
+	return Tier{}
+}
+
+func (r *replicated) Shutdown() {
+	if r.queue != nil {
+		r.queue.close()
+	}
+	for _, s := range r.stores {
+		Shutdown(s)
+	}
+}
+
+type replicaOp struct {
+	name   string // file name in the queue
+	key    string
+	delete bool
+}
+
+type failedOp struct {
+	name string
+	at   time.Time
+}
+
+// replicaQueue keeps the pending replications as files in a directory, so they can be resumed after
+// restarted. The directory is rescanned periodically to pick up the ones left by other processes, the
+// ones not dispatched when the workers are busy, and the failed ones.
+type replicaQueue struct {
+	dir     string
+	seq     atomic.Uint64
+	workers []chan *replicaOp
+	handle  func(op *replicaOp) error
+	mu      sync.Mutex
+	queued  map[string]bool
+	blocked map[string]failedOp // by key, the later operations of the key wait for the failed one
+	spilled bool                // operations are left in the dir, the new ones are dispatched by scan in order
+	wake    chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newReplicaQueue(dir string, workers, size int, handle func(op *replicaOp) error) (*replicaQueue, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	q := &replicaQueue{dir: dir, handle: handle, queued: make(map[string]bool), blocked: make(map[string]failedOp),
+		wake: make(chan struct{}, 1), done: make(chan struct{})}
+	q.workers = make([]chan *replicaOp, workers)
+	for i := range q.workers {
+		q.workers[i] = make(chan *replicaOp, size)
+		q.wg.Add(1)
+		go q.work(q.workers[i])
+	}
+	go q.scan()
+	return q, nil
+}
+
+func (q *replicaQueue) add(op *replicaOp) error {
+	op.name = fmt.Sprintf("%020d-%d-%d", time.Now().UnixNano(), os.Getpid(), q.seq.Add(1))
+	var kind = "put"
+	if op.delete {
+		kind = "delete"
+	}
+	tmp := filepath.Join(q.dir, op.name+".tmp")
+	if err := os.WriteFile(tmp, []byte(kind+"\n"+op.key), 0644); err != nil {
+		return fmt.Errorf("persist replication of %s: %s", op.key, err)
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, op.name)); err != nil {
+		return fmt.Errorf("persist replication of %s: %s", op.key, err)
+	}
+	q.mu.Lock()
+	spilled := q.spilled
+	q.mu.Unlock()
+	if spilled || !q.dispatch(op) {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// dispatch sends op to a worker by hash of key, so the operations of the same key are in order. It
+// returns false if the worker is busy, then op is left in the dir to be dispatched by scan.
+func (q *replicaQueue) dispatch(op *replicaOp) bool {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(op.key))
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queued[op.name] {
+		return true
+	}
+	select {
+	case q.workers[h.Sum32()%uint32(len(q.workers))] <- op:
+		q.queued[op.name] = true
+		return true
+	default:
+		q.spilled = true
+		return false
+	}
+}
+
+func (q *replicaQueue) load(name string) (*replicaOp, error) {
+	buf, err := os.ReadFile(filepath.Join(q.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	kind, key, ok := strings.Cut(string(buf), "\n")
+	if !ok || kind != "put" && kind != "delete" {
+		return nil, fmt.Errorf("invalid content: %q", buf)
+	}
+	return &replicaOp{name: name, key: key, delete: kind == "delete"}, nil
+}
+
+func (q *replicaQueue) scan() {
+	for {
+		entries, err := os.ReadDir(q.dir)
+		if err != nil {
+			logger.Warnf("Scan replication queue %s: %s", q.dir, err)
+		}
+		var names []string
+		for _, e := range entries {
+			if name := e.Name(); !strings.HasSuffix(name, ".tmp") {
+				names = append(names, name)
+			} else if info, err := e.Info(); err == nil && time.Since(info.ModTime()) > time.Hour {
+				_ = os.Remove(filepath.Join(q.dir, name))
+			}
+		}
+		sort.Strings(names)
+		q.unblock(names)
+		spilled := false
+		for _, name := range names {
+			op, err := q.load(name)
+			if errors.Is(err, os.ErrNotExist) {
+				continue // done by others
+			} else if err != nil {
+				logger.Warnf("Load replication %s: %s, remove it", name, err)
+				_ = os.Remove(filepath.Join(q.dir, name))
+				continue
+			}
+			if q.waiting(op) {
+				continue
+			}
+			if !q.dispatch(op) {
+				spilled = true // the later ones are dispatched in next round to keep the order
+				break
+			}
+		}
+		if !spilled {
+			q.mu.Lock()
+			q.spilled = false
+			q.mu.Unlock()
+		}
+		if len(names) > 0 {
+			logger.Debugf("Found %d pending replications in %s", len(names), q.dir)
+		}
+		wait := time.Minute
+		if spilled {
+			wait = time.Second
+		}
+		select {
+		case <-q.done:
+			return
+		case <-q.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// unblock removes the failed operations done by others from blocked.
+func (q *replicaQueue) unblock(names []string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key, f := range q.blocked {
+		if i := sort.SearchStrings(names, f.name); i == len(names) || names[i] != f.name {
+			delete(q.blocked, key)
+		}
+	}
+}
+
+// waiting returns whether op should not be dispatched, which is failed recently, or waiting for the
+// failed one of the same key.
+func (q *replicaQueue) waiting(op *replicaOp) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	f, ok := q.blocked[op.key]
+	return ok && (f.name != op.name || time.Since(f.at) < replicaRetryWait)
+}
+
+func (q *replicaQueue) work(ch chan *replicaOp) {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.done:
+			return
+		case op := <-ch:
+			if !q.run(op) {
+				return
+			}
+		}
+	}
+}
+
+// run tries op a few times with backoff. If it still fails, op is left in the dir to be retried after
+// the next scan, and the later operations of the same key wait for it. It returns false if closed.
+func (q *replicaQueue) run(op *replicaOp) bool {
+	q.mu.Lock()
+	failed, ok := q.blocked[op.key]
+	q.mu.Unlock()
+	var err error
+	if !ok || failed.name == op.name {
+		for try := 0; ; try++ {
+			if err = q.handle(op); err == nil || try+1 >= replicaRetries {
+				break
+			}
+			logger.Warnf("Replicate %s (delete: %t, tried %d times): %s", op.key, op.delete, try+1, err)
+			select {
+			case <-q.done:
+				return false // retry after restarted
+			case <-time.After(time.Second << try):
+			}
+		}
+		if err == nil {
+			if err := os.Remove(filepath.Join(q.dir, op.name)); err != nil && !os.IsNotExist(err) {
+				logger.Warnf("Remove replication %s: %s", op.name, err)
+			}
+		} else {
+			logger.Warnf("Replicate %s (delete: %t): %s, retry it later", op.key, op.delete, err)
+		}
+	}
+	q.mu.Lock()
+	switch {
+	case ok && failed.name != op.name: // wait for the failed one
+	case err == nil:
+		delete(q.blocked, op.key)
+	default:
+		q.blocked[op.key] = failedOp{op.name, time.Now()}
+	}
+	delete(q.queued, op.name)
+	q.mu.Unlock()
+	return true
+}
+
+func (q *replicaQueue) close() {
+	close(q.done)
+	q.wg.Wait()
+}
+
+var _ SupportTier = (*replicated)(nil)
+var _ Shutdownable = (*replicated)(nil)
+var _ ObjectStorage = (*replicated)(nil)
@@ -0,0 +1,271 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package object
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// unstable is a storage which fails or hangs.
+type unstable struct {
+	ObjectStorage
+	broken atomic.Bool
+	delay  atomic.Int64
+}
+
+func (u *unstable) Get(ctx context.Context, key string, off, limit int64, getters ...AttrGetter) (io.ReadCloser, error) {
+	time.Sleep(time.Duration(u.delay.Load()))
+	if u.broken.Load() {
+		return nil, errors.New("connection refused")
+	}
+	return u.ObjectStorage.Get(ctx, key, off, limit, getters...)
+}
+
+func (u *unstable) Put(ctx context.Context, key string, in io.Reader, getters ...AttrGetter) error {
+	time.Sleep(time.Duration(u.delay.Load()))
+	if u.broken.Load() {
+		return errors.New("connection refused")
+	}
+	return u.ObjectStorage.Put(ctx, key, in, getters...)
+}
+
+func (u *unstable) Delete(ctx context.Context, key string, getters ...AttrGetter) error {
+	if u.broken.Load() {
+		return errors.New("connection refused")
+	}
+	return u.ObjectStorage.Delete(ctx, key, getters...)
+}
+
+func (u *unstable) List(ctx context.Context, prefix, marker, token, delimiter string, limit int64, followLink bool) ([]Object, bool, string, error) {
+	if u.broken.Load() {
+		return nil, false, "", errors.New("connection refused")
+	}
+	return u.ObjectStorage.List(ctx, prefix, marker, token, delimiter, limit, followLink)
+}
+
+func getString(t *testing.T, s ObjectStorage, key string) string {
+	r, err := s.Get(context.Background(), key, 0, -1)
+	if err != nil {
+		t.Fatalf("get %s from %s: %s", key, s, err)
+	}
+	defer r.Close()
+	data, _ := io.ReadAll(r)
+	return string(data)
+}
+
+func TestReplicated(t *testing.T) {
+	ctx := context.Background()
+	m1, _ := newMem("primary", "", "", "")
+	m2, _ := newMem("replica", "", "", "")
+	primary := &unstable{ObjectStorage: m1}
+	s, err := NewReplicated(primary, []ObjectStorage{m2}, ReplicaConfig{ReadTimeout: time.Millisecond * 100})
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	if err = s.Put(ctx, "k1", bytes.NewReader([]byte("v1"))); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	if v := getString(t, m2, "k1"); v != "v1" {
+		t.Fatalf("replica got %q", v)
+	}
+
+	primary.broken.Store(true)
+	if v := getString(t, s, "k1"); v != "v1" {
+		t.Fatalf("failover got %q", v)
+	}
+	if err = s.Put(ctx, "k2", bytes.NewReader([]byte("v2"))); err == nil {
+		t.Fatalf("sync put should fail when primary is broken")
+	}
+	primary.broken.Store(false)
+
+	// the primary is slow
+	s.(*replicated).downUntil.Store(0)
+	primary.delay.Store(int64(time.Second))
+	start := time.Now()
+	if v := getString(t, s, "k1"); v != "v1" {
+		t.Fatalf("failover got %q", v)
+	}
+	if used := time.Since(start); used > time.Millisecond*900 {
+		t.Fatalf("failover took %s", used)
+	}
+	primary.delay.Store(0)
+
+	if err = s.Delete(ctx, "k1"); err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+	if _, err = m2.Head(ctx, "k1"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("k1 should be deleted from replica: %s", err)
+	}
+}
+
+func TestReplicatedAsync(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	m1, _ := newMem("primary", "", "", "")
+	m2, _ := newMem("replica", "", "", "")
+	primary := &unstable{ObjectStorage: m1}
+	replica := &unstable{ObjectStorage: m2}
+	replica.broken.Store(true)
+	conf := ReplicaConfig{Async: true, QueueDir: dir, Workers: 2, ReadTimeout: time.Millisecond * 100}
+	s, err := NewReplicated(primary, []ObjectStorage{replica}, conf)
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	if err = s.Put(ctx, "k1", bytes.NewReader([]byte("v1"))); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	// the replication is kept after restarted
+	s.(Shutdownable).Shutdown()
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expect 1 pending replication, got %d", len(entries))
+	}
+	replica.broken.Store(false)
+	s, err = NewReplicated(primary, []ObjectStorage{replica}, conf)
+	if err != nil {
+		t.Fatalf("create: %s", err)
+	}
+	defer s.(Shutdownable).Shutdown()
+	waitFor := func(cond func() bool) {
+		for i := 0; i < 100 && !cond(); i++ {
+			time.Sleep(time.Millisecond * 50)
+		}
+		if !cond() {
+			t.Fatalf("timeout")
+		}
+	}
+	waitFor(func() bool {
+		_, err := m2.Head(ctx, "k1")
+		return err == nil
+	})
+	waitFor(func() bool {
+		entries, _ := os.ReadDir(dir)
+		return len(entries) == 0
+	})
+
+	// write into the replica when the primary is broken
+	primary.broken.Store(true)
+	if err = s.Put(ctx, "k2", bytes.NewReader([]byte("v2"))); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	primary.broken.Store(false)
+	waitFor(func() bool {
+		_, err := m1.Head(ctx, "k2")
+		return err == nil
+	})
+
+	if err = s.Delete(ctx, "k2"); err != nil {
+		t.Fatalf("delete: %s", err)
+	}
+	waitFor(func() bool {
+		_, err := m2.Head(ctx, "k2")
+		return errors.Is(err, os.ErrNotExist)
+	})
+
+	// write into the replica when the primary is slow
+	s.(*replicated).downUntil.Store(0)
+	primary.delay.Store(int64(time.Second))
+	start := time.Now()
+	if err = s.Put(ctx, "k3", bytes.NewReader([]byte("v3"))); err != nil {
+		t.Fatalf("put: %s", err)
+	}
+	if used := time.Since(start); used > time.Millisecond*900 {
+		t.Fatalf("put took %s", used)
+	}
+	if v := getString(t, m2, "k3"); v != "v3" {
+		t.Fatalf("replica got %q", v)
+	}
+	primary.delay.Store(0)
+	waitFor(func() bool {
+		_, err := m1.Head(ctx, "k3")
+		return err == nil
+	})
+
+	// list from the replica and delete in background when the primary is broken
+	primary.broken.Store(true)
+	if objs, _, _, err := s.List(ctx, "k3", "", "", "", 10, false); err != nil || len(objs) != 1 || objs[0].Key() != "k3" {
+		t.Fatalf("list from replica: %+v %s", objs, err)
+	}
+	if err = s.Delete(ctx, "k3"); err != nil {
+		t.Fatalf("delete with broken primary: %s", err)
+	}
+	primary.broken.Store(false)
+	waitFor(func() bool {
+		_, err1 := m1.Head(ctx, "k3")
+		_, err2 := m2.Head(ctx, "k3")
+		return errors.Is(err1, os.ErrNotExist) && errors.Is(err2, os.ErrNotExist)
+	})
+}
+
+func TestReplicaQueue(t *testing.T) {
+	if _, err := NewReplicated(nil, nil, ReplicaConfig{Async: true}); err == nil {
+		t.Fatalf("async replication without queue dir should fail")
+	}
+	dir := t.TempDir()
+	var mu sync.Mutex
+	var done []string
+	var failed, blocked int
+	q, err := newReplicaQueue(dir, 1, 1, func(op *replicaOp) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if op.key == "bad" {
+			if op.delete {
+				blocked++ // should wait for the failed put
+			} else {
+				failed++
+			}
+			return errors.New("broken")
+		}
+		done = append(done, op.key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("create queue: %s", err)
+	}
+	defer q.close()
+	// the worker is blocked by the failed one, the others are left in the dir and dispatched in order
+	for _, op := range []*replicaOp{{key: "bad"}, {key: "a"}, {key: "b"}, {key: "bad", delete: true}, {key: "c"}} {
+		if err = q.add(op); err != nil {
+			t.Fatalf("add %s: %s", op.key, err)
+		}
+	}
+	for i := 0; i < 200; i++ {
+		mu.Lock()
+		n := len(done)
+		mu.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond * 50)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(done, ",") != "a,b,c" || failed != replicaRetries || blocked != 0 {
+		t.Fatalf("unexpected replications: %v, failed %d, blocked %d", done, failed, blocked)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("expect 2 pending replications, got %d", len(entries))
+	}
+}
@@ -32,7 +32,19 @@ func ParseBytes(ctx *cli.Context, key string, unit byte) uint64 {
 }
 
 func ParseBytesStr(key, str string, unit byte) uint64 {
+	val, err := TryParseBytes(str, unit)
+	if err != nil {
+		logger.Fatalf("Invalid value \"%s\" for \"%s\": %s", str, key, err)
+	}
+	return val
+}
+
+// TryParseBytes parses a size like "256M", unit is used when str has no unit.
+func TryParseBytes(str string, unit byte) (uint64, error) {
 	s := str
+	if len(s) == 0 {
+		return 0, errors.New("empty value")
+	}
 	if c := s[len(s)-1]; c < '0' || c > '9' {
 		unit = c
 		s = s[:len(s)-1]
@@ -59,10 +71,7 @@ func ParseBytesStr(key, str string, unit byte) uint64 {
 		}
 		val *= float64(uint64(1) << shift)
 	}
-	if err != nil {
-		logger.Fatalf("Invalid value \"%s\" for \"%s\": %s", str, key, err)
-	}
-	return uint64(val)
+	return uint64(val), err
 }
 
 func ParseMbps(ctx *cli.Context, key string) int64 {
@@ -0,0 +1,42 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vfs
+
+import (
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/meta"
+)
+
+// NewCacheGroupMembers returns a function listing the addresses of the clients in the cache group,
+// which are found in the active sessions of m.
+func NewCacheGroupMembers(m meta.Meta, group string) func() ([]string, error) {
+	return func() ([]string, error) {
+		sessions, err := m.ListSessions()
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		var addrs []string
+		for _, s := range sessions {
+			if s.CacheGroup == group && s.GroupAddr != "" && s.Expire.After(now) {
+				addrs = append(addrs, s.GroupAddr)
+			}
+		}
+		return addrs, nil
+	}
+}
@@ -49,9 +49,24 @@ func (s *blockingChunkStore) EvictCache(id uint64, length uint32) error      { r
 func (s *blockingChunkStore) CheckCache(id uint64, length uint32, handler func(bool, string, int)) error {
 	return nil
 }
-func (s *blockingChunkStore) UsedMemory() int64                  { return 0 }
-func (s *blockingChunkStore) UpdateLimit(upload, download int64) {}
-func (s *blockingChunkStore) BlobStorage() object.ObjectStorage  { return nil }
+func (s *blockingChunkStore) UsedMemory() int64                       { return 0 }
+func (s *blockingChunkStore) UpdateLimit(upload, download int64)      {}
+func (s *blockingChunkStore) UpdateCompress(algr, untagged string)    {}
+func (s *blockingChunkStore) SetDedupIndex(index chunk.DedupIndex)    {}
+func (s *blockingChunkStore) UpdateDedup(enabled bool, secret string) {}
+func (s *blockingChunkStore) BlobStorage() object.ObjectStorage       { return nil }
+func (s *blockingChunkStore) JoinCacheGroup(ctx context.Context, members func() ([]string, error)) string {
+	return ""
+}
+func (s *blockingChunkStore) FillRemoteCache(id uint64, length uint32) error { return nil }
+func (s *blockingChunkStore) PinCache(id uint64, length uint32) error        { return nil }
+func (s *blockingChunkStore) UnpinCache(id uint64, length uint32) error      { return nil }
+func (s *blockingChunkStore) PinnedCache(id uint64, length uint32) uint64    { return 0 }
+func (s *blockingChunkStore) PrefetchCache(id uint64, length uint32, off, size uint32) {
+}
+func (s *blockingChunkStore) UploadStaging(pre, post func()) error {
+	return nil
+}
 
 func createCancellationTestReader(t *testing.T, store chunk.ChunkStore) (*dataReader, Ino) {
 	t.Helper()
@@ -0,0 +1,71 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vfs
+
+import (
+	"github.com/juicedata/juicefs/pkg/chunk"
+	"github.com/juicedata/juicefs/pkg/meta"
+)
+
+// dedupIndex keeps the index of deduplicated blocks in the meta engine.
+type dedupIndex struct {
+	m meta.Meta
+}
+
+// NewDedupIndex returns the index of deduplicated blocks backed by m.
+func NewDedupIndex(m meta.Meta) chunk.DedupIndex {
+	return &dedupIndex{m}
+}
+
+func (d *dedupIndex) AddBlock(id uint64, indx uint32, chunks []chunk.DedupChunk) ([]bool, error) {
+	cs := make([]meta.DedupChunk, len(chunks))
+	for i, c := range chunks {
+		cs[i] = meta.DedupChunk{Hash: c.Hash, Size: c.Size}
+	}
+	exists, st := d.m.AddDedupBlock(meta.Background(), id, indx, cs)
+	if st != 0 {
+		return nil, st
+	}
+	return exists, nil
+}
+
+func toChunks(cs []meta.DedupChunk) []chunk.DedupChunk {
+	if cs == nil {
+		return nil
+	}
+	chunks := make([]chunk.DedupChunk, len(cs))
+	for i, c := range cs {
+		chunks[i] = chunk.DedupChunk{Hash: c.Hash, Size: c.Size}
+	}
+	return chunks
+}
+
+func (d *dedupIndex) GetBlock(id uint64, indx uint32) ([]chunk.DedupChunk, error) {
+	cs, st := d.m.GetDedupBlock(meta.Background(), id, indx)
+	if st != 0 {
+		return nil, st
+	}
+	return toChunks(cs), nil
+}
+
+func (d *dedupIndex) RemoveBlock(id uint64, indx uint32) ([]chunk.DedupChunk, error) {
+	cs, st := d.m.RemoveDedupBlock(meta.Background(), id, indx)
+	if st != 0 {
+		return nil, st
+	}
+	return toChunks(cs), nil
+}
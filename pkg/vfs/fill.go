@@ -46,6 +46,12 @@ func (act CacheAction) String() string {
 		return "evict cache"
 	case CheckCache:
 		return "check cache"
+	case WarmupRemoteCache:
+		return "warmup remote cache"
+	case PinCache:
+		return "pin cache"
+	case UnpinCache:
+		return "unpin cache"
 	}
 	return "unknown operation"
 }
@@ -54,6 +60,11 @@ const (
 	WarmupCache CacheAction = iota
 	EvictCache
 	CheckCache = 2
+	// WarmupRemoteCache loads the blocks into the cache of other members in cache group or the cache servers
+	WarmupRemoteCache CacheAction = 3
+	// PinCache warms up the local cache and keeps the blocks from eviction
+	PinCache   CacheAction = 4
+	UnpinCache CacheAction = 5
 )
 
 type CacheFiller struct {
@@ -99,6 +110,18 @@ func (c *CacheFiller) cacheFile(ctx meta.Context, action CacheAction, resp *Cach
 				}
 				_ = c.meta.Close(ctx, f.ino)
 			}
+		case WarmupRemoteCache:
+			handler = func(s meta.Slice) error {
+				return c.store.FillRemoteCache(s.Id, s.Size)
+			}
+		case PinCache:
+			handler = func(s meta.Slice) error {
+				return c.store.PinCache(s.Id, s.Size)
+			}
+		case UnpinCache:
+			handler = func(s meta.Slice) error {
+				return c.store.UnpinCache(s.Id, s.Size)
+			}
 		case EvictCache:
 			handler = func(s meta.Slice) error {
 				return c.store.EvictCache(s.Id, s.Size)
@@ -114,6 +137,7 @@ func (c *CacheFiller) cacheFile(ctx meta.Context, action CacheAction, resp *Cach
 				}
 			}
 			handler = func(s meta.Slice) error {
+				atomic.AddUint64(&resp.Pinned, c.store.PinnedCache(s.Id, s.Size))
 				return c.store.CheckCache(s.Id, s.Size, blockHandler)
 			}
 		}
@@ -42,6 +42,7 @@ const (
 	controlInode    = minInternalNode + 2
 	StatsInode      = minInternalNode + 3
 	ConfigInode     = minInternalNode + 4
+	snapshotInode   = meta.SnapshotInode
 	trashInode      = meta.TrashInode
 )
 
@@ -81,7 +82,8 @@ var internalNodes = []*internalNode{
 	{logInode, ".accesslog", &Attr{Mode: 0400}},
 	{StatsInode, ".stats", &Attr{Mode: 0444}},
 	{ConfigInode, ".config", &Attr{Mode: 0400}},
-	{trashInode, meta.TrashName, &Attr{Mode: 0555}},
+	{snapshotInode, meta.SnapshotName, &Attr{Mode: 0555}},
+	{trashInode, meta.TrashName, &Attr{Mode: 0555}}, // keep snapshots and trash at the end
 }
 
 func init() {
@@ -89,7 +91,7 @@ func init() {
 	gid := uint32(utils.GetCurrentGID())
 	now := time.Now().Unix()
 	for _, v := range internalNodes {
-		if v.inode == trashInode {
+		if v.inode == trashInode || v.inode == snapshotInode {
 			v.attr.Typ = meta.TypeDirectory
 			v.attr.Nlink = 2
 		} else {
@@ -262,6 +264,7 @@ type InfoResponse struct {
 	FLocks        []meta.FLockItem
 	Tier          object.Tier
 	RestoreStatus string
+	Pinned        uint64
 }
 
 type SummaryReponse struct {
@@ -275,6 +278,7 @@ type CacheResponse struct {
 	SliceCount uint64
 	TotalBytes uint64
 	MissBytes  uint64 // for check op
+	Pinned     uint64 // for check op
 	Locations  map[string]uint64
 }
 
@@ -283,6 +287,7 @@ func (resp *CacheResponse) Add(other *CacheResponse) {
 	resp.TotalBytes += other.TotalBytes
 	resp.SliceCount += other.SliceCount
 	resp.MissBytes += other.MissBytes
+	resp.Pinned += other.Pinned
 	for k, bytes := range other.Locations {
 		resp.Locations[k] += bytes
 	}
@@ -471,6 +476,9 @@ func (v *VFS) handleInternalMsg(ctx meta.Context, cmd uint32, r *utils.Buffer, o
 					var cs []meta.Slice
 					_ = v.Meta.Read(ctx, inode, uint32(indx), &cs)
 					for _, c := range cs {
+						if c.Id > 0 {
+							info.Pinned += v.Store.PinnedCache(c.Id, c.Size)
+						}
 						if raw {
 							info.Chunks = append(info.Chunks, &chunkSlice{indx, c})
 						} else {
@@ -587,6 +595,28 @@ func (v *VFS) handleInternalMsg(ctx meta.Context, cmd uint32, r *utils.Buffer, o
 		writeProgress(&totalChunks, &currChunks, out, done)
 		_, _ = out.Write([]byte{uint8(eno)})
 
+	case meta.UploadStaging:
+		done := make(chan struct{})
+		var total, uploaded uint64
+		var err error
+		go func() {
+			logger.Infof("Start to upload staging blocks")
+			err = v.Store.UploadStaging(func() {
+				atomic.AddUint64(&total, 1)
+			}, func() {
+				atomic.AddUint64(&uploaded, 1)
+			})
+			close(done)
+		}()
+
+		writeProgress(&total, &uploaded, out, done)
+		if err != nil {
+			logger.Errorf("upload staging blocks: %s", err)
+			_, _ = out.Write([]byte{uint8(syscall.EIO)})
+		} else {
+			_, _ = out.Write([]byte{0})
+		}
+
 	case meta.FillCache:
 		paths := strings.Split(string(r.Get(int(r.Get32()))), "\n")
 		concurrent := r.Get16()
@@ -0,0 +1,205 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vfs
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	patternStride = "stride" // fixed distance between reads, forward or backward
+	patternFooter = "footer" // the tail first, then ranges of it (Parquet, ORC)
+
+	footerRange    = 1 << 20 // reads within it from the end are footer
+	maxPredictions = 16
+	maxStrideDepth = 4
+	predictionTTL  = time.Second * 30
+	accuracyWindow = 8  // predictions resolved before checking the accuracy
+	minBackoff     = 16 // reads without prediction after inaccurate
+	maxBackoff     = 1024
+)
+
+var (
+	patternPrefetchIssued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pattern_prefetch_issued",
+		Help: "Number of ranges prefetched by the read pattern.",
+	}, []string{"pattern"})
+	patternPrefetchHits = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pattern_prefetch_hits",
+		Help: "Number of prefetched ranges read later.",
+	}, []string{"pattern"})
+	patternPrefetchWasted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pattern_prefetch_wasted",
+		Help: "Number of prefetched ranges never read.",
+	}, []string{"pattern"})
+)
+
+type prediction struct {
+	frange
+	pattern string
+	expire  time.Time
+}
+
+// readPattern detects the non-sequential access patterns of a file handle, and predicts the ranges to
+// be read next. The sequential reads are left to readahead of the sessions.
+type readPattern struct {
+	last        frange
+	reads       int
+	delta       int64 // distance between the last two reads
+	matches     int   // times of the same distance in a row
+	footer      bool  // the tail is read first
+	predictions []prediction
+	hits        int
+	wasted      int
+	backoff     int // reads left without prediction
+	penalty     int
+}
+
+func (p *readPattern) resolve(hit bool, pattern string) {
+	if hit {
+		p.hits++
+		patternPrefetchHits.WithLabelValues(pattern).Inc()
+	} else {
+		p.wasted++
+		patternPrefetchWasted.WithLabelValues(pattern).Inc()
+	}
+	if p.hits+p.wasted < accuracyWindow {
+		return
+	}
+	if p.hits*2 < p.hits+p.wasted {
+		// less than half of them are useful, stop predicting for a while
+		if p.penalty < minBackoff {
+			p.penalty = minBackoff
+		}
+		p.backoff = p.penalty
+		p.penalty = min(p.penalty*2, maxBackoff)
+		p.predictions = p.predictions[:0]
+	} else {
+		p.penalty = minBackoff
+	}
+	p.hits, p.wasted = 0, 0
+}
+
+// covers returns whether block overlaps any predicted range not read yet.
+func (p *readPattern) covers(block *frange) bool {
+	for i := range p.predictions {
+		if p.predictions[i].overlap(block) {
+			return true
+		}
+	}
+	return false
+}
+
+// observe records a read, and returns the ranges to prefetch.
+func (p *readPattern) observe(block *frange, length, blockSize, readAheadMax uint64) []prediction {
+	now := time.Now()
+	remain := p.predictions[:0]
+	for _, pr := range p.predictions {
+		if pr.overlap(block) {
+			p.resolve(true, pr.pattern)
+		} else if now.After(pr.expire) {
+			p.resolve(false, pr.pattern)
+		} else {
+			remain = append(remain, pr)
+		}
+	}
+	p.predictions = remain
+
+	if p.reads == 0 && block.off+footerRange >= length && length > footerRange {
+		p.footer = true
+	}
+	sequential := p.reads > 0 && block.off >= p.last.off && block.off <= p.last.end()
+	delta := int64(block.off) - int64(p.last.off)
+	if !sequential && p.reads > 0 && delta == p.delta && block.len == p.last.len {
+		p.matches++
+	} else {
+		p.matches = 0
+	}
+	p.delta = delta
+	p.last = *block
+	p.reads++
+	if sequential {
+		return nil
+	}
+	if p.backoff > 0 {
+		p.backoff--
+		return nil
+	}
+
+	var ranges []prediction
+	if p.matches > 0 {
+		off := int64(block.off)
+		for k := 0; k < min(p.matches, maxStrideDepth); k++ {
+			off += delta
+			if off < 0 || uint64(off) >= length {
+				break
+			}
+			ranges = append(ranges, prediction{frange{uint64(off), block.len}, patternStride, now.Add(predictionTTL)})
+		}
+	} else if p.footer && block.off+footerRange < length {
+		// a range (column chunk) is usually read by multiple requests
+		size := min(max(block.len, blockSize), readAheadMax)
+		if block.end() < length && size > 0 {
+			ranges = append(ranges, prediction{frange{block.end(), min(size, length-block.end())}, patternFooter, now.Add(predictionTTL)})
+		}
+	}
+
+	var issued []prediction
+	for _, r := range ranges {
+		if p.covers(&r.frange) {
+			continue
+		}
+		if len(p.predictions) >= maxPredictions {
+			p.resolve(false, p.predictions[0].pattern)
+			p.predictions = p.predictions[1:]
+		}
+		p.predictions = append(p.predictions, r)
+		patternPrefetchIssued.WithLabelValues(r.pattern).Inc()
+		issued = append(issued, r)
+	}
+	return issued
+}
+
+// close counts the predictions never read.
+func (p *readPattern) close() {
+	for _, pr := range p.predictions {
+		patternPrefetchWasted.WithLabelValues(pr.pattern).Inc()
+	}
+	p.predictions = nil
+}
+
+// protected by f
+func (f *fileReader) checkPattern(block *frange) {
+	for _, pr := range f.pattern.observe(block, f.length, f.r.blockSize, f.readAheadMax) {
+		if uint64(readBufferUsed.Load()) >= f.r.readAheadTotal {
+			break
+		}
+		b := pr.frange
+		f.visit(func(s *sliceReader) bool {
+			if s.state.valid() && s.block.include(&b) {
+				b.len = 0
+				return false
+			}
+			return true
+		})
+		for b.len > 0 {
+			f.newSlice(&b)
+		}
+	}
+}
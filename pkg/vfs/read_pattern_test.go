@@ -0,0 +1,89 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPattern(t *testing.T) {
+	const length, bs, ra = 256 << 20, 4 << 20, 32 << 20
+	read := func(p *readPattern, off, size uint64) []prediction {
+		return p.observe(&frange{off, size}, length, bs, ra)
+	}
+
+	// strided
+	var p readPattern
+	require.Empty(t, read(&p, 0, 4096))
+	require.Empty(t, read(&p, 1<<20, 4096))
+	preds := read(&p, 2<<20, 4096)
+	require.Len(t, preds, 1)
+	require.Equal(t, frange{3 << 20, 4096}, preds[0].frange)
+	require.Equal(t, patternStride, preds[0].pattern)
+	preds = read(&p, 3<<20, 4096)
+	require.Equal(t, 1, p.hits)
+	require.Len(t, preds, 2)
+	require.Equal(t, frange{4 << 20, 4096}, preds[0].frange)
+	require.Equal(t, frange{5 << 20, 4096}, preds[1].frange)
+	preds = read(&p, 4<<20, 4096)
+	require.Equal(t, 2, p.hits)
+	require.Len(t, preds, 2, "the next one is predicted already")
+	require.Equal(t, frange{6 << 20, 4096}, preds[0].frange)
+	require.False(t, p.covers(&frange{4 << 20, 4096}))
+	require.True(t, p.covers(&frange{5 << 20, 4096}))
+
+	// sequential reads are left to readahead
+	p = readPattern{}
+	for i := uint64(0); i < 4; i++ {
+		require.Empty(t, read(&p, i<<17, 1<<17))
+	}
+
+	// reverse
+	p = readPattern{}
+	require.Empty(t, read(&p, 100<<20, 1<<16))
+	require.Empty(t, read(&p, 100<<20-1<<16, 1<<16))
+	preds = read(&p, 100<<20-2<<16, 1<<16)
+	require.Len(t, preds, 1)
+	require.Equal(t, frange{100<<20 - 3<<16, 1 << 16}, preds[0].frange)
+
+	// footer then ranges
+	p = readPattern{}
+	require.Empty(t, read(&p, length-8, 8))
+	require.True(t, p.footer)
+	require.Empty(t, read(&p, length-1<<16, 1<<16-8))
+	preds = read(&p, 10<<20, 1<<16)
+	require.Len(t, preds, 1)
+	require.Equal(t, frange{10<<20 + 1<<16, bs}, preds[0].frange)
+	require.Equal(t, patternFooter, preds[0].pattern)
+
+	// back off after inaccurate
+	p = readPattern{}
+	for i := 0; i < accuracyWindow; i++ {
+		p.resolve(false, patternStride)
+	}
+	require.Equal(t, minBackoff, p.backoff)
+	for i := uint64(0); i < minBackoff; i++ {
+		require.Empty(t, read(&p, i<<20, 4096))
+	}
+	require.NotEmpty(t, read(&p, minBackoff<<20, 4096))
+	for i := 0; i < accuracyWindow; i++ {
+		p.resolve(false, patternStride)
+	}
+	require.Equal(t, minBackoff*2, p.backoff)
+}
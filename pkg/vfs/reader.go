@@ -291,6 +291,10 @@ type fileReader struct {
 	slices   *sliceReader
 	last     **sliceReader
 
+	ctx          context.Context // carries the cache policy of directory
+	readAheadMax uint64
+	pattern      readPattern
+
 	sync.Mutex
 	closing bool
 
@@ -309,7 +313,7 @@ func (f *fileReader) GetLength() uint64 {
 // protected by f
 func (f *fileReader) newSlice(block *frange) *sliceReader {
 	s := &sliceReader{}
-	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.ctx, s.cancel = context.WithCancel(f.ctx)
 	s.file = f
 	s.lastAccess = time.Now()
 	s.indx = uint32(block.off / meta.ChunkSize)
@@ -422,9 +426,9 @@ func (f *fileReader) checkReadahead(block *frange) int {
 	seqdata := ses.total
 	readahead := ses.readahead
 	used := uint64(readBufferUsed.Load())
-	if readahead == 0 && f.r.blockSize <= f.r.readAheadMax && (block.off == 0 || seqdata > block.len) { // begin with read-ahead turned on
+	if readahead == 0 && f.r.blockSize <= f.readAheadMax && (block.off == 0 || seqdata > block.len) { // begin with read-ahead turned on
 		ses.readahead = f.r.blockSize
-	} else if readahead < f.r.readAheadMax && seqdata >= readahead && f.r.readAheadTotal > used+readahead*4 {
+	} else if readahead < f.readAheadMax && seqdata >= readahead && f.r.readAheadTotal > used+readahead*4 {
 		ses.readahead *= 2
 	} else if readahead >= f.r.blockSize && (f.r.readAheadTotal < used+readahead/2 || seqdata < readahead/4) {
 		ses.readahead /= 2
@@ -440,6 +444,9 @@ func (f *fileReader) checkReadahead(block *frange) int {
 }
 
 func (f *fileReader) need(block *frange) bool {
+	if f.pattern.covers(block) {
+		return true
+	}
 	for _, ses := range f.sessions {
 		if ses.total == 0 {
 			break
@@ -669,6 +676,9 @@ func (f *fileReader) Read(ctx meta.Context, offset uint64, buf []byte) (int, sys
 		}
 	}()
 	f.checkReadahead(block)
+	if f.r.prefetch {
+		f.checkPattern(block)
+	}
 	return f.waitForIO(ctx, reqs, buf)
 }
 
@@ -685,6 +695,7 @@ func (f *fileReader) visit(fn func(s *sliceReader) bool) {
 func (f *fileReader) Close(ctx meta.Context) {
 	f.Lock()
 	f.closing = true
+	f.pattern.close()
 	f.visit(func(s *sliceReader) bool {
 		s.drop()
 		return true
@@ -704,6 +715,7 @@ type dataReader struct {
 	readAheadTotal uint64
 	maxRequests    int
 	maxRetries     uint32
+	prefetch       bool // prefetch the ranges predicted by read pattern
 }
 
 func NewDataReader(conf *Config, m meta.Meta, store chunk.ChunkStore) DataReader {
@@ -722,6 +734,7 @@ func NewDataReader(conf *Config, m meta.Meta, store chunk.ChunkStore) DataReader
 		readAheadMax:   uint64(readAheadMax),
 		maxRequests:    readAheadMax/conf.Chunk.BlockSize*readSessions + 1,
 		maxRetries:     uint32(conf.Meta.Retries),
+		prefetch:       conf.Chunk.Prefetch > 0,
 	}
 	go r.checkReadBuffer()
 	return r
@@ -750,11 +763,19 @@ func (r *dataReader) checkReadBuffer() {
 
 func (r *dataReader) Open(inode Ino, length uint64) FileReader {
 	f := &fileReader{
-		r:      r,
-		inode:  inode,
-		length: length,
+		r:            r,
+		inode:        inode,
+		length:       length,
+		ctx:          context.Background(),
+		readAheadMax: r.readAheadMax,
 	}
 	f.last = &(f.slices)
+	if p := getCachePolicy(r.m, inode); p != nil {
+		f.ctx = chunk.WithCachePolicy(f.ctx, p)
+		if p.Readahead != nil {
+			f.readAheadMax = min(uint64(*p.Readahead), r.readAheadTotal)
+		}
+	}
 
 	r.Lock()
 	f.refs = 1
@@ -764,6 +785,20 @@ func (r *dataReader) Open(inode Ino, length uint64) FileReader {
 	return f
 }
 
+// getCachePolicy returns the cache policy of the directories of inode, nil to follow the mount options.
+func getCachePolicy(m meta.Meta, inode Ino) *chunk.CachePolicy {
+	s := m.GetCachePolicy(meta.Background(), inode)
+	if s == "" {
+		return nil
+	}
+	p, err := chunk.ParseCachePolicy(s)
+	if err != nil {
+		logger.Warnf("Invalid cache policy %q for inode %d: %s", s, inode, err)
+		return nil
+	}
+	return p
+}
+
 func (r *dataReader) visit(inode Ino, fn func(*fileReader)) {
 	// r could be hold inside f, so Unlock r first to avoid deadlock
 	r.Lock()
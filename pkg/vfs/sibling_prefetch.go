@@ -0,0 +1,226 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vfs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/chunk"
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	maxListings   = 1024 // directories tracked
+	maxTraversals = 64   // processes tracked in a directory
+	minSiblingRun = 3    // files opened in the listing order before prefetching
+)
+
+var (
+	maxListingFiles = 1 << 16 // files tracked in a directory, the ones listed after are not prefetched
+	maxListedFiles  = 1 << 20 // files tracked in all directories
+)
+
+var siblingPrefetchFiles = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "sibling_prefetch_files",
+	Help: "Number of files prefetched in the order of directory listing.",
+})
+
+// traversal is the files of a directory opened by a process.
+type traversal struct {
+	last    int // index of the last opened file
+	run     int // files opened in order
+	fetched int // index of the last prefetched file
+}
+
+// dirListing is the files of a directory in the order returned by readdir.
+type dirListing struct {
+	files      []Ino
+	index      map[Ino]int
+	atime      time.Time
+	traversals map[uint32]*traversal // by pid
+	policy     int8                  // 1: prefetch allowed, -1: disabled by cache policy, 0: unknown
+}
+
+// siblingPrefetcher downloads the first block of the files following the opened one in the listing
+// order, when the files of a directory are read one by one (for example, the loaders of training).
+type siblingPrefetcher struct {
+	sync.Mutex
+	meta      meta.Meta
+	store     chunk.ChunkStore
+	ahead     int
+	blockSize uint32
+	listings  map[Ino]*dirListing
+	parents   map[Ino]Ino   // file -> directory
+	files     int           // files tracked in listings
+	inflight  chan struct{} // limit of files being prefetched in the mount
+}
+
+func newSiblingPrefetcher(conf *Config, m meta.Meta, store chunk.ChunkStore) *siblingPrefetcher {
+	if conf.PrefetchFiles <= 0 || conf.Chunk.Prefetch == 0 {
+		return nil
+	}
+	return &siblingPrefetcher{
+		meta:      m,
+		store:     store,
+		ahead:     conf.PrefetchFiles,
+		blockSize: uint32(conf.Chunk.BlockSize),
+		listings:  make(map[Ino]*dirListing),
+		parents:   make(map[Ino]Ino),
+		inflight:  make(chan struct{}, max(conf.PrefetchFilesLimit, 1)),
+	}
+}
+
+// locked
+func (p *siblingPrefetcher) evict() {
+	var oldest Ino
+	var atime time.Time
+	for ino, l := range p.listings {
+		if oldest == 0 || l.atime.Before(atime) {
+			oldest, atime = ino, l.atime
+		}
+	}
+	for _, f := range p.listings[oldest].files {
+		if p.parents[f] == oldest {
+			delete(p.parents, f)
+		}
+	}
+	p.files -= len(p.listings[oldest].files)
+	delete(p.listings, oldest)
+}
+
+// listed records the order of files returned by readdir.
+func (p *siblingPrefetcher) listed(dir Ino, entries []*meta.Entry) {
+	p.Lock()
+	defer p.Unlock()
+	l := p.listings[dir]
+	if l == nil {
+		if len(p.listings) >= maxListings {
+			p.evict()
+		}
+		l = &dirListing{index: make(map[Ino]int), traversals: make(map[uint32]*traversal)}
+		p.listings[dir] = l
+	}
+	l.atime = time.Now()
+	for _, e := range entries {
+		if e.Attr == nil || e.Attr.Typ != meta.TypeFile {
+			continue
+		}
+		if _, ok := l.index[e.Inode]; ok {
+			continue
+		}
+		if len(l.files) >= maxListingFiles {
+			break
+		}
+		l.index[e.Inode] = len(l.files)
+		l.files = append(l.files, e.Inode)
+		p.parents[e.Inode] = dir
+		p.files++
+	}
+	// the current one is the latest accessed, so it's evicted last
+	for p.files > maxListedFiles && len(p.listings) > 1 {
+		p.evict()
+	}
+}
+
+// opened checks whether the files are opened in the listing order, and prefetches the following ones.
+func (p *siblingPrefetcher) opened(ctx meta.Context, ino Ino) {
+	p.Lock()
+	dir, ok := p.parents[ino]
+	if !ok {
+		p.Unlock()
+		return
+	}
+	l := p.listings[dir]
+	idx := l.index[ino]
+	l.atime = time.Now()
+	t := l.traversals[ctx.Pid()]
+	if t == nil {
+		if len(l.traversals) >= maxTraversals {
+			l.traversals = make(map[uint32]*traversal)
+		}
+		t = &traversal{last: idx, run: 1, fetched: idx}
+		l.traversals[ctx.Pid()] = t
+	} else if idx == t.last+1 {
+		t.run++
+	} else if idx != t.last {
+		t.run, t.fetched = 1, idx
+	}
+	t.last = idx
+	if t.run < minSiblingRun || l.policy < 0 {
+		p.Unlock()
+		return
+	}
+	var files []Ino
+	for i := max(t.fetched, idx) + 1; i < len(l.files) && i <= idx+p.ahead; i++ {
+		select {
+		case p.inflight <- struct{}{}:
+			files = append(files, l.files[i])
+			t.fetched = i
+		default:
+		}
+		if t.fetched != i {
+			break // too busy
+		}
+	}
+	checked := l.policy != 0
+	p.Unlock()
+
+	if len(files) == 0 {
+		return
+	}
+	if !checked {
+		policy := int8(1)
+		if cp := getCachePolicy(p.meta, dir); cp != nil && (cp.Cache == chunk.CacheNone || cp.Prefetch != nil && !*cp.Prefetch) {
+			policy = -1
+		}
+		p.Lock()
+		l.policy = policy
+		p.Unlock()
+		if policy < 0 {
+			for range files {
+				<-p.inflight
+			}
+			return
+		}
+	}
+	for _, f := range files {
+		go p.prefetch(f)
+	}
+}
+
+// prefetch schedules the first block of the file to be downloaded into cache.
+func (p *siblingPrefetcher) prefetch(ino Ino) {
+	defer func() { <-p.inflight }()
+	var slices []meta.Slice
+	if st := p.meta.Read(meta.Background(), ino, 0, &slices); st != 0 {
+		logger.Debugf("read slices of inode %d for prefetch: %s", ino, st)
+		return
+	}
+	var pos uint32
+	for _, s := range slices {
+		if pos >= p.blockSize {
+			break
+		}
+		if s.Id > 0 {
+			p.store.PrefetchCache(s.Id, s.Size, s.Off, min(s.Len, p.blockSize-pos))
+		}
+		pos += s.Len
+	}
+	siblingPrefetchFiles.Inc()
+}
@@ -0,0 +1,132 @@
+/*
+ * JuiceFS, Copyright 2026 Juicedata, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vfs
+
+import (
+	"fmt"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/juicedata/juicefs/pkg/chunk"
+	"github.com/juicedata/juicefs/pkg/meta"
+	"github.com/stretchr/testify/require"
+)
+
+type prefetchRecorder struct {
+	chunk.ChunkStore
+	sync.Mutex
+	ids []uint64
+}
+
+func (s *prefetchRecorder) PrefetchCache(id uint64, length uint32, off, size uint32) {
+	s.Lock()
+	s.ids = append(s.ids, id)
+	s.Unlock()
+}
+
+func (s *prefetchRecorder) fetched() []uint64 {
+	s.Lock()
+	defer s.Unlock()
+	ids := s.ids
+	s.ids = nil
+	return ids
+}
+
+func TestSiblingPrefetch(t *testing.T) {
+	v, _ := createTestVFS(nil, "")
+	ctx := NewLogContext(meta.Background())
+	de, e := v.Mkdir(ctx, 1, "dataset", 0755, 0)
+	require.Equal(t, syscall.Errno(0), e)
+
+	files := make(map[Ino]uint64) // inode -> slice id
+	for i := 0; i < 10; i++ {
+		fe, fh, e := v.Create(ctx, de.Inode, fmt.Sprintf("sample%02d", i), 0644, 0, syscall.O_RDWR)
+		require.Equal(t, syscall.Errno(0), e)
+		require.Equal(t, syscall.Errno(0), v.Write(ctx, fe.Inode, []byte("data"), 0, fh))
+		require.Equal(t, syscall.Errno(0), v.Flush(ctx, fe.Inode, fh, 0))
+		v.Release(ctx, fe.Inode, fh)
+		var slices []meta.Slice
+		require.Equal(t, syscall.Errno(0), v.Meta.Read(meta.Background(), fe.Inode, 0, &slices))
+		files[fe.Inode] = slices[0].Id
+	}
+
+	store := &prefetchRecorder{ChunkStore: v.Store}
+	conf := *v.Conf
+	conf.PrefetchFiles, conf.PrefetchFilesLimit = 2, 4
+	chunkConf := *conf.Chunk
+	chunkConf.Prefetch = 1
+	conf.Chunk = &chunkConf
+	v.siblings = newSiblingPrefetcher(&conf, v.Meta, store)
+
+	fh, e := v.Opendir(ctx, de.Inode, 0)
+	require.Equal(t, syscall.Errno(0), e)
+	entries, _, e := v.Readdir(ctx, de.Inode, 0, 0, fh, true)
+	require.Equal(t, syscall.Errno(0), e)
+	v.Releasedir(ctx, de.Inode, fh)
+	var listed []Ino
+	for _, e := range entries {
+		if e.Attr.Typ == meta.TypeFile {
+			listed = append(listed, e.Inode)
+		}
+	}
+	require.Len(t, listed, 10)
+
+	open := func(i int) []uint64 {
+		_, fh, e := v.Open(ctx, listed[i], syscall.O_RDONLY)
+		require.Equal(t, syscall.Errno(0), e)
+		v.Release(ctx, listed[i], fh)
+		require.Eventually(t, func() bool { return len(v.siblings.inflight) == 0 }, time.Second*5, time.Millisecond*10)
+		return store.fetched()
+	}
+	require.Empty(t, open(0))
+	require.Empty(t, open(1))
+	require.ElementsMatch(t, []uint64{files[listed[3]], files[listed[4]]}, open(2))
+	require.Equal(t, []uint64{files[listed[5]]}, open(3))
+	require.Empty(t, open(3), "opened again")
+	require.Empty(t, open(8), "out of order")
+	require.Empty(t, open(9))
+}
+
+func TestSiblingListingLimit(t *testing.T) {
+	oldFiles, oldListed := maxListingFiles, maxListedFiles
+	maxListingFiles, maxListedFiles = 10, 25
+	defer func() { maxListingFiles, maxListedFiles = oldFiles, oldListed }()
+
+	p := &siblingPrefetcher{listings: make(map[Ino]*dirListing), parents: make(map[Ino]Ino)}
+	list := func(dir Ino, start, n int) {
+		var entries []*meta.Entry
+		for i := start; i < start+n; i++ {
+			entries = append(entries, &meta.Entry{Inode: Ino(i), Attr: &meta.Attr{Typ: meta.TypeFile}})
+		}
+		p.listed(dir, entries)
+	}
+	list(1, 100, 20)
+	require.Len(t, p.listings[1].files, 10)
+	require.Len(t, p.parents, 10)
+	list(1, 120, 5) // full already
+	require.Len(t, p.listings[1].files, 10)
+
+	list(2, 200, 10)
+	list(3, 300, 10)
+	require.Equal(t, 20, p.files, "the oldest directory should be evicted")
+	require.Nil(t, p.listings[1])
+	require.Len(t, p.parents, 20)
+	_, ok := p.parents[100]
+	require.False(t, ok)
+}
@@ -133,6 +133,8 @@ type Config struct {
 	NegEntryTimeout      time.Duration
 	EntryTimeout         time.Duration
 	ReaddirCache         bool
+	PrefetchFiles        int // prefetch the first block of N files following the opened one in listing order
+	PrefetchFilesLimit   int // max number of files being prefetched
 	BackupMeta           time.Duration
 	BackupSkipTrash      bool
 	FastResolve          bool   `json:",omitempty"`
@@ -475,6 +477,9 @@ func (v *VFS) Readdir(ctx Context, ino Ino, size uint32, off int, fh uint64, plu
 		return
 	}
 	readAt = h.readAt
+	if v.siblings != nil {
+		v.siblings.listed(ino, entries)
+	}
 	logger.Debugf("readdir: [%d:%d] %d entries, offset=%d", ino, fh, len(entries), off)
 	return
 }
@@ -589,6 +594,9 @@ func (v *VFS) Open(ctx Context, ino Ino, flags uint32) (entry *meta.Entry, fh ui
 		v.UpdateLength(ino, attr)
 		fh = v.newFileHandle(ino, attr.Length, flags, attr.Tier)
 		entry = &meta.Entry{Inode: ino, Attr: attr}
+		if v.siblings != nil && (flags&O_ACCMODE) != syscall.O_WRONLY {
+			v.siblings.opened(ctx, ino)
+		}
 	}
 	return
 }
@@ -1125,6 +1133,13 @@ func (v *VFS) SetXattr(ctx Context, ino Ino, name string, value []byte, flags ui
 		err = v.Meta.SetFacl(ctx, ino, typ, rule)
 		v.invalidateAttr(ino)
 	} else {
+		if name == meta.CachePolicyXattr {
+			if _, e := chunk.ParseCachePolicy(string(value)); e != nil {
+				logger.Warnf("Invalid cache policy %q: %s", value, e)
+				err = syscall.EINVAL
+				return
+			}
+		}
 		// only retain supported flags
 		if runtime.GOOS == "darwin" {
 			flags &= uint32(macSupportFlags)
@@ -1230,6 +1245,7 @@ type VFS struct {
 	reader          DataReader
 	writer          DataWriter
 	cacheFiller     *CacheFiller
+	siblings        *siblingPrefetcher
 
 	handles   map[Ino][]*handle
 	handleIno map[uint64]Ino
@@ -1253,6 +1269,7 @@ func NewVFS(conf *Config, m meta.Meta, store chunk.ChunkStore, registerer promet
 		reader:      reader,
 		writer:      writer,
 		cacheFiller: NewCacheFiller(conf, m, store),
+		siblings:    newSiblingPrefetcher(conf, m, store),
 		handles:     make(map[Ino][]*handle),
 		handleIno:   make(map[uint64]Ino),
 		modifiedAt:  make(map[meta.Ino]time.Time),
@@ -1264,14 +1281,15 @@ func NewVFS(conf *Config, m meta.Meta, store chunk.ChunkStore, registerer promet
 	v.Conf.Format.RemoveSecret()
 	data, _ := json.MarshalIndent(v.Conf, "", " ")
 	n.attr.Length = uint64(len(data))
-	if conf.Meta.Subdir != "" { // don't show trash directory
-		internalNodes = internalNodes[:len(internalNodes)-1]
+	if conf.Meta.Subdir != "" { // don't show snapshots and trash directory
+		internalNodes = internalNodes[:len(internalNodes)-2]
 	}
 	if conf.PrefixInternal {
 		for _, n := range internalNodes {
 			n.name = ".jfs" + n.name
 		}
 		meta.TrashName = ".jfs" + meta.TrashName
+		meta.SnapshotName = ".jfs" + meta.SnapshotName
 	}
 
 	statePath := os.Getenv("_FUSE_STATE_PATH")
@@ -1396,6 +1414,10 @@ func InitMetrics(registerer prometheus.Registerer) {
 	registerer.MustRegister(opsDurations)
 	registerer.MustRegister(opsIOErrors)
 	registerer.MustRegister(compactSizeHistogram)
+	registerer.MustRegister(patternPrefetchIssued)
+	registerer.MustRegister(patternPrefetchHits)
+	registerer.MustRegister(patternPrefetchWasted)
+	registerer.MustRegister(siblingPrefetchFiles)
 }
 
 // Linux ACL format:
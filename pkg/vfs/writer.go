@@ -239,6 +239,8 @@ type fileWriter struct {
 	inode        Ino
 	length       uint64
 	tierID       uint8
+	compress     string // compression of the directory, empty for the default one
+	policy       *chunk.CachePolicy
 	err          syscall.Errno
 	flushwaiting uint16
 	writewaiting uint16
@@ -280,6 +282,12 @@ func (f *fileWriter) writeChunk(ctx meta.Context, indx uint32, off uint32, data
 			notify:  utils.NewCond(&f.Mutex),
 			started: time.Now(),
 		}
+		if f.compress != "" {
+			s.writer.SetCompress(f.compress)
+		}
+		if f.policy != nil {
+			s.writer.SetCachePolicy(f.policy)
+		}
 		go s.prepareID(meta.Background(), false)
 		c.slices = append(c.slices, s)
 		if len(c.slices) == 1 {
@@ -513,16 +521,20 @@ func (w *dataWriter) flushAll() {
 }
 
 func (w *dataWriter) Open(inode Ino, len uint64, tierID uint8) FileWriter {
+	compress := w.m.GetCompression(meta.Background(), inode)
+	policy := getCachePolicy(w.m, inode)
 	w.Lock()
 	defer w.Unlock()
 	f, ok := w.files[inode]
 	if !ok {
 		f = &fileWriter{
-			w:      w,
-			inode:  inode,
-			length: len,
-			tierID: tierID,
-			chunks: make(map[uint32]*chunkWriter),
+			w:        w,
+			inode:    inode,
+			length:   len,
+			tierID:   tierID,
+			compress: compress,
+			policy:   policy,
+			chunks:   make(map[uint32]*chunkWriter),
 		}
 		f.flushcond = utils.NewCond(f)
 		f.writecond = utils.NewCond(f)
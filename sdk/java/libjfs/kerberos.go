@@ -583,6 +583,8 @@ func (k *kerberos) cleanupTokens() {
 			err := json.Unmarshal(data, t)
 			if err != nil {
 				logger.Warnf("unmarshal token %d: %s", id, err)
+			} else if t.User == "" {
+				continue // not a delegation token, e.g. the users of S3 gateway
 			}
 			if t.Expire <= now {
 				todelete = append(todelete, id)
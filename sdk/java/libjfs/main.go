@@ -675,6 +675,8 @@ func jfs_init(credentialPtr uintptr, count int32, cname, cjsonConf, cuser, group
 		chunkConf := chunk.Config{
 			BlockSize:         format.BlockSize * 1024,
 			Compress:          format.Compression,
+			UntaggedCompress:  format.UntaggedCompress,
+			Dedup:             format.Dedup,
 			CacheDir:          jConf.CacheDir,
 			CacheMode:         0644, // all user can read cache
 			CacheSize:         utils.ParseBytesStr("cache-size", jConf.CacheSize, 'M'),
@@ -706,8 +708,13 @@ func jfs_init(credentialPtr uintptr, count int32, cname, cjsonConf, cuser, group
 		if chunkConf.DownloadLimit == 0 {
 			chunkConf.DownloadLimit = format.DownloadLimit * 1e6 / 8
 		}
+		if chunkConf.DedupSecret, err = format.PlainDedupSecret(); err != nil {
+			logger.Errorf("decrypt dedup secret: %s", err)
+			return nil
+		}
 		chunkConf.SelfCheck(format.UUID)
 		store := chunk.NewCachedStore(blob, chunkConf, registerer)
+		store.SetDedupIndex(vfs.NewDedupIndex(m))
 		m.OnMsg(meta.DeleteSlice, func(args ...interface{}) error {
 			id := args[0].(uint64)
 			length := args[1].(uint32)
@@ -732,6 +739,12 @@ func jfs_init(credentialPtr uintptr, count int32, cname, cjsonConf, cuser, group
 				fmt.DownloadLimit = chunkConf.DownloadLimit
 			}
 			store.UpdateLimit(fmt.UploadLimit, fmt.DownloadLimit)
+			store.UpdateCompress(fmt.Compression, fmt.UntaggedCompress)
+			if secret, err := fmt.PlainDedupSecret(); err != nil {
+				logger.Warnf("decrypt dedup secret: %s", err)
+			} else {
+				store.UpdateDedup(fmt.Dedup, secret)
+			}
 		})
 
 		conf := &vfs.Config{